@@ -1,36 +1,1130 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/user/zenwatch/internal/ansi"
+	"github.com/user/zenwatch/internal/budget"
+	"github.com/user/zenwatch/internal/commitlint"
+	"github.com/user/zenwatch/internal/git"
+	"github.com/user/zenwatch/internal/github"
+	"github.com/user/zenwatch/internal/manifest"
+	"github.com/user/zenwatch/internal/metrics"
+	"github.com/user/zenwatch/internal/plugin"
+	"github.com/user/zenwatch/internal/pushgateway"
+	"github.com/user/zenwatch/internal/report"
+	"github.com/user/zenwatch/internal/vcs"
+	"github.com/user/zenwatch/pkg/zenwatch"
 )
 
-func main() {
-	analyzeCmd := flag.NewFlagSet("analyze", flag.ExitOnError)
-	outFilePath := analyzeCmd.String("out", "reports/latest.md", "Path to save the output Markdown report")
+// complexityThreshold is the cyclomatic complexity above which functions are reported.
+const complexityThreshold = zenwatch.ComplexityThreshold
+
+// version is the zenwatch version, overridden at build time with -ldflags "-X main.version=...".
+var version = "dev"
+
+// now is the clock of the report date and the clone sweeps. Everything else in a report
+// derives from the analyzed commit, so with now fixed, identical input renders identical reports.
+var now = time.Now
+
+// errUsage is returned by parseAnalyzeArgs when the command line is incomplete.
+var errUsage = errors.New("usage: zenwatch analyze <repo-url> --out <output-file>")
+
+// defaultCloneTTL is how old a leftover clone directory must be before gc removes it.
+const defaultCloneTTL = 24 * time.Hour
+
+// Output formats handled by analyze itself. Every other format names a report.Formatter
+// writing to --out.
+const (
+	formatIssues       = "issues"                 // Issue drafts in --issues-dir
+	formatMarkdownGist = "markdown-gist"          // The Markdown report in --out, also published to a secret gist
+	formatPushgateway  = "prometheus-pushgateway" // Prometheus gauges in --out, also pushed to a Pushgateway
+)
+
+// defaultPushgatewayJob is the job label of the metrics pushed to a Pushgateway.
+const defaultPushgatewayJob = "zenwatch"
+
+// gistIDSuffix names the file next to the report remembering the gist it was published to,
+// so later runs update that gist instead of creating a new one.
+const gistIDSuffix = ".gist"
+
+// Exit statuses of analyze besides 0 and the generic 1.
+const (
+	exitNoSourceCode    = 3 // The repository is empty or contains no source code to analyze
+	exitRepoUnavailable = 4 // The repository or the commit to analyze cannot be accessed
+	exitReportFailed    = 5 // The analysis ran but its report could not be rendered or written
+	exitPartialAnalysis = 6 // The report was written, but some analyses failed and their sections are missing
+)
+
+// manifestOnlyFlags are not recorded in manifests because they control the replay itself.
+var manifestOnlyFlags = map[string]bool{"from-manifest": true, "allow-version-drift": true, "write-manifest": true}
+
+// analyzeOptions holds the parsed flags of the analyze subcommand: the options of the
+// analysis, and how its report is written and checked.
+type analyzeOptions struct {
+	zenwatch.Options
+	RepoURL       string
+	VCS           vcs.VCS // Version control system of RepoURL
+	OutPath       string
+	BadgeSVG      string // Path to also write the status badge to as an SVG image; empty writes none
+	Format        string // One of the format* constants
+	IssuesDir     string // Directory of the issues output
+	IssuesMax     int    // Cap on the drafts of the issues output; 0 drafts all findings
+	WriteManifest bool
+	FailOnBanned  bool
+	FailOn        []failRule
+	SweepClones   time.Duration      // Remove leftover clones older than this before cloning; 0 disables
+	Pushgateway   pushgatewayOptions // Where the prometheus-pushgateway output pushes to
+	Flags         map[string]string  // Resolved flag values, recorded in manifests
+}
+
+// pushgatewayOptions configure where the prometheus-pushgateway output pushes its metrics.
+type pushgatewayOptions struct {
+	URL      string
+	Job      string
+	Instance string // Instance label; the normalized repository URL by default
+}
 
+func main() {
 	if len(os.Args) < 2 {
-		fmt.Println("Expected 'analyze' subcommand")
+		fmt.Println("Expected 'analyze', 'analyze-patch', 'metrics', 'budget', 'plugin', 'selfcheck' or 'gc' subcommand")
 		os.Exit(1)
 	}
 
 	switch os.Args[1] {
 	case "analyze":
-		analyzeCmd.Parse(os.Args[2:])
-		if analyzeCmd.NArg() < 1 {
-			fmt.Println("Usage: zenwatch analyze <repo-url> --out <output-file>")
-			analyzeCmd.Usage()
+		opts, err := parseAnalyzeArgs(os.Args[2:])
+		if err != nil {
+			if err != flag.ErrHelp {
+				fmt.Println(err)
+			}
 			os.Exit(1)
 		}
-		repoURL := analyzeCmd.Arg(0)
 
-		fmt.Printf("Repository URL: %s\n", repoURL)
-		fmt.Printf("Output File: %s\n", *outFilePath)
+		fmt.Printf("Repository URL: %s\n", git.RedactURL(opts.RepoURL))
+		fmt.Printf("Output File: %s\n", opts.OutPath)
+
+		if err := runAnalyze(opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(analyzeExitCode(err))
+		}
+	case "gc":
+		if err := runGC(os.Args[2:]); err != nil {
+			if err != flag.ErrHelp {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+			os.Exit(1)
+		}
+	case "analyze-patch":
+		if err := runAnalyzePatch(os.Args[2:], os.Stdin, os.Stdout, os.Stderr); err != nil {
+			if err != flag.ErrHelp {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+			os.Exit(1)
+		}
+	case "budget":
+		if err := runBudget(os.Args[2:]); err != nil {
+			if err != flag.ErrHelp {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+			os.Exit(1)
+		}
+	case "metrics":
+		if err := runMetrics(os.Args[2:], os.Stdout, os.Stderr); err != nil {
+			if err != flag.ErrHelp {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+			os.Exit(1)
+		}
+	case "plugin":
+		if err := runPlugin(os.Args[2:], os.Stdout, os.Stderr); err != nil {
+			if err != flag.ErrHelp {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+			os.Exit(1)
+		}
+	case "selfcheck":
+		if err := runSelfcheck(os.Args[2:], os.Stdout, os.Stderr); err != nil {
+			if err != flag.ErrHelp {
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			}
+			os.Exit(analyzeExitCode(err))
+		}
 	default:
-		fmt.Println("Expected 'analyze' subcommand")
+		fmt.Println("Expected 'analyze', 'analyze-patch', 'metrics', 'budget', 'plugin', 'selfcheck' or 'gc' subcommand")
 		os.Exit(1)
 	}
+}
+
+// parseAnalyzeArgs parses the arguments of the analyze subcommand.
+// With --from-manifest, the flags recorded in the manifest are applied first and
+// flags given on the command line take precedence over them.
+func parseAnalyzeArgs(args []string) (analyzeOptions, error) {
+	analyzeCmd := flag.NewFlagSet("analyze", flag.ContinueOnError)
+	outFilePath := analyzeCmd.String("out", "reports/latest.md", "Path to save the output report")
+	format := analyzeCmd.String("format", report.FormatMarkdown, "Output format: markdown, json (the full report data), heatmap-json (directory tree for treemap visualizations), treemap-json (directories and files with their source lines), issues (refactoring issue drafts in --issues-dir), markdown-gist (markdown report published to a secret gist, needs GITHUB_TOKEN), prometheus-pushgateway (gauges pushed to --pushgateway-url) or a registered formatter")
+	pushgatewayURL := analyzeCmd.String("pushgateway-url", os.Getenv("ZENWATCH_PUSHGATEWAY_URL"), "Base URL of the Prometheus Pushgateway the prometheus-pushgateway output pushes to (env ZENWATCH_PUSHGATEWAY_URL)")
+	pushgatewayJob := analyzeCmd.String("pushgateway-job", defaultPushgatewayJob, "Job label of the metrics pushed to the Pushgateway")
+	pushgatewayInstance := analyzeCmd.String("pushgateway-instance", "", "Instance label of the metrics pushed to the Pushgateway (default: the repository URL without credentials)")
+	heatmapMaxNodes := analyzeCmd.Int("heatmap-max-nodes", report.DefaultHeatmapMaxNodes, "Maximum number of directory nodes in the heatmap-json output")
+	issuesDir := analyzeCmd.String("issues-dir", "reports/issues", "Directory the issues output writes its drafts to")
+	issuesMax := analyzeCmd.Int("issues-max", 0, "Draft issues for the N most complex functions only (0 drafts all functions over threshold)")
+	historyTable := analyzeCmd.Bool("history-table", false, "Include the Commit History table of the commits --depth clones (always shown with more than one commit since the --compare-to-tag tag)")
+	includeTests := analyzeCmd.Bool("include-tests", false, "Also report complexity of test functions, summarized separately")
+	fast := analyzeCmd.Bool("fast", false, "Only analyze the commit and count lines, parsing no source file: skips complexity, coupling, banned imports, vendor drift and code style for a report in seconds")
+	lang := analyzeCmd.String("lang", "", "Comma-separated languages to restrict the analysis to, e.g. go,markdown,yaml")
+	embedData := analyzeCmd.Bool("embed-data", false, "End the Markdown report with its statistics as JSON in a <!-- zenwatch-data: ... --> comment, for tools reading the report")
+	emojiStyle := analyzeCmd.String("emoji-style", report.EmojiStyleColorDot, "Severity indicator style: color-dot, traffic-light or none")
+	redact := analyzeCmd.String("redact", "", "Comma-separated parts of the report to redact: authors, paths, messages, secrets")
+	anonymizeAuthors := analyzeCmd.Bool("anonymize-authors", false, "Replace author names and emails with stable pseudonyms for sharing the report externally (same as --redact authors)")
+	badgeBaseURL := analyzeCmd.String("badge-base-url", envOrDefault("ZENWATCH_BADGE_BASE_URL", report.DefaultBadgeBaseURL), "Base URL of the shields.io-compatible badge service (env ZENWATCH_BADGE_BASE_URL)")
+	badgeBaseline := analyzeCmd.String("badge-baseline", "", "Prior report, Markdown or json, whose average complexity the badge shows the change since; without one the badge shows the absolute numbers")
+	badgeSVG := analyzeCmd.String("badge-svg", "", "Also write the badge as an SVG image to this path")
+	defaultRollup := metrics.DefaultRollupOptions()
+	rollupDepth := analyzeCmd.Int("rollup-depth", defaultRollup.MaxDepth, "Deepest directory level shown in the Directory Rollup")
+	rollupMinSLOC := analyzeCmd.Int("rollup-min-sloc", defaultRollup.MinSLOC, "Fold directories with fewer source lines into their parent in the Directory Rollup")
+	maxLineLength := analyzeCmd.Int("max-line-length", metrics.DefaultMaxLineLength, "Display width in columns above which the Code Style section counts lines as long")
+	rollupSort := analyzeCmd.String("rollup-sort", defaultRollup.SortBy, "Directory Rollup sort column: sloc, files, avg-complexity, max-complexity, churn or path")
+	var bannedImports, excludes stringList
+	analyzeCmd.Var(&excludes, "exclude", "Glob pattern of files to leave out of the analysis, .gitignore-style; repeatable or comma-separated")
+	ignoreFrom := analyzeCmd.String("ignore-from", "", "File of newline-delimited exclude patterns (# starts a comment), merged with --exclude")
+	analyzeCmd.Var(&bannedImports, "banned-import", "Import path to flag wherever it is imported; repeatable or comma-separated")
+	failOnBanned := analyzeCmd.Bool("fail-on-banned-import", false, "Exit with an error after writing the report if any banned import is found")
+	checkBuild := analyzeCmd.Bool("check-build", false, "Run go build ./... and go vet ./... in the clone and report whether it compiles, vet findings and binary sizes (needs the Go toolchain)")
+	buildTimeout := analyzeCmd.Duration("build-timeout", metrics.DefaultBuildTimeout, "Time limit of the --check-build build and vet")
+	buildGoflags := analyzeCmd.String("build-goflags", "", "GOFLAGS of the --check-build go commands, e.g. -tags=integration")
+	buildAllowNetwork := analyzeCmd.Bool("build-allow-network", false, "Let --check-build download the module's dependencies (by default GOPROXY=off)")
+	includeUntracked := analyzeCmd.Bool("include-untracked", false, "For a local repository path, also analyze untracked files that are not ignored")
+	worktree := analyzeCmd.Bool("worktree", false, "For a local repository path, analyze its uncommitted changes, staged or not, against HEAD instead of the latest commit; the repository is left untouched")
+	submodules := analyzeCmd.String("submodules", git.SubmodulesNone, "How to analyze the submodules of a git repository: none lists their paths and pinned commits, shallow also fetches them at their pinned commit so their files count towards the metrics, full also analyzes the pinned commit of each")
+	submoduleDepth := analyzeCmd.Int("submodule-depth", git.DefaultSubmoduleDepth, "Deepest nesting of the submodules --submodules shallow and full fetch; deeper ones are only listed")
+	ownership := analyzeCmd.Bool("ownership", false, "Attribute each function over threshold to the author of most of its lines (needs full history)")
+	annotationAuthors := analyzeCmd.Bool("annotation-authors", false, "Count the TODO and FIXME comments per author of their line (needs full history)")
+	recentWindow := analyzeCmd.Duration("recent-window", 0, "Report the share of Go source lines last changed within this long before the analyzed commit, e.g. 2160h for 90 days (clones the full history; 0 disables)")
+	noExcerpts := analyzeCmd.Bool("no-excerpts", false, "Leave out the source excerpts of the most complex functions")
+	runStats := analyzeCmd.Bool("run-stats", false, "Add a Run Statistics section: objects and bytes fetched, files walked, skipped and analyzed, parse errors")
+	refactoringPlan := analyzeCmd.Bool("refactoring-plan", false, fmt.Sprintf("Add a Suggested Refactoring Plan ranking the functions over threshold by complexity, edits and churn of their file in the last %d days, and ownership with --ownership (clones the full history)", int(metrics.DefaultPlanWindow.Hours()/24)))
+	planWeights := analyzeCmd.String("plan-weights", "", "Comma-separated weights of the signals of --refactoring-plan, e.g. complexity=2,edits=1; signals not listed keep their default (complexity=0.4, edits=0.25, churn=0.15, ownership=0.2)")
+	trend := analyzeCmd.Int("trend", 0, "Chart functions over threshold across the last N commits (clones N commits of history; 0 disables)")
+	compareToTag := analyzeCmd.Bool("compare-to-tag", false, "Report the commits and lines changed since the latest tag reachable from HEAD (clones the full history)")
+	skipArchived := analyzeCmd.Bool("skip-archived", false, "Skip GitHub repositories reported as archived or disabled (needs GITHUB_TOKEN)")
+	cadence := analyzeCmd.Bool("cadence", false, "Report the mean and median interval between commits of the full history (clones the full history)")
+	lintCommits := analyzeCmd.Bool("lint-commits", false, "Check the message of the analyzed commit, or of the commits since the tag with --compare-to-tag, against the rules of "+commitlint.FileName)
+	maxFilesPerCommit := analyzeCmd.Int("max-files-per-commit", 0, "List commits of the history changing more than N files as shotgun commits (clones the full history; 0 disables)")
+	commit := analyzeCmd.String("commit", "", "Commit to analyze instead of the latest, full or abbreviated to at least 7 characters (clones the full history)")
+	depth := analyzeCmd.Int("depth", 1, "Commits of history to clone, raised as other flags need; 0 clones the full history, so the commit is diffed against its actual parent")
+	branch := analyzeCmd.String("branch", "", "Branch to analyze the latest commit of, e.g. develop or refs/heads/develop (default: the default branch of the repository)")
+	vcsName := analyzeCmd.String("vcs", "", "Version control system of the repository: git or hg (default: hg for hg:: URLs and local Mercurial repositories, git otherwise)")
+	concurrency := analyzeCmd.Int("concurrency", 0, "Number of Go files parsed at once (0 parses one per CPU)")
+	var maxMemory byteSize
+	analyzeCmd.Var(&maxMemory, "max-memory", "Soft heap limit, e.g. 512MiB; when reached, files are parsed one at a time and optional analyses are skipped (best effort; 0 disables)")
+	maxFileSize := byteSize(zenwatch.DefaultMaxFileSize)
+	analyzeCmd.Var(&maxFileSize, "max-file-size", "Skip Go files larger than this in the complexity analysis, with a warning (0 disables)")
+	phaseTimeout := analyzeCmd.Duration("phase-timeout", zenwatch.DefaultPhaseTimeout, "Time budget of each analysis; an analysis running out of it is reported as incomplete (0 disables)")
+	sweepClones := analyzeCmd.Duration("sweep-stale-clones", 0, "Before cloning, remove zenwatch clones left in the temp dir that are older than this, e.g. 24h (0 disables)")
+	failOn := analyzeCmd.String("fail-on", "", "Comma-separated rules that fail the run after the report is written, e.g. warnings>0 or diff-coverage<70")
+	pluginsDir := analyzeCmd.String("plugins-dir", "", "Directory of analyzer plugins to run: each executable file is run on the analyzed tree and its findings are reported as Custom Findings")
+	var pluginPaths stringList
+	analyzeCmd.Var(&pluginPaths, "plugin", "Analyzer plugin executable to run in addition to those of --plugins-dir; repeatable or comma-separated")
+	pluginTimeout := analyzeCmd.Duration("plugin-timeout", plugin.DefaultTimeout, "Time limit of each analyzer plugin; a plugin running longer is stopped and reported as a warning")
+	coverProfile := analyzeCmd.String("coverprofile", "", "Report the share of the lines the commit added that the tests of this go test -coverprofile file cover")
+	allowEmpty := analyzeCmd.Bool("allow-empty-analysis", false, "Write a minimal report instead of failing when the repository contains no source code")
+	writeManifest := analyzeCmd.Bool("write-manifest", false, "Write a "+manifest.FileName+" next to the report to make the run reproducible")
+	fromManifest := analyzeCmd.String("from-manifest", "", "Replay the run recorded in a "+manifest.FileName)
+	allowVersionDrift := analyzeCmd.Bool("allow-version-drift", false, "With --from-manifest, replay even if metric algorithm versions changed")
+
+	if err := analyzeCmd.Parse(args); err != nil {
+		return analyzeOptions{}, err
+	}
 
-	// Further implementation will follow in subsequent steps
+	var repoURL, pinnedCommit string
+	if *fromManifest != "" {
+		m, err := manifest.Read(*fromManifest)
+		if err != nil {
+			return analyzeOptions{}, err
+		}
+		if err := m.CheckAlgorithmVersions(metrics.AlgorithmVersions()); err != nil && !*allowVersionDrift {
+			return analyzeOptions{}, fmt.Errorf("%w (use --allow-version-drift to replay anyway)", err)
+		}
+		for name, value := range m.Options {
+			if manifestOnlyFlags[name] {
+				continue
+			}
+			if err := analyzeCmd.Set(name, value); err != nil {
+				return analyzeOptions{}, fmt.Errorf("invalid option %s in manifest: %w", name, err)
+			}
+		}
+		if err := analyzeCmd.Parse(args); err != nil {
+			return analyzeOptions{}, err
+		}
+		if analyzeCmd.NArg() > 0 {
+			return analyzeOptions{}, errors.New("a repository URL cannot be combined with --from-manifest")
+		}
+		repoURL, pinnedCommit = m.RepoURL, m.CommitHash
+	} else {
+		if analyzeCmd.NArg() < 1 {
+			analyzeCmd.Usage()
+			return analyzeOptions{}, errUsage
+		}
+		repoURL = analyzeCmd.Arg(0)
+	}
+
+	if _, ok := report.LookupFormatter(*format); !ok && *format != formatIssues && *format != formatMarkdownGist && *format != formatPushgateway {
+		return analyzeOptions{}, fmt.Errorf("unknown output format %q (supported: %s)", *format,
+			strings.Join(append(report.FormatterNames(), formatIssues, formatMarkdownGist, formatPushgateway), ", "))
+	}
+	if *format == formatPushgateway && *pushgatewayURL == "" {
+		return analyzeOptions{}, errors.New("--format prometheus-pushgateway needs the Pushgateway URL in --pushgateway-url or ZENWATCH_PUSHGATEWAY_URL")
+	}
+	if *pushgatewayJob == "" {
+		return analyzeOptions{}, errors.New("--pushgateway-job must not be empty")
+	}
+	instance := *pushgatewayInstance
+	if instance == "" {
+		instance = manifest.NormalizeRepoURL(repoURL)
+	}
+	if *format == formatMarkdownGist && os.Getenv("GITHUB_TOKEN") == "" {
+		return analyzeOptions{}, errors.New("--format markdown-gist needs a GitHub token with the gist scope in GITHUB_TOKEN")
+	}
+	if *issuesMax < 0 {
+		return analyzeOptions{}, fmt.Errorf("--issues-max must not be negative, got %d", *issuesMax)
+	}
+	if *heatmapMaxNodes < 1 {
+		return analyzeOptions{}, fmt.Errorf("--heatmap-max-nodes must be at least 1, got %d", *heatmapMaxNodes)
+	}
+	if *maxFilesPerCommit < 0 {
+		return analyzeOptions{}, fmt.Errorf("--max-files-per-commit must not be negative, got %d", *maxFilesPerCommit)
+	}
+	if *buildTimeout <= 0 {
+		return analyzeOptions{}, fmt.Errorf("--build-timeout must be positive, got %v", *buildTimeout)
+	}
+	if *phaseTimeout < 0 {
+		return analyzeOptions{}, fmt.Errorf("--phase-timeout must not be negative, got %v", *phaseTimeout)
+	}
+	if maxFileSize > math.MaxInt64 {
+		return analyzeOptions{}, fmt.Errorf("--max-file-size is too large, got %d bytes", maxFileSize)
+	}
+	if *concurrency < 0 {
+		return analyzeOptions{}, fmt.Errorf("--concurrency must not be negative, got %d", *concurrency)
+	}
+	weights, err := metrics.ParsePlanWeights(*planWeights)
+	if err != nil {
+		return analyzeOptions{}, fmt.Errorf("--plan-weights: %w", err)
+	}
+	if *planWeights != "" && !*refactoringPlan {
+		return analyzeOptions{}, errors.New("--plan-weights needs --refactoring-plan")
+	}
+	if *trend < 0 {
+		return analyzeOptions{}, fmt.Errorf("--trend must not be negative, got %d", *trend)
+	}
+	if *submoduleDepth < 1 {
+		return analyzeOptions{}, fmt.Errorf("--submodule-depth must be at least 1, got %d", *submoduleDepth)
+	}
+	if *maxLineLength < 1 {
+		return analyzeOptions{}, fmt.Errorf("--max-line-length must be at least 1, got %d", *maxLineLength)
+	}
+	repoVCS, err := vcs.ForURL(repoURL, *vcsName)
+	if err != nil {
+		return analyzeOptions{}, err
+	}
+	if pinnedCommit != "" && repoVCS.Name() == vcs.NameGit {
+		// A replay checks out the recorded commit, wherever its branch has moved since.
+		*commit, *branch = pinnedCommit, ""
+	}
+	// The options are checked by zenwatch.Options.Validate; these are only those of the CLI.
+	if *fast && *failOnBanned {
+		return analyzeOptions{}, errors.New("--fail-on-banned-import cannot be combined with --fast, which skips the analysis it needs")
+	}
+	if *fast && *format == formatIssues {
+		return analyzeOptions{}, errors.New("--format issues cannot be combined with --fast, which skips the complexity analysis")
+	}
+	if *fast {
+		// Found before cloning in a local repository; in a remote one, only once the analysis ran.
+		budgetPath := filepath.Join(repoURL, budget.FileName)
+		if _, err := os.Stat(budgetPath); err == nil {
+			return analyzeOptions{}, fmt.Errorf("%s cannot be checked with --fast, which skips the complexity analysis; run without --fast", budgetPath)
+		}
+	}
+
+	redactOpts, err := report.ParseRedactOptions(*redact)
+	if err != nil {
+		return analyzeOptions{}, err
+	}
+	if *anonymizeAuthors {
+		redactOpts.Authors = true
+	}
+	reportOpts := report.ReportOptions{EmojiStyle: *emojiStyle, HeatmapMaxNodes: *heatmapMaxNodes, EmbedData: *embedData}
+	if err := reportOpts.Validate(); err != nil {
+		return analyzeOptions{}, err
+	}
+
+	badgeOpts := report.BadgeOptions{BaseURL: *badgeBaseURL, Baseline: *badgeBaseline}
+	if err := badgeOpts.Validate(); err != nil {
+		return analyzeOptions{}, err
+	}
+	failRules, err := parseFailRules(*failOn)
+	if err != nil {
+		return analyzeOptions{}, err
+	}
+	// Resolved before cloning, so a missing plugin fails the run early.
+	plugins, err := plugin.Collect(*pluginsDir, pluginPaths)
+	if err != nil {
+		return analyzeOptions{}, err
+	}
+	if *pluginTimeout < 0 {
+		return analyzeOptions{}, fmt.Errorf("--plugin-timeout must not be negative, got %s", *pluginTimeout)
+	}
+	if *coverProfile != "" {
+		// Checked before cloning, which can take a while.
+		if _, err := os.Stat(*coverProfile); err != nil {
+			return analyzeOptions{}, fmt.Errorf("--coverprofile: %w", err)
+		}
+	}
+	for _, rule := range failRules {
+		if rule.Metric == failOnDiffCoverage && *coverProfile == "" {
+			return analyzeOptions{}, fmt.Errorf("--fail-on %s needs --coverprofile", failOnDiffCoverage)
+		}
+	}
+	if *fast {
+		for _, rule := range failRules {
+			if section, ok := fastSkippedMetrics[rule.Metric]; ok {
+				return analyzeOptions{}, fmt.Errorf("--fail-on %s cannot be checked with --fast, which skips the %s analysis", rule.Metric, section)
+			}
+		}
+	}
+	languages, err := metrics.ParseLanguageFilter(*lang)
+	if err != nil {
+		return analyzeOptions{}, err
+	}
+	excludePatterns := []string(excludes)
+	if *ignoreFrom != "" {
+		patterns, err := metrics.ReadPatternFile(*ignoreFrom)
+		if err != nil {
+			return analyzeOptions{}, err
+		}
+		excludePatterns = append(excludePatterns, patterns...)
+	}
+	exclude, err := metrics.NewExcludeFilter(excludePatterns)
+	if err != nil {
+		return analyzeOptions{}, err
+	}
+	rollupOpts := metrics.RollupOptions{MaxDepth: *rollupDepth, MinSLOC: *rollupMinSLOC, SortBy: *rollupSort, Languages: languages, Exclude: exclude}
+	rollupOpts.ListFiles = *format == report.FormatTreemapJSON
+	if err := rollupOpts.Validate(); err != nil {
+		return analyzeOptions{}, err
+	}
+
+	flags := make(map[string]string)
+	analyzeCmd.VisitAll(func(f *flag.Flag) {
+		if !manifestOnlyFlags[f.Name] {
+			flags[f.Name] = f.Value.String()
+		}
+	})
+
+	excerpts := metrics.ExcerptCount
+	switch {
+	case *noExcerpts:
+		excerpts = 0
+	case *format == formatIssues && *issuesMax > 0:
+		excerpts = *issuesMax // Every drafted issue shows its function
+	case *format == formatIssues:
+		excerpts = -1
+	}
+
+	opts := analyzeOptions{
+		Options: zenwatch.Options{
+			Branch:            *branch,
+			Commit:            *commit,
+			Depth:             *depth,
+			Rollup:            rollupOpts,
+			Fast:              *fast,
+			IncludeTests:      *includeTests,
+			MaxLineLength:     *maxLineLength,
+			BannedImports:     bannedImports,
+			AllowEmpty:        *allowEmpty,
+			CheckBuild:        *checkBuild,
+			Build:             metrics.BuildOptions{Timeout: *buildTimeout, GOFLAGS: *buildGoflags, AllowNetwork: *buildAllowNetwork},
+			Ownership:         *ownership,
+			AnnotationAuthors: *annotationAuthors,
+			RecentWindow:      *recentWindow,
+			Trend:             *trend,
+			RefactoringPlan:   *refactoringPlan,
+			PlanWeights:       weights,
+			MaxFilesPerCommit: *maxFilesPerCommit,
+			CompareToTag:      *compareToTag,
+			LintCommits:       *lintCommits,
+			Cadence:           *cadence,
+			CoverProfile:      *coverProfile,
+			Plugins:           plugins,
+			PluginLimits:      plugin.Options{Timeout: *pluginTimeout},
+			SkipArchived:      *skipArchived,
+			Excerpts:          excerpts,
+			RunStats:          *runStats,
+			Untracked:         *includeUntracked,
+			Worktree:          *worktree,
+			Submodules:        *submodules,
+			SubmoduleDepth:    *submoduleDepth,
+			Workers:           metrics.WorkerOptions{Concurrency: *concurrency, MaxMemory: uint64(maxMemory), MaxFileSize: int64(maxFileSize)},
+			PhaseTimeout:      *phaseTimeout,
+			PinnedCommit:      pinnedCommit,
+			HistoryTable:      *historyTable,
+			Report:            reportOpts,
+			Badge:             badgeOpts,
+			Redact:            redactOpts,
+			Config:            flags,
+		},
+		RepoURL:       repoURL,
+		VCS:           repoVCS,
+		OutPath:       *outFilePath,
+		BadgeSVG:      *badgeSVG,
+		Format:        *format,
+		IssuesDir:     *issuesDir,
+		IssuesMax:     *issuesMax,
+		WriteManifest: *writeManifest,
+		FailOnBanned:  *failOnBanned,
+		FailOn:        failRules,
+		SweepClones:   *sweepClones,
+		Pushgateway:   pushgatewayOptions{URL: *pushgatewayURL, Job: *pushgatewayJob, Instance: instance},
+		Flags:         flags,
+	}
+	if err := opts.Validate(zenwatch.Target{URL: repoURL, VCS: repoVCS.Name()}); err != nil {
+		return analyzeOptions{}, flagError(err)
+	}
+	return opts, nil
+}
+
+// optionFlags holds the flags of the zenwatch.Options fields not named after the field.
+var optionFlags = map[string]string{
+	"Untracked":     "include-untracked",
+	"CoverProfile":  "coverprofile",
+	"BannedImports": "banned-import",
+}
+
+// optionFlag returns the flag setting the zenwatch.Options field named option, e.g.
+// --refactoring-plan for RefactoringPlan.
+func optionFlag(option string) string {
+	if name, ok := optionFlags[option]; ok {
+		return "--" + name
+	}
+	var name strings.Builder
+	for i, r := range option {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				name.WriteByte('-')
+			}
+			r = unicode.ToLower(r)
+		}
+		name.WriteRune(r)
+	}
+	return "--" + name.String()
+}
+
+// flagError returns err with the options of a *zenwatch.OptionError named by their flags.
+func flagError(err error) error {
+	var optErr *zenwatch.OptionError
+	if !errors.As(err, &optErr) {
+		return err
+	}
+	return errors.New(optErr.Describe(optionFlag))
+}
+
+// stringList is a flag.Value collecting a list of strings. It can be repeated and
+// each value may itself be a comma-separated list, which is also how it is recorded in manifests.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*l = append(*l, item)
+		}
+	}
+	return nil
+}
+
+// byteSize is a flag.Value holding a number of bytes, given as a plain number or with
+// one of the binary unit suffixes KiB, MiB and GiB.
+type byteSize uint64
+
+// byteUnits are the suffixes byteSize accepts, largest first.
+var byteUnits = []struct {
+	suffix string
+	size   uint64
+}{
+	{"GiB", 1 << 30},
+	{"MiB", 1 << 20},
+	{"KiB", 1 << 10},
+}
+
+func (b *byteSize) String() string {
+	for _, u := range byteUnits {
+		if *b != 0 && uint64(*b)%u.size == 0 {
+			return strconv.FormatUint(uint64(*b)/u.size, 10) + u.suffix
+		}
+	}
+	return strconv.FormatUint(uint64(*b), 10)
+}
+
+func (b *byteSize) Set(value string) error {
+	number, unit := value, uint64(1)
+	for _, u := range byteUnits {
+		if n, ok := strings.CutSuffix(value, u.suffix); ok {
+			number, unit = n, u.size
+			break
+		}
+	}
+	n, err := strconv.ParseUint(strings.TrimSpace(number), 10, 64)
+	if err != nil || n > math.MaxUint64/unit {
+		return fmt.Errorf("invalid size %q (expected bytes or a number with a KiB, MiB or GiB suffix)", value)
+	}
+	*b = byteSize(n * unit)
+	return nil
+}
+
+// envOrDefault returns the value of the environment variable key, or fallback if it is unset or empty.
+func envOrDefault(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// runGC removes zenwatch clones left in the temp dir by crashed runs.
+func runGC(args []string) error {
+	gcCmd := flag.NewFlagSet("gc", flag.ContinueOnError)
+	ttl := gcCmd.Duration("ttl", defaultCloneTTL, "Only remove clones last modified longer ago than this")
+	if err := gcCmd.Parse(args); err != nil {
+		return err
+	}
+	if gcCmd.NArg() > 0 {
+		return errors.New("usage: zenwatch gc [--ttl <duration>]")
+	}
+
+	removed, err := git.SweepStaleClones("", *ttl, now())
+	for _, path := range removed {
+		fmt.Printf("Removed %s\n", path)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Removed %d stale clone(s)\n", len(removed))
+	return nil
+}
+
+// runMetrics prints the complexity of the functions in the files given with --file, relative
+// to the optional target path (the current directory by default), without cloning anything.
+// Files that cannot be analyzed are reported one by one. It fails if any file could not be
+// analyzed or any function is over the complexity threshold.
+func runMetrics(args []string, stdout, stderr io.Writer) error {
+	metricsCmd := flag.NewFlagSet("metrics", flag.ContinueOnError)
+	metricsCmd.SetOutput(stderr)
+	var files stringList
+	metricsCmd.Var(&files, "file", "Go file to analyze, relative to the target path; repeatable or comma-separated")
+	if err := metricsCmd.Parse(args); err != nil {
+		return err
+	}
+	if len(files) == 0 || metricsCmd.NArg() > 1 {
+		return errors.New("usage: zenwatch metrics --file <path> [--file <path>...] [<target-path>]")
+	}
+	root := "."
+	if metricsCmd.NArg() == 1 {
+		root = metricsCmd.Arg(0)
+	}
+
+	stats, errs := metrics.CollectFileComplexity(root, files)
+	for _, err := range errs {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+	}
+	if len(stats) > 0 {
+		if err := report.WriteComplexityTable(stdout, stats, complexityThreshold); err != nil {
+			return err
+		}
+	}
+
+	over := len(metrics.FilterOverThreshold(stats, complexityThreshold))
+	switch {
+	case len(errs) > 0 && over > 0:
+		return fmt.Errorf("%d file(s) could not be analyzed and %d function(s) are over the complexity threshold of %d", len(errs), over, complexityThreshold)
+	case len(errs) > 0:
+		return fmt.Errorf("%d of %d file(s) could not be analyzed", len(errs), len(files))
+	case over > 0:
+		return fmt.Errorf("%d function(s) are over the complexity threshold of %d", over, complexityThreshold)
+	}
+	return nil
+}
+
+// runPlugin runs the plugin subcommand. "plugin check" runs an analyzer plugin on a tree, the
+// current directory by default, checks that it follows the plugin protocol and prints its
+// findings.
+func runPlugin(args []string, stdout, stderr io.Writer) error {
+	const usage = "usage: zenwatch plugin check [--timeout <duration>] <executable> [<path>]"
+	if len(args) == 0 || args[0] != "check" {
+		return errors.New(usage)
+	}
+	checkCmd := flag.NewFlagSet("plugin check", flag.ContinueOnError)
+	checkCmd.SetOutput(stderr)
+	timeout := checkCmd.Duration("timeout", plugin.DefaultTimeout, "Time limit of each run of the plugin")
+	if err := checkCmd.Parse(args[1:]); err != nil {
+		return err
+	}
+	if checkCmd.NArg() < 1 || checkCmd.NArg() > 2 {
+		return errors.New(usage)
+	}
+	plugins, err := plugin.Collect("", []string{checkCmd.Arg(0)})
+	if err != nil {
+		return err
+	}
+	root := "."
+	if checkCmd.NArg() == 2 {
+		root = checkCmd.Arg(1)
+	}
+	p, opts := plugins[0], plugin.Options{Timeout: *timeout}
+	if err := plugin.Conform(context.Background(), p, root, opts); err != nil {
+		return err
+	}
+	findings, _, err := plugin.Run(context.Background(), p, plugin.Request{Root: root, Repository: root}, opts)
+	if err != nil {
+		return err
+	}
+	for _, f := range findings {
+		location := f.Location()
+		if location == "" {
+			location = "-"
+		}
+		fmt.Fprintf(stdout, "%s\t%s\t%s\t%s\n", f.Severity, location, f.Fingerprint, f.Message)
+	}
+	fmt.Fprintf(stdout, "Plugin %s conforms to protocol %d: %d finding(s)\n", p.Name, plugin.Protocol, len(findings))
+	return nil
+}
+
+// runAnalyzePatch prints the complexity of the functions touched by a unified diff, such as
+// the output of git diff, read from the file given with --patch ("-" for stdin). The changed
+// files are read from the working tree at the optional target path (the current directory by
+// default), which must hold the post-change version. Like runMetrics, it fails if a file could
+// not be analyzed or a touched function is over the complexity threshold.
+func runAnalyzePatch(args []string, stdin io.Reader, stdout, stderr io.Writer) error {
+	patchCmd := flag.NewFlagSet("analyze-patch", flag.ContinueOnError)
+	patchCmd.SetOutput(stderr)
+	patchFile := patchCmd.String("patch", "", "Unified diff to analyze, e.g. the output of git diff; - reads stdin")
+	if err := patchCmd.Parse(args); err != nil {
+		return err
+	}
+	if *patchFile == "" || patchCmd.NArg() > 1 {
+		return errors.New("usage: zenwatch analyze-patch --patch <file> [<target-path>]")
+	}
+	root := "."
+	if patchCmd.NArg() == 1 {
+		root = patchCmd.Arg(0)
+	}
+
+	patch := stdin
+	if *patchFile != "-" {
+		f, err := os.Open(*patchFile)
+		if err != nil {
+			return fmt.Errorf("failed to open patch: %w", err)
+		}
+		defer f.Close()
+		patch = f
+	}
+	changes, err := metrics.ParsePatchChanges(patch)
+	if err != nil {
+		return err
+	}
+	var files []string
+	for file := range changes {
+		if strings.HasSuffix(file, ".go") {
+			files = append(files, file)
+		}
+	}
+	sort.Strings(files)
+	if len(files) == 0 {
+		fmt.Fprintln(stdout, "The patch changes no Go files")
+		return nil
+	}
+
+	stats, errs := metrics.CollectFileComplexity(root, files)
+	for _, err := range errs {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+	}
+	touched := metrics.TouchedFunctions(stats, changes)
+	if len(touched) == 0 {
+		fmt.Fprintln(stdout, "The patch touches no functions")
+	} else if err := report.WriteComplexityTable(stdout, touched, complexityThreshold); err != nil {
+		return err
+	}
+
+	over := len(metrics.FilterOverThreshold(touched, complexityThreshold))
+	switch {
+	case len(errs) > 0 && over > 0:
+		return fmt.Errorf("%d file(s) could not be analyzed and %d touched function(s) are over the complexity threshold of %d", len(errs), over, complexityThreshold)
+	case len(errs) > 0:
+		return fmt.Errorf("%d of %d file(s) could not be analyzed", len(errs), len(files))
+	case over > 0:
+		return fmt.Errorf("%d touched function(s) are over the complexity threshold of %d", over, complexityThreshold)
+	}
+	return nil
+}
+
+// runAnalyze clones the repository, analyzes its latest commit and writes the report.
+func runAnalyze(opts analyzeOptions) error {
+	if opts.SweepClones > 0 {
+		// A failed sweep must not prevent the analysis.
+		if _, err := git.SweepStaleClones("", opts.SweepClones, now()); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+	}
+	client := &zenwatch.Client{Now: now, Log: os.Stdout, ErrorLog: os.Stderr, GitHubToken: os.Getenv("GITHUB_TOKEN")}
+	result, err := client.Analyze(context.Background(), zenwatch.Target{URL: opts.RepoURL, VCS: opts.VCS.Name()}, opts.Options)
+	var noSource *zenwatch.NoSourceError
+	switch {
+	case errors.Is(err, zenwatch.ErrArchived):
+		fmt.Printf("Skipping %s: GitHub reports the repository as archived or disabled\n", git.RedactURL(opts.RepoURL))
+		return nil
+	case errors.As(err, &noSource):
+		return fmt.Errorf("%w (use --allow-empty-analysis to write a report anyway)", err)
+	case err != nil:
+		return err
+	}
+	data := result.Data
+	switch opts.Format {
+	case formatIssues:
+		err = writeIssueDrafts(data, opts.IssuesDir, opts.IssuesMax)
+	case formatMarkdownGist:
+		err = writeGistReport(data, opts.OutPath)
+	case formatPushgateway:
+		err = writePushgatewayReport(data, opts.OutPath, pushgateway.NewClient(opts.Pushgateway.URL), opts.Pushgateway)
+	default:
+		err = report.GenerateReport(data, opts.Format, opts.OutPath)
+	}
+	if err != nil {
+		return err
+	}
+	if opts.BadgeSVG != "" {
+		if err := report.GenerateBadgeSVG(data.Badge, opts.BadgeSVG); err != nil {
+			return err
+		}
+		fmt.Printf("Badge written to %s\n", opts.BadgeSVG)
+	}
+
+	if opts.WriteManifest {
+		manifestPath := filepath.Join(filepath.Dir(opts.OutPath), manifest.FileName)
+		err := manifest.Write(manifestPath, &manifest.Manifest{
+			SchemaVersion:     manifest.SchemaVersion,
+			RepoURL:           manifest.NormalizeRepoURL(opts.RepoURL),
+			CommitHash:        result.Repository.LatestCommit.Hash,
+			ToolVersion:       version,
+			AlgorithmVersions: metrics.AlgorithmVersions(),
+			Options:           report.RedactConfigSecrets(opts.Flags),
+		})
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Manifest written to %s\n", manifestPath)
+	}
+	fmt.Printf("Analysis finished with %d warning(s)\n", len(data.Warnings))
+
+	verdict := checkGates(opts, data, result.Budget)
+	if verdict == nil {
+		// Failed gates take precedence, as they fail the run whatever the missing sections hold.
+		verdict = result.Err()
+	}
+	if ansi.IsTerminal(os.Stdout) {
+		style := ansi.Styler{Color: ansi.ColorEnabled(os.Stdout)}
+		reportPath := opts.OutPath
+		if opts.Format == formatIssues {
+			reportPath = opts.IssuesDir
+		}
+		if err := report.WriteTerminalSummary(os.Stdout, data, reportPath, verdict, style); err != nil {
+			return fmt.Errorf("failed to write summary: %w", err)
+		}
+	}
+	return verdict
+}
+
+// analyzeExitCode maps an error of runAnalyze to the exit status of its class.
+func analyzeExitCode(err error) int {
+	var noSource *zenwatch.NoSourceError
+	switch {
+	case errors.As(err, &noSource), errors.Is(err, git.ErrEmptyRepository):
+		return exitNoSourceCode
+	case errors.Is(err, git.ErrAuthFailed), errors.Is(err, git.ErrHostKeyVerification), errors.Is(err, git.ErrRepoNotFound), errors.Is(err, git.ErrRefNotFound):
+		return exitRepoUnavailable
+	case errors.Is(err, report.ErrTemplateParse), errors.Is(err, report.ErrOutputWrite):
+		return exitReportFailed
+	case errors.Is(err, zenwatch.ErrPartialAnalysis):
+		return exitPartialAnalysis
+	}
+	return 1
+}
+
+// writeGistReport writes the Markdown report to outPath and publishes it to a secret gist.
+func writeGistReport(data report.ReportData, outPath string) error {
+	if err := report.GenerateReport(data, report.FormatMarkdown, outPath); err != nil {
+		return err
+	}
+	description := fmt.Sprintf("ZenWatch report for %s at %s", data.RepoURL, data.Commit.Hash)
+	gist, err := publishGist(github.NewClient(os.Getenv("GITHUB_TOKEN")), outPath, description)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Report published to %s\n", gist.HTMLURL)
+	return nil
+}
+
+// publishGist publishes the report at reportPath to a secret gist. The gist id is remembered
+// in a file next to the report, so the gist is updated by later runs writing the same report.
+func publishGist(client *github.Client, reportPath, description string) (*github.Gist, error) {
+	content, err := os.ReadFile(reportPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report %s: %w", reportPath, err)
+	}
+	idPath := reportPath + gistIDSuffix
+	id, err := os.ReadFile(idPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read gist id %s: %w", idPath, err)
+	}
+	gist, err := client.PublishGist(context.Background(), strings.TrimSpace(string(id)), description, filepath.Base(reportPath), string(content))
+	if err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(idPath, []byte(gist.ID+"\n"), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write gist id %s: %w", idPath, err)
+	}
+	return gist, nil
+}
+
+// writePushgatewayReport writes the report's gauges to outPath and pushes them to the
+// Pushgateway. The metrics are a side channel of the run, so a failed push only prints
+// a warning.
+func writePushgatewayReport(data report.ReportData, outPath string, client *pushgateway.Client, opts pushgatewayOptions) error {
+	var gauges bytes.Buffer
+	if err := report.WritePrometheusMetrics(data, &gauges); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(outPath), 0755); err != nil {
+		return fmt.Errorf("failed to create output directory %s: %w: %w", filepath.Dir(outPath), report.ErrOutputWrite, err)
+	}
+	if err := os.WriteFile(outPath, gauges.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write report file %s: %w: %w", outPath, report.ErrOutputWrite, err)
+	}
+	fmt.Printf("Report (%s) generated at %s\n", formatPushgateway, outPath)
+
+	if err := client.Push(context.Background(), opts.Job, opts.Instance, gauges.Bytes()); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		return nil
+	}
+	fmt.Printf("Metrics pushed to %s\n", client.URL)
+	return nil
+}
+
+// checkGates returns the first failed gate of the analysis: the --fail-on rules, banned
+// imports and b, the budget of the analyzed tree. It returns nil if all of them pass.
+func checkGates(opts analyzeOptions, data report.ReportData, b budget.Budget) error {
+	stats := data.Stats
+	failValues := map[string]int{
+		failOnWarnings:    len(data.Warnings),
+		failOnVendorDrift: len(stats.VendorDrift),
+		failOnCommitLint:  commitlint.Errors(data.CommitLintFindings),
+	}
+	for _, style := range stats.Style {
+		failValues[failOnLongLines] += style.LongLines
+		failValues[failOnMixedIndent] += style.MixedIndentFiles
+	}
+	if stats.Build != nil {
+		if !stats.Build.Compiles {
+			failValues[failOnBuildFailed] = 1
+		}
+		failValues[failOnVetFindings] = stats.Build.VetFindings
+	}
+	if stats.DiffCoverage != nil {
+		// Rounded down, so 69.9% fails diff-coverage<70.
+		failValues[failOnDiffCoverage] = int(math.Floor(stats.DiffCoverage.Percent()))
+	}
+	for _, rule := range opts.FailOn {
+		if err := rule.check(failValues[rule.Metric]); err != nil {
+			return err
+		}
+	}
+
+	if opts.FailOnBanned && len(stats.BannedImports) > 0 {
+		return fmt.Errorf("found %d banned import(s), see the Banned Imports section of %s", len(stats.BannedImports), opts.OutPath)
+	}
+
+	if b != nil {
+		if opts.Fast {
+			// The budgeted metrics are all complexity metrics, which would pass at 0.
+			return fmt.Errorf("%s cannot be checked with --fast, which skips the complexity analysis; run without --fast", budget.FileName)
+		}
+		if err := b.Check(budgetValues(stats)); err != nil {
+			return fmt.Errorf("%w (see %s)", err, budget.FileName)
+		}
+		fmt.Printf("Within the budget of %s\n", budget.FileName)
+	}
+	return nil
+}
+
+// writeIssueDrafts writes issue drafts for the functions over threshold to dir, skipping
+// the findings already drafted there.
+func writeIssueDrafts(data report.ReportData, dir string, limit int) error {
+	written, skipped, err := report.WriteIssueDrafts(dir, report.BuildIssueDrafts(data, limit))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Wrote %d issue draft(s) to %s", len(written), dir)
+	if skipped > 0 {
+		fmt.Printf(", skipped %d already drafted", skipped)
+	}
+	fmt.Println()
+	return nil
+}
+
+// budgetValues returns the metrics a budget can ratchet, by budget metric name.
+func budgetValues(stats *metrics.OverallStats) map[string]float64 {
+	return map[string]float64{
+		budget.FunctionsOverThreshold: float64(stats.FunctionsOverThreshold),
+		budget.AverageComplexity:      stats.AverageComplexity,
+	}
+}
+
+// runBudget runs the budget subcommand. "budget update" analyzes the working tree at the
+// optional path (the current directory by default) with the default analysis options and
+// lowers the budget file there to the values found, creating it if needed. Budgets are never raised.
+func runBudget(args []string) error {
+	const usage = "usage: zenwatch budget update [<path>]"
+	if len(args) == 0 || args[0] != "update" {
+		return errors.New(usage)
+	}
+	budgetCmd := flag.NewFlagSet("budget update", flag.ContinueOnError)
+	if err := budgetCmd.Parse(args[1:]); err != nil {
+		return err
+	}
+	if budgetCmd.NArg() > 1 {
+		return errors.New(usage)
+	}
+	dir := "."
+	if budgetCmd.NArg() == 1 {
+		dir = budgetCmd.Arg(0)
+	}
+
+	stats, err := zenwatch.AnalyzeTree(context.Background(), dir, zenwatch.DefaultOptions())
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(dir, budget.FileName)
+	current, err := budget.Load(path)
+	if err != nil {
+		return err
+	}
+	ratcheted, lowered := current.Ratchet(budgetValues(stats))
+	if err := budget.Write(path, ratcheted); err != nil {
+		return err
+	}
+	switch {
+	case current == nil:
+		fmt.Printf("Created %s\n", path)
+	case len(lowered) == 0:
+		fmt.Printf("No metric improved, %s is unchanged\n", path)
+	default:
+		fmt.Printf("Lowered %s in %s\n", strings.Join(lowered, ", "), path)
+	}
+	return nil
+}
+
+// Metrics --fail-on rules can check.
+const (
+	failOnWarnings    = "warnings"
+	failOnVendorDrift = "vendor-drift"
+	failOnCommitLint  = "commit-lint"
+	failOnBuildFailed = "build-failed"
+	failOnVetFindings = "vet-findings"
+	failOnLongLines   = "long-lines"
+	failOnMixedIndent = "mixed-indentation"
+
+	failOnDiffCoverage = "diff-coverage"
+)
+
+// failOnMetrics lists the metrics in the order they are documented.
+var failOnMetrics = []string{failOnWarnings, failOnVendorDrift, failOnCommitLint, failOnBuildFailed, failOnVetFindings, failOnLongLines, failOnMixedIndent}
+
+// failOnMinimums lists the metrics whose rules set a minimum, written metric<N.
+var failOnMinimums = []string{failOnDiffCoverage}
+
+// fastSkippedMetrics maps the --fail-on metrics --fast cannot measure to the section of the
+// analysis it skips.
+var fastSkippedMetrics = map[string]string{
+	failOnVendorDrift: metrics.SectionVendorDrift,
+	failOnBuildFailed: metrics.SectionBuild,
+	failOnVetFindings: metrics.SectionBuild,
+	failOnLongLines:   metrics.SectionStyle,
+	failOnMixedIndent: metrics.SectionStyle,
+}
+
+// failRule is a --fail-on condition on a count, such as the number of warnings, or on a
+// minimum metric, such as the diff coverage.
+type failRule struct {
+	Metric string
+	Max    int // The run fails if the metric is greater than Max
+	Min    int // For a minimum metric, the run fails if it is less than Min
+}
+
+// parseFailRules parses a comma-separated list of rules such as "warnings>0,vendor-drift>0".
+func parseFailRules(s string) ([]failRule, error) {
+	var rules []failRule
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		metric, limit, ok := strings.Cut(field, ">")
+		minimum := false
+		if !ok {
+			metric, limit, ok = strings.Cut(field, "<")
+			minimum = true
+		}
+		metric = strings.TrimSpace(metric)
+		if !ok || !slices.Contains(failOnMetrics, metric) && !minimum || !slices.Contains(failOnMinimums, metric) && minimum {
+			return nil, fmt.Errorf("invalid --fail-on rule %q (supported: %s>N, %s<N)", field, strings.Join(failOnMetrics, ">N, "), strings.Join(failOnMinimums, "<N, "))
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(limit))
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid --fail-on rule %q: %q is not a non-negative integer", field, limit)
+		}
+		if minimum {
+			rules = append(rules, failRule{Metric: metric, Min: n})
+		} else {
+			rules = append(rules, failRule{Metric: metric, Max: n})
+		}
+	}
+	return rules, nil
+}
+
+// check returns an error if value violates the rule.
+func (r failRule) check(value int) error {
+	if slices.Contains(failOnMinimums, r.Metric) {
+		if value < r.Min {
+			return fmt.Errorf("--fail-on %s<%d: %s is %d", r.Metric, r.Min, r.Metric, value)
+		}
+		return nil
+	}
+	if value > r.Max {
+		return fmt.Errorf("--fail-on %s>%d: found %d %s", r.Metric, r.Max, value, r.Metric)
+	}
+	return nil
 }
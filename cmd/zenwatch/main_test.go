@@ -0,0 +1,860 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/zenwatch/internal/budget"
+	"github.com/user/zenwatch/internal/git"
+	"github.com/user/zenwatch/internal/github"
+	"github.com/user/zenwatch/internal/manifest"
+	"github.com/user/zenwatch/internal/metrics"
+	"github.com/user/zenwatch/internal/pushgateway"
+	"github.com/user/zenwatch/internal/report"
+	"github.com/user/zenwatch/internal/vcs"
+	"github.com/user/zenwatch/internal/warning"
+	"github.com/user/zenwatch/pkg/zenwatch"
+)
+
+// writeFile creates a file (and its parent directories) under root with the given content.
+func writeFile(t *testing.T, root, path, content string) {
+	t.Helper()
+	fullPath := filepath.Join(root, path)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		t.Fatalf("Failed to create directory for %s: %v", path, err)
+	}
+	if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+// complexFunc returns the source of a function with the given cyclomatic complexity.
+func complexFunc(name string, complexity int) string {
+	return "func " + name + "(x int) int {\n" +
+		strings.Repeat("\tif x > 0 {\n\t\tx--\n\t}\n", complexity-1) +
+		"\treturn x\n}\n"
+}
+
+func TestParseAnalyzeArgsFromManifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), manifest.FileName)
+	err := manifest.Write(path, &manifest.Manifest{
+		SchemaVersion:     manifest.SchemaVersion,
+		RepoURL:           "https://github.com/user/repo.git",
+		CommitHash:        "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		AlgorithmVersions: metrics.AlgorithmVersions(),
+		Options:           map[string]string{"include-tests": "true", "out": "old/report.md", "write-manifest": "true", "branch": "main"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+
+	opts, err := parseAnalyzeArgs([]string{"--from-manifest", path, "--out", "new/report.md"})
+	if err != nil {
+		t.Fatalf("parseAnalyzeArgs failed: %v", err)
+	}
+	if opts.RepoURL != "https://github.com/user/repo.git" || opts.PinnedCommit != "6ecf0ef2c2dffb796033e5a02219af86ec6584e5" {
+		t.Errorf("Expected repository and commit from the manifest, got %q at %q", opts.RepoURL, opts.PinnedCommit)
+	}
+	// The recorded commit is checked out rather than the branch it was on.
+	if opts.Commit != opts.PinnedCommit || opts.Branch != "" {
+		t.Errorf("Expected the run to analyze the recorded commit, got commit %q on branch %q", opts.Commit, opts.Branch)
+	}
+	if !opts.IncludeTests {
+		t.Errorf("Expected include-tests to be restored from the manifest")
+	}
+	if opts.OutPath != "new/report.md" {
+		t.Errorf("Expected command-line --out to override the manifest, got %s", opts.OutPath)
+	}
+	if opts.WriteManifest {
+		t.Errorf("Expected replay-control flags in the manifest to be ignored")
+	}
+
+	// The resolved flags round-trip into the next manifest.
+	if opts.Flags["include-tests"] != "true" || opts.Flags["out"] != "new/report.md" {
+		t.Errorf("Unexpected recorded flags: %v", opts.Flags)
+	}
+	if _, ok := opts.Flags["from-manifest"]; ok {
+		t.Errorf("Expected from-manifest not to be recorded")
+	}
+}
+
+func TestParseAnalyzeArgsFromManifestVersionDrift(t *testing.T) {
+	path := filepath.Join(t.TempDir(), manifest.FileName)
+	versions := metrics.AlgorithmVersions()
+	versions["complexity"] = "0"
+	err := manifest.Write(path, &manifest.Manifest{
+		SchemaVersion:     manifest.SchemaVersion,
+		RepoURL:           "https://github.com/user/repo.git",
+		CommitHash:        "6ecf0ef2c2dffb796033e5a02219af86ec6584e5",
+		AlgorithmVersions: versions,
+	})
+	if err != nil {
+		t.Fatalf("Failed to write manifest: %v", err)
+	}
+
+	if _, err := parseAnalyzeArgs([]string{"--from-manifest", path}); err == nil || !strings.Contains(err.Error(), "complexity") {
+		t.Errorf("Expected an algorithm version drift error, got %v", err)
+	}
+	if _, err := parseAnalyzeArgs([]string{"--allow-version-drift", "--from-manifest", path}); err != nil {
+		t.Errorf("Expected --allow-version-drift to permit the replay, got %v", err)
+	}
+}
+
+func TestParseFailRules(t *testing.T) {
+	rules, err := parseFailRules("warnings>2, vendor-drift>0")
+	if err != nil {
+		t.Fatalf("parseFailRules failed: %v", err)
+	}
+	if len(rules) != 2 || rules[0] != (failRule{Metric: "warnings", Max: 2}) || rules[1] != (failRule{Metric: "vendor-drift", Max: 0}) {
+		t.Fatalf("Unexpected rules %+v", rules)
+	}
+	if err := rules[0].check(2); err != nil {
+		t.Errorf("Expected 2 warnings to pass warnings>2, got %v", err)
+	}
+	if err := rules[0].check(3); err == nil {
+		t.Errorf("Expected 3 warnings to fail warnings>2")
+	}
+
+	if rules, err := parseFailRules("commit-lint>0,build-failed>0,vet-findings>3"); err != nil || len(rules) != 3 || rules[2].Metric != failOnVetFindings {
+		t.Errorf("Expected commit-lint, build-failed and vet-findings rules, got %+v, %v", rules, err)
+	}
+
+	for _, invalid := range []string{"warnings", "warnings>-1", "warnings>many", "errors>0", "vendor-drift"} {
+		if _, err := parseFailRules(invalid); err == nil {
+			t.Errorf("Expected parseFailRules(%q) to fail", invalid)
+		}
+	}
+}
+
+func TestCheckGatesStyle(t *testing.T) {
+	data := report.ReportData{Stats: &metrics.OverallStats{Style: []metrics.StyleStats{
+		{Language: "Go", Files: 3, LongLines: 2},
+		{Language: "YAML", Files: 2, IndentChecked: true, LongLines: 1, MixedIndentFiles: 1},
+	}}}
+	rules, err := parseFailRules("long-lines>3,mixed-indentation>1")
+	if err != nil {
+		t.Fatalf("parseFailRules failed: %v", err)
+	}
+	if err := checkGates(analyzeOptions{FailOn: rules}, data, nil); err != nil {
+		t.Errorf("Expected 3 long lines and 1 mixed file to pass, got %v", err)
+	}
+
+	rules, _ = parseFailRules("long-lines>2")
+	err = checkGates(analyzeOptions{FailOn: rules}, data, nil)
+	if err == nil || !strings.Contains(err.Error(), "found 3 long-lines") {
+		t.Errorf("Expected long lines across languages to add up and fail long-lines>2, got %v", err)
+	}
+}
+
+func TestCheckGatesDiffCoverage(t *testing.T) {
+	rules, err := parseFailRules("warnings>0, diff-coverage<70")
+	if err != nil {
+		t.Fatalf("parseFailRules failed: %v", err)
+	}
+	if rules[1] != (failRule{Metric: failOnDiffCoverage, Min: 70}) {
+		t.Fatalf("Unexpected rule %+v", rules[1])
+	}
+	for _, tt := range []struct {
+		covered, coverable int
+		wantErr            bool
+	}{
+		{7, 10, false},
+		{699, 1000, true}, // 69.9% is not 70%
+		{0, 0, false},     // Nothing coverable was added
+	} {
+		data := report.ReportData{Stats: &metrics.OverallStats{DiffCoverage: &metrics.DiffCoverage{Covered: tt.covered, Coverable: tt.coverable}}}
+		if err := checkGates(analyzeOptions{FailOn: rules}, data, nil); (err != nil) != tt.wantErr {
+			t.Errorf("%d of %d lines covered: expected an error %v, got %v", tt.covered, tt.coverable, tt.wantErr, err)
+		}
+	}
+
+	for _, invalid := range []string{"diff-coverage>70", "warnings<1", "diff-coverage<"} {
+		if _, err := parseFailRules(invalid); err == nil {
+			t.Errorf("Expected parseFailRules(%q) to fail", invalid)
+		}
+	}
+
+	const repo = "https://github.com/user/repo.git"
+	if _, err := parseAnalyzeArgs([]string{"--fail-on", "diff-coverage<70", repo}); err == nil || !strings.Contains(err.Error(), "needs --coverprofile") {
+		t.Errorf("Expected diff-coverage without a profile to be refused, got %v", err)
+	}
+	if _, err := parseAnalyzeArgs([]string{"--coverprofile", filepath.Join(t.TempDir(), "missing.out"), repo}); err == nil {
+		t.Errorf("Expected a missing coverage profile to be refused before cloning")
+	}
+}
+
+func TestParseAnalyzeArgsFast(t *testing.T) {
+	const repo = "https://github.com/user/repo.git"
+	opts, err := parseAnalyzeArgs([]string{"--fast", "--fail-on", "warnings>0,commit-lint>0", repo})
+	if err != nil {
+		t.Fatalf("parseAnalyzeArgs failed: %v", err)
+	}
+	if !opts.Fast || opts.Flags["fast"] != "true" {
+		t.Errorf("Expected a recorded fast run, got Fast %v and flags %v", opts.Fast, opts.Flags)
+	}
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--fail-on", "warnings>0,long-lines>0"}, "--fail-on long-lines cannot be checked with --fast, which skips the Code Style analysis"},
+		{[]string{"--fail-on", "vendor-drift>0"}, "--fail-on vendor-drift"},
+		{[]string{"--fail-on", "vet-findings>0"}, "--fail-on vet-findings"},
+		{[]string{"--check-build"}, "--check-build cannot be combined with --fast"},
+		{[]string{"--fail-on-banned-import"}, "--fail-on-banned-import"},
+		{[]string{"--include-tests"}, "--include-tests"},
+		{[]string{"--format", "issues"}, "--format issues"},
+	} {
+		args := append(append([]string{"--fast"}, tt.args...), repo)
+		if _, err := parseAnalyzeArgs(args); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("Expected %v to be refused with %q, got %v", args, tt.want, err)
+		}
+	}
+
+	// The budget of a local repository is refused before any clone.
+	local := t.TempDir()
+	writeFile(t, local, budget.FileName, "{\"functions-over-threshold\": 3}\n")
+	if _, err := parseAnalyzeArgs([]string{"--fast", local}); err == nil || !strings.Contains(err.Error(), budget.FileName+" cannot be checked with --fast") {
+		t.Errorf("Expected the local budget to be refused at startup, got %v", err)
+	}
+
+	// That of a remote repository is only found in the clone.
+	data := report.ReportData{Stats: &metrics.OverallStats{Fast: true}}
+	err = checkGates(opts, data, budget.Budget{budget.FunctionsOverThreshold: 3})
+	if err == nil || !strings.Contains(err.Error(), "cannot be checked with --fast") {
+		t.Errorf("Expected the budget to be refused in fast mode rather than pass at 0 functions, got %v", err)
+	}
+}
+
+func TestParseAnalyzeArgsIncludeUntrackedNeedsLocalRepo(t *testing.T) {
+	if _, err := parseAnalyzeArgs([]string{"--include-untracked", "https://github.com/user/repo.git"}); err == nil {
+		t.Errorf("Expected --include-untracked to be rejected for a remote URL")
+	}
+
+	local := t.TempDir()
+	opts, err := parseAnalyzeArgs([]string{"--include-untracked", local})
+	if err != nil {
+		t.Fatalf("parseAnalyzeArgs failed: %v", err)
+	}
+	if !opts.Untracked || opts.RepoURL != local {
+		t.Errorf("Expected untracked files of %s to be included, got %+v", local, opts)
+	}
+	if opts, _ := parseAnalyzeArgs([]string{local}); opts.Untracked {
+		t.Errorf("Expected untracked files to be excluded without the flag")
+	}
+}
+
+func TestParseAnalyzeArgsWorktree(t *testing.T) {
+	if _, err := parseAnalyzeArgs([]string{"--worktree", "https://github.com/user/repo.git"}); err == nil {
+		t.Errorf("Expected --worktree to be rejected for a remote URL")
+	}
+
+	local := t.TempDir()
+	opts, err := parseAnalyzeArgs([]string{"--worktree", "--include-untracked", local})
+	if err != nil {
+		t.Fatalf("parseAnalyzeArgs failed: %v", err)
+	}
+	if !opts.Worktree || !opts.Untracked || opts.Flags["worktree"] != "true" {
+		t.Errorf("Expected the worktree of %s to be analyzed with its untracked files, got %+v", local, opts)
+	}
+	if _, err := parseAnalyzeArgs([]string{"--worktree", "--branch", "main", local}); err == nil {
+		t.Errorf("Expected --worktree and --branch to be rejected together")
+	}
+}
+
+func TestParseAnalyzeArgsSubmodules(t *testing.T) {
+	opts, err := parseAnalyzeArgs([]string{"--submodules", "full", "--submodule-depth", "2", "https://github.com/user/repo.git"})
+	if err != nil {
+		t.Fatalf("parseAnalyzeArgs failed: %v", err)
+	}
+	if opts.Submodules != git.SubmodulesFull || opts.SubmoduleDepth != 2 {
+		t.Errorf("Expected full submodule analysis to depth 2, got %q to depth %d", opts.Submodules, opts.SubmoduleDepth)
+	}
+	if opts, err := parseAnalyzeArgs([]string{"https://github.com/user/repo.git"}); err != nil || opts.Submodules != git.SubmodulesNone || opts.SubmoduleDepth != git.DefaultSubmoduleDepth {
+		t.Errorf("Expected the submodules to be listed by default, got %q to depth %d, %v", opts.Submodules, opts.SubmoduleDepth, err)
+	}
+	for _, args := range [][]string{
+		{"--submodules", "recursive", "https://github.com/user/repo.git"},
+		{"--submodule-depth", "0", "https://github.com/user/repo.git"},
+		{"--submodules", "shallow", "hg::https://example.com/repo"},
+	} {
+		if _, err := parseAnalyzeArgs(args); err == nil || !strings.Contains(err.Error(), "--submodule") {
+			t.Errorf("Expected %v to be rejected, got %v", args, err)
+		}
+	}
+}
+
+func TestExcludePatternsFromFile(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "lib/lib.go", "package lib\n\n"+complexFunc("Handwritten", complexityThreshold+5))
+	writeFile(t, root, "gen/api.pb.go", "package gen\n\n"+complexFunc("Generated", complexityThreshold+5))
+	writeFile(t, root, "third_party/dep/dep.go", "package dep\n\n"+complexFunc("Dependency", complexityThreshold+5))
+	ignoreFile := filepath.Join(t.TempDir(), "zenwatch.ignore")
+	if err := os.WriteFile(ignoreFile, []byte("# generated code\n*.pb.go\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts, err := parseAnalyzeArgs([]string{"--exclude", "third_party/", "--ignore-from", ignoreFile, root})
+	if err != nil {
+		t.Fatalf("parseAnalyzeArgs failed: %v", err)
+	}
+	if patterns := opts.Rollup.Exclude.Patterns(); len(patterns) != 2 || patterns[0] != "third_party/" || patterns[1] != "*.pb.go" {
+		t.Errorf("Expected --exclude and --ignore-from patterns to be merged, got %q", patterns)
+	}
+
+	stats, err := zenwatch.AnalyzeTree(context.Background(), root, opts.Options)
+	if err != nil {
+		t.Fatalf("AnalyzeTree failed: %v", err)
+	}
+	if len(stats.ComplexityStats) != 1 || stats.ComplexityStats[0].FunctionName != "Handwritten" {
+		t.Errorf("Expected only the function of lib/lib.go to be reported, got %+v", stats.ComplexityStats)
+	}
+	if stats.DirectoryRollup.Files != 1 {
+		t.Errorf("Expected excluded files to be left out of the rollup, got %d files", stats.DirectoryRollup.Files)
+	}
+
+	if _, err := parseAnalyzeArgs([]string{"--ignore-from", filepath.Join(root, "missing"), root}); err == nil {
+		t.Errorf("Expected an error for a missing --ignore-from file")
+	}
+}
+
+func TestRunPluginCheck(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("Skipping TestRunPluginCheck: sh not on PATH")
+	}
+	root, dir := t.TempDir(), t.TempDir()
+	writeFile(t, root, "main.go", "package main\n")
+	good := filepath.Join(dir, "good.sh")
+	writeFile(t, dir, "good.sh", "#!/bin/sh\necho '{\"findings\": [{\"fingerprint\": \"main\", \"severity\": \"info\", \"message\": \"checked\", \"file\": \"main.go\", \"line\": 1}]}'\n")
+	bad := filepath.Join(dir, "bad.sh")
+	writeFile(t, dir, "bad.sh", "#!/bin/sh\necho '{\"findings\": [{\"fingerprint\": \"gone\", \"severity\": \"info\", \"message\": \"x\", \"file\": \"gone.go\"}]}'\n")
+	for _, p := range []string{good, bad} {
+		if err := os.Chmod(p, 0o755); err != nil {
+			t.Fatalf("Failed to make %s executable: %v", p, err)
+		}
+	}
+
+	var stdout, stderr strings.Builder
+	if err := runPlugin([]string{"check", good, root}, &stdout, &stderr); err != nil {
+		t.Fatalf("Expected the good plugin to conform, got %v", err)
+	}
+	if want := "info\tmain.go:1\tmain\tchecked\nPlugin good conforms to protocol 1: 1 finding(s)\n"; stdout.String() != want {
+		t.Errorf("Expected %q, got %q", want, stdout.String())
+	}
+	if err := runPlugin([]string{"check", bad, root}, &stdout, &stderr); err == nil || !strings.Contains(err.Error(), `file "gone.go" is not in the tree`) {
+		t.Errorf("Expected the bad plugin not to conform, got %v", err)
+	}
+	if _, err := parseAnalyzeArgs([]string{"--plugin", filepath.Join(dir, "missing"), "https://github.com/user/repo.git"}); err == nil {
+		t.Errorf("Expected a missing plugin to be refused before cloning")
+	}
+}
+
+func TestRunMetricsFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "lib/simple.go", "package lib\n\nfunc Simple() {}\n")
+	writeFile(t, root, "lib/complex.go", "package lib\n\n"+complexFunc("Complex", complexityThreshold+5))
+
+	var stdout, stderr strings.Builder
+	if err := runMetrics([]string{"--file", "lib/simple.go", root}, &stdout, &stderr); err != nil {
+		t.Fatalf("Expected no error for a simple file, got %v", err)
+	}
+	if !strings.Contains(stdout.String(), "lib/simple.go:3") {
+		t.Errorf("Expected Simple in the table, got:\n%s", stdout.String())
+	}
+
+	stdout.Reset()
+	err := runMetrics([]string{"--file", "lib/simple.go", "--file", "lib/missing.go", "--file", "lib/complex.go", root}, &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "1 function(s) are over the complexity threshold") {
+		t.Errorf("Expected the run to fail for the complex function, got %v", err)
+	}
+	if !strings.Contains(stderr.String(), "lib/missing.go") {
+		t.Errorf("Expected an error for the missing file, got:\n%s", stderr.String())
+	}
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[1], "OVER") || !strings.Contains(lines[1], "Complex") || !strings.Contains(lines[2], "Simple") {
+		t.Errorf("Expected the remaining files sorted by complexity, got:\n%s", stdout.String())
+	}
+
+	if err := runMetrics([]string{root}, &stdout, &stderr); err == nil {
+		t.Errorf("Expected a usage error without --file")
+	}
+}
+
+func TestRunBudgetUpdate(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "a.go", "package a\n\n"+complexFunc("A", complexityThreshold+5)+complexFunc("B", complexityThreshold+5))
+	path := filepath.Join(root, budget.FileName)
+
+	if err := runBudget([]string{"update", root}); err != nil {
+		t.Fatalf("runBudget failed: %v", err)
+	}
+	b, err := budget.Load(path)
+	if err != nil || b[budget.FunctionsOverThreshold] != 2 {
+		t.Fatalf("Expected a budget of 2 functions over threshold, got %v, %v", b, err)
+	}
+
+	// A regression does not raise the budget, an improvement lowers it.
+	writeFile(t, root, "c.go", "package a\n\n"+complexFunc("C", complexityThreshold+5))
+	if err := runBudget([]string{"update", root}); err != nil {
+		t.Fatalf("runBudget failed: %v", err)
+	}
+	if b, _ := budget.Load(path); b[budget.FunctionsOverThreshold] != 2 {
+		t.Errorf("Expected the budget to stay at 2, got %v", b)
+	}
+	if err := os.Remove(filepath.Join(root, "a.go")); err != nil {
+		t.Fatal(err)
+	}
+	if err := runBudget([]string{"update", root}); err != nil {
+		t.Fatalf("runBudget failed: %v", err)
+	}
+	if b, _ := budget.Load(path); b[budget.FunctionsOverThreshold] != 1 {
+		t.Errorf("Expected the budget to be lowered to 1, got %v", b)
+	}
+
+	if err := runBudget([]string{"raise", root}); err == nil {
+		t.Errorf("Expected an error for an unknown budget command")
+	}
+}
+
+func TestRunAnalyzePatch(t *testing.T) {
+	root := t.TempDir()
+	// The working tree after the patch: Grown got complex, Stable was not touched.
+	writeFile(t, root, "lib/lib.go", "package lib\n\nfunc Stable(x int) int { return x }\n\n"+complexFunc("Grown", complexityThreshold+5))
+	patch := "diff --git a/lib/lib.go b/lib/lib.go\n--- a/lib/lib.go\n+++ b/lib/lib.go\n" +
+		"@@ -5,1 +5,2 @@\n func Grown(x int) int {\n+\tif x > 0 {\n"
+
+	var stdout, stderr strings.Builder
+	err := runAnalyzePatch([]string{"--patch", "-", root}, strings.NewReader(patch), &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "1 touched function(s) are over the complexity threshold") {
+		t.Errorf("Expected the touched complex function to fail the run, got %v", err)
+	}
+	if !strings.Contains(stdout.String(), "Grown") || strings.Contains(stdout.String(), "Stable") {
+		t.Errorf("Expected only the touched function in the table, got:\n%s", stdout.String())
+	}
+
+	stdout.Reset()
+	docsOnly := "--- a/README.md\n+++ b/README.md\n@@ -1 +1 @@\n-old\n+new\n"
+	if err := runAnalyzePatch([]string{"--patch", "-", root}, strings.NewReader(docsOnly), &stdout, &stderr); err != nil {
+		t.Errorf("Expected a patch without Go files to pass, got %v", err)
+	}
+}
+
+func TestParseAnalyzeArgsVCS(t *testing.T) {
+	opts, err := parseAnalyzeArgs([]string{"hg::https://hg.example.com/repo"})
+	if err != nil {
+		t.Fatalf("parseAnalyzeArgs failed: %v", err)
+	}
+	if opts.VCS.Name() != vcs.NameMercurial {
+		t.Errorf("Expected an hg:: URL to select Mercurial, got %s", opts.VCS.Name())
+	}
+	if _, err := parseAnalyzeArgs([]string{"--vcs", "hg", "--trend", "5", "https://hg.example.com/repo"}); err == nil || !strings.Contains(err.Error(), "--trend") {
+		t.Errorf("Expected --trend to be rejected for Mercurial, got %v", err)
+	}
+	if _, err := parseAnalyzeArgs([]string{"--vcs", "svn", "https://example.com/repo"}); err == nil {
+		t.Errorf("Expected an unknown --vcs to be rejected")
+	}
+}
+
+func TestParseAnalyzeArgsBranch(t *testing.T) {
+	opts, err := parseAnalyzeArgs([]string{"--branch", "develop", "https://github.com/user/repo.git"})
+	if err != nil {
+		t.Fatalf("parseAnalyzeArgs failed: %v", err)
+	}
+	if opts.Branch != "develop" || opts.Flags["branch"] != "develop" {
+		t.Errorf("Expected a recorded develop branch, got %q and flags %v", opts.Branch, opts.Flags)
+	}
+	if _, err := parseAnalyzeArgs([]string{"--branch", "develop", "hg::https://hg.example.com/repo"}); err == nil || !strings.Contains(err.Error(), "--branch") {
+		t.Errorf("Expected --branch to be rejected for Mercurial, got %v", err)
+	}
+}
+
+func TestParseAnalyzeArgsRefactoringPlan(t *testing.T) {
+	opts, err := parseAnalyzeArgs([]string{"--refactoring-plan", "--plan-weights", "edits=1", "https://github.com/user/repo.git"})
+	if err != nil {
+		t.Fatalf("parseAnalyzeArgs failed: %v", err)
+	}
+	if !opts.RefactoringPlan || opts.PlanWeights.Edits != 1 || opts.PlanWeights.Complexity != metrics.DefaultPlanWeights().Complexity {
+		t.Errorf("Expected a plan with edits weighing 1, got %v %+v", opts.RefactoringPlan, opts.PlanWeights)
+	}
+	for _, tt := range []struct {
+		args []string
+		want string
+	}{
+		{[]string{"--plan-weights", "edits=1", "https://github.com/user/repo.git"}, "needs --refactoring-plan"},
+		{[]string{"--refactoring-plan", "--plan-weights", "size=1", "https://github.com/user/repo.git"}, "unknown plan signal"},
+		{[]string{"--refactoring-plan", "--fast", "https://github.com/user/repo.git"}, "--refactoring-plan cannot be combined with --fast"},
+		{[]string{"--refactoring-plan", "hg::https://hg.example.com/repo"}, "--refactoring-plan is only supported for git"},
+	} {
+		if _, err := parseAnalyzeArgs(tt.args); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("parseAnalyzeArgs(%v): expected an error containing %q, got %v", tt.args, tt.want, err)
+		}
+	}
+}
+
+func TestParseAnalyzeArgsCommit(t *testing.T) {
+	opts, err := parseAnalyzeArgs([]string{"--commit", "abc1234", "https://github.com/user/repo.git"})
+	if err != nil {
+		t.Fatalf("parseAnalyzeArgs failed: %v", err)
+	}
+	if opts.Commit != "abc1234" || opts.Flags["commit"] != "abc1234" {
+		t.Errorf("Expected a recorded commit, got %q and flags %v", opts.Commit, opts.Flags)
+	}
+	for _, args := range [][]string{
+		{"--commit", "abc1234", "--branch", "develop", "https://github.com/user/repo.git"},
+		{"--commit", "abc1234", "hg::https://hg.example.com/repo"},
+	} {
+		if _, err := parseAnalyzeArgs(args); err == nil || !strings.Contains(err.Error(), "--commit") {
+			t.Errorf("parseAnalyzeArgs(%v): expected --commit to be rejected, got %v", args, err)
+		}
+	}
+}
+
+func TestFlagError(t *testing.T) {
+	for _, tt := range []struct {
+		err  error
+		want string
+	}{
+		{&zenwatch.OptionError{Option: "Commit", With: "Branch"}, "--commit cannot be combined with --branch"},
+		{&zenwatch.OptionError{Option: "MaxFilesPerCommit", Reason: "is only supported for git repositories"}, "--max-files-per-commit is only supported for git repositories"},
+		{&zenwatch.OptionError{Option: "BannedImports", With: "Fast", Reason: "which skips the analysis it needs"}, "--banned-import cannot be combined with --fast, which skips the analysis it needs"},
+		{&zenwatch.OptionError{Option: "Untracked", Reason: "needs a local repository path, got x"}, "--include-untracked needs a local repository path, got x"},
+		{fmt.Errorf("wrapped: %w", &zenwatch.OptionError{Option: "CoverProfile", Reason: "is only supported for git repositories"}), "--coverprofile is only supported for git repositories"},
+		{errors.New("unknown emoji style"), "unknown emoji style"},
+	} {
+		if got := flagError(tt.err).Error(); got != tt.want {
+			t.Errorf("flagError(%v) = %q, expected %q", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestParseAnalyzeArgsDepth(t *testing.T) {
+	opts, err := parseAnalyzeArgs([]string{"https://github.com/user/repo.git"})
+	if err != nil {
+		t.Fatalf("parseAnalyzeArgs failed: %v", err)
+	}
+	if opts.Depth != 1 {
+		t.Errorf("Expected a default depth of 1, got %d", opts.Depth)
+	}
+	opts, err = parseAnalyzeArgs([]string{"--depth", "0", "https://github.com/user/repo.git"})
+	if err != nil {
+		t.Fatalf("parseAnalyzeArgs failed: %v", err)
+	}
+	if opts.Depth != 0 || opts.Flags["depth"] != "0" {
+		t.Errorf("Expected a recorded full clone, got depth %d and flags %v", opts.Depth, opts.Flags)
+	}
+	if _, err := parseAnalyzeArgs([]string{"--depth", "-1", "https://github.com/user/repo.git"}); err == nil || !strings.Contains(err.Error(), "--depth") {
+		t.Errorf("Expected a negative depth to be rejected, got %v", err)
+	}
+}
+
+func TestParseAnalyzeArgsAnonymizeAuthors(t *testing.T) {
+	opts, err := parseAnalyzeArgs([]string{"--anonymize-authors", "--redact", "paths", "https://github.com/user/repo.git"})
+	if err != nil {
+		t.Fatalf("parseAnalyzeArgs failed: %v", err)
+	}
+	if !opts.Redact.Authors || !opts.Redact.Paths {
+		t.Errorf("Expected authors and paths to be redacted, got %+v", opts.Redact)
+	}
+}
+
+func TestPublishGist(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		w.Write([]byte(`{"id": "abc123", "html_url": "https://gist.github.com/abc123"}`))
+	}))
+	defer server.Close()
+	client := &github.Client{BaseURL: server.URL, Token: "secret", HTTP: server.Client()}
+
+	dir := t.TempDir()
+	reportPath := filepath.Join(dir, "report.md")
+	writeFile(t, dir, "report.md", "# ZenWatch Analysis Report\n")
+	for i := 0; i < 2; i++ {
+		gist, err := publishGist(client, reportPath, "ZenWatch report")
+		if err != nil {
+			t.Fatalf("publishGist failed: %v", err)
+		}
+		if gist.HTMLURL != "https://gist.github.com/abc123" {
+			t.Errorf("Unexpected gist %+v", gist)
+		}
+	}
+	if want := "POST /gists,PATCH /gists/abc123"; strings.Join(requests, ",") != want {
+		t.Errorf("Expected the gist to be created, then updated: want %s, got %v", want, requests)
+	}
+	if id, err := os.ReadFile(reportPath + gistIDSuffix); err != nil || string(id) != "abc123\n" {
+		t.Errorf("Expected the gist id to be stored next to the report, got %q, %v", id, err)
+	}
+}
+
+func TestParseAnalyzeArgsMarkdownGistNeedsToken(t *testing.T) {
+	t.Setenv("GITHUB_TOKEN", "")
+	if _, err := parseAnalyzeArgs([]string{"--format", "markdown-gist", "https://github.com/user/repo.git"}); err == nil {
+		t.Errorf("Expected --format markdown-gist to be rejected without GITHUB_TOKEN")
+	}
+	t.Setenv("GITHUB_TOKEN", "secret")
+	if _, err := parseAnalyzeArgs([]string{"--format", "markdown-gist", "https://github.com/user/repo.git"}); err != nil {
+		t.Errorf("Expected --format markdown-gist to be accepted with GITHUB_TOKEN, got %v", err)
+	}
+}
+
+func TestParseAnalyzeArgsWorkerLimits(t *testing.T) {
+	opts, err := parseAnalyzeArgs([]string{"--concurrency", "2", "--max-memory", "512MiB", "https://github.com/user/repo.git"})
+	if err != nil {
+		t.Fatalf("parseAnalyzeArgs failed: %v", err)
+	}
+	if opts.Workers.Concurrency != 2 || opts.Workers.MaxMemory != 512<<20 {
+		t.Errorf("Expected 2 workers and a 512 MiB memory guard, got %+v", opts.Workers)
+	}
+	if opts.Flags["max-memory"] != "512MiB" {
+		t.Errorf("Expected the memory limit to be recorded as 512MiB, got %q", opts.Flags["max-memory"])
+	}
+	for _, args := range [][]string{
+		{"--concurrency", "-1", "https://github.com/user/repo.git"},
+		{"--max-memory", "1TB", "https://github.com/user/repo.git"},
+	} {
+		if _, err := parseAnalyzeArgs(args); err == nil {
+			t.Errorf("Expected %v to be rejected", args)
+		}
+	}
+}
+
+func TestWritePushgatewayReport(t *testing.T) {
+	var pushed []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		pushed = append(pushed, r.Method+" "+r.URL.Path+"\n"+string(body))
+	}))
+	defer server.Close()
+	client := &pushgateway.Client{URL: server.URL, HTTP: server.Client()}
+	opts := pushgatewayOptions{URL: server.URL, Job: "nightly", Instance: "https://github.com/user/repo"}
+
+	data := report.ReportData{
+		ComplexityThreshold: complexityThreshold,
+		Stats: &metrics.OverallStats{
+			TotalLinesAdded:        12,
+			TotalLinesDeleted:      3,
+			FunctionsOverThreshold: 4,
+			AverageComplexity:      18.5,
+			Build:                  &metrics.BuildStatus{Compiles: true, VetFindings: 2},
+		},
+		Warnings: []warning.Warning{{Code: warning.UnparseableFile, File: "broken.go"}},
+	}
+	outPath := filepath.Join(t.TempDir(), "reports", "metrics.prom")
+	if err := writePushgatewayReport(data, outPath, client, opts); err != nil {
+		t.Fatalf("writePushgatewayReport failed: %v", err)
+	}
+	if len(pushed) != 1 {
+		t.Fatalf("Expected one push, got %d", len(pushed))
+	}
+	if want := "PUT /metrics/job@base64/bmlnaHRseQ/instance@base64/aHR0cHM6Ly9naXRodWIuY29tL3VzZXIvcmVwbw\n"; !strings.HasPrefix(pushed[0], want) {
+		t.Errorf("Expected the push to start with %q, got %q", want, pushed[0])
+	}
+	for _, want := range []string{
+		"# TYPE zenwatch_functions_over_threshold gauge\nzenwatch_functions_over_threshold 4\n",
+		"zenwatch_average_complexity 18.5\n",
+		"zenwatch_lines_added 12\n",
+		"zenwatch_lines_deleted 3\n",
+		"zenwatch_build_compiles 1\n",
+		"zenwatch_vet_findings 2\n",
+		"zenwatch_warnings 1\n",
+	} {
+		if !strings.Contains(pushed[0], want) {
+			t.Errorf("Expected %q in the pushed metrics, got:\n%s", want, pushed[0])
+		}
+	}
+	if written, err := os.ReadFile(outPath); err != nil || !strings.HasSuffix(pushed[0], string(written)) {
+		t.Errorf("Expected the pushed metrics in %s, got %q, %v", outPath, written, err)
+	}
+
+	// A failed push is only a warning.
+	server.Close()
+	if err := writePushgatewayReport(data, outPath, client, opts); err != nil {
+		t.Errorf("Expected a failed push not to fail the run, got %v", err)
+	}
+}
+
+func TestParseAnalyzeArgsPushgateway(t *testing.T) {
+	t.Setenv("ZENWATCH_PUSHGATEWAY_URL", "")
+	if _, err := parseAnalyzeArgs([]string{"--format", "prometheus-pushgateway", "https://github.com/user/repo.git"}); err == nil {
+		t.Errorf("Expected --format prometheus-pushgateway to be rejected without a Pushgateway URL")
+	}
+	opts, err := parseAnalyzeArgs([]string{"--format", "prometheus-pushgateway", "--pushgateway-url", "http://pushgateway:9091", "https://token@github.com/user/repo.git"})
+	if err != nil {
+		t.Fatalf("parseAnalyzeArgs failed: %v", err)
+	}
+	if want := (pushgatewayOptions{URL: "http://pushgateway:9091", Job: "zenwatch", Instance: "https://github.com/user/repo.git"}); opts.Pushgateway != want {
+		t.Errorf("Expected %+v, got %+v", want, opts.Pushgateway)
+	}
+}
+
+func TestAnalyzeExitCode(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{errors.New("boom"), 1},
+		{&zenwatch.NoSourceError{Inventory: &metrics.SourceInventory{}}, exitNoSourceCode},
+		{fmt.Errorf("failed to get HEAD reference: %w", git.ErrEmptyRepository), exitNoSourceCode},
+		{fmt.Errorf("failed to clone repository: %w", git.ErrAuthFailed), exitRepoUnavailable},
+		{fmt.Errorf("failed to clone repository: %w", git.ErrHostKeyVerification), exitRepoUnavailable},
+		{fmt.Errorf("failed to clone repository: %w", git.ErrRepoNotFound), exitRepoUnavailable},
+		{fmt.Errorf("failed to get commit object: %w", git.ErrRefNotFound), exitRepoUnavailable},
+		{fmt.Errorf("failed to parse template: %w", report.ErrTemplateParse), exitReportFailed},
+		{fmt.Errorf("failed to write report file: %w", report.ErrOutputWrite), exitReportFailed},
+		{fmt.Errorf("%w: Cyclomatic Complexity failed", zenwatch.ErrPartialAnalysis), exitPartialAnalysis},
+	}
+	for _, tt := range tests {
+		if got := analyzeExitCode(tt.err); got != tt.want {
+			t.Errorf("analyzeExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
+
+// hasWarning reports whether warnings holds a warning with the given code.
+func hasWarning(warnings []warning.Warning, code warning.Code) bool {
+	for _, w := range warnings {
+		if w.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// runGit runs git in dir as a fixed author.
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_AUTHOR_DATE=2024-06-01T12:00:00Z", "GIT_COMMITTER_NAME=Test",
+		"GIT_COMMITTER_EMAIL=test@example.com", "GIT_COMMITTER_DATE=2024-06-01T12:00:00Z")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, out)
+	}
+}
+
+// assertDeterministic runs analyze over repo twice for each format, with the clock fixed,
+// and fails unless both runs write byte-identical output.
+func assertDeterministic(t *testing.T, repo string, formats ...string) {
+	t.Helper()
+	defer func(clock func() time.Time) { now = clock }(now)
+	now = func() time.Time { return time.Date(2024, 6, 2, 9, 30, 0, 0, time.UTC) }
+
+	out := filepath.Join(t.TempDir(), "report")
+	for _, format := range formats {
+		var outputs [2][]byte
+		for i := range outputs {
+			opts, err := parseAnalyzeArgs([]string{"--out", out, "--format", format, "--include-tests", "--rollup-min-sloc", "0", repo})
+			if err != nil {
+				t.Fatalf("parseAnalyzeArgs failed: %v", err)
+			}
+			if err := runAnalyze(opts); err != nil {
+				t.Fatalf("analyze with --format %s failed: %v", format, err)
+			}
+			if outputs[i], err = os.ReadFile(out); err != nil {
+				t.Fatalf("Failed to read the %s output: %v", format, err)
+			}
+		}
+		if !bytes.Equal(outputs[0], outputs[1]) {
+			t.Errorf("Expected identical %s output from identical input, got:\n%s\n--- and ---\n%s", format, outputs[0], outputs[1])
+		}
+	}
+}
+
+// deterministicFixture returns a repository with functions of equal complexity, packages
+// of equal size, files the analyses warn about, and more files than the parser has workers,
+// to catch any order that depends on map iteration or on which goroutine finishes first.
+func deterministicFixture(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping determinism tests: git not on PATH")
+	}
+	repo := t.TempDir()
+	for i := range 12 {
+		pkg := fmt.Sprintf("pkg%02d", i%4)
+		writeFile(t, repo, fmt.Sprintf("%s/file%02d.go", pkg, i),
+			"package "+pkg+"\n\n"+complexFunc(fmt.Sprintf("Func%02d", i), complexityThreshold+1+i%2))
+	}
+	writeFile(t, repo, "pkg00/file_test.go", "package pkg00\n\n"+complexFunc("TestTie", complexityThreshold+1))
+	writeFile(t, repo, "broken/a.go", "package broken\n\nfunc {\n")
+	writeFile(t, repo, "broken/b.go", "package broken\n\nfunc {\n")
+	writeFile(t, repo, "docs/guide.md", "# Guide\n")
+	writeFile(t, repo, "docs/notes.md", "# Notes\n")
+	runGit(t, repo, "init", "-q")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "-q", "-m", "Add fixture")
+	return repo
+}
+
+func TestAnalyzeIsDeterministic(t *testing.T) {
+	repo := deterministicFixture(t)
+	assertDeterministic(t, repo, report.FormatMarkdown, report.FormatJSON, report.FormatHeatmapJSON, report.FormatTreemapJSON)
+}
+
+func TestRunSelfcheck(t *testing.T) {
+	repo := deterministicFixture(t)
+	var stdout, stderr bytes.Buffer
+	args := []string{"--include-tests", "--rollup-min-sloc", "0", "--redact", "authors,paths", repo}
+	if err := runSelfcheck(args, &stdout, &stderr); err != nil {
+		t.Fatalf("selfcheck failed: %v\n%s", err, stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "produced identical reports") {
+		t.Errorf("Expected the reports to be identical, got:\n%s", stdout.String())
+	}
+
+	// A commit landing between the runs fails the check rather than being compared.
+	out, err := exec.Command("git", "-C", repo, "rev-parse", "HEAD").Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	head := strings.TrimSpace(string(out))
+	selfcheckPause = func() {
+		writeFile(t, repo, "pkg00/late.go", "package pkg00\n\n"+complexFunc("Late", complexityThreshold+3))
+		runGit(t, repo, "add", ".")
+		runGit(t, repo, "commit", "-q", "-m", "Land a commit between the runs")
+	}
+	defer func() { selfcheckPause = func() {} }()
+	stdout.Reset()
+	err = runSelfcheck(args, &stdout, &stderr)
+	if err == nil || !strings.Contains(err.Error(), "run 2") || !strings.Contains(err.Error(), "pinned commit "+head) {
+		t.Errorf("Expected the second run to fail on the moved branch, got %v\n%s", err, stdout.String())
+	}
+
+	if err := runSelfcheck([]string{"--include-tests"}, &stdout, &stderr); err == nil || !strings.Contains(err.Error(), "zenwatch selfcheck") {
+		t.Errorf("Expected the selfcheck usage without a repository, got %v", err)
+	}
+}
+
+func TestDiffJSON(t *testing.T) {
+	decode := func(s string) any {
+		var v any
+		if err := json.Unmarshal([]byte(s), &v); err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	a := decode(`{"reportDate": "1", "stats": {"complexityStats": [{"line": 3}, {"line": 9}], "run": {"files": 2}}, "branch": "main"}`)
+	b := decode(`{"reportDate": "2", "stats": {"complexityStats": [{"line": 3}, {"line": 8}], "run": {"files": 2}}, "warnings": []}`)
+	var diffs []string
+	diffJSON("", a, b, &diffs)
+	want := []string{
+		`branch: "main" != null`,
+		`reportDate: "1" != "2"`,
+		`stats.complexityStats[1].line: 9 != 8`,
+		`warnings: null != []`,
+	}
+	if !slices.Equal(diffs, want) {
+		t.Errorf("Expected\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(diffs, "\n"))
+	}
+}
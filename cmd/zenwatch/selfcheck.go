@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user/zenwatch/internal/report"
+	"github.com/user/zenwatch/pkg/zenwatch"
+)
+
+// selfcheckDate is the fixed clock of both runs of selfcheck, so the report dates cannot differ.
+var selfcheckDate = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// selfcheckRedactKey replaces the random redaction key, so pseudonyms and path hashes match.
+var selfcheckRedactKey = []byte("zenwatch selfcheck")
+
+// maxSelfcheckDiffs is the number of differing fields selfcheck lists.
+const maxSelfcheckDiffs = 20
+
+// clonePlaceholder replaces the path of the clone of a run in its report, as the clones of
+// two runs never share a path.
+const clonePlaceholder = "$CLONE"
+
+// selfcheckPause runs between the two compared runs of selfcheck. Tests replace it to move the
+// branch.
+var selfcheckPause = func() {}
+
+// runSelfcheck runs the selfcheck subcommand: it analyzes the repository twice with the
+// analyze flags given, with the clock and the redaction key fixed. It fails listing the fields
+// of the two json reports that differ, if any; every one of them is a source of
+// nondeterminism.
+func runSelfcheck(args []string, stdout, stderr io.Writer) error {
+	opts, err := parseAnalyzeArgs(args)
+	if errors.Is(err, errUsage) {
+		return errors.New("usage: zenwatch selfcheck [<analyze flags>] <repo-url>")
+	}
+	if err != nil {
+		return err
+	}
+	opts.Redact.Key = selfcheckRedactKey
+	client := &zenwatch.Client{Now: func() time.Time { return selfcheckDate }, ErrorLog: stderr}
+	target := zenwatch.Target{URL: opts.RepoURL, VCS: opts.VCS.Name()}
+
+	// Both runs keep the branch and depth given. The second is pinned to the commit of the
+	// first, so it fails rather than compare two commits if the branch moves meanwhile.
+	var reports [2]any
+	for i := range reports {
+		if i > 0 {
+			selfcheckPause()
+		}
+		result, err := client.Analyze(context.Background(), target, opts.Options)
+		if err != nil {
+			return fmt.Errorf("run %d: %w", i+1, err)
+		}
+		opts.PinnedCommit = result.Repository.LatestCommit.Hash
+		if reports[i], err = comparableReport(result); err != nil {
+			return fmt.Errorf("run %d: %w", i+1, err)
+		}
+	}
+
+	var diffs []string
+	diffJSON("", reports[0], reports[1], &diffs)
+	commit := opts.PinnedCommit[:min(len(opts.PinnedCommit), 7)]
+	if len(diffs) == 0 {
+		fmt.Fprintf(stdout, "Two runs over commit %s produced identical reports\n", commit)
+		return nil
+	}
+	for i, d := range diffs {
+		if i == maxSelfcheckDiffs {
+			fmt.Fprintf(stdout, "... and %d more\n", len(diffs)-i)
+			break
+		}
+		fmt.Fprintln(stdout, d)
+	}
+	return fmt.Errorf("%d field(s) of the reports of two runs over commit %s differ", len(diffs), commit)
+}
+
+// comparableReport returns the json report of result decoded into maps and slices, with the
+// path of its clone replaced by clonePlaceholder.
+func comparableReport(result *zenwatch.Result) (any, error) {
+	var buf bytes.Buffer
+	if err := result.Render(report.FormatJSON, &buf); err != nil {
+		return nil, err
+	}
+	content := buf.Bytes()
+	if clone := result.Repository.TempPath; clone != "" {
+		// The path is replaced as it is escaped in JSON strings.
+		escaped, err := json.Marshal(clone)
+		if err != nil {
+			return nil, err
+		}
+		content = bytes.ReplaceAll(content, bytes.Trim(escaped, `"`), []byte(clonePlaceholder))
+	}
+	var decoded any
+	if err := json.Unmarshal(content, &decoded); err != nil {
+		return nil, fmt.Errorf("failed to decode JSON report: %w", err)
+	}
+	return decoded, nil
+}
+
+// diffJSON appends to diffs a line for every value that differs between the decoded JSON
+// values a and b, named by its path from the root, e.g. "stats.complexityStats[2].line".
+// Object keys are visited in order, so the lines are too.
+func diffJSON(path string, a, b any, diffs *[]string) {
+	switch a := a.(type) {
+	case map[string]any:
+		if b, ok := b.(map[string]any); ok {
+			keys := make([]string, 0, len(a)+len(b))
+			for k := range a {
+				keys = append(keys, k)
+			}
+			for k := range b {
+				if _, ok := a[k]; !ok {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				diffJSON(strings.TrimPrefix(path+"."+k, "."), a[k], b[k], diffs)
+			}
+			return
+		}
+	case []any:
+		if b, ok := b.([]any); ok {
+			if len(a) != len(b) {
+				*diffs = append(*diffs, fmt.Sprintf("%s: %d element(s) != %d element(s)", path, len(a), len(b)))
+				return
+			}
+			for i := range a {
+				diffJSON(fmt.Sprintf("%s[%d]", path, i), a[i], b[i], diffs)
+			}
+			return
+		}
+	}
+	if !reflect.DeepEqual(a, b) {
+		*diffs = append(*diffs, fmt.Sprintf("%s: %s != %s", path, jsonValue(a), jsonValue(b)))
+	}
+}
+
+// jsonValue renders a decoded JSON value for a diff line; a missing value renders as null.
+func jsonValue(v any) string {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(content)
+}
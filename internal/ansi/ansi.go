@@ -0,0 +1,53 @@
+// Package ansi styles terminal output with ANSI escape sequences.
+package ansi
+
+import (
+	"os"
+	"regexp"
+)
+
+const reset = "\x1b[0m"
+
+// Styler wraps text in ANSI styles. The zero value leaves text unstyled.
+type Styler struct {
+	Color bool // Emit escape sequences; see ColorEnabled
+}
+
+func (s Styler) style(code, text string) string {
+	if !s.Color || text == "" {
+		return text
+	}
+	return "\x1b[" + code + "m" + text + reset
+}
+
+// Bold, Dim, Red, Green and Yellow return text in their style.
+func (s Styler) Bold(text string) string   { return s.style("1", text) }
+func (s Styler) Dim(text string) string    { return s.style("2", text) }
+func (s Styler) Red(text string) string    { return s.style("31", text) }
+func (s Styler) Green(text string) string  { return s.style("32", text) }
+func (s Styler) Yellow(text string) string { return s.style("33", text) }
+
+// escapeSequence matches the SGR sequences a Styler emits.
+var escapeSequence = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// Strip removes the styling from text.
+func Strip(text string) string {
+	return escapeSequence.ReplaceAllString(text, "")
+}
+
+// IsTerminal reports whether f is a character device such as a terminal, rather than a
+// file or pipe.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// ColorEnabled reports whether output to f should be colored: f must be a terminal, and
+// color is off whenever NO_COLOR is set to a non-empty value (https://no-color.org) or
+// TERM is "dumb".
+func ColorEnabled(f *os.File) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return IsTerminal(f)
+}
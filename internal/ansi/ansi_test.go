@@ -0,0 +1,49 @@
+package ansi
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStrip(t *testing.T) {
+	s := Styler{Color: true}
+	styled := s.Bold("Verdict:") + " " + s.Red("FAIL") + " " + s.Dim(s.Green("nested"))
+	if styled == "Verdict: FAIL nested" {
+		t.Fatalf("Expected escape sequences in %q", styled)
+	}
+	if got := Strip(styled); got != "Verdict: FAIL nested" {
+		t.Errorf("Expected styling to be stripped, got %q", got)
+	}
+	// Other escape characters and brackets are kept.
+	if got := Strip("[x] \x1b[31;1mred\x1b[0m 50%"); got != "[x] red 50%" {
+		t.Errorf("Expected only SGR sequences to be stripped, got %q", got)
+	}
+}
+
+func TestStylerWithoutColor(t *testing.T) {
+	var s Styler
+	if got := s.Red(s.Bold("plain")); got != "plain" {
+		t.Errorf("Expected the zero Styler to leave text unstyled, got %q", got)
+	}
+}
+
+func TestColorEnabled(t *testing.T) {
+	f, err := os.Create(filepath.Join(t.TempDir(), "out.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	t.Setenv("NO_COLOR", "")
+	if ColorEnabled(f) {
+		t.Errorf("Expected no color for a regular file")
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if tty, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0); err == nil {
+		defer tty.Close()
+		if ColorEnabled(tty) {
+			t.Errorf("Expected NO_COLOR to disable color on a terminal")
+		}
+	}
+}
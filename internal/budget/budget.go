@@ -0,0 +1,111 @@
+// Package budget implements ratcheting metric budgets: a committed file records the highest
+// allowed value of each metric, runs fail only if they exceed it, and improving runs lower it.
+package budget
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FileName is the name of the budget file, read from the root of the analyzed tree.
+const FileName = "zenwatch.budget.json"
+
+// Metric names recorded in budget files. Lower values are better for all of them.
+const (
+	FunctionsOverThreshold = "functions-over-threshold"
+	AverageComplexity      = "avg-complexity"
+)
+
+// Budget maps metric names to their highest allowed value.
+type Budget map[string]float64
+
+// Load reads the budget file at path. A missing file yields a nil budget and no error,
+// in which case only the regular thresholds apply.
+func Load(path string) (Budget, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read budget %s: %w", path, err)
+	}
+	var b Budget
+	if err := json.Unmarshal(content, &b); err != nil {
+		return nil, fmt.Errorf("failed to parse budget %s: %w", path, err)
+	}
+	return b, nil
+}
+
+// Write stores the budget as indented JSON at path.
+func Write(path string, b Budget) error {
+	content, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode budget: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create budget directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(content, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write budget %s: %w", path, err)
+	}
+	return nil
+}
+
+// Check returns an error listing every budgeted metric whose actual value exceeds its budget.
+// Metrics without a budget and budgets without an actual value are ignored.
+func (b Budget) Check(actual map[string]float64) error {
+	var exceeded []string
+	for _, name := range sortedNames(b) {
+		value, ok := actual[name]
+		if ok && value > b[name]+1e-9 {
+			exceeded = append(exceeded, fmt.Sprintf("%s is %s, budget %s", name, formatValue(value), formatValue(b[name])))
+		}
+	}
+	if len(exceeded) > 0 {
+		return fmt.Errorf("over budget: %s", strings.Join(exceeded, "; "))
+	}
+	return nil
+}
+
+// Ratchet returns the budget lowered to the actual values where they improved on it, and the
+// names of the lowered metrics. A budget is never raised. Metrics not yet in the budget are
+// added at their actual value, so a missing budget is initialized from the run.
+// Values are rounded up to two decimals so that the same run stays within the new budget.
+func (b Budget) Ratchet(actual map[string]float64) (Budget, []string) {
+	ratcheted := make(Budget, len(actual))
+	for name, limit := range b {
+		ratcheted[name] = limit
+	}
+	var lowered []string
+	for _, name := range sortedNames(actual) {
+		value := math.Ceil(actual[name]*100) / 100
+		limit, ok := b[name]
+		switch {
+		case !ok:
+			ratcheted[name] = value
+		case value < limit:
+			ratcheted[name] = value
+			lowered = append(lowered, name)
+		}
+	}
+	return ratcheted, lowered
+}
+
+func sortedNames(m map[string]float64) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func formatValue(v float64) string {
+	return fmt.Sprintf("%g", math.Round(v*100)/100)
+}
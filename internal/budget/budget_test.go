@@ -0,0 +1,102 @@
+package budget
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRatchetNeverLoosens(t *testing.T) {
+	b := Budget{FunctionsOverThreshold: 37, AverageComplexity: 11.2}
+
+	// A worse run leaves the budget unchanged.
+	ratcheted, lowered := b.Ratchet(map[string]float64{FunctionsOverThreshold: 40, AverageComplexity: 12.5})
+	if !reflect.DeepEqual(ratcheted, b) || len(lowered) != 0 {
+		t.Errorf("Expected a worse run not to raise the budget, got %v (lowered %v)", ratcheted, lowered)
+	}
+
+	// A mixed run only lowers the improved metric.
+	ratcheted, lowered = b.Ratchet(map[string]float64{FunctionsOverThreshold: 35, AverageComplexity: 11.9})
+	want := Budget{FunctionsOverThreshold: 35, AverageComplexity: 11.2}
+	if !reflect.DeepEqual(ratcheted, want) || !reflect.DeepEqual(lowered, []string{FunctionsOverThreshold}) {
+		t.Errorf("Expected %v with %s lowered, got %v (lowered %v)", want, FunctionsOverThreshold, ratcheted, lowered)
+	}
+	if b[FunctionsOverThreshold] != 37 {
+		t.Errorf("Ratchet modified its receiver")
+	}
+
+	// Values are rounded up, so the run that lowered the budget still passes it.
+	actual := map[string]float64{AverageComplexity: 10.123}
+	ratcheted, _ = b.Ratchet(actual)
+	if ratcheted[AverageComplexity] != 10.13 {
+		t.Errorf("Expected avg-complexity to be rounded up to 10.13, got %v", ratcheted[AverageComplexity])
+	}
+	if err := ratcheted.Check(actual); err != nil {
+		t.Errorf("Expected the ratcheting run to pass the new budget, got %v", err)
+	}
+
+	// Ratcheting repeatedly with arbitrary runs never raises any value.
+	current := b
+	for _, run := range []map[string]float64{
+		{FunctionsOverThreshold: 50, AverageComplexity: 9},
+		{FunctionsOverThreshold: 30, AverageComplexity: 20},
+		{FunctionsOverThreshold: 45, AverageComplexity: 15},
+	} {
+		next, _ := current.Ratchet(run)
+		for name, limit := range current {
+			if next[name] > limit {
+				t.Errorf("Budget for %s loosened from %v to %v", name, limit, next[name])
+			}
+		}
+		current = next
+	}
+	if want := (Budget{FunctionsOverThreshold: 30, AverageComplexity: 9}); !reflect.DeepEqual(current, want) {
+		t.Errorf("Expected %v after ratcheting, got %v", want, current)
+	}
+}
+
+func TestRatchetInitializesMissingMetrics(t *testing.T) {
+	var missing Budget
+	ratcheted, lowered := missing.Ratchet(map[string]float64{FunctionsOverThreshold: 3, AverageComplexity: 1})
+	if want := (Budget{FunctionsOverThreshold: 3, AverageComplexity: 1}); !reflect.DeepEqual(ratcheted, want) || len(lowered) != 0 {
+		t.Errorf("Expected a new budget %v, got %v (lowered %v)", want, ratcheted, lowered)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	b := Budget{FunctionsOverThreshold: 37, AverageComplexity: 11.2}
+	if err := b.Check(map[string]float64{FunctionsOverThreshold: 37, AverageComplexity: 11.2, "warnings": 99}); err != nil {
+		t.Errorf("Expected values at the budget and unbudgeted metrics to pass, got %v", err)
+	}
+	err := b.Check(map[string]float64{FunctionsOverThreshold: 38, AverageComplexity: 11.25})
+	if err == nil || !strings.Contains(err.Error(), "functions-over-threshold is 38, budget 37") || !strings.Contains(err.Error(), "avg-complexity is 11.25, budget 11.2") {
+		t.Errorf("Expected both metrics to be over budget, got %v", err)
+	}
+}
+
+func TestLoadMissingBudget(t *testing.T) {
+	b, err := Load(filepath.Join(t.TempDir(), FileName))
+	if err != nil || b != nil {
+		t.Fatalf("Expected no budget and no error for a missing file, got %v, %v", b, err)
+	}
+	// Without a budget, every run passes the budget gate and only the regular thresholds apply.
+	if err := b.Check(map[string]float64{FunctionsOverThreshold: 1000}); err != nil {
+		t.Errorf("Expected a missing budget to pass, got %v", err)
+	}
+}
+
+func TestWriteLoadRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), FileName)
+	original := Budget{FunctionsOverThreshold: 37, AverageComplexity: 11.2}
+	if err := Write(path, original); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if !reflect.DeepEqual(original, loaded) {
+		t.Errorf("Round trip mismatch: wrote %v, read %v", original, loaded)
+	}
+}
@@ -0,0 +1,197 @@
+// Package commitlint checks commit messages against a contributing guide's rules: subject
+// length and capitalization, a blank line before the body, body wrapping, a required
+// reference such as an issue key and forbidden work-in-progress markers.
+package commitlint
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/user/zenwatch/internal/git"
+)
+
+// FileName is the name of the rules file, read from the root of the analyzed tree.
+const FileName = "zenwatch.commitlint.json"
+
+// Rule names, used in findings and to disable rules.
+const (
+	RuleSubjectLength      = "subject-length"
+	RuleSubjectCapitalized = "subject-capitalized"
+	RuleBlankLine          = "blank-line"
+	RuleBodyWrap           = "body-wrap"
+	RuleRequiredPattern    = "required-pattern"
+	RuleForbiddenMarker    = "forbidden-marker"
+)
+
+// Severity is the weight of a finding. Only errors count towards the commit-lint gate.
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// ruleSeverities maps every rule to the severity of its findings.
+var ruleSeverities = map[string]Severity{
+	RuleSubjectLength:      SeverityError,
+	RuleSubjectCapitalized: SeverityWarning,
+	RuleBlankLine:          SeverityError,
+	RuleBodyWrap:           SeverityWarning,
+	RuleRequiredPattern:    SeverityError,
+	RuleForbiddenMarker:    SeverityError,
+}
+
+// Config holds the rules. Fields missing from the rules file keep their default.
+type Config struct {
+	SubjectMaxLength  int      `json:"subjectMaxLength"`  // Longest allowed subject line, in characters
+	BodyMaxLineLength int      `json:"bodyMaxLineLength"` // Longest allowed body line, in characters
+	RequiredPattern   string   `json:"requiredPattern"`   // Regular expression the message must match, e.g. "JIRA-\\d+"; empty requires nothing
+	ForbiddenMarkers  []string `json:"forbiddenMarkers"`  // Case-insensitive words the subject must not contain, e.g. "WIP"
+	Disable           []string `json:"disable"`           // Names of rules not to check
+}
+
+// DefaultConfig returns the rules used without a rules file.
+func DefaultConfig() Config {
+	return Config{
+		SubjectMaxLength:  72,
+		BodyMaxLineLength: 72,
+		ForbiddenMarkers:  []string{"WIP", "fixup!", "squash!"},
+	}
+}
+
+// Load reads the rules file at path over the defaults. A missing file yields the defaults.
+func Load(path string) (Config, error) {
+	config := DefaultConfig()
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return config, nil
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read commit lint rules %s: %w", path, err)
+	}
+	if err := json.Unmarshal(content, &config); err != nil {
+		return Config{}, fmt.Errorf("failed to parse commit lint rules %s: %w", path, err)
+	}
+	if err := config.Validate(); err != nil {
+		return Config{}, fmt.Errorf("invalid commit lint rules %s: %w", path, err)
+	}
+	return config, nil
+}
+
+// Validate checks that the lengths are positive, the pattern compiles and the disabled rules exist.
+func (c Config) Validate() error {
+	if c.SubjectMaxLength < 1 || c.BodyMaxLineLength < 1 {
+		return fmt.Errorf("subjectMaxLength and bodyMaxLineLength must be at least 1, got %d and %d", c.SubjectMaxLength, c.BodyMaxLineLength)
+	}
+	if _, err := regexp.Compile(c.RequiredPattern); err != nil {
+		return fmt.Errorf("invalid requiredPattern: %w", err)
+	}
+	for _, rule := range c.Disable {
+		if _, ok := ruleSeverities[rule]; !ok {
+			return fmt.Errorf("unknown rule %q in disable", rule)
+		}
+	}
+	return nil
+}
+
+// Finding is a rule a commit message breaks.
+type Finding struct {
+	Commit   string   `json:"commit"` // Hash of the commit
+	Rule     string   `json:"rule"`   // One of the Rule* constants
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"` // What is wrong, e.g. "subject is 80 characters long, limit 72"
+}
+
+// Lint checks the full message of every commit and returns the findings in commit order.
+// The config must be valid.
+func (c Config) Lint(commits []git.CommitInfo) []Finding {
+	required := regexp.MustCompile(c.RequiredPattern)
+	var findings []Finding
+	for _, commit := range commits {
+		add := func(rule, format string, args ...any) {
+			if !c.disabled(rule) {
+				findings = append(findings, Finding{Commit: commit.Hash, Rule: rule, Severity: ruleSeverities[rule], Message: fmt.Sprintf(format, args...)})
+			}
+		}
+		message := commit.FullMessage
+		if message == "" {
+			message = commit.Message
+		}
+		lines := strings.Split(message, "\n")
+		subject := lines[0]
+
+		if n := utf8.RuneCountInString(subject); n > c.SubjectMaxLength {
+			add(RuleSubjectLength, "subject is %d characters long, limit %d", n, c.SubjectMaxLength)
+		}
+		if first, _ := utf8.DecodeRuneInString(subject); unicode.IsLower(first) {
+			add(RuleSubjectCapitalized, "subject does not start with a capital letter")
+		}
+		if len(lines) > 1 && strings.TrimSpace(lines[1]) != "" {
+			add(RuleBlankLine, "second line is not blank")
+		}
+		for i, line := range lines[1:] {
+			if n := utf8.RuneCountInString(line); n > c.BodyMaxLineLength {
+				add(RuleBodyWrap, "body line %d is %d characters long, limit %d", i+2, n, c.BodyMaxLineLength)
+				break
+			}
+		}
+		if c.RequiredPattern != "" && !required.MatchString(message) {
+			add(RuleRequiredPattern, "message does not match %s", c.RequiredPattern)
+		}
+		for _, marker := range c.ForbiddenMarkers {
+			if containsWord(subject, marker) {
+				add(RuleForbiddenMarker, "subject contains %s", marker)
+			}
+		}
+	}
+	return findings
+}
+
+// Errors counts the findings of error severity.
+func Errors(findings []Finding) int {
+	n := 0
+	for _, f := range findings {
+		if f.Severity == SeverityError {
+			n++
+		}
+	}
+	return n
+}
+
+func (c Config) disabled(rule string) bool {
+	return slices.Contains(c.Disable, rule)
+}
+
+// containsWord reports whether s contains marker, ignoring case, not as part of a longer
+// word: "WIP" matches "WIP: parser" but not "Wipe cache".
+func containsWord(s, marker string) bool {
+	lower, marker := strings.ToLower(s), strings.ToLower(marker)
+	for start := 0; ; {
+		i := strings.Index(lower[start:], marker)
+		if i < 0 {
+			return false
+		}
+		i += start
+		end := i + len(marker)
+		if !isWordByte(lower, i-1) && !isWordByte(lower, end) {
+			return true
+		}
+		start = i + 1
+	}
+}
+
+// isWordByte reports whether s[i] exists and is a letter, digit or underscore.
+func isWordByte(s string, i int) bool {
+	if i < 0 || i >= len(s) {
+		return false
+	}
+	b := s[i]
+	return b == '_' || b >= '0' && b <= '9' || b >= 'a' && b <= 'z'
+}
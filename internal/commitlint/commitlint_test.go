@@ -0,0 +1,94 @@
+package commitlint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/user/zenwatch/internal/git"
+)
+
+func rules(findings []Finding) []string {
+	var names []string
+	for _, f := range findings {
+		names = append(names, f.Rule)
+	}
+	return names
+}
+
+func TestLint(t *testing.T) {
+	config := DefaultConfig()
+	config.RequiredPattern = `JIRA-\d+`
+	tests := []struct {
+		name    string
+		message string
+		want    []string
+	}{
+		{"clean", "Fix parser crash on empty input\n\nRefs JIRA-12", nil},
+		{"long subject", strings.Repeat("A", 73) + " JIRA-1", []string{RuleSubjectLength}},
+		{"lowercase", "fix parser JIRA-1", []string{RuleSubjectCapitalized}},
+		{"no blank line", "Fix parser JIRA-1\nmore details", []string{RuleBlankLine}},
+		{"long body line", "Fix parser JIRA-1\n\n" + strings.Repeat("x", 73), []string{RuleBodyWrap}},
+		{"no reference", "Fix parser", []string{RuleRequiredPattern}},
+		{"wip", "WIP: parser JIRA-1", []string{RuleForbiddenMarker}},
+		{"fixup", "fixup! Fix parser JIRA-1", []string{RuleSubjectCapitalized, RuleForbiddenMarker}},
+		{"marker inside word", "Wipe cache JIRA-1", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			findings := config.Lint([]git.CommitInfo{{Hash: "abc", FullMessage: tt.message}})
+			if got := rules(findings); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("Expected rules %v, got %v (%+v)", tt.want, got, findings)
+			}
+			for _, f := range findings {
+				if f.Commit != "abc" || f.Severity != ruleSeverities[f.Rule] {
+					t.Errorf("Unexpected finding %+v", f)
+				}
+			}
+		})
+	}
+}
+
+func TestLintDisable(t *testing.T) {
+	config := DefaultConfig()
+	config.Disable = []string{RuleSubjectCapitalized, RuleForbiddenMarker}
+	if findings := config.Lint([]git.CommitInfo{{Message: "wip parser"}}); len(findings) != 0 {
+		t.Errorf("Expected disabled rules to be skipped, got %+v", findings)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	findings := DefaultConfig().Lint([]git.CommitInfo{{FullMessage: "fix parser\nno blank line"}})
+	if got := Errors(findings); got != 1 {
+		t.Errorf("Expected only the blank-line finding to be an error, got %d in %+v", got, findings)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	config, err := Load(filepath.Join(dir, FileName))
+	if err != nil {
+		t.Fatalf("Load failed for a missing file: %v", err)
+	}
+	if config.SubjectMaxLength != 72 || len(config.ForbiddenMarkers) != 3 {
+		t.Errorf("Expected the defaults without a rules file, got %+v", config)
+	}
+
+	path := filepath.Join(dir, FileName)
+	os.WriteFile(path, []byte(`{"subjectMaxLength": 50, "requiredPattern": "JIRA-\\d+", "disable": ["body-wrap"]}`), 0o644)
+	config, err = Load(path)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if config.SubjectMaxLength != 50 || config.BodyMaxLineLength != 72 || config.RequiredPattern != `JIRA-\d+` || len(config.Disable) != 1 {
+		t.Errorf("Expected the file to override the defaults, got %+v", config)
+	}
+
+	for _, content := range []string{`{"requiredPattern": "("}`, `{"disable": ["no-such-rule"]}`, `{"subjectMaxLength": 0}`, `not json`} {
+		os.WriteFile(path, []byte(content), 0o644)
+		if _, err := Load(path); err == nil {
+			t.Errorf("Expected %s to be rejected", content)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Package filelock serializes the writers of a file shared by several zenwatch processes, such
+// as CI jobs of one runner sharing a cache, with a lock file next to it.
+//
+// A lock file holds the PID of its holder and the time it was taken. A lock older than
+// Options.Stale is taken to be left by a process that died and is taken over. Locking is
+// advisory and best effort: writers still replace shared files atomically, so a lock taken
+// over too early can lose an update but never tear a file.
+package filelock
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Defaults of Options.
+const (
+	DefaultStale = 30 * time.Second
+	DefaultWait  = time.Minute
+)
+
+// pollInterval is how often a held lock is checked again.
+const pollInterval = 20 * time.Millisecond
+
+// Options configure Acquire.
+type Options struct {
+	Stale time.Duration // Age past which a lock is taken over; 0 means DefaultStale
+	Wait  time.Duration // How long to wait for a held lock; 0 means DefaultWait
+}
+
+// Lock is a lock file held by this process.
+type Lock struct {
+	path string
+}
+
+// Acquire creates the lock file at path, waiting while another process holds it and taking
+// it over once it is stale. It fails if the lock is still held after opts.Wait.
+func Acquire(path string, opts Options) (*Lock, error) {
+	stale, wait := opts.Stale, opts.Wait
+	if stale <= 0 {
+		stale = DefaultStale
+	}
+	if wait <= 0 {
+		wait = DefaultWait
+	}
+	deadline := time.Now().Add(wait)
+	for {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err == nil {
+			_, err = fmt.Fprintf(f, "%d\n%s\n", os.Getpid(), time.Now().UTC().Format(time.RFC3339Nano))
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("failed to write lock %s: %w", path, err)
+			}
+			return &Lock{path: path}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, fmt.Errorf("failed to create lock %s: %w", path, err)
+		}
+
+		holder, taken, err := readLock(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue // Released in the meantime
+		}
+		if err != nil {
+			return nil, err
+		}
+		if time.Since(taken) > stale {
+			// Removing only the lock that was found stale keeps a fresh one taken by another
+			// process in the meantime, except in the short window between the read and removal.
+			if again, againTaken, err := readLock(path); err == nil && again == holder && againTaken.Equal(taken) {
+				os.Remove(path)
+			}
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("lock %s is still held by process %s after %s", path, holder, wait)
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// Release removes the lock file.
+func (l *Lock) Release() error {
+	if err := os.Remove(l.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to release lock %s: %w", l.path, err)
+	}
+	return nil
+}
+
+// readLock returns the PID the lock file at path names and the time it was taken. A lock
+// whose holder has not written it yet, or died writing it, is dated by its modification time.
+func readLock(path string) (holder string, taken time.Time, err error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", time.Time{}, err
+		}
+		return "", time.Time{}, fmt.Errorf("failed to read lock %s: %w", path, err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) == 2 {
+		if _, err := strconv.Atoi(lines[0]); err == nil {
+			if taken, err := time.Parse(time.RFC3339Nano, lines[1]); err == nil {
+				return lines[0], taken, nil
+			}
+		}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", time.Time{}, err
+		}
+		return "", time.Time{}, fmt.Errorf("failed to stat lock %s: %w", path, err)
+	}
+	return "unknown", info.ModTime(), nil
+}
@@ -0,0 +1,98 @@
+package filelock
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAcquireSerializes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.lock")
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		holders int
+		maxSeen int
+	)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			lock, err := Acquire(path, Options{})
+			if err != nil {
+				t.Errorf("Acquire failed: %v", err)
+				return
+			}
+			mu.Lock()
+			holders++
+			maxSeen = max(maxSeen, holders)
+			mu.Unlock()
+			time.Sleep(time.Millisecond)
+			mu.Lock()
+			holders--
+			mu.Unlock()
+			if err := lock.Release(); err != nil {
+				t.Errorf("Release failed: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+	if maxSeen != 1 {
+		t.Errorf("Expected one holder at a time, saw %d", maxSeen)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the lock file to be removed, got %v", err)
+	}
+}
+
+func TestAcquireStale(t *testing.T) {
+	dir := t.TempDir()
+
+	// A lock left by a process that died is taken over once stale.
+	stale := filepath.Join(dir, "stale.lock")
+	if err := os.WriteFile(stale, []byte("999999\n"+time.Now().Add(-time.Hour).UTC().Format(time.RFC3339Nano)+"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	lock, err := Acquire(stale, Options{Stale: time.Minute, Wait: time.Second})
+	if err != nil {
+		t.Fatalf("Expected the stale lock to be taken over, got %v", err)
+	}
+	if holder, _, err := readLock(stale); err != nil || holder != strconv.Itoa(os.Getpid()) {
+		t.Errorf("Expected the lock to name this process, got %q, %v", holder, err)
+	}
+	lock.Release()
+
+	// A lock without content is dated by its modification time.
+	empty := filepath.Join(dir, "empty.lock")
+	if err := os.WriteFile(empty, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(empty, old, old); err != nil {
+		t.Fatal(err)
+	}
+	lock, err = Acquire(empty, Options{Stale: time.Minute, Wait: time.Second})
+	if err != nil {
+		t.Fatalf("Expected the empty stale lock to be taken over, got %v", err)
+	}
+	lock.Release()
+
+	// A fresh lock is waited for, up to Wait.
+	held, err := Acquire(filepath.Join(dir, "held.lock"), Options{})
+	if err != nil {
+		t.Fatalf("Acquire failed: %v", err)
+	}
+	defer held.Release()
+	start := time.Now()
+	_, err = Acquire(held.path, Options{Stale: time.Minute, Wait: 100 * time.Millisecond})
+	if err == nil || !strings.Contains(err.Error(), "still held by process "+strconv.Itoa(os.Getpid())) {
+		t.Errorf("Expected the held lock to time out naming its holder, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed < 100*time.Millisecond || elapsed > 5*time.Second {
+		t.Errorf("Expected to wait about 100ms, waited %s", elapsed)
+	}
+}
@@ -0,0 +1,184 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"golang.org/x/crypto/ssh"
+)
+
+// Environment variables SSHAuth and HTTPAuth read when their fields are empty.
+const (
+	SSHKeyEnv        = "ZENWATCH_SSH_KEY"
+	SSHPassphraseEnv = "ZENWATCH_SSH_PASSPHRASE"
+	GitTokenEnv      = "ZENWATCH_GIT_TOKEN"
+	GitHubTokenEnv   = "GITHUB_TOKEN"
+)
+
+// cloneAuth returns the auth method of a clone of url with opts, nil if the transport needs
+// none or the URL carries its own credentials.
+func cloneAuth(url string, opts CloneOptions) (transport.AuthMethod, error) {
+	if auth, err := opts.SSH.method(url); auth != nil || err != nil {
+		return auth, err
+	}
+	return opts.HTTP.method(url), nil
+}
+
+// HTTPAuth configures the authentication of clones over HTTPS with an access token, which is
+// sent as the password of HTTP basic authentication, as GitHub and GitLab expect. Tokens are
+// never sent over plain HTTP, nor to a URL that holds credentials of its own.
+type HTTPAuth struct {
+	Token    string // Access token; empty reads GitTokenEnv, then GitHubTokenEnv for github.com URLs only
+	Username string // User name sent with the token; empty is "x-access-token" for github.com and "oauth2" elsewhere
+}
+
+// method returns the basic auth of a clone of raw, nil if no token applies.
+func (a HTTPAuth) method(raw string) transport.AuthMethod {
+	u, err := url.Parse(raw)
+	if err != nil || !strings.EqualFold(u.Scheme, "https") || u.User != nil {
+		return nil
+	}
+	github := strings.EqualFold(u.Hostname(), "github.com")
+	token := a.Token
+	if token == "" {
+		token = os.Getenv(GitTokenEnv)
+	}
+	if token == "" && github {
+		// GITHUB_TOKEN is often set for other tools, so it is only sent where it belongs.
+		token = os.Getenv(GitHubTokenEnv)
+	}
+	if token == "" {
+		return nil
+	}
+	username := a.Username
+	switch {
+	case username != "":
+	case github:
+		username = "x-access-token"
+	default:
+		username = "oauth2"
+	}
+	return &githttp.BasicAuth{Username: username, Password: token}
+}
+
+// authSecret returns the secret auth holds, empty if it holds none.
+func authSecret(auth transport.AuthMethod) string {
+	if basic, ok := auth.(*githttp.BasicAuth); ok {
+		return basic.Password
+	}
+	return ""
+}
+
+// urlSecret returns the secret of the user info of an HTTP(S) URL: its password, or its user
+// name when a token is given as one ("https://<token>@github.com/...").
+func urlSecret(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil || !(strings.EqualFold(u.Scheme, "http") || strings.EqualFold(u.Scheme, "https")) {
+		return ""
+	}
+	if password, ok := u.User.Password(); ok {
+		return password
+	}
+	return u.User.Username()
+}
+
+// RedactURL returns raw without its credentials: an HTTP(S) URL loses its user info, which
+// usually holds a token, and other URLs their password.
+func RedactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	if strings.EqualFold(u.Scheme, "http") || strings.EqualFold(u.Scheme, "https") {
+		u.User = nil
+	} else {
+		u.User = url.User(u.User.Username())
+	}
+	return u.String()
+}
+
+// defaultSSHKeys are the keys tried, relative to the home directory, when none is configured.
+var defaultSSHKeys = []string{".ssh/id_ed25519", ".ssh/id_rsa"}
+
+// SSHAuth configures the authentication of clones over SSH, from ssh:// and scp-style
+// ("git@host:owner/repo.git") URLs. The host key of the server is verified against the
+// known_hosts files, ~/.ssh/known_hosts by default or those listed in SSH_KNOWN_HOSTS.
+type SSHAuth struct {
+	KeyPath    string // Private key; empty reads SSHKeyEnv, then tries ~/.ssh/id_ed25519 and ~/.ssh/id_rsa
+	Passphrase string // Passphrase of an encrypted key; empty reads SSHPassphraseEnv
+}
+
+// method returns the auth method of a clone of url, nil for URLs other than SSH ones. The
+// key is used if one is found and can be decrypted, ssh-agent otherwise; without either the
+// clone is bound to fail, so that is reported as ErrAuthFailed.
+func (a SSHAuth) method(url string) (transport.AuthMethod, error) {
+	endpoint, err := transport.NewEndpoint(url)
+	if err != nil || endpoint.Protocol != "ssh" {
+		return nil, nil
+	}
+	user := endpoint.User
+	if user == "" {
+		user = "git"
+	}
+	keyPath := a.KeyPath
+	if keyPath == "" {
+		keyPath = os.Getenv(SSHKeyEnv)
+	}
+	passphrase := a.Passphrase
+	if passphrase == "" {
+		passphrase = os.Getenv(SSHPassphraseEnv)
+	}
+
+	keys := []string{keyPath}
+	if keyPath == "" {
+		keys = nil
+		if home, err := os.UserHomeDir(); err == nil {
+			for _, key := range defaultSSHKeys {
+				keys = append(keys, filepath.Join(home, key))
+			}
+		}
+	}
+	encrypted := "" // A key that needs a passphrase that is not set
+	for _, key := range keys {
+		pemBytes, err := os.ReadFile(key)
+		if keyPath == "" && errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to read SSH key: %w", ErrAuthFailed, err)
+		}
+		if passphrase == "" {
+			var missing *ssh.PassphraseMissingError
+			if _, err := ssh.ParseRawPrivateKey(pemBytes); errors.As(err, &missing) {
+				encrypted = key
+				break
+			}
+		}
+		auth, err := gitssh.NewPublicKeys(user, pemBytes, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to load SSH key %s: %w", ErrAuthFailed, key, err)
+		}
+		return auth, nil
+	}
+
+	// An agent usually holds the decrypted key, so it is tried before asking for the passphrase.
+	if os.Getenv("SSH_AUTH_SOCK") != "" {
+		auth, err := gitssh.NewSSHAgentAuth(user)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to connect to ssh-agent: %w", ErrAuthFailed, err)
+		}
+		return auth, nil
+	}
+	if encrypted != "" {
+		return nil, fmt.Errorf("%w: SSH key %s is encrypted: set %s or add the key to ssh-agent", ErrAuthFailed, encrypted, SSHPassphraseEnv)
+	}
+	return nil, fmt.Errorf("%w: no SSH key found: set %s or start ssh-agent", ErrAuthFailed, SSHKeyEnv)
+}
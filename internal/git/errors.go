@@ -0,0 +1,90 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Classes of errors callers branch on, such as the CLI mapping them to exit statuses.
+// They are wrapped together with the underlying error, so errors.Is finds both.
+var (
+	ErrAuthFailed          = errors.New("authentication failed")
+	ErrHostKeyVerification = errors.New("host key verification failed")
+	ErrRepoNotFound        = errors.New("repository not found")
+	ErrEmptyRepository     = errors.New("repository is empty")
+	ErrRefNotFound         = errors.New("reference not found")
+)
+
+// errorClasses maps the go-git errors to the class they belong to.
+var errorClasses = []struct {
+	cause, class error
+}{
+	{transport.ErrAuthenticationRequired, ErrAuthFailed},
+	{transport.ErrAuthorizationFailed, ErrAuthFailed},
+	{transport.ErrInvalidAuthMethod, ErrAuthFailed},
+	{transport.ErrRepositoryNotFound, ErrRepoNotFound},
+	{git.ErrRepositoryNotExists, ErrRepoNotFound},
+	{transport.ErrEmptyRemoteRepository, ErrEmptyRepository},
+	{plumbing.ErrReferenceNotFound, ErrRefNotFound},
+	{plumbing.ErrObjectNotFound, ErrRefNotFound},
+}
+
+// classify wraps err in its class, or returns it unchanged if it has none.
+func classify(err error) error {
+	for _, c := range errorClasses {
+		if errors.Is(err, c.cause) {
+			return fmt.Errorf("%w: %w", c.class, err)
+		}
+	}
+	var noMatch git.NoMatchingRefSpecError
+	if errors.As(err, &noMatch) {
+		return fmt.Errorf("%w: %w", ErrRefNotFound, err)
+	}
+	// The SSH transport returns the errors of golang.org/x/crypto/ssh unclassified, and those
+	// of failed authentication and of a missing known_hosts file only as messages.
+	var keyErr *knownhosts.KeyError
+	var revokedErr *knownhosts.RevokedError
+	switch {
+	case errors.As(err, &keyErr), errors.As(err, &revokedErr), strings.Contains(err.Error(), "valid known_hosts file"):
+		return fmt.Errorf("%w: %w", ErrHostKeyVerification, err)
+	case strings.Contains(err.Error(), "ssh: unable to authenticate"):
+		return fmt.Errorf("%w: %w", ErrAuthFailed, err)
+	}
+	return err
+}
+
+// redactedError is an error whose message has secrets, such as tokens, masked. It wraps the
+// original error, so errors.Is still finds its class.
+type redactedError struct {
+	err     error
+	secrets []string
+}
+
+// redact returns err with the non-empty secrets masked in its message, nil if err is nil.
+func redact(err error, secrets ...string) error {
+	if err == nil {
+		return nil
+	}
+	secrets = slices.DeleteFunc(secrets, func(s string) bool { return s == "" })
+	if len(secrets) == 0 {
+		return err
+	}
+	return &redactedError{err: err, secrets: secrets}
+}
+
+func (e *redactedError) Error() string {
+	msg := e.err.Error()
+	for _, s := range e.secrets {
+		msg = strings.ReplaceAll(msg, s, "***")
+	}
+	return msg
+}
+
+func (e *redactedError) Unwrap() error { return e.err }
@@ -0,0 +1,82 @@
+package git
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+)
+
+func TestCloneErrorClasses(t *testing.T) {
+	status := func(code int) string {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(code)
+		}))
+		t.Cleanup(server.Close)
+		return server.URL + "/user/repo.git"
+	}
+	empty := t.TempDir()
+	if _, err := git.PlainInit(empty, false); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		url  string
+		want error
+	}{
+		{"authentication required", status(http.StatusUnauthorized), ErrAuthFailed},
+		{"authorization failed", status(http.StatusForbidden), ErrAuthFailed},
+		{"remote not found", status(http.StatusNotFound), ErrRepoNotFound},
+		{"local path not found", filepath.Join(t.TempDir(), "missing"), ErrRepoNotFound},
+		{"empty repository", empty, ErrEmptyRepository},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path, _, err := CloneRepositoryWithStats(tt.url, 1)
+			if err == nil {
+				os.RemoveAll(path)
+				t.Fatalf("Expected cloning %s to fail", tt.url)
+			}
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Expected %v in the chain of %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestAnalyzeLatestCommitEmptyRepository(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := git.PlainInit(dir, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := AnalyzeLatestCommit(dir); !errors.Is(err, ErrEmptyRepository) {
+		t.Errorf("Expected ErrEmptyRepository for a repository without commits, got %v", err)
+	}
+	if _, err := AnalyzeLatestCommit(t.TempDir()); !errors.Is(err, ErrRepoNotFound) {
+		t.Errorf("Expected ErrRepoNotFound for a directory without a repository, got %v", err)
+	}
+}
+
+func TestReadFileAtUnknownCommit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping TestReadFileAtUnknownCommit: git not on PATH")
+	}
+	dir := t.TempDir()
+	runGit(t, dir, "init", "-q")
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "Initial commit")
+
+	_, err := ReadFileAt(dir, "0123456789abcdef0123456789abcdef01234567", "main.go")
+	if !errors.Is(err, ErrRefNotFound) {
+		t.Errorf("Expected ErrRefNotFound for an unknown commit, got %v", err)
+	}
+}
@@ -0,0 +1,252 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/user/zenwatch/internal/metrics"
+	"github.com/user/zenwatch/internal/warning"
+)
+
+// FunctionChange is how a commit changed a function.
+type FunctionChange string
+
+const (
+	FunctionAdded    FunctionChange = "added"
+	FunctionRemoved  FunctionChange = "removed"
+	FunctionModified FunctionChange = "modified"
+	FunctionMoved    FunctionChange = "moved" // Only its position changed, its source is the same
+	// FileModified stands for all functions of a file that could not be parsed.
+	FileModified FunctionChange = "file modified"
+)
+
+// ChangedFunction is a Go function added, removed or modified by a commit.
+type ChangedFunction struct {
+	Name         string         `json:"name"` // "Foo", "T.Foo" or "(*T).Foo"; empty for FileModified
+	File         string         `json:"file"`
+	Change       FunctionChange `json:"change"`
+	LinesTouched int            `json:"linesTouched"` // Lines the commit added to or deleted from the function
+}
+
+// patchChangedFunctions lists the functions changed by the Go files of patch.
+func patchChangedFunctions(patch *object.Patch) ([]ChangedFunction, []warning.Warning) {
+	var changed []ChangedFunction
+	var warnings []warning.Warning
+	for _, filePatch := range patch.FilePatches() {
+		from, to := filePatch.Files()
+		var path string
+		if to != nil {
+			path = to.Path()
+		} else if from != nil {
+			path = from.Path()
+		}
+		if !strings.HasSuffix(path, ".go") || filePatch.IsBinary() {
+			continue
+		}
+		funcs, funcWarnings := changedFunctions(path, diffChunks(filePatch.Chunks()))
+		changed = append(changed, funcs...)
+		warnings = append(warnings, funcWarnings...)
+	}
+	return changed, warnings
+}
+
+// patchAddedLines returns the lines each Go file of patch gained, by path, see addedLines.
+// Deleted files and files without added lines are left out.
+func patchAddedLines(patch *object.Patch) map[string][]int {
+	added := make(map[string][]int)
+	for _, filePatch := range patch.FilePatches() {
+		_, to := filePatch.Files()
+		if to == nil || !strings.HasSuffix(to.Path(), ".go") || filePatch.IsBinary() {
+			continue
+		}
+		if lines := addedLines(diffChunks(filePatch.Chunks())); len(lines) > 0 {
+			added[to.Path()] = lines
+		}
+	}
+	return added
+}
+
+// addedLines returns the numbers of the non-blank lines d added to the new version, ascending.
+func addedLines(d fileDiff) []int {
+	src := strings.Split(d.newSrc, "\n")
+	var lines []int
+	for line := range d.added {
+		if line <= len(src) && strings.TrimSpace(src[line-1]) != "" {
+			lines = append(lines, line)
+		}
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+// chunkLines returns the number of lines of the content of a chunk.
+func chunkLines(content string) int {
+	lines := strings.Count(content, "\n")
+	if !strings.HasSuffix(content, "\n") && content != "" {
+		lines++ // The last line of a file without trailing newline
+	}
+	return lines
+}
+
+// countChunkLines returns the number of lines chunks add and delete.
+func countChunkLines(chunks []fdiff.Chunk) (added, deleted int) {
+	for _, chunk := range chunks {
+		switch chunk.Type() {
+		case fdiff.Add:
+			added += chunkLines(chunk.Content())
+		case fdiff.Delete:
+			deleted += chunkLines(chunk.Content())
+		}
+	}
+	return added, deleted
+}
+
+// fileDiff holds both versions of a file and the lines a patch changed in them.
+type fileDiff struct {
+	oldSrc, newSrc  string
+	deleted, added  map[int]bool // Line numbers in the old and the new version
+	deletions, adds int
+}
+
+// diffChunks reassembles the old and new version of a file from the chunks of its patch.
+func diffChunks(chunks []fdiff.Chunk) fileDiff {
+	var oldSrc, newSrc strings.Builder
+	d := fileDiff{deleted: make(map[int]bool), added: make(map[int]bool)}
+	oldLine, newLine := 1, 1
+	for _, chunk := range chunks {
+		content := chunk.Content()
+		lines := chunkLines(content)
+		switch chunk.Type() {
+		case fdiff.Equal:
+			oldSrc.WriteString(content)
+			newSrc.WriteString(content)
+			oldLine += lines
+			newLine += lines
+		case fdiff.Delete:
+			oldSrc.WriteString(content)
+			for i := 0; i < lines; i++ {
+				d.deleted[oldLine] = true
+				oldLine++
+			}
+			d.deletions += lines
+		case fdiff.Add:
+			newSrc.WriteString(content)
+			for i := 0; i < lines; i++ {
+				d.added[newLine] = true
+				newLine++
+			}
+			d.adds += lines
+		}
+	}
+	d.oldSrc, d.newSrc = oldSrc.String(), newSrc.String()
+	return d
+}
+
+// goFunction is a function declaration of one version of a file.
+type goFunction struct {
+	name       string
+	start, end int    // Lines of the declaration, doc comment excluded
+	source     string // Source of the declaration, to tell moved from modified functions
+}
+
+// parseFunctions returns the functions of src by key: the name, suffixed with "#n" for the
+// n-th repeat of a name such as init. Empty source has no functions.
+func parseFunctions(path, src string) (map[string]goFunction, error) {
+	funcs := make(map[string]goFunction)
+	if src == "" {
+		return funcs, nil
+	}
+	fset := token.NewFileSet()
+	file, err := parser.ParseFile(fset, path, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]int)
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok {
+			continue
+		}
+		name := metrics.FunctionName(fn)
+		key := name
+		if seen[name] > 0 {
+			key = fmt.Sprintf("%s#%d", name, seen[name])
+		}
+		seen[name]++
+		start, end := fset.Position(fn.Pos()), fset.Position(fn.End())
+		funcs[key] = goFunction{name: name, start: start.Line, end: end.Line, source: src[start.Offset:end.Offset]}
+	}
+	return funcs, nil
+}
+
+// changedFunctions maps the changed lines of a Go file onto the functions of its old and new
+// version. A file that fails to parse is reported as a single FileModified entry with a warning.
+func changedFunctions(path string, d fileDiff) ([]ChangedFunction, []warning.Warning) {
+	oldFuncs, oldErr := parseFunctions(path, d.oldSrc)
+	newFuncs, newErr := parseFunctions(path, d.newSrc)
+	if err := errors.Join(oldErr, newErr); err != nil {
+		return []ChangedFunction{{File: path, Change: FileModified, LinesTouched: d.adds + d.deletions}},
+			[]warning.Warning{{
+				Code:    warning.UnparseableFile,
+				Message: fmt.Sprintf("changed functions are not listed: %v", err),
+				File:    path,
+			}}
+	}
+
+	var changed []ChangedFunction
+	// Functions are listed in the order of the new version, then the removed ones in the
+	// order of the old version.
+	for _, key := range sortedByLine(newFuncs) {
+		fn := newFuncs[key]
+		added := countLines(d.added, fn.start, fn.end)
+		old, existed := oldFuncs[key]
+		if !existed {
+			changed = append(changed, ChangedFunction{Name: fn.name, File: path, Change: FunctionAdded, LinesTouched: added})
+			continue
+		}
+		touched := added + countLines(d.deleted, old.start, old.end)
+		switch {
+		case touched == 0:
+		case old.source == fn.source:
+			changed = append(changed, ChangedFunction{Name: fn.name, File: path, Change: FunctionMoved, LinesTouched: touched})
+		default:
+			changed = append(changed, ChangedFunction{Name: fn.name, File: path, Change: FunctionModified, LinesTouched: touched})
+		}
+	}
+	for _, key := range sortedByLine(oldFuncs) {
+		if _, ok := newFuncs[key]; !ok {
+			fn := oldFuncs[key]
+			changed = append(changed, ChangedFunction{Name: fn.name, File: path, Change: FunctionRemoved, LinesTouched: countLines(d.deleted, fn.start, fn.end)})
+		}
+	}
+	return changed, nil
+}
+
+// sortedByLine returns the keys of funcs ordered by the line the functions start on.
+func sortedByLine(funcs map[string]goFunction) []string {
+	keys := make([]string, 0, len(funcs))
+	for key := range funcs {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return funcs[keys[i]].start < funcs[keys[j]].start })
+	return keys
+}
+
+// countLines counts the lines of set in [start, end].
+func countLines(set map[int]bool, start, end int) int {
+	n := 0
+	for line := range set {
+		if line >= start && line <= end {
+			n++
+		}
+	}
+	return n
+}
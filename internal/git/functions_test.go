@@ -0,0 +1,166 @@
+package git
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+
+	"github.com/user/zenwatch/internal/warning"
+)
+
+type testChunk struct {
+	content string
+	op      fdiff.Operation
+}
+
+func (c testChunk) Content() string       { return c.content }
+func (c testChunk) Type() fdiff.Operation { return c.op }
+
+// diffLines returns the chunks of a line diff from oldSrc to newSrc, computed from their
+// longest common subsequence of lines.
+func diffLines(oldSrc, newSrc string) []fdiff.Chunk {
+	a, b := strings.SplitAfter(oldSrc, "\n"), strings.SplitAfter(newSrc, "\n")
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var chunks []fdiff.Chunk
+	emit := func(line string, op fdiff.Operation) {
+		if n := len(chunks); n > 0 && chunks[n-1].Type() == op {
+			chunks[n-1] = testChunk{chunks[n-1].Content() + line, op}
+			return
+		}
+		chunks = append(chunks, testChunk{line, op})
+	}
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			emit(a[i], fdiff.Equal)
+			i, j = i+1, j+1
+		case j < len(b) && (i == len(a) || lcs[i][j+1] >= lcs[i+1][j]):
+			emit(b[j], fdiff.Add)
+			j++
+		default:
+			emit(a[i], fdiff.Delete)
+			i++
+		}
+	}
+	return chunks
+}
+
+func TestDiffChunks(t *testing.T) {
+	oldSrc := "a\nb\nc\n"
+	newSrc := "a\nx\nc\nd\n"
+	d := diffChunks(diffLines(oldSrc, newSrc))
+	if d.oldSrc != oldSrc || d.newSrc != newSrc {
+		t.Errorf("Expected both versions to be reassembled, got %q and %q", d.oldSrc, d.newSrc)
+	}
+	if !d.deleted[2] || len(d.deleted) != 1 || !d.added[2] || !d.added[4] || len(d.added) != 2 {
+		t.Errorf("Expected line 2 deleted and lines 2 and 4 added, got %v and %v", d.deleted, d.added)
+	}
+	if d.adds != 2 || d.deletions != 1 {
+		t.Errorf("Expected 2 additions and 1 deletion, got %d and %d", d.adds, d.deletions)
+	}
+}
+
+func TestAddedLines(t *testing.T) {
+	oldSrc := "package p\n\nfunc A() {}\n"
+	newSrc := "package p\n\nfunc A() {}\n\n// B is new.\nfunc B() {\n\t\n}\n"
+	lines := addedLines(diffChunks(diffLines(oldSrc, newSrc)))
+	if want := []int{5, 6, 8}; !slices.Equal(lines, want) {
+		t.Errorf("Expected the non-blank added lines %v, got %v", want, lines)
+	}
+}
+
+func TestChangedFunctions(t *testing.T) {
+	oldSrc := `package p
+
+func Unchanged() {}
+
+func Modified() int {
+	a := 1
+	b := 2
+	c := 3
+	return a + b + c
+}
+
+func Deleted() string {
+	s := "gone"
+	return s
+}
+
+func (t *T) Moved() int {
+	x := 3
+	return x
+}
+`
+	newSrc := `package p
+
+func Unchanged() {}
+
+func (t *T) Moved() int {
+	x := 3
+	return x
+}
+
+func Modified() int {
+	a := 1
+	b := 2
+	c := 3
+	return a * b * c
+}
+
+func Added() bool {
+	return true
+}
+`
+	changed, warnings := changedFunctions("p/p.go", diffChunks(diffLines(oldSrc, newSrc)))
+	if len(warnings) != 0 {
+		t.Fatalf("Unexpected warnings %v", warnings)
+	}
+	var got []string
+	for _, cf := range changed {
+		if cf.File != "p/p.go" || cf.LinesTouched == 0 {
+			t.Errorf("Unexpected changed function %+v", cf)
+		}
+		got = append(got, cf.Name+" "+string(cf.Change))
+	}
+	want := "(*T).Moved moved, Modified modified, Added added, Deleted removed"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("Expected %s, got %s", want, strings.Join(got, ", "))
+	}
+}
+
+func TestChangedFunctionsNewAndDeletedFiles(t *testing.T) {
+	src := "package p\n\nfunc F() {}\n\nfunc (T) G() {}\n"
+	changed, _ := changedFunctions("new.go", diffChunks(diffLines("", src)))
+	if len(changed) != 2 || changed[0].Change != FunctionAdded || changed[1].Name != "T.G" || changed[1].LinesTouched != 1 {
+		t.Errorf("Expected F and T.G to be added, got %+v", changed)
+	}
+	changed, _ = changedFunctions("old.go", diffChunks(diffLines(src, "")))
+	if len(changed) != 2 || changed[0].Change != FunctionRemoved || changed[0].Name != "F" {
+		t.Errorf("Expected F and T.G to be removed, got %+v", changed)
+	}
+}
+
+func TestChangedFunctionsUnparseable(t *testing.T) {
+	changed, warnings := changedFunctions("broken.go", diffChunks(diffLines("package p\n", "package p\n\nfunc {\n")))
+	if len(changed) != 1 || changed[0].Change != FileModified || changed[0].Name != "" || changed[0].LinesTouched != 2 {
+		t.Errorf("Expected a file-level change, got %+v", changed)
+	}
+	if len(warnings) != 1 || warnings[0].Code != warning.UnparseableFile || warnings[0].File != "broken.go" {
+		t.Errorf("Expected an unparseable-file warning, got %v", warnings)
+	}
+}
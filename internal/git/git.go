@@ -1,113 +1,337 @@
 package git
 
 import (
+	"bytes"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+
+	"github.com/user/zenwatch/internal/metrics"
+	"github.com/user/zenwatch/internal/warning"
 )
 
+// clonePrefix names the temporary directories CloneRepository creates.
+const clonePrefix = "zenwatch-clone-"
+
 // RepositoryInfo holds basic information about a repository and its latest commit.
 type RepositoryInfo struct {
-	URL               string
+	URL               string // Set by the caller, without credentials (see RedactURL)
 	TempPath          string // Path to the temporary clone
+	Branch            string // Branch checked out in the clone; empty if HEAD is detached
 	LatestCommit      CommitInfo
-	ChangedFiles      []ChangedFileStats // Per-file line counts will be 0 due to env limitations
-	TotalLinesAdded   int
-	TotalLinesDeleted int
+	ChangedFiles      []ChangedFileStats // Files of the patch of the commit, with their line counts
+	TotalLinesAdded   int                // Sum of the LinesAdded of ChangedFiles
+	TotalLinesDeleted int                // Sum of the LinesDeleted of ChangedFiles
+	Warnings          []warning.Warning  // Problems that made the commit analysis less complete
+	ChangedFunctions  []ChangedFunction  // Go functions the commit added, removed or modified
+	AddedLines        map[string][]int   // Non-blank lines the commit added to each Go file, by path, ascending
+	Uncommitted       bool               // The changes are those of the worktree against LatestCommit, see AnalyzeWorktree
 }
 
-// CommitInfo holds information about a specific commit.
+// CommitInfo holds information about a specific commit, including its aggregate diff stats.
 type CommitInfo struct {
-	Hash    string
-	Message string
-	Author  string
-	Email   string
-	Date    string
+	Hash         string    `json:"hash"`
+	Message      string    `json:"message"`     // Subject line of the commit message
+	FullMessage  string    `json:"fullMessage"` // Complete commit message, trailing newlines trimmed
+	Author       string    `json:"author"`
+	Email        string    `json:"email"`
+	Date         string    `json:"date"` // Author date, as formatted by time.Time.String
+	When         time.Time `json:"when"` // Author date
+	FilesChanged int       `json:"filesChanged"`
+	LinesAdded   int       `json:"linesAdded"`
+	LinesDeleted int       `json:"linesDeleted"`
 }
 
 // ChangedFileStats holds statistics for a single changed file.
-// Note: LinesAdded and LinesDeleted will currently be 0 for individual files
-// due to environment limitations in resolving go-git diff constants.
 type ChangedFileStats struct {
-	Path         string
-	FileType     string // e.g., ".go", ".md"
-	LinesAdded   int    // Currently will be 0
-	LinesDeleted int    // Currently will be 0
+	Path         string `json:"path"`
+	FileType     string `json:"fileType"`     // e.g., ".go", ".md"
+	LinesAdded   int    `json:"linesAdded"`   // Lines of the Add chunks of the file's patch; 0 for binary files
+	LinesDeleted int    `json:"linesDeleted"` // Lines of the Delete chunks of the file's patch; 0 for binary files
 }
 
 // CloneRepository clones a git repository from the given URL to a temporary directory.
 func CloneRepository(url string) (string, error) {
-	tempDir, err := os.MkdirTemp("", "zenwatch-clone-*")
+	return CloneRepositoryWithDepth(url, 1)
+}
+
+// CloneRepositoryWithDepth is CloneRepository keeping the last depth commits of history.
+// A depth of 0 clones the full history. At a depth of 1 the parent of HEAD is missing, so
+// AnalyzeLatestCommit diffs HEAD against an empty tree; accurate per-file diffs need more.
+func CloneRepositoryWithDepth(url string, depth int) (string, error) {
+	tempDir, _, err := CloneRepositoryWithStats(url, depth)
+	return tempDir, err
+}
+
+// CloneStats describes the transfer of a clone.
+type CloneStats struct {
+	Objects int   // Objects the server reported sending; 0 if it sent no progress messages
+	Bytes   int64 // Size of the received packfiles, as go-git does not count transferred bytes
+}
+
+// CloneRepositoryWithStats is CloneRepositoryWithDepth also returning transfer statistics.
+func CloneRepositoryWithStats(url string, depth int) (string, CloneStats, error) {
+	return CloneRepositoryWithOptions(url, CloneOptions{Depth: depth})
+}
+
+// CloneOptions configure a clone.
+type CloneOptions struct {
+	Depth  int      // Commits of history to keep; 0 clones the full history
+	Branch string   // Branch to check out, short ("develop") or full ("refs/heads/develop"); empty checks out the default branch
+	Commit string   // Commit to check out, full or abbreviated (see ResolveCommit); the full history of every branch is cloned to find it
+	SSH    SSHAuth  // Authentication of SSH URLs; the zero value reads the environment
+	HTTP   HTTPAuth // Authentication of HTTPS URLs; the zero value reads the environment
+}
+
+// CloneRepositoryWithOptions is CloneRepositoryWithStats checking out the branch or commit of
+// opts. A branch the remote does not have, or a commit none of its branches contain, is
+// reported as ErrRefNotFound, naming it. Over SSH, a rejected key is reported as
+// ErrAuthFailed and an unknown or changed host key as ErrHostKeyVerification. Errors name
+// the URL without its credentials and never quote a token.
+func CloneRepositoryWithOptions(url string, opts CloneOptions) (string, CloneStats, error) {
+	auth, err := cloneAuth(url, opts)
+	if err != nil {
+		return "", CloneStats{}, fmt.Errorf("failed to clone repository %s: %w", RedactURL(url), err)
+	}
+	tempDir, stats, err := cloneRepository(url, auth, opts)
+	// The errors name the URL without its credentials, but those of go-git may still quote them.
+	return tempDir, stats, redact(err, authSecret(auth), urlSecret(url))
+}
+
+// cloneRepository is CloneRepositoryWithOptions authenticating with auth.
+func cloneRepository(url string, auth transport.AuthMethod, opts CloneOptions) (string, CloneStats, error) {
+	tempDir, err := os.MkdirTemp("", clonePrefix+"*")
 	if err != nil {
-		return "", fmt.Errorf("failed to create temp dir: %w", err)
+		return "", CloneStats{}, fmt.Errorf("failed to create temp dir: %w", err)
 	}
 
-	_, err = git.PlainClone(tempDir, false, &git.CloneOptions{
+	progress := &progressCounter{}
+	cloneOpts := &git.CloneOptions{
 		URL:      url,
-		Progress: nil,
-		Depth:    1,
-	})
+		Auth:     auth,
+		Progress: progress,
+		Depth:    opts.Depth,
+	}
+	if opts.Branch != "" {
+		cloneOpts.ReferenceName = branchReference(opts.Branch)
+	}
+	if opts.Commit != "" {
+		// A shallow clone only has the tips of the branches, and go-git cannot fetch an
+		// arbitrary commit by its hash.
+		cloneOpts.Depth = 0
+	}
+	repo, err := git.PlainClone(tempDir, false, cloneOpts)
 
 	if err != nil {
 		os.RemoveAll(tempDir)
-		return "", fmt.Errorf("failed to clone repository %s: %w", url, err)
+		var noMatch git.NoMatchingRefSpecError
+		if opts.Branch != "" && (errors.As(err, &noMatch) || errors.Is(err, plumbing.ErrReferenceNotFound)) {
+			return "", CloneStats{}, fmt.Errorf("failed to clone repository %s: branch %q does not exist: %w", RedactURL(url), opts.Branch, ErrRefNotFound)
+		}
+		return "", CloneStats{}, fmt.Errorf("failed to clone repository %s: %w", RedactURL(url), classify(err))
+	}
+
+	if opts.Commit != "" {
+		if err := checkoutCommit(repo, opts.Commit); err != nil {
+			os.RemoveAll(tempDir)
+			return "", CloneStats{}, fmt.Errorf("failed to clone repository %s: %w", RedactURL(url), err)
+		}
+	}
+
+	stats := CloneStats{Objects: progress.objects}
+	packs, _ := filepath.Glob(filepath.Join(tempDir, ".git", "objects", "pack", "*.pack"))
+	for _, pack := range packs {
+		if info, err := os.Stat(pack); err == nil {
+			stats.Bytes += info.Size()
+		}
+	}
+	return tempDir, stats, nil
+}
+
+// branchReference returns the reference of branch, which is either a full reference name or
+// the short name of a branch.
+func branchReference(branch string) plumbing.ReferenceName {
+	if strings.HasPrefix(branch, "refs/") {
+		return plumbing.ReferenceName(branch)
+	}
+	return plumbing.NewBranchReferenceName(branch)
+}
+
+// checkoutCommit checks out the commit hash names, detaching HEAD.
+func checkoutCommit(repo *git.Repository, hash string) error {
+	commit, err := ResolveCommit(repo, hash)
+	if err != nil {
+		return err
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to get worktree: %w", err)
+	}
+	if err := worktree.Checkout(&git.CheckoutOptions{Hash: commit.Hash, Force: true}); err != nil {
+		return fmt.Errorf("failed to check out commit %s: %w", commit.Hash, err)
+	}
+	return nil
+}
+
+// minAbbreviatedHash is the shortest abbreviation of a commit hash ResolveCommit accepts, as
+// the default of git's core.abbrev.
+const minAbbreviatedHash = 7
+
+// ResolveCommit returns the commit of repo that hash names, in full or abbreviated to at least
+// 7 hexadecimal characters. An abbreviation several commits share is an error listing them; a
+// hash no commit has is reported as ErrRefNotFound.
+func ResolveCommit(repo *git.Repository, hash string) (*object.Commit, error) {
+	hash = strings.ToLower(hash)
+	if len(hash) < minAbbreviatedHash || len(hash) > 40 || strings.Trim(hash, "0123456789abcdef") != "" {
+		return nil, fmt.Errorf("invalid commit %q: expected %d to 40 hexadecimal characters", hash, minAbbreviatedHash)
+	}
+	if len(hash) == 40 {
+		commit, err := repo.CommitObject(plumbing.NewHash(hash))
+		if err != nil {
+			return nil, fmt.Errorf("commit %s does not exist: %w", hash, classify(err))
+		}
+		return commit, nil
+	}
+	iter, err := repo.CommitObjects()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits: %w", classify(err))
+	}
+	var matches []*object.Commit
+	err = iter.ForEach(func(c *object.Commit) error {
+		if strings.HasPrefix(c.Hash.String(), hash) {
+			matches = append(matches, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list commits: %w", classify(err))
+	}
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("commit %s does not exist: %w", hash, ErrRefNotFound)
+	case 1:
+		return matches[0], nil
+	}
+	var hashes []string
+	for _, c := range matches {
+		hashes = append(hashes, c.Hash.String())
 	}
-	return tempDir, nil
+	sort.Strings(hashes)
+	return nil, fmt.Errorf("commit %s is ambiguous: it abbreviates %s", hash, strings.Join(hashes, ", "))
+}
+
+// progressTotal matches the object count of a server's progress messages, e.g.
+// "Total 1234 (delta 56), reused ..." or "Counting objects: 100% (1234/1234), done.".
+var progressTotal = regexp.MustCompile(`(?:^Total (\d+)|^Counting objects: +\d+% \(\d+/(\d+)\))`)
+
+// progressCounter is the Progress writer of a clone. It picks the number of objects sent
+// from the server's progress messages, which arrive in arbitrary chunks and are separated
+// by carriage returns or newlines.
+type progressCounter struct {
+	pending []byte
+	objects int
 }
 
-// AnalyzeLatestCommit analyzes the latest commit of the repository cloned at repoPath.
-// It will populate total lines added/deleted for the commit, but per-file line counts
-// will be zero due to limitations in the current Go environment with go-git diff constants.
+func (p *progressCounter) Write(b []byte) (int, error) {
+	p.pending = append(p.pending, b...)
+	for {
+		i := bytes.IndexAny(p.pending, "\r\n")
+		if i < 0 {
+			return len(b), nil
+		}
+		line := strings.TrimPrefix(string(p.pending[:i]), "remote: ")
+		p.pending = p.pending[i+1:]
+		if m := progressTotal.FindStringSubmatch(line); m != nil {
+			total := m[1]
+			if total == "" {
+				total = m[2]
+			}
+			if n, err := strconv.Atoi(total); err == nil && n > p.objects {
+				p.objects = n
+			}
+		}
+	}
+}
+
+// AnalyzeLatestCommit analyzes the latest commit of the repository cloned at repoPath,
+// counting the lines added and deleted per file from its patch. A commit without a parent in
+// the clone is diffed against the empty tree, so every line of it counts as added.
 func AnalyzeLatestCommit(repoPath string) (*RepositoryInfo, error) {
+	return analyzeCommit(repoPath, "", true)
+}
+
+// AnalyzeLatestCommitFiles is AnalyzeLatestCommit without the changed functions, so that no
+// Go file is parsed.
+func AnalyzeLatestCommitFiles(repoPath string) (*RepositoryInfo, error) {
+	return analyzeCommit(repoPath, "", false)
+}
+
+// AnalyzeCommit is AnalyzeLatestCommit for the commit hash names, full or abbreviated (see
+// ResolveCommit), instead of HEAD. The commit is read from the object store, so it does not
+// need to be checked out, but the clone needs its history, which a shallow clone rarely has.
+func AnalyzeCommit(repoPath, hash string) (*RepositoryInfo, error) {
+	return analyzeCommit(repoPath, hash, true)
+}
+
+// AnalyzeCommitFiles is AnalyzeCommit without the changed functions.
+func AnalyzeCommitFiles(repoPath, hash string) (*RepositoryInfo, error) {
+	return analyzeCommit(repoPath, hash, false)
+}
+
+// analyzeCommit analyzes the commit hash names, or HEAD if hash is empty.
+func analyzeCommit(repoPath, hash string, functions bool) (*RepositoryInfo, error) {
 	repo, err := git.PlainOpen(repoPath)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open repository at %s: %w", repoPath, err)
+		return nil, fmt.Errorf("failed to open repository at %s: %w", repoPath, classify(err))
 	}
 
 	headRef, err := repo.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		// HEAD is unborn until the first commit.
+		return nil, fmt.Errorf("failed to get HEAD reference: %w: %w", ErrEmptyRepository, err)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to get HEAD reference: %w", err)
+		return nil, fmt.Errorf("failed to get HEAD reference: %w", classify(err))
 	}
 
-	latestCommit, err := repo.CommitObject(headRef.Hash())
-	if err != nil {
-		return nil, fmt.Errorf("failed to get latest commit object: %w", err)
+	var latestCommit *object.Commit
+	if hash == "" {
+		latestCommit, err = repo.CommitObject(headRef.Hash())
+		if err != nil {
+			return nil, fmt.Errorf("failed to get latest commit object: %w", classify(err))
+		}
+	} else if latestCommit, err = ResolveCommit(repo, hash); err != nil {
+		return nil, err
 	}
 
 	commitInfo := CommitInfo{
-		Hash:    latestCommit.Hash.String(),
-		Message: strings.Split(latestCommit.Message, "\n")[0],
-		Author:  latestCommit.Author.Name,
-		Email:   latestCommit.Author.Email,
-		Date:    latestCommit.Author.When.String(),
+		Hash:        latestCommit.Hash.String(),
+		Message:     strings.Split(latestCommit.Message, "\n")[0],
+		FullMessage: strings.TrimRight(latestCommit.Message, "\n"),
+		Author:      latestCommit.Author.Name,
+		Email:       latestCommit.Author.Email,
+		Date:        latestCommit.Author.When.String(),
+		When:        latestCommit.Author.When,
 	}
 
 	repoInfo := &RepositoryInfo{
-		TempPath:     repoPath,
-		LatestCommit: commitInfo,
+		TempPath: repoPath,
 	}
-
-	// Get overall commit stats for total lines added/deleted
-	totalAdded := 0
-	totalDeleted := 0
-
-	commitStats, err := latestCommit.Stats()
-	if err != nil {
-		// Fallback or note if stats are unavailable, though it should generally work
-		// For Depth:1 clones, this often fails with "object not found" if parent is needed by Stats()
-		// fmt.Fprintf(os.Stderr, "Warning: could not retrieve commit stats: %v\n", err)
-	} else {
-		for _, fileStat := range commitStats {
-			totalAdded += fileStat.Addition
-			totalDeleted += fileStat.Deletion
-		}
+	if headRef.Name().IsBranch() && headRef.Hash() == latestCommit.Hash {
+		repoInfo.Branch = headRef.Name().Short()
 	}
-	repoInfo.TotalLinesAdded = totalAdded
-	repoInfo.TotalLinesDeleted = totalDeleted
 
 	currentTree, err := latestCommit.Tree()
 	if err != nil {
@@ -132,6 +356,10 @@ func AnalyzeLatestCommit(repoPath string) (*RepositoryInfo, error) {
 		parentCommit, errParent := latestCommit.Parent(0)
 		if errParent != nil {
 			// Fallback for shallow clone where parent isn't available
+			repoInfo.Warnings = append(repoInfo.Warnings, warning.Warning{
+				Code:    warning.ShallowCloneFallback,
+				Message: fmt.Sprintf("parent of commit %s is not available (%v), so every file in the tree is reported as changed", commitInfo.Hash, errParent),
+			})
 			changes, diffErr := object.DiffTree(nil, currentTree) // Use nil for an empty tree
 			if diffErr != nil {
 				return nil, fmt.Errorf("failed to diff current tree with empty (parent fetch failed: %v): %w", errParent, diffErr)
@@ -152,28 +380,56 @@ func AnalyzeLatestCommit(repoPath string) (*RepositoryInfo, error) {
 		}
 	}
 
-    if patch != nil {
-        for _, filePatch := range patch.FilePatches() {
-            from, to := filePatch.Files()
-            filePath := ""
-            if to != nil {
-                filePath = to.Path()
-            } else if from != nil { // File was deleted
-                filePath = from.Path()
-            }
-            if filePath == "" { // Should not happen with valid patches
-                continue
-            }
-            changedFileStatsList = append(changedFileStatsList, ChangedFileStats{
-                Path:         filePath,
-                FileType:     strings.ToLower(filepath.Ext(filePath)),
-                LinesAdded:   0, // Per-file line counts set to 0 due to env limitations
-                LinesDeleted: 0, // Per-file line counts set to 0 due to env limitations
-            })
-        }
-    }
+	if patch != nil {
+		// The commit is counted from the patch, so its totals match the files listed.
+		for _, filePatch := range patch.FilePatches() {
+			from, to := filePatch.Files()
+			filePath := ""
+			if to != nil {
+				filePath = to.Path()
+			} else if from != nil { // File was deleted
+				filePath = from.Path()
+			}
+			if filePath == "" { // Should not happen with valid patches
+				continue
+			}
+			// Binary files have no chunks, so they count no lines.
+			added, deleted := countChunkLines(filePatch.Chunks())
+			changedFileStatsList = append(changedFileStatsList, ChangedFileStats{
+				Path:         filePath,
+				FileType:     strings.ToLower(filepath.Ext(filePath)),
+				LinesAdded:   added,
+				LinesDeleted: deleted,
+			})
+			commitInfo.LinesAdded += added
+			commitInfo.LinesDeleted += deleted
+		}
+		commitInfo.FilesChanged = len(changedFileStatsList)
+	} else if commitStats, err := latestCommit.Stats(); err != nil {
+		// For Depth:1 clones, this often fails with "object not found" if parent is needed by Stats()
+		repoInfo.Warnings = append(repoInfo.Warnings, warning.Warning{
+			Code:    warning.CommitStatsUnavailable,
+			Message: fmt.Sprintf("line counts of commit %s are reported as 0: %v", commitInfo.Hash, err),
+		})
+	} else {
+		commitInfo.FilesChanged = len(commitStats)
+		for _, fileStat := range commitStats {
+			commitInfo.LinesAdded += fileStat.Addition
+			commitInfo.LinesDeleted += fileStat.Deletion
+		}
+	}
 
+	repoInfo.LatestCommit = commitInfo
+	repoInfo.TotalLinesAdded, repoInfo.TotalLinesDeleted = commitInfo.LinesAdded, commitInfo.LinesDeleted
 	repoInfo.ChangedFiles = changedFileStatsList
+	if patch != nil {
+		repoInfo.AddedLines = patchAddedLines(patch)
+	}
+	if patch != nil && functions {
+		var funcWarnings []warning.Warning
+		repoInfo.ChangedFunctions, funcWarnings = patchChangedFunctions(patch)
+		repoInfo.Warnings = append(repoInfo.Warnings, funcWarnings...)
+	}
 	return repoInfo, nil
 }
 
@@ -181,3 +437,604 @@ func AnalyzeLatestCommit(repoPath string) (*RepositoryInfo, error) {
 func Cleanup(repoPath string) {
 	os.RemoveAll(repoPath)
 }
+
+// BlameLine is the last change to one line of a file.
+type BlameLine struct {
+	Author string
+	Email  string
+	Date   time.Time // Author date of the commit that last changed the line
+}
+
+// Blame returns the last change to every line of the file at path (slash-separated,
+// relative to the repository root) as of HEAD, indexed from 0 for line 1.
+// Blame needs the full history: in a shallow clone it fails once it reaches a missing commit.
+func Blame(repoPath, path string) ([]BlameLine, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository at %s: %w", repoPath, classify(err))
+	}
+	headRef, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD reference: %w", classify(err))
+	}
+	commit, err := repo.CommitObject(headRef.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD commit object: %w", err)
+	}
+
+	result, err := git.Blame(commit, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to blame %s: %w", path, err)
+	}
+	lines := make([]BlameLine, len(result.Lines))
+	for i, line := range result.Lines {
+		lines[i] = BlameLine{Author: line.AuthorName, Email: line.Author, Date: line.Date}
+	}
+	return lines, nil
+}
+
+// BlameAuthors returns the author of every line of the file at path, like Blame.
+func BlameAuthors(repoPath, path string) ([]metrics.Author, error) {
+	lines, err := Blame(repoPath, path)
+	if err != nil {
+		return nil, err
+	}
+	authors := make([]metrics.Author, len(lines))
+	for i, line := range lines {
+		authors[i] = metrics.Author{Name: line.Author, Email: line.Email}
+	}
+	return authors, nil
+}
+
+// CopyUntrackedFiles copies the untracked files of the local repository at srcRepo into
+// dstDir, keeping their relative paths, and returns the copied paths (slash-separated).
+// Files matched by .gitignore are not untracked in git's sense and are never copied.
+func CopyUntrackedFiles(srcRepo, dstDir string) ([]string, error) {
+	repo, err := git.PlainOpen(srcRepo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository at %s: %w", srcRepo, classify(err))
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worktree of %s: %w", srcRepo, err)
+	}
+	status, err := worktree.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status of %s: %w", srcRepo, err)
+	}
+
+	var copied []string
+	for path, fileStatus := range status {
+		if fileStatus.Worktree != git.Untracked {
+			continue
+		}
+		content, err := os.ReadFile(filepath.Join(srcRepo, filepath.FromSlash(path)))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read untracked file %s: %w", path, err)
+		}
+		dst := filepath.Join(dstDir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create directory for %s: %w", path, err)
+		}
+		if err := os.WriteFile(dst, content, 0o644); err != nil {
+			return nil, fmt.Errorf("failed to copy untracked file %s: %w", path, err)
+		}
+		copied = append(copied, path)
+	}
+	sort.Strings(copied)
+	return copied, nil
+}
+
+// FirstParentHistory returns the hashes of HEAD and up to n-1 of its first-parent ancestors,
+// oldest first. If the history ends early because a parent is missing from a shallow clone,
+// the available commits are returned with a warning.
+func FirstParentHistory(repoPath string, n int) ([]string, []warning.Warning, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open repository at %s: %w", repoPath, classify(err))
+	}
+	headRef, err := repo.Head()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get HEAD reference: %w", classify(err))
+	}
+	commit, err := repo.CommitObject(headRef.Hash())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get HEAD commit object: %w", err)
+	}
+
+	var hashes []string
+	var warnings []warning.Warning
+	for {
+		hashes = append([]string{commit.Hash.String()}, hashes...)
+		if len(hashes) >= n || commit.NumParents() == 0 {
+			break
+		}
+		parent, err := commit.Parent(0)
+		if err != nil {
+			warnings = append(warnings, warning.Warning{
+				Code:    warning.HistoryTruncated,
+				Message: fmt.Sprintf("only %d of %d commits are available: parent of %s is missing (%v)", len(hashes), n, commit.Hash, err),
+			})
+			break
+		}
+		commit = parent
+	}
+	return hashes, warnings, nil
+}
+
+// CommitTimes returns the author dates of all commits reachable from HEAD, merge commits
+// included, in history order. Unlike CommitHistory it reads no trees, so it stays fast on
+// long histories.
+func CommitTimes(repoPath string) ([]time.Time, []warning.Warning, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open repository at %s: %w", repoPath, classify(err))
+	}
+	headRef, err := repo.Head()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get HEAD reference: %w", classify(err))
+	}
+	iter, err := repo.Log(&git.LogOptions{From: headRef.Hash()})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	defer iter.Close()
+
+	var times []time.Time
+	for {
+		commit, err := iter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return times, []warning.Warning{{
+				Code:    warning.HistoryTruncated,
+				Message: fmt.Sprintf("history ends after %d commits: %v", len(times), err),
+			}}, nil
+		}
+		times = append(times, commit.Author.When)
+	}
+	return times, nil, nil
+}
+
+// CommitHistory returns the commits reachable from HEAD, newest first, with their file and
+// line counts, the first limit of them if limit is above 0. Merge commits are left out, as
+// their changes belong to the merged commits. Commits whose counts are unavailable, such as
+// the oldest commit of a shallow clone, are left out with a warning.
+func CommitHistory(repoPath string, limit int) ([]CommitInfo, []warning.Warning, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open repository at %s: %w", repoPath, classify(err))
+	}
+	headRef, err := repo.Head()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get HEAD reference: %w", classify(err))
+	}
+	iter, err := repo.Log(&git.LogOptions{From: headRef.Hash()})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	defer iter.Close()
+
+	var history []CommitInfo
+	var warnings []warning.Warning
+	for limit <= 0 || len(history) < limit {
+		commit, err := iter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			warnings = append(warnings, warning.Warning{
+				Code:    warning.HistoryTruncated,
+				Message: fmt.Sprintf("history ends after %d commits: %v", len(history), err),
+			})
+			break
+		}
+		if commit.NumParents() > 1 {
+			continue
+		}
+		info, _, err := commitWithStats(commit)
+		if err != nil {
+			warnings = append(warnings, warning.Warning{
+				Code:    warning.CommitStatsUnavailable,
+				Message: fmt.Sprintf("commit %s is left out of the history: %v", info.Hash, err),
+			})
+			continue
+		}
+		history = append(history, info)
+	}
+	return history, warnings, nil
+}
+
+// FileChanges counts the changes of a file over a period of history.
+type FileChanges struct {
+	Commits      int // Commits that changed the file
+	LinesAdded   int
+	LinesDeleted int
+}
+
+// FileChangesSince returns the changes of the commits reachable from HEAD authored since
+// since, by slash-separated file path. Merge commits are left out, as in CommitHistory, and so
+// are commits whose counts are unavailable, with a warning.
+func FileChangesSince(repoPath string, since time.Time) (map[string]FileChanges, []warning.Warning, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open repository at %s: %w", repoPath, classify(err))
+	}
+	headRef, err := repo.Head()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get HEAD reference: %w", classify(err))
+	}
+	iter, err := repo.Log(&git.LogOptions{From: headRef.Hash(), Since: &since})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	defer iter.Close()
+
+	changes := make(map[string]FileChanges)
+	var warnings []warning.Warning
+	for {
+		commit, err := iter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			warnings = append(warnings, warning.Warning{
+				Code:    warning.HistoryTruncated,
+				Message: fmt.Sprintf("history since %s ends early: %v", since.Format(time.DateOnly), err),
+			})
+			break
+		}
+		if commit.NumParents() > 1 {
+			continue
+		}
+		info, fileStats, err := commitWithStats(commit)
+		if err != nil {
+			warnings = append(warnings, warning.Warning{
+				Code:    warning.CommitStatsUnavailable,
+				Message: fmt.Sprintf("commit %s is left out of the file changes: %v", info.Hash, err),
+			})
+			continue
+		}
+		for _, fs := range fileStats {
+			c := changes[fs.Name]
+			c.Commits++
+			c.LinesAdded += fs.Addition
+			c.LinesDeleted += fs.Deletion
+			changes[fs.Name] = c
+		}
+	}
+	return changes, warnings, nil
+}
+
+// commitWithStats returns the CommitInfo of commit with its file and line counts, and the
+// per-file counts they were summed from. On error, only the hash, message, author and date
+// are set.
+func commitWithStats(commit *object.Commit) (CommitInfo, object.FileStats, error) {
+	info := CommitInfo{
+		Hash:        commit.Hash.String(),
+		Message:     strings.Split(commit.Message, "\n")[0],
+		FullMessage: strings.TrimRight(commit.Message, "\n"),
+		Author:      commit.Author.Name,
+		Email:       commit.Author.Email,
+		Date:        commit.Author.When.String(),
+		When:        commit.Author.When,
+	}
+	fileStats, err := commit.Stats()
+	if err != nil {
+		return info, nil, err
+	}
+	info.FilesChanged = len(fileStats)
+	for _, fileStat := range fileStats {
+		info.LinesAdded += fileStat.Addition
+		info.LinesDeleted += fileStat.Deletion
+	}
+	return info, fileStats, nil
+}
+
+// TagRange is the history from the latest tag reachable from HEAD up to HEAD.
+type TagRange struct {
+	Tag          string       `json:"tag"`          // Name of the tag
+	TagCommit    string       `json:"tagCommit"`    // Hash of the tagged commit
+	Commits      []CommitInfo `json:"commits"`      // Commits after the tag, newest first; merge commits are left out
+	FilesChanged int          `json:"filesChanged"` // Distinct files changed by Commits
+	LinesAdded   int          `json:"linesAdded"`
+	LinesDeleted int          `json:"linesDeleted"`
+}
+
+// ErrNoTag is returned by LatestTagRange when no tag is reachable from HEAD.
+var ErrNoTag = errors.New("no tag is reachable from HEAD")
+
+// LatestTagRange finds the nearest tag reachable from HEAD, like git describe: the tagged
+// commit the fewest parent links away, the newest one at the same distance. It returns the
+// commits since it. Commits whose counts are unavailable are left out with a warning.
+// It returns ErrNoTag if the repository has no tag reachable from HEAD.
+func LatestTagRange(repoPath string) (*TagRange, []warning.Warning, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open repository at %s: %w", repoPath, classify(err))
+	}
+	tags, err := tagsByCommit(repo)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tags) == 0 {
+		return nil, nil, ErrNoTag
+	}
+	headRef, err := repo.Head()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get HEAD reference: %w", classify(err))
+	}
+	head, err := repo.CommitObject(headRef.Hash())
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get HEAD commit: %w", classify(err))
+	}
+
+	// History is listed nearest first, so across merges the first tagged commit holds the
+	// nearest tag rather than the first one down the first parents.
+	hashes, err := historyByDistance(head, func(hash string) bool { return len(tags[hash]) > 0 })
+	if err != nil {
+		return nil, nil, err
+	}
+	tag, index, ok := nearestTag(hashes, tags)
+	if !ok {
+		return nil, nil, ErrNoTag
+	}
+	// The commits since the tag are those it does not reach, on every branch merged since.
+	released, err := ancestors(repo, plumbing.NewHash(hashes[index]))
+	if err != nil {
+		return nil, nil, err
+	}
+	commits, err := commitsSince(head, released)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r := &TagRange{Tag: tag, TagCommit: hashes[index]}
+	var warnings []warning.Warning
+	files := make(map[string]bool)
+	for _, commit := range commits {
+		if commit.NumParents() > 1 {
+			continue
+		}
+		info, fileStats, err := commitWithStats(commit)
+		if err != nil {
+			warnings = append(warnings, warning.Warning{
+				Code:    warning.CommitStatsUnavailable,
+				Message: fmt.Sprintf("commit %s is left out of the changes since %s: %v", info.Hash, tag, err),
+			})
+			continue
+		}
+		r.Commits = append(r.Commits, info)
+		r.LinesAdded += info.LinesAdded
+		r.LinesDeleted += info.LinesDeleted
+		for _, fileStat := range fileStats {
+			files[fileStat.Name] = true
+		}
+	}
+	r.FilesChanged = len(files)
+	return r, warnings, nil
+}
+
+// tagsByCommit returns the names of the tags of repo, keyed by the hash of the commit they
+// point to. Annotated tags are resolved to their commit; tags of other objects are ignored.
+func tagsByCommit(repo *git.Repository) (map[string][]string, error) {
+	refs, err := repo.Tags()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tags: %w", err)
+	}
+	defer refs.Close()
+	tags := make(map[string][]string)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		hash := ref.Hash()
+		if tag, err := repo.TagObject(hash); err == nil {
+			commit, err := tag.Commit()
+			if err != nil {
+				return nil
+			}
+			hash = commit.Hash
+		} else if !errors.Is(err, plumbing.ErrObjectNotFound) {
+			return fmt.Errorf("failed to read tag %s: %w", ref.Name().Short(), err)
+		}
+		tags[hash.String()] = append(tags[hash.String()], ref.Name().Short())
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
+// historyByDistance returns the hashes of the commits reachable from head by their distance
+// from it, in parent links, and newest first at the same distance, up to the first distance
+// holding a commit stop is true for.
+func historyByDistance(head *object.Commit, stop func(hash string) bool) ([]string, error) {
+	var hashes []string
+	seen := map[plumbing.Hash]bool{head.Hash: true}
+	for level, done := []*object.Commit{head}, false; len(level) > 0 && !done; {
+		sort.SliceStable(level, func(a, b int) bool { return level[a].Committer.When.After(level[b].Committer.When) })
+		var next []*object.Commit
+		for _, commit := range level {
+			hashes = append(hashes, commit.Hash.String())
+			done = done || stop(commit.Hash.String())
+			err := commit.Parents().ForEach(func(parent *object.Commit) error {
+				if !seen[parent.Hash] {
+					seen[parent.Hash] = true
+					next = append(next, parent)
+				}
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to read history: %w", err)
+			}
+		}
+		level = next
+	}
+	return hashes, nil
+}
+
+// ancestors returns the hashes of the commits reachable from hash, hash included.
+func ancestors(repo *git.Repository, hash plumbing.Hash) (map[plumbing.Hash]bool, error) {
+	iter, err := repo.Log(&git.LogOptions{From: hash})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	defer iter.Close()
+	reached := make(map[plumbing.Hash]bool)
+	err = iter.ForEach(func(commit *object.Commit) error {
+		reached[commit.Hash] = true
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+	return reached, nil
+}
+
+// commitsSince returns the commits reachable from head but not in excluded, newest first by
+// commit time, as git log excluded..head lists them.
+func commitsSince(head *object.Commit, excluded map[plumbing.Hash]bool) ([]*object.Commit, error) {
+	var commits []*object.Commit
+	seen := make(map[plumbing.Hash]bool)
+	for stack := []*object.Commit{head}; len(stack) > 0; {
+		commit := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if seen[commit.Hash] || excluded[commit.Hash] {
+			continue
+		}
+		seen[commit.Hash] = true
+		commits = append(commits, commit)
+		err := commit.Parents().ForEach(func(parent *object.Commit) error {
+			stack = append(stack, parent)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to read history: %w", err)
+		}
+	}
+	sort.SliceStable(commits, func(a, b int) bool { return commits[a].Committer.When.After(commits[b].Committer.When) })
+	return commits, nil
+}
+
+// nearestTag returns the tag of the first tagged commit of history, a list of commit hashes
+// nearest first, and its index. When a commit has several tags, release tags win over
+// pre-release tags (containing "-", e.g. "v1.2.0-rc1"), then the last by name is picked.
+func nearestTag(history []string, tags map[string][]string) (string, int, bool) {
+	for i, hash := range history {
+		names := tags[hash]
+		if len(names) == 0 {
+			continue
+		}
+		sorted := append([]string(nil), names...)
+		sort.Slice(sorted, func(a, b int) bool {
+			preA, preB := strings.Contains(sorted[a], "-"), strings.Contains(sorted[b], "-")
+			if preA != preB {
+				return preA
+			}
+			return sorted[a] < sorted[b]
+		})
+		return sorted[len(sorted)-1], i, true
+	}
+	return "", 0, false
+}
+
+// ShotgunCommits returns the commits of history that changed more than maxFiles files, in
+// their given order. Changes spread over many files often lack cohesion ("shotgun surgery").
+func ShotgunCommits(history []CommitInfo, maxFiles int) []CommitInfo {
+	var wide []CommitInfo
+	for _, c := range history {
+		if c.FilesChanged > maxFiles {
+			wide = append(wide, c)
+		}
+	}
+	return wide
+}
+
+// GoSourcesAt returns the contents of the Go files in the tree of the given commit, keyed by
+// slash-separated path. The worktree is not touched.
+func GoSourcesAt(repoPath, hash string) (map[string][]byte, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository at %s: %w", repoPath, classify(err))
+	}
+	commit, err := repo.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit object %s: %w", hash, classify(err))
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree of commit %s: %w", hash, err)
+	}
+
+	sources := make(map[string][]byte)
+	err = tree.Files().ForEach(func(f *object.File) error {
+		if !strings.HasSuffix(f.Name, ".go") {
+			return nil
+		}
+		contents, err := f.Contents()
+		if err != nil {
+			return fmt.Errorf("failed to read %s at %s: %w", f.Name, hash, err)
+		}
+		sources[f.Name] = []byte(contents)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sources, nil
+}
+
+// ReadFileAt returns the contents of the file at path (slash-separated) in the tree of the
+// given commit, which may differ from the worktree.
+func ReadFileAt(repoPath, hash, path string) ([]byte, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository at %s: %w", repoPath, classify(err))
+	}
+	commit, err := repo.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit object %s: %w", hash, classify(err))
+	}
+	file, err := commit.File(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find %s at %s: %w", path, hash, err)
+	}
+	contents, err := file.Contents()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s at %s: %w", path, hash, err)
+	}
+	return []byte(contents), nil
+}
+
+// SweepStaleClones removes the clone directories in tempDir (os.TempDir() if empty) that were
+// last modified more than ttl before now, e.g. left behind by a crashed run. Only directories
+// whose name starts with the clone prefix are considered. It returns the removed paths.
+func SweepStaleClones(tempDir string, ttl time.Duration, now time.Time) ([]string, error) {
+	if tempDir == "" {
+		tempDir = os.TempDir()
+	}
+	entries, err := os.ReadDir(tempDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read temp dir %s: %w", tempDir, err)
+	}
+
+	var removed []string
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), clonePrefix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // Removed concurrently
+		}
+		if now.Sub(info.ModTime()) <= ttl {
+			continue
+		}
+		path := filepath.Join(tempDir, entry.Name())
+		if err := os.RemoveAll(path); err != nil {
+			return removed, fmt.Errorf("failed to remove stale clone %s: %w", path, err)
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
@@ -1,10 +1,17 @@
 package git
 
 import (
+	"errors"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 	"sort" // For comparing file lists
+	"time"
+
+	"github.com/user/zenwatch/internal/warning"
 )
 
 const testRepoURL = "https://github.com/git-fixtures/basic.git"
@@ -104,10 +111,37 @@ func TestAnalyzeLatestCommit(t *testing.T) {
 	}
 
 
-	// For a Depth:1 clone, commit.Stats() often returns an error or 0 lines
-	// because the parent commit is not available to compare against.
-	// So, TotalLinesAdded/Deleted might be 0. This is an accepted limitation.
+	// For a Depth:1 clone the patch is taken against the empty tree, so the totals count
+	// every line of the tree as added, and they are the sums of the per-file counts.
 	t.Logf("Retrieved TotalLinesAdded: %d, TotalLinesDeleted: %d", repoInfo.TotalLinesAdded, repoInfo.TotalLinesDeleted)
+	sumAdded, sumDeleted := 0, 0
+	for _, cf := range repoInfo.ChangedFiles {
+		sumAdded += cf.LinesAdded
+		sumDeleted += cf.LinesDeleted
+	}
+	if repoInfo.TotalLinesAdded != sumAdded || repoInfo.TotalLinesDeleted != sumDeleted {
+		t.Errorf("Expected RepositoryInfo totals (+%d/-%d) to be the sums of ChangedFiles (+%d/-%d)",
+			repoInfo.TotalLinesAdded, repoInfo.TotalLinesDeleted, sumAdded, sumDeleted)
+	}
+	if repoInfo.TotalLinesAdded != repoInfo.LatestCommit.LinesAdded || repoInfo.TotalLinesDeleted != repoInfo.LatestCommit.LinesDeleted {
+		t.Errorf("Expected RepositoryInfo totals (+%d/-%d) to match LatestCommit (+%d/-%d)",
+			repoInfo.TotalLinesAdded, repoInfo.TotalLinesDeleted, repoInfo.LatestCommit.LinesAdded, repoInfo.LatestCommit.LinesDeleted)
+	}
+	if repoInfo.TotalLinesAdded == 0 || repoInfo.TotalLinesDeleted != 0 {
+		t.Errorf("Expected only added lines against the empty tree, got +%d/-%d", repoInfo.TotalLinesAdded, repoInfo.TotalLinesDeleted)
+	}
+
+	// Both limitations of the Depth:1 clone must be reported as warnings rather than hidden.
+	warningCodes := make(map[warning.Code]bool)
+	for _, w := range repoInfo.Warnings {
+		warningCodes[w.Code] = true
+	}
+	if !warningCodes[warning.ShallowCloneFallback] {
+		t.Errorf("Expected a %s warning for the parentless Depth:1 clone, got %+v", warning.ShallowCloneFallback, repoInfo.Warnings)
+	}
+	if repoInfo.LatestCommit.FilesChanged == 0 && !warningCodes[warning.CommitStatsUnavailable] {
+		t.Errorf("Expected a %s warning when commit stats are missing, got %+v", warning.CommitStatsUnavailable, repoInfo.Warnings)
+	}
 
 
 	// Check ChangedFiles: For a Depth:1 clone, AnalyzeLatestCommit diffs the tree against an empty one.
@@ -140,12 +174,12 @@ func TestAnalyzeLatestCommit(t *testing.T) {
 		    }
 		}
 
-		// Per-file lines are expected to be 0 due to current limitations
-		if cf.LinesAdded != 0 {
-			t.Errorf("Expected LinesAdded to be 0 for file %s due to limitations, got %d", cf.Path, cf.LinesAdded)
-		}
+		// Against the empty tree, every text file only adds lines; the binary file adds none.
 		if cf.LinesDeleted != 0 {
-			t.Errorf("Expected LinesDeleted to be 0 for file %s due to limitations, got %d", cf.Path, cf.LinesDeleted)
+			t.Errorf("Expected no deleted lines for file %s, got %d", cf.Path, cf.LinesDeleted)
+		}
+		if (cf.Path == "binary.jpg") != (cf.LinesAdded == 0) {
+			t.Errorf("Unexpected LinesAdded %d for file %s", cf.LinesAdded, cf.Path)
 		}
 	}
 
@@ -197,3 +231,476 @@ func TestCleanup(t *testing.T) {
 		t.Errorf("Expected directory %s to be removed by Cleanup, but it still exists.", dummyPath)
 	}
 }
+
+func TestSweepStaleClones(t *testing.T) {
+	tempDir := t.TempDir()
+	now := time.Now()
+	old := now.Add(-48 * time.Hour)
+
+	// name -> whether it must be swept
+	entries := map[string]bool{
+		clonePrefix + "stale1": true,
+		clonePrefix + "stale2": true,
+		clonePrefix + "fresh":  false,
+		"other-tool-stale":     false, // Unknown prefix: never touched
+		"zenwatch-cache-stale": false,
+	}
+	for name := range entries {
+		dir := filepath.Join(tempDir, name)
+		if err := os.MkdirAll(filepath.Join(dir, ".git"), 0o755); err != nil {
+			t.Fatalf("Failed to create %s: %v", dir, err)
+		}
+		mtime := old
+		if name == clonePrefix+"fresh" {
+			mtime = now
+		}
+		if err := os.Chtimes(dir, mtime, mtime); err != nil {
+			t.Fatalf("Failed to age %s: %v", dir, err)
+		}
+	}
+	// A stale file with the prefix is not a clone directory.
+	staleFile := filepath.Join(tempDir, clonePrefix+"file")
+	if err := os.WriteFile(staleFile, nil, 0o644); err != nil {
+		t.Fatalf("Failed to create %s: %v", staleFile, err)
+	}
+	os.Chtimes(staleFile, old, old)
+
+	removed, err := SweepStaleClones(tempDir, 24*time.Hour, now)
+	if err != nil {
+		t.Fatalf("SweepStaleClones failed: %v", err)
+	}
+	if len(removed) != 2 {
+		t.Errorf("Expected 2 removed clones, got %v", removed)
+	}
+	for name, stale := range entries {
+		_, err := os.Stat(filepath.Join(tempDir, name))
+		if exists := err == nil; exists == stale {
+			t.Errorf("%s: expected removed=%v", name, stale)
+		}
+	}
+	if _, err := os.Stat(staleFile); err != nil {
+		t.Errorf("Expected non-directory %s to be kept", staleFile)
+	}
+}
+
+// runGit runs a git command in dir for building local fixture repositories.
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=Test", "GIT_COMMITTER_EMAIL=test@example.com")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, out)
+	}
+}
+
+func TestCloneRepositoryBranch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping TestCloneRepositoryBranch: git not on PATH")
+	}
+	src := t.TempDir()
+	if err := os.WriteFile(filepath.Join(src, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, src, "init", "-q", "-b", "main")
+	runGit(t, src, "add", ".")
+	runGit(t, src, "commit", "-q", "-m", "Add main")
+	runGit(t, src, "checkout", "-q", "-b", "develop")
+	runGit(t, src, "commit", "-q", "--allow-empty", "-m", "Start develop")
+	runGit(t, src, "checkout", "-q", "main")
+
+	for _, tt := range []struct {
+		branch, wantBranch, wantMessage string
+	}{
+		{"", "main", "Add main"},
+		{"develop", "develop", "Start develop"},
+		{"refs/heads/develop", "develop", "Start develop"},
+	} {
+		clone, _, err := CloneRepositoryWithOptions(src, CloneOptions{Depth: 1, Branch: tt.branch})
+		if err != nil {
+			t.Fatalf("Cloning branch %q failed: %v", tt.branch, err)
+		}
+		defer Cleanup(clone)
+		info, err := AnalyzeLatestCommit(clone)
+		if err != nil {
+			t.Fatalf("AnalyzeLatestCommit failed: %v", err)
+		}
+		if info.Branch != tt.wantBranch || info.LatestCommit.Message != tt.wantMessage {
+			t.Errorf("Branch %q: expected %q at %q, got %q at %q", tt.branch, tt.wantMessage, tt.wantBranch, info.LatestCommit.Message, info.Branch)
+		}
+	}
+
+	_, _, err := CloneRepositoryWithOptions(src, CloneOptions{Depth: 1, Branch: "release"})
+	if !errors.Is(err, ErrRefNotFound) || !strings.Contains(err.Error(), `branch "release" does not exist`) || strings.Contains(err.Error(), "couldn't find remote ref") {
+		t.Errorf("Expected a clear error for a missing branch, got %v", err)
+	}
+}
+
+func TestAnalyzeLatestCommitLineCounts(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping TestAnalyzeLatestCommitLineCounts: git not on PATH")
+	}
+	src := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(src, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("main.go", "package main\n\nfunc main() {\n}\n")
+	write("old.txt", "one\ntwo\nthree")
+	runGit(t, src, "init", "-q", "-b", "main")
+	runGit(t, src, "add", ".")
+	runGit(t, src, "commit", "-q", "-m", "Initial")
+
+	info, err := AnalyzeLatestCommit(src)
+	if err != nil {
+		t.Fatalf("AnalyzeLatestCommit failed: %v", err)
+	}
+	// The initial commit is diffed against the empty tree; old.txt has no trailing newline.
+	if info.TotalLinesAdded != 7 || info.TotalLinesDeleted != 0 {
+		t.Errorf("Expected +7/-0 for the initial commit, got +%d/-%d", info.TotalLinesAdded, info.TotalLinesDeleted)
+	}
+
+	write("main.go", "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(1)\n}\n")
+	write("new.txt", "a\nb\n")
+	runGit(t, src, "rm", "-q", "old.txt")
+	runGit(t, src, "add", ".")
+	runGit(t, src, "commit", "-q", "-m", "Second")
+
+	info, err = AnalyzeLatestCommit(src)
+	if err != nil {
+		t.Fatalf("AnalyzeLatestCommit failed: %v", err)
+	}
+	got := make(map[string][2]int)
+	for _, cf := range info.ChangedFiles {
+		got[cf.Path] = [2]int{cf.LinesAdded, cf.LinesDeleted}
+	}
+	want := map[string][2]int{"main.go": {3, 0}, "new.txt": {2, 0}, "old.txt": {0, 3}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected per-file counts %v, got %v", want, got)
+	}
+	if info.TotalLinesAdded != 5 || info.TotalLinesDeleted != 3 {
+		t.Errorf("Expected totals +5/-3, got +%d/-%d", info.TotalLinesAdded, info.TotalLinesDeleted)
+	}
+	if info.TotalLinesAdded != info.LatestCommit.LinesAdded || info.TotalLinesDeleted != info.LatestCommit.LinesDeleted {
+		t.Errorf("Expected totals to match the commit stats +%d/-%d", info.LatestCommit.LinesAdded, info.LatestCommit.LinesDeleted)
+	}
+
+	// Without the parent in a depth-1 clone, the commit is counted like the files it lists
+	// rather than left at 0.
+	shallow := filepath.Join(t.TempDir(), "shallow")
+	runGit(t, src, "clone", "-q", "--depth", "1", "file://"+src, shallow)
+	info, err = AnalyzeLatestCommit(shallow)
+	if err != nil {
+		t.Fatalf("AnalyzeLatestCommit failed: %v", err)
+	}
+	c := info.LatestCommit
+	if c.FilesChanged != 2 || c.LinesAdded != 9 || c.LinesDeleted != 0 {
+		t.Errorf("Expected the commit stats of the tree, 2 files +9/-0, got %d files +%d/-%d", c.FilesChanged, c.LinesAdded, c.LinesDeleted)
+	}
+	if info.TotalLinesAdded != c.LinesAdded || info.TotalLinesDeleted != c.LinesDeleted || len(info.ChangedFiles) != c.FilesChanged {
+		t.Errorf("Expected totals +%d/-%d of %d files to match the commit stats", info.TotalLinesAdded, info.TotalLinesDeleted, len(info.ChangedFiles))
+	}
+}
+
+func TestAnalyzeLatestCommitDepth(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping TestAnalyzeLatestCommitDepth: git not on PATH")
+	}
+	src := t.TempDir()
+	for i, content := range []string{"one\n", "one\ntwo\n", "one\ntwo\nthree\n"} {
+		if err := os.WriteFile(filepath.Join(src, "list.txt"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			if err := os.WriteFile(filepath.Join(src, "static.txt"), []byte("static\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			runGit(t, src, "init", "-q", "-b", "main")
+		}
+		runGit(t, src, "add", ".")
+		runGit(t, src, "commit", "-q", "-m", "Commit "+string(rune('1'+i)))
+	}
+
+	for _, tt := range []struct {
+		depth     int
+		wantFiles []string // Changed files of HEAD
+		fallback  bool     // Diffed against an empty tree for lack of the parent
+	}{
+		{1, []string{"list.txt", "static.txt"}, true},
+		{2, []string{"list.txt"}, false},
+		{0, []string{"list.txt"}, false},
+	} {
+		path, _, err := CloneRepositoryWithOptions(src, CloneOptions{Depth: tt.depth})
+		if err != nil {
+			t.Fatalf("Depth %d: clone failed: %v", tt.depth, err)
+		}
+		defer Cleanup(path)
+		info, err := AnalyzeLatestCommit(path)
+		if err != nil {
+			t.Fatalf("Depth %d: AnalyzeLatestCommit failed: %v", tt.depth, err)
+		}
+		var files []string
+		for _, cf := range info.ChangedFiles {
+			files = append(files, cf.Path)
+		}
+		fallback := false
+		for _, w := range info.Warnings {
+			fallback = fallback || w.Code == warning.ShallowCloneFallback
+		}
+		if !reflect.DeepEqual(files, tt.wantFiles) || fallback != tt.fallback {
+			t.Errorf("Depth %d: expected changed files %v and fallback %v, got %v and %v", tt.depth, tt.wantFiles, tt.fallback, files, fallback)
+		}
+		// Against its parent, HEAD only added the third line.
+		if !tt.fallback && (info.TotalLinesAdded != 1 || info.TotalLinesDeleted != 0) {
+			t.Errorf("Depth %d: expected the parent diff +1/-0, got +%d/-%d", tt.depth, info.TotalLinesAdded, info.TotalLinesDeleted)
+		}
+	}
+}
+
+func TestAnalyzeCommit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping TestAnalyzeCommit: git not on PATH")
+	}
+	src := t.TempDir()
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(src, "main.go"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("package main\n")
+	runGit(t, src, "init", "-q", "-b", "main")
+	runGit(t, src, "add", ".")
+	runGit(t, src, "commit", "-q", "-m", "First")
+	write("package main\n\nfunc main() {}\n")
+	runGit(t, src, "commit", "-q", "-am", "Second")
+	runGit(t, src, "checkout", "-q", "-b", "side")
+	write("package main\n\nfunc main() { println() }\n")
+	runGit(t, src, "commit", "-q", "-am", "Side")
+	runGit(t, src, "checkout", "-q", "main")
+	write("package main\n\nfunc main() {}\n\nvar x = 1\n")
+	runGit(t, src, "commit", "-q", "-am", "Third")
+	revParse := func(rev string) string {
+		t.Helper()
+		out, err := exec.Command("git", "-C", src, "rev-parse", rev).Output()
+		if err != nil {
+			t.Fatalf("git rev-parse %s failed: %v", rev, err)
+		}
+		return strings.TrimSpace(string(out))
+	}
+	second, side := revParse("main~1"), revParse("side")
+
+	for _, hash := range []string{second, second[:7], strings.ToUpper(second[:10])} {
+		info, err := AnalyzeCommit(src, hash)
+		if err != nil {
+			t.Fatalf("AnalyzeCommit(%s) failed: %v", hash, err)
+		}
+		if info.LatestCommit.Hash != second || info.LatestCommit.Message != "Second" || info.Branch != "" {
+			t.Errorf("AnalyzeCommit(%s): expected Second off any branch, got %s %q on %q", hash, info.LatestCommit.Hash, info.LatestCommit.Message, info.Branch)
+		}
+		if info.TotalLinesAdded != 2 || info.TotalLinesDeleted != 0 {
+			t.Errorf("AnalyzeCommit(%s): expected the diff against First, got +%d/-%d", hash, info.TotalLinesAdded, info.TotalLinesDeleted)
+		}
+	}
+	for _, tt := range []struct{ hash, want string }{
+		{second[:6], "expected 7 to 40 hexadecimal characters"},
+		{"zzzzzzz", "expected 7 to 40 hexadecimal characters"},
+		{"0000000", "does not exist"},
+		{strings.Repeat("0", 40), "does not exist"},
+	} {
+		if _, err := AnalyzeCommit(src, tt.hash); err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("AnalyzeCommit(%s): expected an error containing %q, got %v", tt.hash, tt.want, err)
+		}
+	}
+	if _, err := AnalyzeCommit(src, "0000000"); !errors.Is(err, ErrRefNotFound) {
+		t.Errorf("Expected a missing commit to be ErrRefNotFound, got %v", err)
+	}
+
+	// A clone asked for a commit of another branch clones the history and checks it out.
+	clone, _, err := CloneRepositoryWithOptions(src, CloneOptions{Depth: 1, Commit: side[:8]})
+	if err != nil {
+		t.Fatalf("Cloning commit %s failed: %v", side, err)
+	}
+	defer Cleanup(clone)
+	info, err := AnalyzeLatestCommit(clone)
+	if err != nil {
+		t.Fatalf("AnalyzeLatestCommit failed: %v", err)
+	}
+	if info.LatestCommit.Hash != side || len(info.Warnings) != 0 {
+		t.Errorf("Expected HEAD at %s with its parent, got %s and warnings %v", side, info.LatestCommit.Hash, info.Warnings)
+	}
+	if content, err := os.ReadFile(filepath.Join(clone, "main.go")); err != nil || !strings.Contains(string(content), "println") {
+		t.Errorf("Expected the worktree of the side commit, got %q, %v", content, err)
+	}
+	if _, _, err := CloneRepositoryWithOptions(src, CloneOptions{Commit: "0000000"}); !errors.Is(err, ErrRefNotFound) {
+		t.Errorf("Expected cloning a missing commit to be ErrRefNotFound, got %v", err)
+	}
+}
+
+func TestFileChangesSince(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping TestFileChangesSince: git not on PATH")
+	}
+	src := t.TempDir()
+	commit := func(date, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(src, "a.go"), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		t.Setenv("GIT_COMMITTER_DATE", date)
+		runGit(t, src, "add", ".")
+		runGit(t, src, "commit", "-q", "-m", "Change a", "--date", date)
+	}
+	runGit(t, src, "init", "-q", "-b", "main")
+	commit("2024-01-01T00:00:00Z", "package a\n")
+	commit("2024-05-01T00:00:00Z", "package a\n\nvar x = 1\n")
+	commit("2024-06-01T00:00:00Z", "package a\n\nvar x = 2\n")
+
+	changes, warnings, err := FileChangesSince(src, time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("FileChangesSince failed: %v", err)
+	}
+	if want := (FileChanges{Commits: 2, LinesAdded: 3, LinesDeleted: 1}); changes["a.go"] != want || len(changes) != 1 || len(warnings) != 0 {
+		t.Errorf("Expected %+v for a.go since April, got %+v and warnings %v", want, changes, warnings)
+	}
+}
+
+func TestCopyUntrackedFiles(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping TestCopyUntrackedFiles: git not on PATH")
+	}
+
+	src := t.TempDir()
+	files := map[string]string{
+		".gitignore":         "*.log\nbuild/\n",
+		"tracked.go":         "package main\n",
+		"wip/new.go":         "package wip\n",
+		"debug.log":          "ignored\n",
+		"build/generated.go": "package build\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(src, filepath.FromSlash(name))
+		os.MkdirAll(filepath.Dir(path), 0o755)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+	runGit(t, src, "init", "-q")
+	runGit(t, src, "add", ".gitignore", "tracked.go")
+	runGit(t, src, "commit", "-q", "-m", "initial")
+
+	dst := t.TempDir()
+	copied, err := CopyUntrackedFiles(src, dst)
+	if err != nil {
+		t.Fatalf("CopyUntrackedFiles failed: %v", err)
+	}
+	if len(copied) != 1 || copied[0] != "wip/new.go" {
+		t.Errorf("Expected only wip/new.go to be copied, got %v", copied)
+	}
+	if content, err := os.ReadFile(filepath.Join(dst, "wip", "new.go")); err != nil || string(content) != files["wip/new.go"] {
+		t.Errorf("Expected wip/new.go in the destination, got %q, %v", content, err)
+	}
+	for _, name := range []string{"debug.log", "build/generated.go", "tracked.go"} {
+		if _, err := os.Stat(filepath.Join(dst, filepath.FromSlash(name))); err == nil {
+			t.Errorf("Expected %s not to be copied", name)
+		}
+	}
+}
+
+func TestProgressCounter(t *testing.T) {
+	p := &progressCounter{}
+	// Sideband messages arrive in arbitrary chunks, with \r separating updates of a line.
+	chunks := []string{
+		"Enumerating objects: 31, done.\nCounting objects:  50% (15/",
+		"31)\rCounting objects: 100% (31/31), done.\n",
+		"Compressing objects: 100% (20/20), done.\nTo",
+		"tal 31 (delta 4), reused 25 (delta 2), pack-reused 0\n",
+	}
+	for _, chunk := range chunks {
+		if n, err := p.Write([]byte(chunk)); n != len(chunk) || err != nil {
+			t.Fatalf("Write returned %d, %v for a %d byte chunk", n, err, len(chunk))
+		}
+	}
+	if p.objects != 31 {
+		t.Errorf("Expected 31 objects, got %d", p.objects)
+	}
+
+	// Servers that only report counting progress are still counted.
+	p = &progressCounter{}
+	p.Write([]byte("remote: Counting objects: 100% (12/12), done.\n"))
+	if p.objects != 12 {
+		t.Errorf("Expected 12 objects from the counting progress, got %d", p.objects)
+	}
+}
+
+func TestShotgunCommits(t *testing.T) {
+	history := []CommitInfo{
+		{Hash: "c4", FilesChanged: 2},
+		{Hash: "c3", FilesChanged: 3},
+		{Hash: "c2", FilesChanged: 42, Message: "rename the logger everywhere"},
+		{Hash: "c1", FilesChanged: 1},
+	}
+	wide := ShotgunCommits(history, 3)
+	if len(wide) != 1 || wide[0].Hash != "c2" {
+		t.Errorf("Expected only the 42-file commit to be flagged, got %v", wide)
+	}
+	if wide := ShotgunCommits(history, 50); len(wide) != 0 {
+		t.Errorf("Expected no commit over 50 files, got %v", wide)
+	}
+}
+
+func TestNearestTag(t *testing.T) {
+	// c5 (HEAD) - c4 - c3 (v1.1.0, v1.1.0-rc1) - c2 - c1 (v1.0.0)
+	history := []string{"c5", "c4", "c3", "c2", "c1"}
+	tags := map[string][]string{"c1": {"v1.0.0"}, "c3": {"v1.1.0-rc1", "v1.1.0"}, "other": {"v2.0.0"}}
+
+	tag, index, ok := nearestTag(history, tags)
+	if !ok || tag != "v1.1.0" || index != 2 {
+		t.Errorf("Expected v1.1.0 at index 2, got %q at %d (found %v)", tag, index, ok)
+	}
+	if _, _, ok := nearestTag(history, map[string][]string{"other": {"v2.0.0"}}); ok {
+		t.Errorf("Expected no tag reachable from HEAD")
+	}
+}
+
+func TestLatestTagRangeAcrossMerge(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping TestLatestTagRangeAcrossMerge: git not on PATH")
+	}
+	// M (HEAD) merges C and F; F holds v0.2, the nearest tag, while the first parents lead to v0.1.
+	//   A (v0.1) - B - C - M
+	//               \     /
+	//                F (v0.2)
+	repo := t.TempDir()
+	commit := func(file, message string) {
+		if err := os.WriteFile(filepath.Join(repo, file), []byte(message+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		runGit(t, repo, "add", ".")
+		runGit(t, repo, "commit", "-q", "-m", message)
+	}
+	runGit(t, repo, "init", "-q", "-b", "main")
+	commit("a.txt", "A")
+	runGit(t, repo, "tag", "v0.1")
+	commit("b.txt", "B")
+	runGit(t, repo, "checkout", "-q", "-b", "feature")
+	commit("f.txt", "F")
+	runGit(t, repo, "tag", "v0.2")
+	runGit(t, repo, "checkout", "-q", "main")
+	commit("c.txt", "C")
+	runGit(t, repo, "merge", "-q", "--no-ff", "-m", "M", "feature")
+
+	r, _, err := LatestTagRange(repo)
+	if err != nil {
+		t.Fatalf("LatestTagRange failed: %v", err)
+	}
+	if r.Tag != "v0.2" {
+		t.Errorf("Expected the nearest tag v0.2, got %s", r.Tag)
+	}
+	// The merge is left out; B and A are released with v0.2.
+	if len(r.Commits) != 1 || r.Commits[0].Message != "C" || r.FilesChanged != 1 {
+		t.Errorf("Expected only C since v0.2, got %+v", r.Commits)
+	}
+}
@@ -0,0 +1,243 @@
+package git
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+
+	"github.com/user/zenwatch/internal/warning"
+)
+
+// Submodule modes, as accepted by --submodules.
+const (
+	SubmodulesNone    = "none"    // Submodules are listed with their pinned commit, not fetched
+	SubmodulesShallow = "shallow" // Submodules are fetched at their pinned commit, so their files are analyzed
+	SubmodulesFull    = "full"    // As shallow, also analyzing the pinned commit of every submodule
+)
+
+// DefaultSubmoduleDepth is the nesting of the submodules FetchSubmodules fetches by default:
+// the submodules of the repository, theirs and theirs.
+const DefaultSubmoduleDepth = 3
+
+// Submodule is a submodule of an analyzed commit: a gitlink of its tree and the entry of
+// .gitmodules for its path.
+type Submodule struct {
+	Path         string      `json:"path"`                   // Slash-separated path from the repository root, through the submodules it is nested in
+	URL          string      `json:"url"`                    // URL in .gitmodules; empty if .gitmodules has no entry for Path
+	Commit       string      `json:"commit"`                 // Hash of the pinned commit
+	Depth        int         `json:"depth"`                  // 1 for the submodules of the repository, 2 for theirs, and so on
+	Fetched      bool        `json:"fetched"`                // The files of the pinned commit are in the clone at Path
+	LatestCommit *CommitInfo `json:"latestCommit,omitempty"` // Optional: the pinned commit and the files it changed, with SubmodulesFull
+}
+
+// SubmoduleOptions configures FetchSubmodules.
+type SubmoduleOptions struct {
+	Mode     string // SubmodulesShallow or SubmodulesFull
+	MaxDepth int    // Deepest nesting to fetch; 0 means DefaultSubmoduleDepth
+}
+
+// ListSubmodules returns the submodules of the commit with the given full hash in the clone
+// at repoPath, by path: the gitlinks of its tree with the URLs its .gitmodules gives them.
+func ListSubmodules(repoPath, hash string) ([]Submodule, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository at %s: %w", repoPath, classify(err))
+	}
+	commit, err := repo.CommitObject(plumbing.NewHash(hash))
+	if err != nil {
+		return nil, fmt.Errorf("failed to find commit %s: %w", hash, err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tree of commit %s: %w", hash, err)
+	}
+
+	urls := make(map[string]string)
+	if file, err := tree.File(".gitmodules"); err == nil {
+		content, err := file.Contents()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read .gitmodules: %w", err)
+		}
+		modules := config.NewModules()
+		if err := modules.Unmarshal([]byte(content)); err != nil {
+			return nil, fmt.Errorf("failed to parse .gitmodules: %w", err)
+		}
+		for _, m := range modules.Submodules {
+			urls[path.Clean(m.Path)] = m.URL
+		}
+	} else if !errors.Is(err, object.ErrFileNotFound) {
+		return nil, fmt.Errorf("failed to read .gitmodules: %w", err)
+	}
+
+	var submodules []Submodule
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+	for {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to walk tree of commit %s: %w", hash, err)
+		}
+		if entry.Mode == filemode.Submodule {
+			submodules = append(submodules, Submodule{Path: name, URL: urls[name], Commit: entry.Hash.String(), Depth: 1})
+		}
+	}
+	return submodules, nil
+}
+
+// FetchSubmodules fetches each of submodules, as listed by ListSubmodules, into the clone at
+// repoPath and checks out its pinned commit, so its files are analyzed under its path.
+// SubmodulesShallow clones a submodule at depth 1, and with its full history when the pinned
+// commit is no longer the tip of its default branch; SubmodulesFull always clones the full
+// history and also analyzes the pinned commit. The submodules of fetched submodules are
+// fetched in turn, up to opts.MaxDepth, and returned after their parent; those nested deeper
+// are listed without being fetched.
+//
+// Relative URLs are resolved against baseURL, the URL of the repository. A submodule that
+// cannot be fetched is left unfetched with a warning, and the others still are.
+func FetchSubmodules(repoPath, baseURL string, submodules []Submodule, opts SubmoduleOptions) ([]Submodule, []warning.Warning) {
+	maxDepth := opts.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = DefaultSubmoduleDepth
+	}
+	var (
+		fetched  []Submodule
+		warnings []warning.Warning
+	)
+	for _, sm := range submodules {
+		if sm.Depth > maxDepth {
+			fetched = append(fetched, sm)
+			warnings = append(warnings, warning.Warning{
+				Code:    warning.SubmoduleUnavailable,
+				Message: fmt.Sprintf("the submodule is nested deeper than the submodule depth limit of %d, so it was not fetched", maxDepth),
+				File:    sm.Path,
+			})
+			continue
+		}
+		subURL := resolveSubmoduleURL(baseURL, sm.URL)
+		dir := filepath.Join(repoPath, filepath.FromSlash(sm.Path))
+		nested, err := fetchSubmodule(dir, subURL, sm, opts)
+		if err != nil {
+			fetched = append(fetched, sm)
+			warnings = append(warnings, warning.Warning{
+				Code:    warning.SubmoduleUnavailable,
+				Message: fmt.Sprintf("the submodule could not be fetched, so its files are not analyzed: %v", err),
+				File:    sm.Path,
+			})
+			continue
+		}
+		sm.Fetched = true
+		if opts.Mode == SubmodulesFull {
+			info, err := AnalyzeLatestCommit(dir)
+			if err != nil {
+				warnings = append(warnings, warning.Warning{
+					Code:    warning.SubmoduleUnavailable,
+					Message: fmt.Sprintf("the pinned commit of the submodule could not be analyzed: %v", err),
+					File:    sm.Path,
+				})
+			} else {
+				sm.LatestCommit = &info.LatestCommit
+			}
+		}
+		fetched = append(fetched, sm)
+		for i := range nested {
+			nested[i].Path = sm.Path + "/" + nested[i].Path
+			nested[i].Depth = sm.Depth + 1
+		}
+		nestedFetched, nestedWarnings := FetchSubmodules(repoPath, subURL, nested, opts)
+		fetched = append(fetched, nestedFetched...)
+		warnings = append(warnings, nestedWarnings...)
+	}
+	return fetched, warnings
+}
+
+// fetchSubmodule clones the submodule sm from url into dir, checked out at its pinned
+// commit, and returns the submodules of that commit.
+func fetchSubmodule(dir, url string, sm Submodule, opts SubmoduleOptions) ([]Submodule, error) {
+	if sm.URL == "" {
+		return nil, errors.New("no URL for it in .gitmodules")
+	}
+	if !filepath.IsLocal(filepath.FromSlash(sm.Path)) {
+		return nil, fmt.Errorf("path %q is outside the repository", sm.Path)
+	}
+	depth := 0
+	if opts.Mode == SubmodulesShallow {
+		depth = 1
+	}
+	clone, err := cloneAtCommit(url, sm.Commit, depth)
+	if err != nil && depth > 0 {
+		clone, err = cloneAtCommit(url, sm.Commit, 0)
+	}
+	if err != nil {
+		return nil, err
+	}
+	// The clone replaces the empty directory of the gitlink.
+	if err := os.Remove(dir); err != nil && !errors.Is(err, os.ErrNotExist) {
+		Cleanup(clone)
+		return nil, fmt.Errorf("failed to replace the directory of the submodule: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		Cleanup(clone)
+		return nil, fmt.Errorf("failed to create directory for the submodule: %w", err)
+	}
+	if err := os.Rename(clone, dir); err != nil {
+		Cleanup(clone)
+		return nil, fmt.Errorf("failed to move the submodule into the clone: %w", err)
+	}
+	return ListSubmodules(dir, sm.Commit)
+}
+
+// cloneAtCommit clones the repository at url keeping depth commits of history and checks out
+// the commit with the given full hash, which must be in that history.
+func cloneAtCommit(url, hash string, depth int) (string, error) {
+	clone, err := CloneRepositoryWithDepth(url, depth)
+	if err != nil {
+		return "", err
+	}
+	repo, err := git.PlainOpen(clone)
+	if err != nil {
+		Cleanup(clone)
+		return "", fmt.Errorf("failed to open repository at %s: %w", clone, classify(err))
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		Cleanup(clone)
+		return "", fmt.Errorf("failed to get worktree: %w", err)
+	}
+	if err := worktree.Checkout(&git.CheckoutOptions{Hash: plumbing.NewHash(hash), Force: true}); err != nil {
+		Cleanup(clone)
+		return "", fmt.Errorf("failed to check out pinned commit %s: %w", hash, err)
+	}
+	return clone, nil
+}
+
+// resolveSubmoduleURL resolves the URL of a submodule in .gitmodules against baseURL, the URL
+// of its parent repository, as git does: a URL starting with "./" or "../" is relative to
+// baseURL as if it were a directory, others are used as they are.
+func resolveSubmoduleURL(baseURL, subURL string) string {
+	if !strings.HasPrefix(subURL, "./") && !strings.HasPrefix(subURL, "../") {
+		return subURL
+	}
+	base := strings.TrimSuffix(baseURL, "/")
+	if u, err := url.Parse(base); err == nil && u.Scheme != "" && u.Host != "" {
+		u.Path = path.Join(u.Path, subURL)
+		return u.String()
+	}
+	if host, repoPath, ok := strings.Cut(base, ":"); ok && !strings.Contains(host, "/") && len(host) > 1 {
+		return host + ":" + path.Join(repoPath, subURL) // scp-like git@host:user/repo.git
+	}
+	return filepath.Join(base, filepath.FromSlash(subURL))
+}
@@ -0,0 +1,134 @@
+package git
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/user/zenwatch/internal/warning"
+)
+
+func TestFetchSubmodules(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping TestFetchSubmodules: git not on PATH")
+	}
+
+	newRepo := func(name, content string) string {
+		t.Helper()
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		runGit(t, dir, "init", "-q")
+		runGit(t, dir, "add", ".")
+		runGit(t, dir, "commit", "-q", "-m", "initial")
+		return dir
+	}
+	addSubmodule := func(dir, url, path string) {
+		t.Helper()
+		runGit(t, dir, "-c", "protocol.file.allow=always", "submodule", "add", "-q", url, path)
+	}
+	head := func(dir string) string {
+		t.Helper()
+		out, err := exec.Command("git", "-C", dir, "rev-parse", "HEAD").Output()
+		if err != nil {
+			t.Fatalf("git rev-parse failed: %v", err)
+		}
+		return strings.TrimSpace(string(out))
+	}
+
+	deep := newRepo("deep.go", "package deep\n")
+	lib := newRepo("lib.go", "package lib\n")
+	addSubmodule(lib, deep, "third_party/deep")
+	runGit(t, lib, "commit", "-q", "-m", "add deep")
+	pinned := head(lib)
+
+	super := newRepo("main.go", "package main\n")
+	addSubmodule(super, lib, "libs/lib")
+	addSubmodule(super, deep, "broken")
+	runGit(t, super, "config", "-f", ".gitmodules", "submodule.broken.url", filepath.Join(t.TempDir(), "missing"))
+	runGit(t, super, "add", ".gitmodules")
+	runGit(t, super, "commit", "-q", "-m", "add submodules")
+	// The pinned commit is no longer the tip of lib, so a clone at depth 1 does not have it.
+	if err := os.WriteFile(filepath.Join(lib, "later.go"), []byte("package lib\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	runGit(t, lib, "add", "later.go")
+	runGit(t, lib, "commit", "-q", "-m", "later")
+
+	list := func() (string, []Submodule) {
+		t.Helper()
+		clone, err := CloneRepository(super)
+		if err != nil {
+			t.Fatalf("Failed to clone %s: %v", super, err)
+		}
+		t.Cleanup(func() { Cleanup(clone) })
+		submodules, err := ListSubmodules(clone, head(super))
+		if err != nil {
+			t.Fatalf("ListSubmodules failed: %v", err)
+		}
+		return clone, submodules
+	}
+
+	clone, submodules := list()
+	if len(submodules) != 2 || submodules[1] != (Submodule{Path: "libs/lib", URL: lib, Commit: pinned, Depth: 1}) {
+		t.Fatalf("Expected the submodules broken and libs/lib pinned at %s, got %+v", pinned, submodules)
+	}
+
+	fetched, warnings := FetchSubmodules(clone, super, submodules, SubmoduleOptions{Mode: SubmodulesShallow})
+	var got []string
+	for _, sm := range fetched {
+		got = append(got, fmt.Sprintf("%s depth=%d fetched=%v", sm.Path, sm.Depth, sm.Fetched))
+	}
+	want := []string{"broken depth=1 fetched=false", "libs/lib depth=1 fetched=true", "libs/lib/third_party/deep depth=2 fetched=true"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected the submodules %v, got %v", want, got)
+	}
+	if len(warnings) != 1 || warnings[0].Code != warning.SubmoduleUnavailable || warnings[0].File != "broken" {
+		t.Errorf("Expected a submodule-unavailable warning for broken, got %+v", warnings)
+	}
+	for _, name := range []string{"libs/lib/lib.go", "libs/lib/third_party/deep/deep.go"} {
+		if _, err := os.Stat(filepath.Join(clone, filepath.FromSlash(name))); err != nil {
+			t.Errorf("Expected %s in the clone, got %v", name, err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(clone, "libs", "lib", "later.go")); err == nil {
+		t.Error("Expected libs/lib at its pinned commit, without later.go")
+	}
+	if fetched[1].LatestCommit != nil {
+		t.Errorf("Expected no commit analysis in shallow mode, got %+v", fetched[1].LatestCommit)
+	}
+
+	clone, submodules = list()
+	fetched, warnings = FetchSubmodules(clone, super, submodules, SubmoduleOptions{Mode: SubmodulesFull, MaxDepth: 1})
+	if len(fetched) != 3 || !fetched[1].Fetched || fetched[2].Fetched {
+		t.Fatalf("Expected libs/lib fetched and its nested submodule only listed, got %+v", fetched)
+	}
+	if c := fetched[1].LatestCommit; c == nil || c.Hash != pinned || c.Message != "add deep" {
+		t.Errorf("Expected the pinned commit of libs/lib analyzed, got %+v", c)
+	}
+	if len(warnings) != 2 || warnings[1].File != "libs/lib/third_party/deep" || !strings.Contains(warnings[1].Message, "depth limit of 1") {
+		t.Errorf("Expected a warning for the submodule over the depth limit, got %+v", warnings)
+	}
+}
+
+func TestResolveSubmoduleURL(t *testing.T) {
+	tests := []struct {
+		base, url, expected string
+	}{
+		{"https://github.com/user/repo.git", "https://github.com/other/lib.git", "https://github.com/other/lib.git"},
+		{"https://github.com/user/repo.git", "../lib.git", "https://github.com/user/lib.git"},
+		{"https://github.com/user/repo", "./lib", "https://github.com/user/repo/lib"},
+		{"git@github.com:user/repo.git", "../lib.git", "git@github.com:user/lib.git"},
+		{"/src/repo/", "../lib", filepath.FromSlash("/src/lib")},
+	}
+	for _, tc := range tests {
+		if got := resolveSubmoduleURL(tc.base, tc.url); got != tc.expected {
+			t.Errorf("resolveSubmoduleURL(%q, %q) = %q, expected %q", tc.base, tc.url, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package git
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	fdiff "github.com/go-git/go-git/v5/plumbing/format/diff"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/utils/diff"
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+// binarySniffLen is how much of a file is searched for a NUL byte to tell binary files, as git does.
+const binarySniffLen = 8000
+
+// AnalyzeWorktree is AnalyzeLatestCommit for the uncommitted changes of the local repository
+// at repoPath: the difference between the tree of HEAD and the files on disk, so staged and
+// unstaged changes alike. Untracked files count as added if untracked is set; ignored files
+// never count. LatestCommit describes HEAD, except that its FilesChanged and line counts are
+// those of the changes, and Uncommitted is set. Nothing under repoPath is written.
+func AnalyzeWorktree(repoPath string, untracked bool) (*RepositoryInfo, error) {
+	return analyzeWorktree(repoPath, untracked, true)
+}
+
+// AnalyzeWorktreeFiles is AnalyzeWorktree without the changed functions.
+func AnalyzeWorktreeFiles(repoPath string, untracked bool) (*RepositoryInfo, error) {
+	return analyzeWorktree(repoPath, untracked, false)
+}
+
+func analyzeWorktree(repoPath string, untracked, functions bool) (*RepositoryInfo, error) {
+	repo, err := git.PlainOpen(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository at %s: %w", repoPath, classify(err))
+	}
+	headRef, err := repo.Head()
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		return nil, fmt.Errorf("failed to get HEAD reference: %w: %w", ErrEmptyRepository, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD reference: %w", classify(err))
+	}
+	head, err := repo.CommitObject(headRef.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest commit object: %w", classify(err))
+	}
+	changes, err := worktreeChanges(repo, repoPath, head, untracked)
+	if err != nil {
+		return nil, err
+	}
+
+	repoInfo := &RepositoryInfo{
+		LatestCommit: CommitInfo{
+			Hash:         head.Hash.String(),
+			Message:      strings.Split(head.Message, "\n")[0],
+			FullMessage:  strings.TrimRight(head.Message, "\n"),
+			Author:       head.Author.Name,
+			Email:        head.Author.Email,
+			Date:         head.Author.When.String(),
+			When:         head.Author.When,
+			FilesChanged: len(changes),
+		},
+		AddedLines:  make(map[string][]int),
+		Uncommitted: true,
+	}
+	if headRef.Name().IsBranch() {
+		repoInfo.Branch = headRef.Name().Short()
+	}
+	for _, c := range changes {
+		stats := ChangedFileStats{Path: c.path, FileType: strings.ToLower(filepath.Ext(c.path))}
+		if !isBinary(c.old) && !isBinary(c.new) {
+			chunks := diffContents(string(c.old), string(c.new))
+			stats.LinesAdded, stats.LinesDeleted = countChunkLines(chunks)
+			if strings.HasSuffix(c.path, ".go") {
+				d := diffChunks(chunks)
+				if lines := addedLines(d); len(lines) > 0 {
+					repoInfo.AddedLines[c.path] = lines
+				}
+				if functions {
+					funcs, funcWarnings := changedFunctions(c.path, d)
+					repoInfo.ChangedFunctions = append(repoInfo.ChangedFunctions, funcs...)
+					repoInfo.Warnings = append(repoInfo.Warnings, funcWarnings...)
+				}
+			}
+		}
+		repoInfo.ChangedFiles = append(repoInfo.ChangedFiles, stats)
+		repoInfo.TotalLinesAdded += stats.LinesAdded
+		repoInfo.TotalLinesDeleted += stats.LinesDeleted
+	}
+	repoInfo.LatestCommit.LinesAdded = repoInfo.TotalLinesAdded
+	repoInfo.LatestCommit.LinesDeleted = repoInfo.TotalLinesDeleted
+	return repoInfo, nil
+}
+
+// CopyWorktreeChanges makes the clone at dstDir of the local repository srcRepo match its
+// files on disk: the files srcRepo changed since HEAD are copied, those it deleted removed,
+// and its untracked files copied if untracked is set. The clone must have HEAD of srcRepo
+// checked out. It returns the slash-separated paths of the files changed in the clone.
+func CopyWorktreeChanges(srcRepo, dstDir string, untracked bool) ([]string, error) {
+	repo, err := git.PlainOpen(srcRepo)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open repository at %s: %w", srcRepo, classify(err))
+	}
+	headRef, err := repo.Head()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get HEAD reference: %w", classify(err))
+	}
+	head, err := repo.CommitObject(headRef.Hash())
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest commit object: %w", classify(err))
+	}
+	changes, err := worktreeChanges(repo, srcRepo, head, untracked)
+	if err != nil {
+		return nil, err
+	}
+	var copied []string
+	for _, c := range changes {
+		dst := filepath.Join(dstDir, filepath.FromSlash(c.path))
+		if !c.onDisk {
+			if err := os.Remove(dst); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, fmt.Errorf("failed to remove deleted file %s: %w", c.path, err)
+			}
+		} else {
+			if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+				return nil, fmt.Errorf("failed to create directory for %s: %w", c.path, err)
+			}
+			if err := os.WriteFile(dst, c.new, 0o644); err != nil {
+				return nil, fmt.Errorf("failed to copy changed file %s: %w", c.path, err)
+			}
+		}
+		copied = append(copied, c.path)
+	}
+	return copied, nil
+}
+
+// worktreeChange is a file whose content on disk differs from the tree of HEAD.
+type worktreeChange struct {
+	path           string // Slash-separated, relative to the repository root
+	old, new       []byte
+	inHead, onDisk bool // Whether old and new exist
+}
+
+// worktreeChanges returns the files of the worktree at root whose content differs from the
+// tree of head, by path. Untracked files are left out unless untracked is set.
+func worktreeChanges(repo *git.Repository, root string, head *object.Commit, untracked bool) ([]worktreeChange, error) {
+	worktree, err := repo.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worktree of %s: %w", root, err)
+	}
+	// Status leaves out the files .gitignore ignores.
+	status, err := worktree.Status()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get status of %s: %w", root, err)
+	}
+	tree, err := head.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get commit tree: %w", err)
+	}
+
+	var changes []worktreeChange
+	for path, fileStatus := range status {
+		if fileStatus.Worktree == git.Untracked && !untracked {
+			continue
+		}
+		c := worktreeChange{path: path}
+		if file, err := tree.File(path); err == nil {
+			contents, err := file.Contents()
+			if err != nil {
+				return nil, fmt.Errorf("failed to read %s at HEAD: %w", path, err)
+			}
+			c.old, c.inHead = []byte(contents), true
+		} else if !errors.Is(err, object.ErrFileNotFound) {
+			return nil, fmt.Errorf("failed to look up %s at HEAD: %w", path, err)
+		}
+		content, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(path)))
+		if err == nil {
+			c.new, c.onDisk = content, true
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("failed to read changed file %s: %w", path, err)
+		}
+		// A change staged and then undone in the worktree, or a file added to the index and
+		// deleted from disk, leaves nothing to analyze.
+		if c.inHead == c.onDisk && bytes.Equal(c.old, c.new) {
+			continue
+		}
+		changes = append(changes, c)
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].path < changes[j].path })
+	return changes, nil
+}
+
+// isBinary reports whether content looks binary: whether its start holds a NUL byte.
+func isBinary(content []byte) bool {
+	return bytes.IndexByte(content[:min(len(content), binarySniffLen)], 0) >= 0
+}
+
+// textChunk is a chunk of a diff computed from the contents of a file rather than read from
+// a patch.
+type textChunk struct {
+	content string
+	op      fdiff.Operation
+}
+
+func (c textChunk) Content() string       { return c.content }
+func (c textChunk) Type() fdiff.Operation { return c.op }
+
+// diffContents returns the chunks of a line diff from oldSrc to newSrc, as in a patch.
+func diffContents(oldSrc, newSrc string) []fdiff.Chunk {
+	var chunks []fdiff.Chunk
+	for _, d := range diff.Do(oldSrc, newSrc) {
+		op := fdiff.Equal
+		switch d.Type {
+		case diffmatchpatch.DiffInsert:
+			op = fdiff.Add
+		case diffmatchpatch.DiffDelete:
+			op = fdiff.Delete
+		}
+		chunks = append(chunks, textChunk{content: d.Text, op: op})
+	}
+	return chunks
+}
@@ -0,0 +1,141 @@
+package git
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// worktreeFixture returns a repository with one commit and uncommitted changes of every kind:
+// staged, unstaged, both, a deletion, an untracked, an ignored and a binary file.
+func worktreeFixture(t *testing.T) string {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping worktree tests: git not on PATH")
+	}
+	dir := t.TempDir()
+	write := func(path, content string) {
+		t.Helper()
+		full := filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write(".gitignore", "*.log\n")
+	write("staged.go", "package p\n\nfunc Staged() int {\n\treturn 1\n}\n")
+	write("unstaged.go", "package p\n\nfunc Unstaged() int {\n\treturn 1\n}\n")
+	write("both.txt", "one\ntwo\n")
+	write("deleted.txt", "gone\nsoon\n")
+	write("undone.txt", "same\n")
+	write("image.bin", "\x00\x01\x02")
+	runGit(t, dir, "init", "-q", "-b", "main")
+	runGit(t, dir, "add", ".")
+	runGit(t, dir, "commit", "-q", "-m", "Initial commit")
+
+	write("staged.go", "package p\n\nfunc Staged() int {\n\treturn 2\n}\n\nfunc Added() {}\n")
+	write("new.go", "package p\n\nfunc New() {}\n")
+	runGit(t, dir, "add", "staged.go", "new.go")
+	write("unstaged.go", "package p\n\nfunc Unstaged() int {\n\treturn 2\n}\n")
+	write("both.txt", "one\ntwo\nthree\n")
+	runGit(t, dir, "add", "both.txt")
+	write("both.txt", "one\nthree\nfour\n")
+	write("undone.txt", "changed\n")
+	runGit(t, dir, "add", "undone.txt")
+	write("undone.txt", "same\n")
+	write("image.bin", "\x00\x01\x02\x03")
+	runGit(t, dir, "rm", "-q", "deleted.txt")
+	write("untracked.go", "package p\n\nfunc Untracked() {}\n")
+	write("debug.log", "ignored\n")
+	return dir
+}
+
+func TestAnalyzeWorktree(t *testing.T) {
+	dir := worktreeFixture(t)
+	index, err := os.ReadFile(filepath.Join(dir, ".git", "index"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := AnalyzeWorktree(dir, false)
+	if err != nil {
+		t.Fatalf("AnalyzeWorktree failed: %v", err)
+	}
+	if !info.Uncommitted || info.Branch != "main" || info.LatestCommit.Message != "Initial commit" {
+		t.Errorf("Expected the uncommitted changes against main's commit, got %+v", info)
+	}
+	want := []ChangedFileStats{
+		{Path: "both.txt", FileType: ".txt", LinesAdded: 2, LinesDeleted: 1},
+		{Path: "deleted.txt", FileType: ".txt", LinesAdded: 0, LinesDeleted: 2},
+		{Path: "image.bin", FileType: ".bin"},
+		{Path: "new.go", FileType: ".go", LinesAdded: 3},
+		{Path: "staged.go", FileType: ".go", LinesAdded: 3, LinesDeleted: 1},
+		{Path: "unstaged.go", FileType: ".go", LinesAdded: 1, LinesDeleted: 1},
+	}
+	if !reflect.DeepEqual(info.ChangedFiles, want) {
+		t.Errorf("Expected changed files\n%+v\ngot\n%+v", want, info.ChangedFiles)
+	}
+	if info.TotalLinesAdded != 9 || info.TotalLinesDeleted != 5 || info.LatestCommit.FilesChanged != 6 || info.LatestCommit.LinesAdded != 9 {
+		t.Errorf("Expected 6 files, +9/-5, got %d files, +%d/-%d", info.LatestCommit.FilesChanged, info.TotalLinesAdded, info.TotalLinesDeleted)
+	}
+	var functions []string
+	for _, cf := range info.ChangedFunctions {
+		functions = append(functions, cf.File+":"+cf.Name+":"+string(cf.Change))
+	}
+	wantFunctions := []string{"new.go:New:added", "staged.go:Staged:modified", "staged.go:Added:added", "unstaged.go:Unstaged:modified"}
+	if !slices.Equal(functions, wantFunctions) {
+		t.Errorf("Expected changed functions %v, got %v", wantFunctions, functions)
+	}
+	if !slices.Equal(info.AddedLines["staged.go"], []int{4, 7}) {
+		t.Errorf("Expected lines 4 and 7 added to staged.go, got %v", info.AddedLines["staged.go"])
+	}
+
+	info, err = AnalyzeWorktreeFiles(dir, true)
+	if err != nil {
+		t.Fatalf("AnalyzeWorktreeFiles failed: %v", err)
+	}
+	var paths []string
+	for _, f := range info.ChangedFiles {
+		paths = append(paths, f.Path)
+	}
+	if !slices.Contains(paths, "untracked.go") || slices.Contains(paths, "debug.log") || len(info.ChangedFunctions) != 0 {
+		t.Errorf("Expected untracked.go but not the ignored debug.log, and no functions, got %v, %v", paths, info.ChangedFunctions)
+	}
+
+	// Nothing is written to the repository, not even the stat cache of the index.
+	if after, err := os.ReadFile(filepath.Join(dir, ".git", "index")); err != nil || !slices.Equal(after, index) {
+		t.Errorf("Expected the index to be left alone, got %v", err)
+	}
+}
+
+func TestCopyWorktreeChanges(t *testing.T) {
+	dir := worktreeFixture(t)
+	clone, err := CloneRepositoryWithDepth(dir, 1)
+	if err != nil {
+		t.Fatalf("Failed to clone the fixture: %v", err)
+	}
+	defer Cleanup(clone)
+
+	copied, err := CopyWorktreeChanges(dir, clone, false)
+	if err != nil {
+		t.Fatalf("CopyWorktreeChanges failed: %v", err)
+	}
+	if want := []string{"both.txt", "deleted.txt", "image.bin", "new.go", "staged.go", "unstaged.go"}; !slices.Equal(copied, want) {
+		t.Errorf("Expected %v to be changed, got %v", want, copied)
+	}
+	for path, want := range map[string]string{"both.txt": "one\nthree\nfour\n", "undone.txt": "same\n", "new.go": "package p\n\nfunc New() {}\n"} {
+		if got, err := os.ReadFile(filepath.Join(clone, path)); err != nil || string(got) != want {
+			t.Errorf("Expected %s to hold %q, got %q, %v", path, want, got, err)
+		}
+	}
+	for _, path := range []string{"deleted.txt", "untracked.go", "debug.log"} {
+		if _, err := os.Stat(filepath.Join(clone, path)); !os.IsNotExist(err) {
+			t.Errorf("Expected no %s in the clone, got %v", path, err)
+		}
+	}
+}
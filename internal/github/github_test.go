@@ -0,0 +1,123 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/user/zenwatch/internal/warning"
+)
+
+func TestParseRepoURL(t *testing.T) {
+	tests := []struct {
+		url         string
+		owner, name string
+		ok          bool
+	}{
+		{"https://github.com/user/repo.git", "user", "repo", true},
+		{"https://github.com/user/repo/", "user", "repo", true},
+		{"git@github.com:user/repo.git", "user", "repo", true},
+		{"ssh://git@github.com/user/repo", "user", "repo", true},
+		{"https://gitlab.com/user/repo.git", "", "", false},
+		{"https://github.com/user", "", "", false},
+		{"/tmp/repo", "", "", false},
+	}
+	for _, tt := range tests {
+		owner, name, ok := ParseRepoURL(tt.url)
+		if owner != tt.owner || name != tt.name || ok != tt.ok {
+			t.Errorf("ParseRepoURL(%q) = %q, %q, %v, want %q, %q, %v", tt.url, owner, name, ok, tt.owner, tt.name, tt.ok)
+		}
+	}
+}
+
+func TestRepoStatus(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/user/old", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"full_name": "user/old", "archived": true, "disabled": false}`))
+	})
+	mux.HandleFunc("/repos/user/renamed", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/repositories/42", http.StatusMovedPermanently)
+	})
+	mux.HandleFunc("/repositories/42", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"full_name": "org/new-name", "archived": false}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	client := &Client{BaseURL: server.URL, Token: "secret", HTTP: server.Client()}
+	status, err := client.RepoStatus(context.Background(), "user", "old")
+	if err != nil {
+		t.Fatalf("RepoStatus failed: %v", err)
+	}
+	if !status.Archived || status.Disabled || status.Moved {
+		t.Errorf("Expected an archived repository that has not moved, got %+v", status)
+	}
+	if warnings := status.Warnings(); len(warnings) != 1 || warnings[0].Code != warning.RepoArchived {
+		t.Errorf("Expected a repo-archived warning, got %v", warnings)
+	}
+
+	status, err = client.RepoStatus(context.Background(), "user", "renamed")
+	if err != nil {
+		t.Fatalf("RepoStatus failed: %v", err)
+	}
+	if !status.Moved || status.FullName != "org/new-name" || status.Archived {
+		t.Errorf("Expected the repository to have moved to org/new-name, got %+v", status)
+	}
+	if warnings := status.Warnings(); len(warnings) != 1 || warnings[0].Code != warning.RepoMoved || !strings.Contains(warnings[0].Message, "org/new-name") {
+		t.Errorf("Expected a repo-moved warning naming org/new-name, got %v", warnings)
+	}
+
+	if _, err := client.RepoStatus(context.Background(), "user", "missing"); err == nil {
+		t.Errorf("Expected an error for a missing repository")
+	}
+}
+
+func TestPublishGist(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Public *bool `json:"public"`
+			Files  map[string]struct {
+				Content string `json:"content"`
+			} `json:"files"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode request: %v", err)
+		}
+		visibility := "unchanged"
+		if body.Public != nil && !*body.Public {
+			visibility = "secret"
+		}
+		requests = append(requests, fmt.Sprintf("%s %s %s %s", r.Method, r.URL.Path, visibility, body.Files["report.md"].Content))
+		if r.Method == http.MethodPost {
+			w.WriteHeader(http.StatusCreated)
+		}
+		w.Write([]byte(`{"id": "abc123", "html_url": "https://gist.github.com/abc123"}`))
+	}))
+	defer server.Close()
+	client := &Client{BaseURL: server.URL, Token: "secret", HTTP: server.Client()}
+
+	gist, err := client.PublishGist(context.Background(), "", "ZenWatch report", "report.md", "# First")
+	if err != nil {
+		t.Fatalf("PublishGist failed to create: %v", err)
+	}
+	if gist.ID != "abc123" || gist.HTMLURL != "https://gist.github.com/abc123" {
+		t.Errorf("Unexpected gist %+v", gist)
+	}
+	if _, err := client.PublishGist(context.Background(), gist.ID, "ZenWatch report", "report.md", "# Second"); err != nil {
+		t.Fatalf("PublishGist failed to update: %v", err)
+	}
+
+	want := []string{"POST /gists secret # First", "PATCH /gists/abc123 unchanged # Second"}
+	if strings.Join(requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Expected requests %q, got %q", want, requests)
+	}
+}
@@ -0,0 +1,109 @@
+package manifest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// FileName is the name of the manifest written alongside a report.
+const FileName = "zenwatch.lock.json"
+
+// SchemaVersion is the version of the manifest file format.
+const SchemaVersion = 1
+
+// Manifest captures everything needed to reproduce a report.
+type Manifest struct {
+	SchemaVersion     int               `json:"schemaVersion"`
+	RepoURL           string            `json:"repoURL"`    // Normalized, without credentials
+	CommitHash        string            `json:"commitHash"` // Full hash of the analyzed commit
+	ToolVersion       string            `json:"toolVersion"`
+	AlgorithmVersions map[string]string `json:"algorithmVersions"`
+	Options           map[string]string `json:"options"` // Resolved analyze flags, by flag name
+}
+
+// Write stores the manifest as indented JSON at path, creating parent directories as needed.
+func Write(path string, m *Manifest) error {
+	content, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create manifest directory: %w", err)
+	}
+	if err := os.WriteFile(path, append(content, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest %s: %w", path, err)
+	}
+	return nil
+}
+
+// Read loads a manifest previously written by Write.
+func Read(path string) (*Manifest, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest %s: %w", path, err)
+	}
+	var m Manifest
+	if err := json.Unmarshal(content, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+	if m.SchemaVersion != SchemaVersion {
+		return nil, fmt.Errorf("unsupported manifest schema version %d (expected %d)", m.SchemaVersion, SchemaVersion)
+	}
+	if m.RepoURL == "" || m.CommitHash == "" {
+		return nil, fmt.Errorf("manifest %s is missing the repository URL or commit hash", path)
+	}
+	return &m, nil
+}
+
+// CheckAlgorithmVersions returns an error listing every algorithm whose recorded
+// version differs from current, or that is missing on either side.
+func (m *Manifest) CheckAlgorithmVersions(current map[string]string) error {
+	names := make(map[string]bool)
+	for name := range m.AlgorithmVersions {
+		names[name] = true
+	}
+	for name := range current {
+		names[name] = true
+	}
+
+	var drift []string
+	for name := range names {
+		recorded, now := m.AlgorithmVersions[name], current[name]
+		if recorded != now {
+			drift = append(drift, fmt.Sprintf("%s (manifest %q, current %q)", name, recorded, now))
+		}
+	}
+	if len(drift) == 0 {
+		return nil
+	}
+	sort.Strings(drift)
+	return fmt.Errorf("metric algorithm versions differ from the manifest: %s", strings.Join(drift, ", "))
+}
+
+// NormalizeRepoURL removes credentials and trailing slashes and lower-cases the
+// scheme and host of a repository URL. HTTP(S) URLs lose their user info entirely
+// (it usually holds a token); other schemes keep the user name, which SSH needs.
+// scp-style URLs (git@host:org/repo) are returned unchanged apart from surrounding whitespace.
+func NormalizeRepoURL(raw string) string {
+	raw = strings.TrimSpace(raw)
+	u, err := url.Parse(raw)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return raw
+	}
+	switch {
+	case u.User == nil:
+	case strings.EqualFold(u.Scheme, "http") || strings.EqualFold(u.Scheme, "https"):
+		u.User = nil
+	default:
+		u.User = url.User(u.User.Username())
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	u.Path = strings.TrimRight(u.Path, "/")
+	return u.String()
+}
@@ -0,0 +1,146 @@
+package metrics
+
+import (
+	"go/scanner"
+	"go/token"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/user/zenwatch/internal/warning"
+)
+
+// Kinds of annotation comments.
+const (
+	AnnotationTODO  = "TODO"
+	AnnotationFIXME = "FIXME"
+)
+
+// annotationPattern matches a comment line starting with an annotation marker, after the
+// comment delimiter and the leading asterisk of block comments.
+var annotationPattern = regexp.MustCompile(`^(?://|/\*)?[\s*]*(TODO|FIXME)\b`)
+
+// Annotation is a TODO or FIXME comment line.
+type Annotation struct {
+	Kind string // AnnotationTODO or AnnotationFIXME
+	File string // Slash-separated and relative to the repository root
+	Line int
+}
+
+// AuthorAnnotations counts the annotations whose line was last changed by one author.
+type AuthorAnnotations struct {
+	Author string `json:"author"`
+	Email  string `json:"email,omitempty"`
+	TODO   int    `json:"todo"`
+	FIXME  int    `json:"fixme"`
+}
+
+// Total returns the number of annotations of the author.
+func (a AuthorAnnotations) Total() int {
+	return a.TODO + a.FIXME
+}
+
+// ScanAnnotations lists the comment lines of the Go files under repoPath, in the directories
+// the go tool builds, that start with TODO or FIXME. Markers elsewhere in a comment, or in
+// string literals, are not annotations. Files matching exclude are not scanned.
+func ScanAnnotations(repoPath string, exclude ExcludeFilter) ([]Annotation, error) {
+	var annotations []Annotation
+	err := walkGoFiles(repoPath, exclude, func(rel, p string) error {
+		src, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		annotations = append(annotations, scanFileAnnotations(rel, src)...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return annotations, nil
+}
+
+// scanFileAnnotations returns the annotations in the comments of src. Syntax errors are
+// skipped over, so unparseable files still have their comments scanned.
+func scanFileAnnotations(file string, src []byte) []Annotation {
+	fset := token.NewFileSet()
+	tf := fset.AddFile(file, -1, len(src))
+	var s scanner.Scanner
+	s.Init(tf, src, nil, scanner.ScanComments)
+
+	var annotations []Annotation
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF {
+			break
+		}
+		if tok != token.COMMENT {
+			continue
+		}
+		line := fset.Position(pos).Line
+		for i, text := range strings.Split(lit, "\n") {
+			if m := annotationPattern.FindStringSubmatch(strings.TrimSpace(text)); m != nil {
+				annotations = append(annotations, Annotation{Kind: m[1], File: file, Line: line + i})
+			}
+		}
+	}
+	return annotations
+}
+
+// AttributeAnnotations counts annotations per author of their line, blaming each file once,
+// most annotations first. Files that cannot be blamed leave their annotations uncounted and
+// are reported as warnings.
+func AttributeAnnotations(annotations []Annotation, blame BlameFunc) ([]AuthorAnnotations, []warning.Warning) {
+	var warnings []warning.Warning
+	blamed := make(map[string][]Author)
+	failed := make(map[string]bool)
+	byAuthor := make(map[Author]*AuthorAnnotations)
+	for _, a := range annotations {
+		if failed[a.File] {
+			continue
+		}
+		authors, ok := blamed[a.File]
+		if !ok {
+			var err error
+			authors, err = blame(a.File)
+			if err != nil {
+				failed[a.File] = true
+				warnings = append(warnings, warning.Warning{
+					Code:    warning.BlameUnavailable,
+					Message: "annotations have no author: " + err.Error(),
+					File:    a.File,
+				})
+				continue
+			}
+			blamed[a.File] = authors
+		}
+		if a.Line > len(authors) {
+			continue
+		}
+
+		author := authors[a.Line-1]
+		aa, ok := byAuthor[author]
+		if !ok {
+			aa = &AuthorAnnotations{Author: author.Name, Email: author.Email}
+			byAuthor[author] = aa
+		}
+		switch a.Kind {
+		case AnnotationTODO:
+			aa.TODO++
+		case AnnotationFIXME:
+			aa.FIXME++
+		}
+	}
+
+	counts := make([]AuthorAnnotations, 0, len(byAuthor))
+	for _, aa := range byAuthor {
+		counts = append(counts, *aa)
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Total() != counts[j].Total() {
+			return counts[i].Total() > counts[j].Total()
+		}
+		return Author{counts[i].Author, counts[i].Email}.less(Author{counts[j].Author, counts[j].Email})
+	})
+	return counts, warnings
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/user/zenwatch/internal/warning"
+)
+
+func TestAnnotationsByAuthor(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "svc/svc.go", `package svc
+
+// TODO: retry on timeout.
+func Call() string {
+	//FIXME leaks the connection
+	return "TODO is not a comment"
+}
+
+/*
+ * TODO(bob): split this up.
+ * Mentions a TODO mid-sentence.
+ */
+func Split() {}
+`)
+	writeFile(t, root, "shallow.go", "package main\n\n// TODO: lost\n")
+	writeFile(t, root, "vendor/dep/dep.go", "package dep\n\n// TODO: not ours\n")
+
+	annotations, err := ScanAnnotations(root, ExcludeFilter{})
+	if err != nil {
+		t.Fatalf("ScanAnnotations failed: %v", err)
+	}
+	expected := []Annotation{
+		{Kind: AnnotationTODO, File: "shallow.go", Line: 3},
+		{Kind: AnnotationTODO, File: "svc/svc.go", Line: 3},
+		{Kind: AnnotationFIXME, File: "svc/svc.go", Line: 5},
+		{Kind: AnnotationTODO, File: "svc/svc.go", Line: 10},
+	}
+	if !reflect.DeepEqual(annotations, expected) {
+		t.Fatalf("Expected annotations %+v, got %+v", expected, annotations)
+	}
+
+	// Alice wrote the function, Bob the doc comments, Carol the FIXME.
+	blame := map[string][]string{
+		"svc/svc.go": {
+			"Alice", "Alice", "Bob", "Alice", "Carol", "Alice", "Alice",
+			"Alice", "Bob", "Bob", "Bob", "Bob", "Alice",
+		},
+	}
+	counts, warnings := AttributeAnnotations(annotations, func(file string) ([]Author, error) {
+		authors, ok := blame[file]
+		if !ok {
+			return nil, errors.New("object not found")
+		}
+		return blameAuthors(authors), nil
+	})
+	expectedCounts := []AuthorAnnotations{
+		{Author: "Bob", Email: "bob@example.com", TODO: 2},
+		{Author: "Carol", Email: "carol@example.com", FIXME: 1},
+	}
+	if !reflect.DeepEqual(counts, expectedCounts) {
+		t.Errorf("Expected counts %+v, got %+v", expectedCounts, counts)
+	}
+	if len(warnings) != 1 || warnings[0].Code != warning.BlameUnavailable || warnings[0].File != "shallow.go" {
+		t.Errorf("Expected one blame-unavailable warning for shallow.go, got %+v", warnings)
+	}
+}
@@ -0,0 +1,207 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/user/zenwatch/internal/warning"
+)
+
+// maxBuildErrors is the number of compiler error lines kept in a BuildStatus.
+const maxBuildErrors = 5
+
+// DefaultBuildTimeout bounds the build and vet of a build check when no timeout is configured.
+const DefaultBuildTimeout = 5 * time.Minute
+
+// BuildOptions configures a build check.
+type BuildOptions struct {
+	Timeout      time.Duration    // Limit of the build and vet together; 0 means DefaultBuildTimeout
+	GOFLAGS      string           // GOFLAGS of the go commands, e.g. "-tags=integration"
+	AllowNetwork bool             // Let the go commands download modules; by default GOPROXY is off
+	Now          func() time.Time // Clock of the build duration; time.Now if nil
+}
+
+// BuildStatus is the result of compiling a Go module.
+type BuildStatus struct {
+	Compiles    bool          `json:"compiles"`
+	TimedOut    bool          `json:"timedOut"`    // The check was stopped at its timeout, so Compiles is false
+	Duration    time.Duration `json:"duration"`    // Time taken by go build
+	Errors      []string      `json:"errors"`      // The first compiler errors, with paths relative to the module root
+	VetFindings int           `json:"vetFindings"` // Findings of go vet; only counted when the module compiles
+	Binaries    []BinarySize  `json:"binaries"`    // Executables of the main packages, by name
+}
+
+// BinarySize is the size of the executable built for a main package.
+type BinarySize struct {
+	Name  string `json:"name"`
+	Bytes int64  `json:"bytes"`
+}
+
+// vetFindingPattern matches the "file.go:line:col: message" lines of go vet.
+var vetFindingPattern = regexp.MustCompile(`^\S+\.go:\d+(:\d+)?: `)
+
+// CheckBuild runs "go build ./..." and, if it succeeds, "go vet ./..." in dir. The go
+// commands run with GOPATH, GOCACHE and GOMODCACHE in a temporary directory that is removed
+// afterwards, without network access unless opts allow it, and with the local toolchain only.
+// It returns nil if dir is not a Go module, and nil with a warning if the toolchain is missing;
+// failing to run the toolchain at all is returned as an error.
+func CheckBuild(ctx context.Context, dir string, opts BuildOptions) (*BuildStatus, []warning.Warning, error) {
+	modulePath, err := readModulePath(dir)
+	if err != nil || modulePath == "" {
+		return nil, nil, err
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		return nil, []warning.Warning{{Code: warning.BuildSkipped, Message: "the build check was skipped: go toolchain not found on PATH"}}, nil
+	}
+
+	sandbox, err := os.MkdirTemp("", "zenwatch-build-*")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create build dir: %w", err)
+	}
+	defer os.RemoveAll(sandbox)
+	binDir := filepath.Join(sandbox, "bin")
+	if err := os.Mkdir(binDir, 0o755); err != nil {
+		return nil, nil, fmt.Errorf("failed to create build dir: %w", err)
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = DefaultBuildTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	now := opts.Now
+	if now == nil {
+		now = time.Now
+	}
+	start := now()
+	stderr, ok, err := runGo(goCommand(ctx, dir, sandbox, opts, "build", "./..."))
+	if err != nil {
+		return nil, nil, err
+	}
+	status := &BuildStatus{Duration: now().Sub(start)}
+	if ctx.Err() != nil {
+		status.TimedOut = true
+		return status, nil, nil
+	}
+	if !ok {
+		status.Errors = buildErrors(stderr)
+		return status, nil, nil
+	}
+	status.Compiles = true
+
+	var stdout bytes.Buffer
+	list := goCommand(ctx, dir, sandbox, opts, "list", "-f", "{{if eq .Name \"main\"}}{{.ImportPath}}{{end}}", "./...")
+	list.Stdout = &stdout
+	if stderr, ok, err = runGo(list); err == nil && ok {
+		if mains := strings.Fields(stdout.String()); len(mains) > 0 {
+			// With -o naming a directory, the executable of every main package is written there.
+			args := append([]string{"build", "-o", binDir + string(filepath.Separator)}, mains...)
+			stderr, ok, err = runGo(goCommand(ctx, dir, sandbox, opts, args...))
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if ctx.Err() != nil {
+		status.TimedOut = true
+		return status, nil, nil
+	}
+	if !ok {
+		return nil, nil, fmt.Errorf("failed to build main packages: %s", strings.TrimSpace(stderr))
+	}
+	if status.Binaries, err = binarySizes(binDir); err != nil {
+		return nil, nil, err
+	}
+
+	stderr, ok, err = runGo(goCommand(ctx, dir, sandbox, opts, "vet", "./..."))
+	if err != nil {
+		return nil, nil, err
+	}
+	if ctx.Err() != nil {
+		status.TimedOut = true
+		return status, nil, nil
+	}
+	if !ok {
+		for _, line := range strings.Split(stderr, "\n") {
+			if vetFindingPattern.MatchString(line) {
+				status.VetFindings++
+			}
+		}
+	}
+	return status, nil, nil
+}
+
+// goCommand returns a go command running in dir with its caches in sandbox.
+func goCommand(ctx context.Context, dir, sandbox string, opts BuildOptions, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, "go", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GOPATH="+filepath.Join(sandbox, "gopath"),
+		"GOCACHE="+filepath.Join(sandbox, "cache"),
+		"GOMODCACHE="+filepath.Join(sandbox, "modcache"),
+		// The module cache is read-only by default, which would keep it from being removed.
+		"GOFLAGS=-modcacherw "+opts.GOFLAGS,
+		"GOTOOLCHAIN=local",
+		"GOWORK=off",
+	)
+	if !opts.AllowNetwork {
+		cmd.Env = append(cmd.Env, "GOPROXY=off")
+	}
+	return cmd
+}
+
+// runGo runs a go command and returns its standard error and whether it succeeded.
+// Only failing to start the command for another reason than its context ending is returned
+// as an error.
+func runGo(cmd *exec.Cmd) (string, bool, error) {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if err != nil && !errors.As(err, &exitErr) && !errors.Is(err, context.DeadlineExceeded) && !errors.Is(err, context.Canceled) {
+		return "", false, fmt.Errorf("failed to run %s: %w", strings.Join(cmd.Args[:2], " "), err)
+	}
+	return stderr.String(), err == nil, nil
+}
+
+// buildErrors returns the first compiler errors of go build's standard error.
+func buildErrors(stderr string) []string {
+	var errs []string
+	for _, line := range strings.Split(stderr, "\n") {
+		// "# pkg" lines only name the package the following errors belong to.
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if len(errs) == maxBuildErrors {
+			break
+		}
+		errs = append(errs, strings.TrimPrefix(line, "./"))
+	}
+	return errs
+}
+
+// binarySizes returns the size of every executable in dir, sorted by name.
+func binarySizes(dir string) ([]BinarySize, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list built binaries: %w", err)
+	}
+	var sizes []BinarySize
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat built binary: %w", err)
+		}
+		sizes = append(sizes, BinarySize{Name: entry.Name(), Bytes: info.Size()})
+	}
+	return sizes, nil
+}
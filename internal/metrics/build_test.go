@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/zenwatch/internal/warning"
+)
+
+func TestCheckBuild(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("Skipping TestCheckBuild: go toolchain not on PATH")
+	}
+
+	compiling := t.TempDir()
+	writeFile(t, compiling, "go.mod", "module example.com/ok\n\ngo 1.21\n")
+	writeFile(t, compiling, "main.go", "package main\n\nfunc main() {}\n")
+	writeFile(t, compiling, "cmd/tool/main.go", "package main\n\nimport \"fmt\"\n\nfunc main() { fmt.Printf(\"%d\\n\", \"not an int\") }\n")
+	writeFile(t, compiling, "lib/lib.go", "package lib\n\nfunc F() int { return 1 }\n")
+
+	status, warnings, err := CheckBuild(context.Background(), compiling, BuildOptions{})
+	if err != nil {
+		t.Fatalf("CheckBuild failed: %v", err)
+	}
+	if !status.Compiles || len(status.Errors) != 0 || len(warnings) != 0 {
+		t.Errorf("Expected the module to compile, got %+v, %v", status, warnings)
+	}
+	if status.VetFindings != 1 {
+		t.Errorf("Expected 1 vet finding for the Printf mismatch, got %d", status.VetFindings)
+	}
+	if len(status.Binaries) != 2 || status.Binaries[0].Name != "ok" || status.Binaries[1].Name != "tool" || status.Binaries[0].Bytes == 0 {
+		t.Errorf("Expected binaries ok and tool, got %+v", status.Binaries)
+	}
+
+	broken := t.TempDir()
+	writeFile(t, broken, "go.mod", "module example.com/broken\n\ngo 1.21\n")
+	writeFile(t, broken, "lib/lib.go", "package lib\n\nfunc F() int { return \"not an int\" }\n")
+
+	status, _, err = CheckBuild(context.Background(), broken, BuildOptions{})
+	if err != nil {
+		t.Fatalf("CheckBuild failed: %v", err)
+	}
+	if status.Compiles {
+		t.Fatalf("Expected the module not to compile")
+	}
+	if len(status.Errors) == 0 || !strings.HasPrefix(status.Errors[0], "lib/lib.go:3:") {
+		t.Errorf("Expected the first error to point at lib/lib.go:3, got %q", status.Errors)
+	}
+}
+
+func TestCheckBuildTimeout(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("Skipping TestCheckBuildTimeout: go toolchain not on PATH")
+	}
+
+	dir := t.TempDir()
+	writeFile(t, dir, "go.mod", "module example.com/ok\n\ngo 1.21\n")
+	writeFile(t, dir, "main.go", "package main\n\nfunc main() {}\n")
+
+	status, _, err := CheckBuild(context.Background(), dir, BuildOptions{Timeout: time.Nanosecond})
+	if err != nil {
+		t.Fatalf("CheckBuild failed: %v", err)
+	}
+	if !status.TimedOut || status.Compiles {
+		t.Errorf("Expected the check to time out, got %+v", status)
+	}
+}
+
+func TestCheckBuildNotAModule(t *testing.T) {
+	status, warnings, err := CheckBuild(context.Background(), t.TempDir(), BuildOptions{})
+	if err != nil || status != nil || warnings != nil {
+		t.Errorf("Expected no status outside a Go module, got %+v, %v, %v", status, warnings, err)
+	}
+}
+
+func TestCheckBuildWithoutToolchain(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "go.mod", "module example.com/ok\n\ngo 1.21\n")
+	t.Setenv("PATH", "")
+
+	status, warnings, err := CheckBuild(context.Background(), dir, BuildOptions{})
+	if err != nil || status != nil {
+		t.Fatalf("Expected the check to be skipped, got %+v, %v", status, err)
+	}
+	if len(warnings) != 1 || warnings[0].Code != warning.BuildSkipped {
+		t.Errorf("Expected a build-skipped warning, got %v", warnings)
+	}
+}
@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"slices"
+	"time"
+)
+
+// Cadence summarizes the intervals between successive commits of a history. Long or
+// irregular intervals hint at an abandoned or bursty project.
+type Cadence struct {
+	Commits        int           `json:"commits"`
+	First          time.Time     `json:"first"`        // Date of the oldest commit
+	Last           time.Time     `json:"last"`         // Date of the newest commit
+	MeanInterval   time.Duration `json:"meanInterval"` // Zero with fewer than two commits
+	MedianInterval time.Duration `json:"medianInterval"`
+	LongestGap     time.Duration `json:"longestGap"`
+}
+
+// ComputeCadence returns the cadence of the commits dated times, in any order, or nil
+// if there are none. Intervals are measured between commits sorted by date.
+func ComputeCadence(times []time.Time) *Cadence {
+	if len(times) == 0 {
+		return nil
+	}
+	sorted := slices.Clone(times)
+	slices.SortFunc(sorted, func(a, b time.Time) int { return a.Compare(b) })
+	c := &Cadence{Commits: len(sorted), First: sorted[0], Last: sorted[len(sorted)-1]}
+	if len(sorted) < 2 {
+		return c
+	}
+
+	intervals := make([]time.Duration, len(sorted)-1)
+	for i := 1; i < len(sorted); i++ {
+		intervals[i-1] = sorted[i].Sub(sorted[i-1])
+	}
+	slices.Sort(intervals)
+	c.MeanInterval = c.Last.Sub(c.First) / time.Duration(len(intervals))
+	c.LongestGap = intervals[len(intervals)-1]
+	if mid := len(intervals) / 2; len(intervals)%2 == 1 {
+		c.MedianInterval = intervals[mid]
+	} else {
+		c.MedianInterval = (intervals[mid-1] + intervals[mid]) / 2
+	}
+	return c
+}
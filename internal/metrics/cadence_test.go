@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"testing"
+	"time"
+)
+
+func TestComputeCadence(t *testing.T) {
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	// History order, newest first, with intervals of 1h, 2h, 3h and 10 days.
+	times := []time.Time{
+		base.Add(10*24*time.Hour + 6*time.Hour),
+		base.Add(6 * time.Hour),
+		base.Add(3 * time.Hour),
+		base.Add(time.Hour),
+		base,
+	}
+	c := ComputeCadence(times)
+	if c.Commits != 5 || !c.First.Equal(base) || !c.Last.Equal(times[0]) {
+		t.Errorf("Unexpected commits or dates: %+v", c)
+	}
+	if want := (10*24*time.Hour + 6*time.Hour) / 4; c.MeanInterval != want {
+		t.Errorf("Expected mean interval %v, got %v", want, c.MeanInterval)
+	}
+	if want := 2*time.Hour + 30*time.Minute; c.MedianInterval != want {
+		t.Errorf("Expected median interval %v, got %v", want, c.MedianInterval)
+	}
+	if c.LongestGap != 10*24*time.Hour {
+		t.Errorf("Expected longest gap of 10 days, got %v", c.LongestGap)
+	}
+	if !times[0].Equal(base.Add(10*24*time.Hour + 6*time.Hour)) {
+		t.Errorf("ComputeCadence reordered its input")
+	}
+
+	// Odd number of intervals.
+	if c := ComputeCadence(times[1:]); c.MedianInterval != 2*time.Hour {
+		t.Errorf("Expected median interval 2h, got %v", c.MedianInterval)
+	}
+}
+
+func TestComputeCadenceSingleCommit(t *testing.T) {
+	if c := ComputeCadence(nil); c != nil {
+		t.Errorf("Expected no cadence without commits, got %+v", c)
+	}
+	base := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	c := ComputeCadence([]time.Time{base})
+	if c.Commits != 1 || c.MeanInterval != 0 || c.MedianInterval != 0 || c.LongestGap != 0 {
+		t.Errorf("Expected a single commit without intervals, got %+v", c)
+	}
+}
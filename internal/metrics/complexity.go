@@ -0,0 +1,383 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/user/zenwatch/internal/warning"
+)
+
+// AnalyzeComplexity walks the Go files under repoPath and returns the functions whose
+// cyclomatic complexity is greater than threshold, most complex first.
+// Functions declared in _test.go files are tagged with IsTest. Unparseable files are skipped.
+func AnalyzeComplexity(repoPath string, threshold int) ([]ComplexityStat, error) {
+	all, _, err := CollectComplexity(context.Background(), repoPath, WorkerOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return FilterOverThreshold(all, threshold), nil
+}
+
+// CollectComplexity walks the Go files under repoPath and returns the complexity of
+// every function, most complex first. Files are parsed concurrently within the limits of opts.
+// Files that cannot be parsed, or are larger than opts.MaxFileSize, are skipped with a warning;
+// as every analysis walks the same files, this is the only place they are reported.
+// If ctx is done before every file is parsed, the functions of the files parsed so far are
+// returned with an error wrapping ctx's.
+func CollectComplexity(ctx context.Context, repoPath string, opts WorkerOptions) ([]ComplexityStat, []warning.Warning, error) {
+	var paths, relPaths []string
+	var warnings []warning.Warning
+	err := filepath.WalkDir(repoPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != repoPath && skipGoDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(repoPath, path)
+		if err != nil {
+			return err
+		}
+		if opts.MaxFileSize > 0 {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if info.Size() > opts.MaxFileSize {
+				warnings = append(warnings, warning.Warning{
+					Code:    warning.FileTooLarge,
+					Message: fmt.Sprintf("file skipped: %d bytes is over the limit of %d", info.Size(), opts.MaxFileSize),
+					File:    filepath.ToSlash(relPath),
+				})
+				return nil
+			}
+		}
+		paths = append(paths, path)
+		relPaths = append(relPaths, filepath.ToSlash(relPath))
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Each file fills its own slot, so the results keep the walk order.
+	fileStats := make([][]ComplexityStat, len(paths))
+	fileErrs := make([]error, len(paths))
+	fset := token.NewFileSet()
+	parsed := newWorkerPool(opts).run(ctx, len(paths), func(i int) {
+		fileStats[i], fileErrs[i] = fileComplexity(fset, paths[i], relPaths[i], nil)
+	})
+
+	var stats []ComplexityStat
+	for i := range parsed {
+		if fileErrs[i] != nil {
+			warnings = append(warnings, parseWarning(relPaths[i], fileErrs[i]))
+			continue
+		}
+		stats = append(stats, fileStats[i]...)
+	}
+	sortComplexity(stats)
+	sort.SliceStable(warnings, func(i, j int) bool { return warnings[i].File < warnings[j].File })
+	if parsed < len(paths) {
+		return stats, warnings, fmt.Errorf("complexity analysis stopped after %d of %d files: %w", parsed, len(paths), ctx.Err())
+	}
+	return stats, warnings, nil
+}
+
+// CollectFileComplexity computes the complexity of the functions in the given Go files, whose
+// paths are absolute or relative to root, most complex first. A file that cannot be read or
+// parsed yields an error of its own and the other files are still analyzed.
+func CollectFileComplexity(root string, files []string) ([]ComplexityStat, []error) {
+	var stats []ComplexityStat
+	var errs []error
+	fset := token.NewFileSet()
+	for _, file := range files {
+		path := file
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(root, path)
+		}
+		relPath := filepath.ToSlash(file)
+		if rel, err := filepath.Rel(root, path); err == nil && !strings.HasPrefix(rel, "..") {
+			relPath = filepath.ToSlash(rel)
+		}
+
+		src, err := os.ReadFile(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read %s: %w", file, err))
+			continue
+		}
+		fileStats, err := fileComplexity(fset, path, relPath, src)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to parse %s: %w", file, err))
+			continue
+		}
+		stats = append(stats, fileStats...)
+	}
+	sortComplexity(stats)
+	return stats, errs
+}
+
+// CollectComplexityFromSources is CollectComplexity for Go sources held in memory, such as
+// the files of a past commit, keyed by slash-separated path relative to the repository root.
+// Paths in directories the filesystem walk skips (vendor, testdata, ...) are ignored.
+func CollectComplexityFromSources(sources map[string][]byte) ([]ComplexityStat, []warning.Warning) {
+	var stats []ComplexityStat
+	var warnings []warning.Warning
+	fset := token.NewFileSet()
+	for relPath, src := range sources {
+		if !strings.HasSuffix(relPath, ".go") || inSkippedGoDir(relPath) {
+			continue
+		}
+		fileStats, err := fileComplexity(fset, relPath, relPath, src)
+		if err != nil {
+			warnings = append(warnings, parseWarning(relPath, err))
+			continue
+		}
+		stats = append(stats, fileStats...)
+	}
+	sortComplexity(stats)
+	sort.Slice(warnings, func(i, j int) bool { return warnings[i].File < warnings[j].File })
+	return stats, warnings
+}
+
+// fileComplexity parses one Go file, from src if not nil and from path otherwise, and
+// returns the complexity of its functions.
+func fileComplexity(fset *token.FileSet, path, relPath string, src any) ([]ComplexityStat, error) {
+	file, err := parser.ParseFile(fset, path, src, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, err
+	}
+	isTest := strings.HasSuffix(relPath, "_test.go")
+
+	var stats []ComplexityStat
+	for _, decl := range file.Decls {
+		fn, ok := decl.(*ast.FuncDecl)
+		if !ok || fn.Body == nil {
+			continue
+		}
+		stats = append(stats, ComplexityStat{
+			Complexity:      ComputeCyclomaticComplexity(fn),
+			Package:         file.Name.Name,
+			FunctionName:    FunctionName(fn),
+			File:            relPath,
+			Line:            fset.Position(fn.Pos()).Line,
+			EndLine:         fset.Position(fn.End()).Line,
+			IsTest:          isTest,
+			TableDrivenTest: isTest && IsTableDrivenTest(fn),
+			Fingerprint:     functionFingerprint(fingerprintKindComplexity, relPath, file.Name.Name, fset, fn),
+		})
+	}
+	return stats, nil
+}
+
+// FunctionName returns the name of fn qualified by its receiver type, "Foo", "T.Foo" or
+// "(*T).Foo", so that a method is told apart from a function of the same name. The type
+// parameters of a generic receiver are dropped: a method of T[K, V] is "T.Foo".
+func FunctionName(fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return fn.Name.Name
+	}
+	typ := fn.Recv.List[0].Type
+	pointer := false
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ, pointer = star.X, true
+	}
+	switch t := typ.(type) {
+	case *ast.IndexExpr:
+		typ = t.X
+	case *ast.IndexListExpr:
+		typ = t.X
+	}
+	recv := "?"
+	if ident, ok := typ.(*ast.Ident); ok {
+		recv = ident.Name
+	}
+	if pointer {
+		return fmt.Sprintf("(*%s).%s", recv, fn.Name.Name)
+	}
+	return recv + "." + fn.Name.Name
+}
+
+// sortComplexity orders stats most complex first, then by file and line.
+func sortComplexity(stats []ComplexityStat) {
+	sort.SliceStable(stats, func(i, j int) bool {
+		if stats[i].Complexity != stats[j].Complexity {
+			return stats[i].Complexity > stats[j].Complexity
+		}
+		if stats[i].File != stats[j].File {
+			return stats[i].File < stats[j].File
+		}
+		return stats[i].Line < stats[j].Line
+	})
+}
+
+// parseWarning turns a parse error into a warning located at its first syntax error.
+// The message omits the file path, which is in the File field relative to the repository.
+func parseWarning(relPath string, err error) warning.Warning {
+	w := warning.Warning{Code: warning.UnparseableFile, Message: "file skipped: " + err.Error(), File: relPath}
+	if list, ok := err.(scanner.ErrorList); ok && len(list) > 0 {
+		w.Message = "file skipped: " + list[0].Msg
+		w.Line = list[0].Pos.Line
+	}
+	return w
+}
+
+// AggregateComplexity returns the mean complexity of the stats over threshold and their
+// number. The mean is 0, not NaN, when none is over threshold.
+func AggregateComplexity(stats []ComplexityStat, threshold int) (avg float64, count int) {
+	total := 0
+	for _, s := range stats {
+		if s.Complexity > threshold {
+			total += s.Complexity
+			count++
+		}
+	}
+	if count == 0 {
+		return 0, 0
+	}
+	return float64(total) / float64(count), count
+}
+
+// FilterOverThreshold returns the stats whose complexity is greater than threshold, in their original order.
+func FilterOverThreshold(stats []ComplexityStat, threshold int) []ComplexityStat {
+	var over []ComplexityStat
+	for _, s := range stats {
+		if s.Complexity > threshold {
+			over = append(over, s)
+		}
+	}
+	return over
+}
+
+// ComputeCyclomaticComplexity returns the McCabe cyclomatic complexity of fn:
+// one plus the number of decision points (if, for, range, non-default case and
+// select clauses, && and ||). In table-driven tests, the range over the test
+// cases is not counted, since each case is data rather than a branch.
+func ComputeCyclomaticComplexity(fn *ast.FuncDecl) int {
+	tableLoops := tableDrivenRanges(fn)
+	complexity := 1
+	ast.Inspect(fn, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.IfStmt, *ast.ForStmt:
+			complexity++
+		case *ast.RangeStmt:
+			if !tableLoops[node] {
+				complexity++
+			}
+		case *ast.CaseClause:
+			if node.List != nil {
+				complexity++
+			}
+		case *ast.CommClause:
+			if node.Comm != nil {
+				complexity++
+			}
+		case *ast.BinaryExpr:
+			if node.Op == token.LAND || node.Op == token.LOR {
+				complexity++
+			}
+		}
+		return true
+	})
+	return complexity
+}
+
+// SplitTestComplexity separates production functions from test functions.
+func SplitTestComplexity(stats []ComplexityStat) (production, tests []ComplexityStat) {
+	for _, s := range stats {
+		if s.IsTest {
+			tests = append(tests, s)
+		} else {
+			production = append(production, s)
+		}
+	}
+	return production, tests
+}
+
+// IsTableDrivenTest reports whether fn is a test function (TestXxx) that ranges
+// over a slice of structs, either a literal or a local variable holding one.
+func IsTableDrivenTest(fn *ast.FuncDecl) bool {
+	return len(tableDrivenRanges(fn)) > 0
+}
+
+// tableDrivenRanges returns the range statements of a TestXxx function that iterate over test case tables.
+func tableDrivenRanges(fn *ast.FuncDecl) map[*ast.RangeStmt]bool {
+	if fn.Body == nil || !isTestFuncName(fn.Name.Name) {
+		return nil
+	}
+
+	tables := make(map[string]bool)
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.AssignStmt:
+			for i, rhs := range node.Rhs {
+				if i >= len(node.Lhs) || !isStructSliceLit(rhs) {
+					continue
+				}
+				if ident, ok := node.Lhs[i].(*ast.Ident); ok {
+					tables[ident.Name] = true
+				}
+			}
+		case *ast.ValueSpec:
+			for i, value := range node.Values {
+				if i < len(node.Names) && isStructSliceLit(value) {
+					tables[node.Names[i].Name] = true
+				}
+			}
+		}
+		return true
+	})
+
+	ranges := make(map[*ast.RangeStmt]bool)
+	ast.Inspect(fn.Body, func(n ast.Node) bool {
+		rng, ok := n.(*ast.RangeStmt)
+		if !ok {
+			return true
+		}
+		if ident, isIdent := rng.X.(*ast.Ident); (isIdent && tables[ident.Name]) || isStructSliceLit(rng.X) {
+			ranges[rng] = true
+		}
+		return true
+	})
+	return ranges
+}
+
+// isTestFuncName reports whether name follows the TestXxx convention of the testing package.
+func isTestFuncName(name string) bool {
+	if !strings.HasPrefix(name, "Test") {
+		return false
+	}
+	rest := name[len("Test"):]
+	return rest == "" || !unicode.IsLower([]rune(rest)[0])
+}
+
+// isStructSliceLit reports whether expr is a composite literal of type []struct{...}.
+func isStructSliceLit(expr ast.Expr) bool {
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		return false
+	}
+	arr, ok := lit.Type.(*ast.ArrayType)
+	if !ok {
+		return false
+	}
+	_, ok = arr.Elt.(*ast.StructType)
+	return ok
+}
@@ -0,0 +1,347 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+	"testing"
+
+	"github.com/user/zenwatch/internal/warning"
+)
+
+// parseFunc parses src and returns the function declaration named name.
+func parseFunc(t *testing.T, src, name string) *ast.FuncDecl {
+	t.Helper()
+	file, err := parser.ParseFile(token.NewFileSet(), "src.go", src, 0)
+	if err != nil {
+		t.Fatalf("Failed to parse source: %v", err)
+	}
+	for _, decl := range file.Decls {
+		if fn, ok := decl.(*ast.FuncDecl); ok && fn.Name.Name == name {
+			return fn
+		}
+	}
+	t.Fatalf("Function %s not found", name)
+	return nil
+}
+
+func TestComputeCyclomaticComplexity(t *testing.T) {
+	src := `package p
+
+func straight() int { return 1 }
+
+func branchy(xs []int, ch chan int) int {
+	n := 0
+	for _, x := range xs { // +1
+		if x > 0 && x < 10 || x == 42 { // +3
+			n++
+		}
+	}
+	for i := 0; i < 3; i++ { // +1
+	}
+	switch n {
+	case 1, 2: // +1
+	case 3: // +1
+	default:
+	}
+	select {
+	case v := <-ch: // +1
+		n += v
+	default:
+	}
+	return n
+}
+`
+	if got := ComputeCyclomaticComplexity(parseFunc(t, src, "straight")); got != 1 {
+		t.Errorf("Expected complexity 1 for straight, got %d", got)
+	}
+	if got := ComputeCyclomaticComplexity(parseFunc(t, src, "branchy")); got != 9 {
+		t.Errorf("Expected complexity 9 for branchy, got %d", got)
+	}
+}
+
+func TestAnalyzeComplexitySeparatesTests(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "pkg/parse.go", `package pkg
+
+func Parse(s string) int {
+	if s == "" {
+		return 0
+	}
+	if s == "a" || s == "b" {
+		return 1
+	}
+	return 2
+}
+
+func simple() {}
+`)
+	writeFile(t, root, "pkg/parse_test.go", `package pkg
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	if Parse("") != 0 {
+		t.Fatal("empty")
+	}
+	if Parse("a") != 1 && Parse("b") != 1 {
+		t.Fatal("a")
+	}
+}
+`)
+	writeFile(t, root, "vendor/dep/dep.go", "package dep\n\nfunc Vendored(x int) { if x > 0 && x < 2 && x != 3 {} }\n")
+
+	stats, err := AnalyzeComplexity(root, 1)
+	if err != nil {
+		t.Fatalf("AnalyzeComplexity failed: %v", err)
+	}
+
+	production, tests := SplitTestComplexity(stats)
+	if len(production) != 1 || production[0].FunctionName != "Parse" {
+		t.Fatalf("Expected only Parse in the production bucket, got %+v", production)
+	}
+	if len(tests) != 1 || tests[0].FunctionName != "TestParse" {
+		t.Fatalf("Expected only TestParse in the test bucket, got %+v", tests)
+	}
+
+	p := production[0]
+	if p.Complexity != 4 || p.Package != "pkg" || p.File != "pkg/parse.go" || p.Line != 3 || p.IsTest {
+		t.Errorf("Unexpected stat for Parse: %+v", p)
+	}
+	if !tests[0].IsTest || tests[0].File != "pkg/parse_test.go" {
+		t.Errorf("Unexpected stat for TestParse: %+v", tests[0])
+	}
+}
+
+func TestIsTableDrivenTest(t *testing.T) {
+	src := `package p
+
+import "testing"
+
+func TestInlineTable(t *testing.T) {
+	for _, tc := range []struct{ in, want int }{{1, 1}, {2, 2}} {
+		if tc.in != tc.want {
+			t.Fail()
+		}
+	}
+}
+
+func TestShortVarTable(t *testing.T) {
+	tests := []struct {
+		name string
+		in   int
+	}{{"one", 1}}
+	for _, tc := range tests {
+		if tc.in == 0 {
+			t.Fail()
+		}
+	}
+}
+
+func TestVarDeclTable(t *testing.T) {
+	var cases = []struct{ in int }{{1}}
+	for _, tc := range cases {
+		_ = tc
+	}
+}
+
+func TestRangeOverInts(t *testing.T) {
+	for _, n := range []int{1, 2, 3} {
+		if n == 0 {
+			t.Fail()
+		}
+	}
+}
+
+func TestNoLoop(t *testing.T) {
+	if 1 != 1 {
+		t.Fail()
+	}
+}
+
+func Testament(t *testing.T) {
+	for range []struct{}{{}} {
+	}
+}
+
+func helper() {
+	for range []struct{ in int }{{1}} {
+	}
+}
+`
+	tests := []struct {
+		name        string
+		tableDriven bool
+		complexity  int
+	}{
+		{name: "TestInlineTable", tableDriven: true, complexity: 2},
+		{name: "TestShortVarTable", tableDriven: true, complexity: 2},
+		{name: "TestVarDeclTable", tableDriven: true, complexity: 1},
+		{name: "TestRangeOverInts", tableDriven: false, complexity: 3},
+		{name: "TestNoLoop", tableDriven: false, complexity: 2},
+		{name: "Testament", tableDriven: false, complexity: 2},
+		{name: "helper", tableDriven: false, complexity: 2},
+	}
+	for _, tc := range tests {
+		fn := parseFunc(t, src, tc.name)
+		if got := IsTableDrivenTest(fn); got != tc.tableDriven {
+			t.Errorf("IsTableDrivenTest(%s) = %v, expected %v", tc.name, got, tc.tableDriven)
+		}
+		if got := ComputeCyclomaticComplexity(fn); got != tc.complexity {
+			t.Errorf("ComputeCyclomaticComplexity(%s) = %d, expected %d", tc.name, got, tc.complexity)
+		}
+	}
+}
+
+func TestCollectComplexityWarnsAboutUnparseableFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "ok.go", "package ok\n\nfunc OK() {}\n")
+	writeFile(t, root, "broken/broken.go", "package broken\n\nfunc Broken( {\n")
+
+	stats, warnings, err := CollectComplexity(context.Background(), root, WorkerOptions{})
+	if err != nil {
+		t.Fatalf("CollectComplexity failed: %v", err)
+	}
+	if len(stats) != 1 || stats[0].FunctionName != "OK" {
+		t.Errorf("Expected only the parseable function, got %+v", stats)
+	}
+	if len(warnings) != 1 {
+		t.Fatalf("Expected 1 warning, got %+v", warnings)
+	}
+	w := warnings[0]
+	if w.Code != warning.UnparseableFile || w.File != "broken/broken.go" || w.Line != 3 {
+		t.Errorf("Unexpected warning %+v", w)
+	}
+	if strings.Contains(w.Message, root) {
+		t.Errorf("Expected the warning message not to contain the clone path, got %q", w.Message)
+	}
+}
+
+func TestCollectComplexityGenerics(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "go.mod", "module example.com/generic\n\ngo 1.21\n")
+	writeFile(t, root, "set/set.go", `package set
+
+type Number interface {
+	~int | ~int64 | ~float64
+}
+
+type Set[T comparable] struct {
+	items map[T]struct{}
+}
+
+func (s *Set[T]) Add(v T) bool {
+	if _, ok := s.items[v]; ok {
+		return false
+	}
+	s.items[v] = struct{}{}
+	return true
+}
+
+func Sum[N Number](values []N, skipNegative bool) N {
+	var total N
+	for _, v := range values {
+		if skipNegative && v < 0 {
+			continue
+		}
+		total += v
+	}
+	return total
+}
+`)
+
+	stats, warnings, err := CollectComplexity(context.Background(), root, WorkerOptions{})
+	if err != nil {
+		t.Fatalf("CollectComplexity failed: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Fatalf("Expected generic code to parse without warnings, got %+v", warnings)
+	}
+
+	complexity := make(map[string]int)
+	for _, cs := range stats {
+		complexity[cs.FunctionName] = cs.Complexity
+	}
+	// The | of the type constraint is not a decision point.
+	if complexity["Sum"] != 4 || complexity["(*Set).Add"] != 2 {
+		t.Errorf("Expected Sum=4 and (*Set).Add=2, got %v", complexity)
+	}
+}
+
+func TestCollectComplexityQualifiesMethods(t *testing.T) {
+	stats, warnings := CollectComplexityFromSources(map[string][]byte{"pkg/pkg.go": []byte(`package pkg
+
+type T struct{}
+
+type Pair[K comparable, V any] struct{}
+
+func Foo() {}
+
+func (t T) Foo() {}
+
+func (t *T) Bar() {}
+
+func (Pair[K, V]) Baz() {}
+`)})
+	if len(warnings) != 0 {
+		t.Fatalf("Expected no warnings, got %+v", warnings)
+	}
+	var names []string
+	for _, cs := range stats {
+		names = append(names, cs.FunctionName)
+	}
+	want := []string{"Foo", "T.Foo", "(*T).Bar", "Pair.Baz"}
+	if strings.Join(names, " ") != strings.Join(want, " ") {
+		t.Errorf("Expected functions %v, got %v", want, names)
+	}
+}
+
+func TestCollectComplexitySkipsLargeFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "small.go", "package lib\n\nfunc Small() {}\n")
+	writeFile(t, root, "gen/huge.go", "package gen\n\nfunc Huge() {}\n"+strings.Repeat("// padding\n", 1000))
+
+	stats, warnings, err := CollectComplexity(context.Background(), root, WorkerOptions{MaxFileSize: 1024})
+	if err != nil {
+		t.Fatalf("CollectComplexity failed: %v", err)
+	}
+	if len(stats) != 1 || stats[0].FunctionName != "Small" {
+		t.Errorf("Expected only the function of the small file, got %+v", stats)
+	}
+	if len(warnings) != 1 || warnings[0].Code != warning.FileTooLarge || warnings[0].File != "gen/huge.go" {
+		t.Errorf("Expected a file-too-large warning for gen/huge.go, got %+v", warnings)
+	}
+}
+
+func TestCollectComplexityStopsWhenContextIsDone(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.go", "b.go", "c.go"} {
+		writeFile(t, root, name, "package lib\n\nfunc F() {}\n")
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	stats, _, err := CollectComplexity(ctx, root, WorkerOptions{})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the cancellation to be returned, got %v", err)
+	}
+	if len(stats) != 0 {
+		t.Errorf("Expected no functions from files that were never parsed, got %+v", stats)
+	}
+}
+
+func TestAggregateComplexity(t *testing.T) {
+	stats := []ComplexityStat{{Complexity: 20}, {Complexity: 15}, {Complexity: 17}, {Complexity: 3}}
+	if avg, count := AggregateComplexity(stats, 15); avg != 18.5 || count != 2 {
+		t.Errorf("Expected an average of 18.5 over 2 functions, got %v over %d", avg, count)
+	}
+	if avg, count := AggregateComplexity(stats, 20); avg != 0 || count != 0 {
+		t.Errorf("Expected 0 without functions over threshold, got %v over %d", avg, count)
+	}
+	if avg, count := AggregateComplexity(nil, 0); avg != 0 || count != 0 {
+		t.Errorf("Expected 0 without functions, got %v over %d", avg, count)
+	}
+}
@@ -0,0 +1,197 @@
+package metrics
+
+import (
+	"context"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"math"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Zones of the abstractness/instability plane that indicate poor package design.
+const (
+	ZoneOfPain        = "Zone of Pain"        // Stable and concrete: hard to change, yet depended upon
+	ZoneOfUselessness = "Zone of Uselessness" // Unstable and abstract: abstractions nobody depends on
+)
+
+// zoneDistanceThreshold is the distance from the main sequence above which a package is flagged.
+const zoneDistanceThreshold = 0.7
+
+// PackageCouplingStats holds Robert Martin's package coupling metrics for one package.
+// Only packages inside the analyzed module are counted as dependencies.
+type PackageCouplingStats struct {
+	Package                  string  `json:"package"`                  // Import path
+	AfferentCoupling         int     `json:"afferentCoupling"`         // Ca: number of module packages importing this package
+	EfferentCoupling         int     `json:"efferentCoupling"`         // Ce: number of module packages this package imports
+	Instability              float64 `json:"instability"`              // I = Ce / (Ca + Ce)
+	Interfaces               int     `json:"interfaces"`               // Interface types declared in the package
+	ConcreteTypes            int     `json:"concreteTypes"`            // All other declared types: structs, named basic types, function types...
+	AbstractnessScore        float64 `json:"abstractnessScore"`        // A = interfaces / all declared types
+	DistanceFromMainSequence float64 `json:"distanceFromMainSequence"` // D = |A + I - 1|
+	Zone                     string  `json:"zone"`                     // ZoneOfPain, ZoneOfUselessness or empty
+}
+
+// packageInfo is the raw data collected for a package before computing the metrics.
+type packageInfo struct {
+	imports    map[string]bool
+	interfaces int
+	types      int
+}
+
+// ComputePackageCoupling computes coupling metrics for every package of the Go module rooted at dir.
+// The import graph is built from the non-test Go files of each package directory.
+// It returns no stats if dir is not a Go module.
+func ComputePackageCoupling(ctx context.Context, dir string) ([]PackageCouplingStats, error) {
+	modulePath, err := readModulePath(dir)
+	if err != nil || modulePath == "" {
+		return nil, err
+	}
+
+	packages := make(map[string]*packageInfo)
+	fset := token.NewFileSet()
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != dir && skipGoDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(p, ".go") || strings.HasSuffix(p, "_test.go") {
+			return nil
+		}
+
+		// Files with syntax errors still contribute whatever was parsed; CollectComplexity reports them.
+		file, _ := parser.ParseFile(fset, p, nil, parser.SkipObjectResolution)
+		if file == nil {
+			return nil
+		}
+
+		rel, err := filepath.Rel(dir, filepath.Dir(p))
+		if err != nil {
+			return err
+		}
+		importPath := path.Join(modulePath, filepath.ToSlash(rel))
+		info, ok := packages[importPath]
+		if !ok {
+			info = &packageInfo{imports: make(map[string]bool)}
+			packages[importPath] = info
+		}
+		for _, imp := range file.Imports {
+			if target, err := strconv.Unquote(imp.Path.Value); err == nil {
+				info.imports[target] = true
+			}
+		}
+		for _, decl := range file.Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				info.types++
+				if _, isInterface := spec.(*ast.TypeSpec).Type.(*ast.InterfaceType); isInterface {
+					info.interfaces++
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	afferent := make(map[string]int)
+	efferent := make(map[string]int)
+	for importPath, info := range packages {
+		for target := range info.imports {
+			if _, internal := packages[target]; internal && target != importPath {
+				efferent[importPath]++
+				afferent[target]++
+			}
+		}
+	}
+
+	stats := make([]PackageCouplingStats, 0, len(packages))
+	for importPath, info := range packages {
+		s := PackageCouplingStats{
+			Package:          importPath,
+			AfferentCoupling: afferent[importPath],
+			EfferentCoupling: efferent[importPath],
+			Interfaces:       info.interfaces,
+			ConcreteTypes:    info.types - info.interfaces,
+		}
+		if total := s.AfferentCoupling + s.EfferentCoupling; total > 0 {
+			s.Instability = float64(s.EfferentCoupling) / float64(total)
+		}
+		if info.types > 0 {
+			s.AbstractnessScore = float64(info.interfaces) / float64(info.types)
+		}
+		s.DistanceFromMainSequence = math.Abs(s.AbstractnessScore + s.Instability - 1)
+		if s.DistanceFromMainSequence > zoneDistanceThreshold {
+			if s.AbstractnessScore+s.Instability < 1 {
+				s.Zone = ZoneOfPain
+			} else {
+				s.Zone = ZoneOfUselessness
+			}
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Package < stats[j].Package })
+	return stats, nil
+}
+
+// walkGoFiles calls fn with the slash-separated relative path and the path of every regular
+// Go file under repoPath, in the directories the go tool builds, except those matching exclude.
+func walkGoFiles(repoPath string, exclude ExcludeFilter, fn func(rel, path string) error) error {
+	return filepath.WalkDir(repoPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != repoPath && skipGoDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(p, ".go") || !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(repoPath, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if exclude.Excludes(rel) {
+			return nil
+		}
+		return fn(rel, p)
+	})
+}
+
+// skipGoDir reports whether a directory is ignored by the go tool (or holds no module code).
+func skipGoDir(name string) bool {
+	return name == "vendor" || name == "testdata" || strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_")
+}
+
+// inSkippedGoDir reports whether the slash-separated relative path is below a directory
+// that skipGoDir skips.
+func inSkippedGoDir(relPath string) bool {
+	dirs := strings.Split(path.Dir(relPath), "/")
+	for _, dir := range dirs {
+		if dir != "." && skipGoDir(dir) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package metrics
+
+import (
+	"context"
+	"math"
+	"testing"
+)
+
+func TestComputePackageCoupling(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "go.mod", "module example.com/shop\n\ngo 1.22\n")
+	// a imports b and c; b imports c and only declares an interface; c is concrete and imports nothing.
+	writeFile(t, root, "a/a.go", `package a
+
+import (
+	"fmt"
+
+	"example.com/shop/b"
+	"example.com/shop/c"
+)
+
+func Run() { fmt.Println(b.Name, c.Value{}) }
+`)
+	writeFile(t, root, "b/b.go", `package b
+
+import "example.com/shop/c"
+
+const Name = "b"
+
+type Store interface{ Get() c.Value }
+`)
+	writeFile(t, root, "c/c.go", `package c
+
+type Value struct{}
+type ID int
+`)
+	// d is abstract and imported by nobody.
+	writeFile(t, root, "d/d.go", `package d
+
+import "example.com/shop/c"
+
+type Reader interface{ Read() c.Value }
+`)
+	// Test files and vendored code do not contribute to coupling.
+	writeFile(t, root, "c/c_test.go", "package c\n\nimport _ \"example.com/shop/a\"\n")
+	writeFile(t, root, "vendor/x/x.go", "package x\n\nimport _ \"example.com/shop/c\"\n")
+
+	stats, err := ComputePackageCoupling(context.Background(), root)
+	if err != nil {
+		t.Fatalf("ComputePackageCoupling failed: %v", err)
+	}
+
+	expected := []PackageCouplingStats{
+		{Package: "example.com/shop/a", AfferentCoupling: 0, EfferentCoupling: 2, Instability: 1, AbstractnessScore: 0, DistanceFromMainSequence: 0},
+		{Package: "example.com/shop/b", AfferentCoupling: 1, EfferentCoupling: 1, Instability: 0.5, AbstractnessScore: 1, DistanceFromMainSequence: 0.5},
+		{Package: "example.com/shop/c", AfferentCoupling: 3, EfferentCoupling: 0, Instability: 0, AbstractnessScore: 0, DistanceFromMainSequence: 1, Zone: ZoneOfPain},
+		{Package: "example.com/shop/d", AfferentCoupling: 0, EfferentCoupling: 1, Instability: 1, AbstractnessScore: 1, DistanceFromMainSequence: 1, Zone: ZoneOfUselessness},
+	}
+	if len(stats) != len(expected) {
+		t.Fatalf("Expected %d packages, got %d: %+v", len(expected), len(stats), stats)
+	}
+	for i, e := range expected {
+		s := stats[i]
+		if s.Package != e.Package || s.AfferentCoupling != e.AfferentCoupling || s.EfferentCoupling != e.EfferentCoupling || s.Zone != e.Zone {
+			t.Errorf("Package %d: expected %+v, got %+v", i, e, s)
+		}
+		if math.Abs(s.Instability-e.Instability) > 1e-9 || math.Abs(s.AbstractnessScore-e.AbstractnessScore) > 1e-9 ||
+			math.Abs(s.DistanceFromMainSequence-e.DistanceFromMainSequence) > 1e-9 {
+			t.Errorf("Package %s: expected I=%.2f A=%.2f D=%.2f, got I=%.2f A=%.2f D=%.2f", e.Package,
+				e.Instability, e.AbstractnessScore, e.DistanceFromMainSequence,
+				s.Instability, s.AbstractnessScore, s.DistanceFromMainSequence)
+		}
+	}
+}
+
+func TestComputePackageCouplingAbstractnessRatio(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "go.mod", "module example.com/store\n\ngo 1.22\n")
+	// 2 interfaces and 3 concrete types, one of them in a grouped declaration.
+	writeFile(t, root, "store.go", `package store
+
+type Reader interface{ Read(key string) ([]byte, error) }
+type Writer interface{ Write(key string, value []byte) error }
+
+type (
+	Memory struct{ data map[string][]byte }
+	Key    string
+)
+`)
+	writeFile(t, root, "disk.go", "package store\n\ntype Disk struct{ dir string }\n")
+
+	stats, err := ComputePackageCoupling(context.Background(), root)
+	if err != nil {
+		t.Fatalf("ComputePackageCoupling failed: %v", err)
+	}
+	if len(stats) != 1 {
+		t.Fatalf("Expected 1 package, got %+v", stats)
+	}
+	s := stats[0]
+	if s.Interfaces != 2 || s.ConcreteTypes != 3 || math.Abs(s.AbstractnessScore-0.4) > 1e-9 {
+		t.Errorf("Expected 2 interfaces, 3 concrete types and A=0.40, got %d, %d and A=%.2f", s.Interfaces, s.ConcreteTypes, s.AbstractnessScore)
+	}
+}
+
+func TestComputePackageCouplingNotAModule(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "README.md", "# docs only\n")
+
+	stats, err := ComputePackageCoupling(context.Background(), root)
+	if err != nil {
+		t.Fatalf("ComputePackageCoupling failed: %v", err)
+	}
+	if len(stats) != 0 {
+		t.Errorf("Expected no stats outside a Go module, got %+v", stats)
+	}
+}
+
+func TestComputePackageCouplingCanceled(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "go.mod", "module example.com/shop\n")
+	writeFile(t, root, "a/a.go", "package a\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ComputePackageCoupling(ctx, root); err == nil {
+		t.Errorf("Expected an error for a canceled context")
+	}
+}
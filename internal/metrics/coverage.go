@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// CoverProfile is a coverage profile written by go test -coverprofile: the statement blocks
+// of each instrumented file and whether the tests ran them.
+type CoverProfile struct {
+	Mode   string                  // set, count or atomic
+	blocks map[string][]coverBlock // By file name as in the profile: import path of the package and file name
+}
+
+// coverBlock is a block of statements of a profile, by line.
+type coverBlock struct {
+	start, end int
+	covered    bool
+}
+
+// ParseCoverProfile parses a coverage profile. A block listed several times, as in merged
+// profiles, is covered if any of its listings has a count.
+func ParseCoverProfile(r io.Reader) (*CoverProfile, error) {
+	profile := &CoverProfile{blocks: make(map[string][]coverBlock)}
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if mode, ok := strings.CutPrefix(line, "mode:"); ok && n == 1 {
+			profile.Mode = strings.TrimSpace(mode)
+			continue
+		}
+		if profile.Mode == "" {
+			return nil, fmt.Errorf("coverage profile line %d: expected a mode line first", n)
+		}
+		// name.go:startLine.startCol,endLine.endCol numStmt count
+		colon := strings.LastIndex(line, ":")
+		if colon < 0 || len(strings.Fields(line[colon+1:])) != 3 {
+			return nil, fmt.Errorf("coverage profile line %d: malformed block %q", n, line)
+		}
+		fields := strings.Fields(line[colon+1:])
+		startPos, endPos, ok := strings.Cut(fields[0], ",")
+		start, err1 := strconv.Atoi(strings.Split(startPos, ".")[0])
+		end, err2 := strconv.Atoi(strings.Split(endPos, ".")[0])
+		count, err3 := strconv.Atoi(fields[2])
+		if !ok || err1 != nil || err2 != nil || err3 != nil || end < start {
+			return nil, fmt.Errorf("coverage profile line %d: malformed block %q", n, line)
+		}
+		name := line[:colon]
+		profile.blocks[name] = append(profile.blocks[name], coverBlock{start: start, end: end, covered: count > 0})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read coverage profile: %w", err)
+	}
+	return profile, nil
+}
+
+// fileBlocks returns the blocks of the file at relPath (slash-separated, relative to the
+// repository root). Profiles name files by import path, so the file is the one whose name
+// ends in relPath, the first by name if several do; modules nested in the repository are
+// matched the same way.
+func (p *CoverProfile) fileBlocks(relPath string) ([]coverBlock, bool) {
+	if blocks, ok := p.blocks[relPath]; ok {
+		return blocks, true
+	}
+	match := ""
+	for name := range p.blocks {
+		if strings.HasSuffix(name, "/"+relPath) && (match == "" || name < match) {
+			match = name
+		}
+	}
+	if match == "" {
+		return nil, false
+	}
+	return p.blocks[match], true
+}
+
+// FileDiffCoverage is the coverage of the lines a commit added to one file.
+type FileDiffCoverage struct {
+	File      string      `json:"file"`
+	InProfile bool        `json:"inProfile"`
+	Coverable int         `json:"coverable"` // Added lines in a statement block of the profile; every added line if the file is not in it
+	Covered   int         `json:"covered"`   // Coverable lines in a block the tests ran
+	Uncovered []LineRange `json:"uncovered"` // Coverable lines not covered, merged into ranges
+}
+
+// Percent returns the share of the coverable lines covered, 100 if there are none.
+func (f FileDiffCoverage) Percent() float64 {
+	return coveragePercent(f.Covered, f.Coverable)
+}
+
+// DiffCoverage is the share of the lines a commit added to Go files that tests cover, which
+// tells a pull request's reviewers more than the coverage of the whole repository.
+type DiffCoverage struct {
+	Profile   string             `json:"profile"` // Name of the profile file
+	Files     []FileDiffCoverage `json:"files"`   // Files with coverable added lines, by path
+	Coverable int                `json:"coverable"`
+	Covered   int                `json:"covered"`
+}
+
+// Percent returns the share of the coverable lines covered, 100 if there are none.
+func (c DiffCoverage) Percent() float64 {
+	return coveragePercent(c.Covered, c.Coverable)
+}
+
+func coveragePercent(covered, coverable int) float64 {
+	if coverable == 0 {
+		return 100
+	}
+	return 100 * float64(covered) / float64(coverable)
+}
+
+// ComputeDiffCoverage checks added, the lines a commit added to each file (by path relative
+// to the repository root), against profile. Only the lines in a statement block of the
+// profile count, so comments and declarations do not; a Go file missing from the profile
+// counts as entirely uncovered. Non-Go files, tests and excluded files are left out.
+func ComputeDiffCoverage(profile *CoverProfile, added map[string][]int, exclude ExcludeFilter) DiffCoverage {
+	var dc DiffCoverage
+	for file, lines := range added {
+		if !strings.HasSuffix(file, ".go") || strings.HasSuffix(file, "_test.go") || exclude.Excludes(file) {
+			continue
+		}
+		blocks, inProfile := profile.fileBlocks(file)
+		fc := FileDiffCoverage{File: file, InProfile: inProfile}
+		for _, line := range lines {
+			coverable, covered := !inProfile, false
+			for _, b := range blocks {
+				if line >= b.start && line <= b.end {
+					coverable = true
+					covered = covered || b.covered
+				}
+			}
+			switch {
+			case covered:
+				fc.Covered++
+			case coverable:
+				if n := len(fc.Uncovered); n > 0 && fc.Uncovered[n-1].End == line-1 {
+					fc.Uncovered[n-1].End = line
+				} else {
+					fc.Uncovered = append(fc.Uncovered, LineRange{Start: line, End: line})
+				}
+			}
+			if coverable {
+				fc.Coverable++
+			}
+		}
+		if fc.Coverable == 0 {
+			continue
+		}
+		dc.Files = append(dc.Files, fc)
+		dc.Coverable += fc.Coverable
+		dc.Covered += fc.Covered
+	}
+	sort.Slice(dc.Files, func(i, j int) bool { return dc.Files[i].File < dc.Files[j].File })
+	return dc
+}
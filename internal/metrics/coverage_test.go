@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sampleProfile covers the first block of lib/lib.go and not the second; merged.go lists a
+// block twice, covered by one of the listings.
+const sampleProfile = `mode: set
+example.com/repo/lib/lib.go:3.20,5.2 1 1
+example.com/repo/lib/lib.go:7.20,12.2 3 0
+example.com/repo/lib/merged.go:3.14,4.2 1 0
+example.com/repo/lib/merged.go:3.14,4.2 1 1
+`
+
+func TestParseCoverProfile(t *testing.T) {
+	profile, err := ParseCoverProfile(strings.NewReader(sampleProfile))
+	if err != nil {
+		t.Fatalf("ParseCoverProfile failed: %v", err)
+	}
+	if profile.Mode != "set" {
+		t.Errorf("Expected mode set, got %q", profile.Mode)
+	}
+	blocks, ok := profile.fileBlocks("lib/lib.go")
+	if want := []coverBlock{{3, 5, true}, {7, 12, false}}; !ok || !reflect.DeepEqual(blocks, want) {
+		t.Errorf("Expected the blocks of lib/lib.go to be %+v, got %+v", want, blocks)
+	}
+	if _, ok := profile.fileBlocks("b/lib.go"); ok {
+		t.Errorf("Expected a suffix to match whole path elements only")
+	}
+
+	for _, invalid := range []string{
+		"example.com/repo/lib.go:3.20,5.2 1 1\n",        // No mode line
+		"mode: set\nexample.com/repo/lib.go 1 1\n",      // No position
+		"mode: set\nexample.com/repo/lib.go:3.20 1 1\n", // No end
+		"mode: set\nexample.com/repo/lib.go:9.1,5.2 1 1\n",
+		"mode: set\nexample.com/repo/lib.go:3.20,5.2 1 many\n",
+	} {
+		if _, err := ParseCoverProfile(strings.NewReader(invalid)); err == nil {
+			t.Errorf("Expected ParseCoverProfile(%q) to fail", invalid)
+		}
+	}
+}
+
+func TestComputeDiffCoverage(t *testing.T) {
+	profile, err := ParseCoverProfile(strings.NewReader(sampleProfile))
+	if err != nil {
+		t.Fatalf("ParseCoverProfile failed: %v", err)
+	}
+	exclude, err := NewExcludeFilter([]string{"gen/"})
+	if err != nil {
+		t.Fatalf("NewExcludeFilter failed: %v", err)
+	}
+	added := map[string][]int{
+		"lib/lib.go":      {2, 4, 8, 9, 11}, // 2 is outside every block
+		"lib/merged.go":   {3},
+		"lib/new.go":      {1, 2},
+		"lib/lib_test.go": {5},
+		"gen/gen.go":      {1},
+		"README.md":       {1},
+		"lib/doc.go":      nil,
+	}
+	dc := ComputeDiffCoverage(profile, added, exclude)
+	want := []FileDiffCoverage{
+		{File: "lib/lib.go", InProfile: true, Coverable: 4, Covered: 1, Uncovered: []LineRange{{Start: 8, End: 9}, {Start: 11, End: 11}}},
+		{File: "lib/merged.go", InProfile: true, Coverable: 1, Covered: 1},
+		{File: "lib/new.go", Coverable: 2, Uncovered: []LineRange{{Start: 1, End: 2}}},
+	}
+	if !reflect.DeepEqual(dc.Files, want) {
+		t.Errorf("Expected files %+v, got %+v", want, dc.Files)
+	}
+	if dc.Coverable != 7 || dc.Covered != 2 {
+		t.Errorf("Expected 2 of 7 lines covered, got %d of %d", dc.Covered, dc.Coverable)
+	}
+	if got := (DiffCoverage{}).Percent(); got != 100 {
+		t.Errorf("Expected 100%% with nothing coverable, got %v", got)
+	}
+}
@@ -0,0 +1,33 @@
+package metrics
+
+import "fmt"
+
+// Severities of a CustomFinding.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+	SeverityInfo    = "info"
+)
+
+// CustomFinding is a finding of a check zenwatch does not implement itself, such as an
+// external analyzer plugin.
+type CustomFinding struct {
+	Source      string `json:"source"`      // Name of the check that reported the finding, e.g. the plugin name
+	Fingerprint string `json:"fingerprint"` // Identifies the finding across runs; chosen by the source
+	Severity    string `json:"severity"`    // One of the Severity* constants
+	Message     string `json:"message"`
+	File        string `json:"file,omitempty"` // Optional: slash-separated path relative to the repository root
+	Line        int    `json:"line,omitempty"` // Optional: 0 if the finding is not tied to a line
+}
+
+// Location returns "file:line", "file" or "" depending on which are set.
+func (f CustomFinding) Location() string {
+	switch {
+	case f.File == "":
+		return ""
+	case f.Line == 0:
+		return f.File
+	default:
+		return fmt.Sprintf("%s:%d", f.File, f.Line)
+	}
+}
@@ -0,0 +1,69 @@
+package metrics
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// ExcerptCount is the number of most complex functions the report shows excerpts of.
+	ExcerptCount = 3
+	// excerptBodyLines is the number of lines shown after the first line of the signature.
+	excerptBodyLines = 10
+	// maxExcerptBytes caps an excerpt, e.g. of generated code with very long lines.
+	maxExcerptBytes = 2048
+)
+
+// CodeExcerpt is the beginning of a function's source, shown in the report for context.
+type CodeExcerpt struct {
+	FunctionName string `json:"functionName"`
+	File         string `json:"file"`
+	Line         int    `json:"line"`
+	Language     string `json:"language"` // Code fence info string, e.g. "go"; empty if the language is unknown
+	Code         string `json:"code"`
+	Truncated    bool   `json:"truncated"` // The function continues after Code
+}
+
+// ExtractExcerpt returns the first lines of the function described by stat from src, the
+// contents of stat.File: its signature line and up to ten more, capped at a fixed size
+// without splitting multi-byte characters.
+func ExtractExcerpt(stat ComplexityStat, src []byte) CodeExcerpt {
+	excerpt := CodeExcerpt{
+		FunctionName: stat.FunctionName,
+		File:         stat.File,
+		Line:         stat.Line,
+		Language:     fenceLanguage(stat.File),
+	}
+
+	lines := strings.Split(string(src), "\n")
+	if stat.Line < 1 || stat.Line > len(lines) {
+		return excerpt
+	}
+	end := min(stat.Line+excerptBodyLines, len(lines))
+	if stat.EndLine >= stat.Line && stat.EndLine < end {
+		end = stat.EndLine
+	}
+	excerpt.Truncated = end < max(stat.EndLine, stat.Line)
+	code := strings.Join(lines[stat.Line-1:end], "\n")
+
+	if len(code) > maxExcerptBytes {
+		cut := maxExcerptBytes
+		for cut > 0 && !utf8.RuneStart(code[cut]) {
+			cut--
+		}
+		code = code[:cut]
+		excerpt.Truncated = true
+	}
+	excerpt.Code = code
+	return excerpt
+}
+
+// fenceLanguage returns the code fence info string for path, derived from its language.
+func fenceLanguage(path string) string {
+	for _, lang := range Languages {
+		if lang.matches(path) {
+			return strings.ToLower(lang.Name)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestExtractExcerpt(t *testing.T) {
+	src := []byte("package lib\n\n" + branchyFunc("Long", 5) + "\nfunc Short() {}\n")
+	stats, warnings := CollectComplexityFromSources(map[string][]byte{"lib/lib.go": src})
+	if len(warnings) != 0 || len(stats) != 2 {
+		t.Fatalf("Expected 2 functions without warnings, got %+v, %v", stats, warnings)
+	}
+
+	long := ExtractExcerpt(stats[0], src)
+	if long.Language != "go" || long.FunctionName != "Long" || long.Line != 3 {
+		t.Errorf("Unexpected excerpt metadata: %+v", long)
+	}
+	lines := strings.Split(long.Code, "\n")
+	if len(lines) != 11 || lines[0] != "func Long(x int) int {" || !long.Truncated {
+		t.Errorf("Expected the signature and 10 more lines, truncated, got %d lines: %q", len(lines), long.Code)
+	}
+
+	short := ExtractExcerpt(stats[1], src)
+	if short.Code != "func Short() {}" || short.Truncated {
+		t.Errorf("Expected the whole one-line function, got %+v", short)
+	}
+}
+
+func TestExtractExcerptSizeCap(t *testing.T) {
+	// A generated function with one huge line of multi-byte characters.
+	src := []byte("package gen\n\nfunc Table() string {\n\treturn \"" + strings.Repeat("é", 3000) + "\"\n}\n")
+	excerpt := ExtractExcerpt(ComplexityStat{FunctionName: "Table", File: "gen/table.go", Line: 3, EndLine: 5}, src)
+
+	if len(excerpt.Code) > maxExcerptBytes || !excerpt.Truncated {
+		t.Errorf("Expected a truncated excerpt of at most %d bytes, got %d bytes", maxExcerptBytes, len(excerpt.Code))
+	}
+	if !utf8.ValidString(excerpt.Code) {
+		t.Errorf("Expected truncation not to split a multi-byte character")
+	}
+}
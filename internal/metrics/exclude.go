@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"slices"
+	"strings"
+)
+
+// ExcludeFilter leaves files out of the analysis by glob pattern. The zero value excludes nothing.
+//
+// Patterns follow .gitignore conventions where path.Match allows: a pattern without a slash
+// matches a file or directory name at any depth, a pattern with a slash matches a path from
+// the repository root, and a trailing slash matches directories only. Excluding a directory
+// excludes everything below it.
+type ExcludeFilter struct {
+	patterns []string
+	scoped   []scopedPatterns
+}
+
+// scopedPatterns are exclude patterns that apply below dir only, relative to it.
+type scopedPatterns struct {
+	dir      string
+	patterns []string
+}
+
+// NewExcludeFilter validates patterns and returns a filter excluding the files they match.
+func NewExcludeFilter(patterns []string) (ExcludeFilter, error) {
+	for _, p := range patterns {
+		if _, err := path.Match(strings.TrimSuffix(p, "/"), ""); err != nil {
+			return ExcludeFilter{}, fmt.Errorf("invalid exclude pattern %q: %w", p, err)
+		}
+	}
+	return ExcludeFilter{patterns: patterns}, nil
+}
+
+// Under returns a copy of the filter that also excludes the files below dir (slash-separated,
+// relative to the repository root) that patterns match. The patterns are relative to dir, as
+// if it were the root, so a pattern with a slash matches a path from dir.
+func (f ExcludeFilter) Under(dir string, patterns []string) (ExcludeFilter, error) {
+	scoped, err := NewExcludeFilter(patterns)
+	if err != nil {
+		return ExcludeFilter{}, err
+	}
+	dir = path.Clean(dir)
+	if dir == "." {
+		f.patterns = append(slices.Clip(f.patterns), patterns...)
+		return f, nil
+	}
+	f.scoped = append(slices.Clip(f.scoped), scopedPatterns{dir: dir, patterns: scoped.patterns})
+	return f, nil
+}
+
+// ReadPatternFile reads newline-delimited exclude patterns from file. Blank lines and lines
+// starting with # are skipped, and surrounding whitespace is trimmed.
+func ReadPatternFile(file string) ([]string, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open pattern file %s: %w", file, err)
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		patterns = append(patterns, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read pattern file %s: %w", file, err)
+	}
+	return patterns, nil
+}
+
+// Active reports whether the filter excludes anything.
+func (f ExcludeFilter) Active() bool {
+	return len(f.patterns) > 0 || len(f.scoped) > 0
+}
+
+// Patterns returns the filter's patterns, without those added by Under.
+func (f ExcludeFilter) Patterns() []string {
+	return f.patterns
+}
+
+// Excludes reports whether the file at relPath (slash-separated, relative to the repository
+// root) or one of its parent directories matches a pattern.
+func (f ExcludeFilter) Excludes(relPath string) bool {
+	relPath = path.Clean(relPath)
+	if matchPatterns(f.patterns, relPath) {
+		return true
+	}
+	for _, s := range f.scoped {
+		if rest, ok := strings.CutPrefix(relPath, s.dir+"/"); ok && matchPatterns(s.patterns, rest) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPatterns reports whether the file at relPath or one of its parent directories matches
+// one of patterns.
+func matchPatterns(patterns []string, relPath string) bool {
+	segments := strings.Split(relPath, "/")
+	for i := range segments {
+		isDir := i < len(segments)-1
+		for _, p := range patterns {
+			if strings.HasSuffix(p, "/") {
+				if !isDir {
+					continue
+				}
+				p = strings.TrimSuffix(p, "/")
+			}
+			name := segments[i]
+			if strings.Contains(p, "/") {
+				p = strings.TrimPrefix(p, "/")
+				name = strings.Join(segments[:i+1], "/")
+			}
+			if ok, _ := path.Match(p, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
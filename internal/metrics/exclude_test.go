@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestExcludeFilter(t *testing.T) {
+	filter, err := NewExcludeFilter([]string{"*.pb.go", "gen/", "internal/legacy", "docs/*.md"})
+	if err != nil {
+		t.Fatalf("NewExcludeFilter failed: %v", err)
+	}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"api/service.pb.go", true},
+		{"service.pb.go", true},
+		{"gen/models.go", true},
+		{"cmd/gen/main.go", true},
+		{"gen", false}, // A file called gen is not a directory
+		{"internal/legacy/old.go", true},
+		{"pkg/internal/legacy/old.go", false},
+		{"docs/a.md", true},
+		{"docs/api/a.md", false},
+		{"internal/git/git.go", false},
+	}
+	for _, tt := range tests {
+		if got := filter.Excludes(tt.path); got != tt.want {
+			t.Errorf("Excludes(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	if (ExcludeFilter{}).Excludes("main.go") {
+		t.Errorf("Expected the zero filter to exclude nothing")
+	}
+	if _, err := NewExcludeFilter([]string{"[unclosed"}); err == nil {
+		t.Errorf("Expected an error for a malformed pattern")
+	}
+}
+
+func TestExcludeFilterUnder(t *testing.T) {
+	base, err := NewExcludeFilter([]string{"gen/"})
+	if err != nil {
+		t.Fatalf("NewExcludeFilter failed: %v", err)
+	}
+	filter, err := base.Under("experiments", []string{"*_scratch.go", "/legacy"})
+	if err != nil {
+		t.Fatalf("Under failed: %v", err)
+	}
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"core/gen/a.go", true}, // The base patterns still apply everywhere
+		{"experiments/x_scratch.go", true},
+		{"experiments/deep/x_scratch.go", true},
+		{"core/x_scratch.go", false}, // Scoped patterns only apply below their directory
+		{"experiments/legacy/old.go", true},
+		{"experiments/deep/legacy/old.go", false}, // A leading slash anchors at the scope directory
+		{"legacy/old.go", false},
+		{"experimentsx/x_scratch.go", false},
+	}
+	for _, tt := range tests {
+		if got := filter.Excludes(tt.path); got != tt.want {
+			t.Errorf("Excludes(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+	if base.Excludes("experiments/x_scratch.go") {
+		t.Errorf("Expected Under to leave the original filter unchanged")
+	}
+	if scoped, _ := (ExcludeFilter{}).Under("a", []string{"b"}); !scoped.Active() {
+		t.Errorf("Expected a filter with scoped patterns only to be active")
+	}
+	if _, err := base.Under("a", []string{"[unclosed"}); err == nil {
+		t.Errorf("Expected an error for a malformed pattern")
+	}
+}
+
+func TestReadPatternFileExcludesFromRollup(t *testing.T) {
+	root := rollupFixture(t)
+	patternDir := t.TempDir()
+	writeFile(t, patternDir, "ignore.txt", "# generated and vendored code\ninternal/report/\n\n  *.png  \n")
+
+	patterns, err := ReadPatternFile(filepath.Join(patternDir, "ignore.txt"))
+	if err != nil {
+		t.Fatalf("ReadPatternFile failed: %v", err)
+	}
+	if len(patterns) != 2 || patterns[0] != "internal/report/" || patterns[1] != "*.png" {
+		t.Fatalf("Expected comments and blank lines to be skipped, got %q", patterns)
+	}
+	exclude, err := NewExcludeFilter(append([]string{"docs"}, patterns...))
+	if err != nil {
+		t.Fatalf("NewExcludeFilter failed: %v", err)
+	}
+
+	rollup, warnings, err := ComputeDirectoryRollup(root, nil, nil, RollupOptions{MaxDepth: 2, Exclude: exclude})
+	if err != nil {
+		t.Fatalf("ComputeDirectoryRollup failed: %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("Expected no binary file warning for the excluded logo, got %v", warnings)
+	}
+	// main.go and internal/git/ remain.
+	if rollup.Files != 3 {
+		t.Errorf("Expected 3 files after exclusion, got %d", rollup.Files)
+	}
+	for _, excluded := range []string{"docs", "assets"} {
+		if findChild(rollup, excluded) != nil {
+			t.Errorf("Expected %s to be excluded from the rollup", excluded)
+		}
+	}
+	if internal := findChild(rollup, "internal"); internal == nil || findChild(internal, "internal/report") != nil {
+		t.Errorf("Expected internal/report to be excluded, got %+v", internal)
+	}
+	if _, err := ReadPatternFile(filepath.Join(patternDir, "missing.txt")); err == nil {
+		t.Errorf("Expected an error for a missing pattern file")
+	}
+}
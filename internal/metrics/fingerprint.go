@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"go/ast"
+	"go/printer"
+	"go/token"
+	"path"
+	"strings"
+)
+
+// fingerprintKindComplexity is the kind of complexity findings in fingerprints.
+const fingerprintKindComplexity = "complexity"
+
+// fingerprintStatements is the number of leading statements of a function's body that go
+// into its fingerprint.
+const fingerprintStatements = 3
+
+// functionFingerprint identifies a finding of the given kind about fn, declared in package
+// pkg in the file at the slash-separated relPath, across runs. It hashes the kind, the
+// package and its directory, the receiver type, the function name and the first statements
+// of the body with comments and whitespace normalized away. Unlike a file and line, it survives edits
+// elsewhere in the file, moving the function within its package and reformatting.
+func functionFingerprint(kind, relPath, pkg string, fset *token.FileSet, fn *ast.FuncDecl) string {
+	var b strings.Builder
+	for _, part := range []string{kind, path.Dir(relPath), pkg, receiverType(fset, fn), fn.Name.Name} {
+		b.WriteString(part)
+		b.WriteByte(0)
+	}
+	for _, stmt := range fn.Body.List[:min(len(fn.Body.List), fingerprintStatements)] {
+		b.WriteString(normalizedNode(fset, stmt))
+		b.WriteByte(0)
+	}
+	sum := sha256.Sum256([]byte(b.String()))
+	return hex.EncodeToString(sum[:8])
+}
+
+// receiverType returns the printed receiver type of a method, e.g. "*T", or "" for functions.
+func receiverType(fset *token.FileSet, fn *ast.FuncDecl) string {
+	if fn.Recv == nil || len(fn.Recv.List) == 0 {
+		return ""
+	}
+	return normalizedNode(fset, fn.Recv.List[0].Type)
+}
+
+// normalizedNode prints node without comments and with runs of whitespace collapsed to a
+// single space.
+func normalizedNode(fset *token.FileSet, node ast.Node) string {
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, fset, node); err != nil {
+		return ""
+	}
+	return strings.Join(strings.Fields(buf.String()), " ")
+}
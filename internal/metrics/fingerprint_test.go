@@ -0,0 +1,75 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+// fingerprints returns the fingerprints of the functions in a single file, by function name.
+func fingerprints(t *testing.T, relPath, src string) map[string]string {
+	t.Helper()
+	stats, warnings := CollectComplexityFromSources(map[string][]byte{relPath: []byte(src)})
+	if len(warnings) != 0 {
+		t.Fatalf("Unexpected warnings: %v", warnings)
+	}
+	fps := make(map[string]string)
+	for _, cs := range stats {
+		if cs.Fingerprint == "" {
+			t.Fatalf("Expected a fingerprint for %s", cs.FunctionName)
+		}
+		fps[cs.FunctionName] = cs.Fingerprint
+	}
+	return fps
+}
+
+const fingerprintSrc = `package lib
+
+func Parse(s string) int {
+	if s == "" {
+		return 0
+	}
+	return len(s)
+}
+
+func Other() int {
+	return 1
+}
+`
+
+func TestFingerprintStableUnderLineDrift(t *testing.T) {
+	before := fingerprints(t, "lib/lib.go", fingerprintSrc)
+
+	// Shift both functions down 50 lines and edit the other function.
+	shifted := strings.Replace(fingerprintSrc, "package lib\n", "package lib\n"+strings.Repeat("\n", 50), 1)
+	shifted = strings.Replace(shifted, "return 1", "return 2", 1)
+	after := fingerprints(t, "lib/lib.go", shifted)
+	if before["Parse"] != after["Parse"] {
+		t.Errorf("Expected Parse's fingerprint to survive the shift, got %s and %s", before["Parse"], after["Parse"])
+	}
+
+	// Comments and formatting don't matter, nor does the file within the package.
+	reformatted := strings.Replace(fingerprintSrc, "\tif s == \"\" {", "\t// Empty input.\n\tif s==\"\"   {", 1)
+	if fp := fingerprints(t, "lib/parse.go", reformatted)["Parse"]; fp != before["Parse"] {
+		t.Errorf("Expected Parse's fingerprint to survive comments and reformatting, got %s and %s", before["Parse"], fp)
+	}
+}
+
+func TestFingerprintDistinguishesFindings(t *testing.T) {
+	base := fingerprints(t, "lib/lib.go", fingerprintSrc)["Parse"]
+	for name, fp := range map[string]string{
+		"another package directory": fingerprints(t, "other/lib.go", fingerprintSrc)["Parse"],
+		"changed first statements":  fingerprints(t, "lib/lib.go", strings.Replace(fingerprintSrc, `s == ""`, `s == "-"`, 1))["Parse"],
+	} {
+		if fp == base {
+			t.Errorf("Expected %s to change the fingerprint", name)
+		}
+	}
+
+	methods := fingerprints(t, "lib/lib.go", "package lib\n\ntype A struct{}\ntype B struct{}\n\n"+
+		"func (A) Name() string { return \"\" }\n")
+	other := fingerprints(t, "lib/lib.go", "package lib\n\ntype A struct{}\ntype B struct{}\n\n"+
+		"func (*B) Name() string { return \"\" }\n")
+	if methods["A.Name"] == "" || methods["A.Name"] == other["(*B).Name"] {
+		t.Errorf("Expected methods of different receivers to have different fingerprints")
+	}
+}
@@ -0,0 +1,66 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/user/zenwatch/internal/warning"
+)
+
+// DateBlameFunc returns the date of the last change to every line of a file, indexed from 0
+// for line 1. file is slash-separated and relative to the repository root.
+type DateBlameFunc func(file string) ([]time.Time, error)
+
+// CodeFreshness is the share of the source lines changed recently. A large share of recently
+// changed code may signal code that has not settled yet.
+type CodeFreshness struct {
+	Window      time.Duration `json:"window"` // Lines changed within this long before AsOf are recent
+	AsOf        time.Time     `json:"asOf"`   // Date of the analyzed commit
+	RecentLines int           `json:"recentLines"`
+	TotalLines  int           `json:"totalLines"` // Lines of the files that could be blamed
+}
+
+// Ratio returns the fraction of the lines that are recent, or 0 if there are no lines.
+func (f *CodeFreshness) Ratio() float64 {
+	if f.TotalLines == 0 {
+		return 0
+	}
+	return float64(f.RecentLines) / float64(f.TotalLines)
+}
+
+// Percent returns Ratio as a percentage.
+func (f *CodeFreshness) Percent() float64 {
+	return f.Ratio() * 100
+}
+
+// ComputeCodeFreshness blames the Go files under repoPath, in the directories the go tool
+// builds, and counts the lines last changed within window before asOf. Files matching
+// exclude are not blamed. Files that cannot be blamed are left out and reported as warnings.
+// Once ctx is done, no more files are blamed and the counts so far are returned with ctx.Err().
+func ComputeCodeFreshness(ctx context.Context, repoPath string, exclude ExcludeFilter, blame DateBlameFunc, asOf time.Time, window time.Duration) (*CodeFreshness, []warning.Warning, error) {
+	freshness := &CodeFreshness{Window: window, AsOf: asOf}
+	since := asOf.Add(-window)
+	var warnings []warning.Warning
+	err := walkGoFiles(repoPath, exclude, func(rel, _ string) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		dates, err := blame(rel)
+		if err != nil {
+			warnings = append(warnings, warning.Warning{
+				Code:    warning.BlameUnavailable,
+				Message: "lines left out of the code freshness: " + err.Error(),
+				File:    rel,
+			})
+			return nil
+		}
+		freshness.TotalLines += len(dates)
+		for _, date := range dates {
+			if !date.Before(since) {
+				freshness.RecentLines++
+			}
+		}
+		return nil
+	})
+	return freshness, warnings, err
+}
@@ -0,0 +1,55 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/user/zenwatch/internal/warning"
+)
+
+func TestComputeCodeFreshness(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "old.go", "package main\n\nfunc old() {}\n")
+	writeFile(t, root, "svc/mixed.go", "package svc\n\nfunc A() {}\nfunc B() {}\nfunc C() {}\n")
+	writeFile(t, root, "untracked.go", "package main\n")
+	writeFile(t, root, "vendor/dep/dep.go", "package dep\n")
+
+	asOf := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	old := asOf.AddDate(-1, 0, 0)
+	recent := asOf.AddDate(0, 0, -10)
+	boundary := asOf.AddDate(0, 0, -90)
+	blame := map[string][]time.Time{
+		"old.go":       {old, old, old},
+		"svc/mixed.go": {old, old, recent, boundary, boundary.Add(-time.Second)},
+	}
+	blameFunc := func(file string) ([]time.Time, error) {
+		dates, ok := blame[file]
+		if !ok {
+			return nil, errors.New("file not found in HEAD")
+		}
+		return dates, nil
+	}
+
+	freshness, warnings, err := ComputeCodeFreshness(context.Background(), root, ExcludeFilter{}, blameFunc, asOf, 90*24*time.Hour)
+	if err != nil {
+		t.Fatalf("ComputeCodeFreshness failed: %v", err)
+	}
+	if freshness.RecentLines != 2 || freshness.TotalLines != 8 {
+		t.Errorf("Expected 2 of 8 lines to be recent, got %d of %d", freshness.RecentLines, freshness.TotalLines)
+	}
+	if ratio := freshness.Ratio(); ratio != 0.25 {
+		t.Errorf("Expected ratio 0.25, got %v", ratio)
+	}
+	if len(warnings) != 1 || warnings[0].Code != warning.BlameUnavailable || warnings[0].File != "untracked.go" {
+		t.Errorf("Expected one blame-unavailable warning for untracked.go, got %+v", warnings)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	freshness, _, err = ComputeCodeFreshness(ctx, root, ExcludeFilter{}, blameFunc, asOf, 90*24*time.Hour)
+	if !errors.Is(err, context.Canceled) || freshness.TotalLines != 0 {
+		t.Errorf("Expected a cancelled walk to blame nothing, got %d lines and error %v", freshness.TotalLines, err)
+	}
+}
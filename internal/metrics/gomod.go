@@ -0,0 +1,143 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"go/version"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/user/zenwatch/internal/warning"
+)
+
+// readModulePath returns the module path declared in dir/go.mod.
+// It returns an empty path and no error if dir has no go.mod.
+func readModulePath(dir string) (string, error) {
+	file, err := os.Open(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", nil
+		}
+		return "", fmt.Errorf("failed to open go.mod: %w", err)
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "module" {
+			if unquoted, err := strconv.Unquote(fields[1]); err == nil {
+				return unquoted, nil
+			}
+			return fields[1], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read go.mod: %w", err)
+	}
+	return "", fmt.Errorf("go.mod in %s has no module directive", dir)
+}
+
+// goMod holds the Go version, requirements and replacements of a go.mod file.
+type goMod struct {
+	goVersion string            // Language version of the go directive, e.g. "1.21"; empty if absent
+	requires  map[string]string // module path -> required version
+	replaces  map[string]string // "path" or "path@version" -> "new-path [new-version]"
+}
+
+// readGoMod parses the require and replace directives of dir/go.mod, in both the
+// single-line and the block form. It returns nil and no error if dir has no go.mod.
+func readGoMod(dir string) (*goMod, error) {
+	content, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read go.mod: %w", err)
+	}
+
+	mod := &goMod{requires: make(map[string]string), replaces: make(map[string]string)}
+	block := ""
+	for _, line := range strings.Split(string(content), "\n") {
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		directive := block
+		switch {
+		case block != "" && fields[0] == ")":
+			block = ""
+			continue
+		case block == "" && len(fields) == 2 && fields[1] == "(":
+			block = fields[0]
+			continue
+		case block == "":
+			directive, fields = fields[0], fields[1:]
+		}
+
+		switch directive {
+		case "go":
+			if len(fields) >= 1 {
+				mod.goVersion = fields[0]
+			}
+		case "require":
+			if len(fields) >= 2 {
+				mod.requires[unquoteModulePath(fields[0])] = fields[1]
+			}
+		case "replace":
+			// old [version] => new [version]
+			for i, f := range fields {
+				if f != "=>" || i == 0 || i == len(fields)-1 {
+					continue
+				}
+				old := unquoteModulePath(fields[0])
+				if i == 2 {
+					old += "@" + fields[1]
+				}
+				mod.replaces[old] = strings.Join(fields[i+1:], " ")
+			}
+		}
+	}
+	return mod, nil
+}
+
+// CheckGoVersion warns if the go directive of repoPath/go.mod declares a newer Go version
+// than toolchain (as reported by runtime.Version), since syntax added after the toolchain's
+// release cannot be parsed. Older versions need no handling: go/parser accepts every
+// earlier language version, including generics from Go 1.18 on.
+func CheckGoVersion(repoPath, toolchain string) ([]warning.Warning, error) {
+	mod, err := readGoMod(repoPath)
+	if err != nil || mod == nil || mod.goVersion == "" {
+		return nil, err
+	}
+	declared := "go" + mod.goVersion
+	if !version.IsValid(declared) || !version.IsValid(toolchain) || version.Compare(declared, toolchain) <= 0 {
+		return nil, nil
+	}
+	return []warning.Warning{{
+		Code:    warning.NewerGoVersion,
+		Message: fmt.Sprintf("go.mod declares go %s but zenwatch was built with %s; files using newer syntax are skipped", mod.goVersion, toolchain),
+		File:    "go.mod",
+	}}, nil
+}
+
+// replacement returns the replacement of path at version, preferring a version-specific one.
+func (m *goMod) replacement(path, version string) string {
+	if r, ok := m.replaces[path+"@"+version]; ok {
+		return r
+	}
+	return m.replaces[path]
+}
+
+func unquoteModulePath(s string) string {
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		return unquoted
+	}
+	return s
+}
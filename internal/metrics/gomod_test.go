@@ -0,0 +1,26 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/user/zenwatch/internal/warning"
+)
+
+func TestCheckGoVersion(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "go.mod", "module example.com/future\n\ngo 1.99\n")
+
+	warnings, err := CheckGoVersion(root, "go1.22.5")
+	if err != nil {
+		t.Fatalf("CheckGoVersion failed: %v", err)
+	}
+	if len(warnings) != 1 || warnings[0].Code != warning.NewerGoVersion || warnings[0].File != "go.mod" {
+		t.Errorf("Expected a newer-go-version warning, got %+v", warnings)
+	}
+
+	for _, toolchain := range []string{"go1.99", "go1.100.1", "devel +abc123"} {
+		if warnings, err := CheckGoVersion(root, toolchain); err != nil || len(warnings) != 0 {
+			t.Errorf("Expected no warning with toolchain %s, got %+v, %v", toolchain, warnings, err)
+		}
+	}
+}
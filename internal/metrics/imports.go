@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// BannedImport is an import of a package on the configured banned list.
+type BannedImport struct {
+	File       string `json:"file"` // Slash-separated path relative to the repository root
+	Line       int    `json:"line"`
+	ImportPath string `json:"importPath"`
+}
+
+// FindBannedImports walks the Go files under repoPath, including tests, and returns every
+// import whose path exactly matches one of banned, ordered by file and line.
+func FindBannedImports(repoPath string, banned []string) ([]BannedImport, error) {
+	if len(banned) == 0 {
+		return nil, nil
+	}
+	bannedSet := make(map[string]bool, len(banned))
+	for _, importPath := range banned {
+		bannedSet[importPath] = true
+	}
+
+	var found []BannedImport
+	fset := token.NewFileSet()
+	err := filepath.WalkDir(repoPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != repoPath && skipGoDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(path, ".go") {
+			return nil
+		}
+
+		// Unparseable files are reported by CollectComplexity.
+		file, _ := parser.ParseFile(fset, path, nil, parser.ImportsOnly)
+		if file == nil {
+			return nil
+		}
+		relPath, err := filepath.Rel(repoPath, path)
+		if err != nil {
+			return err
+		}
+		for _, imp := range file.Imports {
+			importPath, err := strconv.Unquote(imp.Path.Value)
+			if err != nil || !bannedSet[importPath] {
+				continue
+			}
+			found = append(found, BannedImport{
+				File:       filepath.ToSlash(relPath),
+				Line:       fset.Position(imp.Pos()).Line,
+				ImportPath: importPath,
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].File != found[j].File {
+			return found[i].File < found[j].File
+		}
+		return found[i].Line < found[j].Line
+	})
+	return found, nil
+}
@@ -0,0 +1,48 @@
+package metrics
+
+import "testing"
+
+func TestFindBannedImports(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "legacy/files.go", `package legacy
+
+import (
+	"fmt"
+	"io/ioutil"
+)
+
+func Read() { fmt.Println(ioutil.Discard) }
+`)
+	writeFile(t, root, "legacy/files_test.go", "package legacy\n\nimport \"example.com/internal/oldlog\"\n")
+	// Exact matching: a package whose path merely starts with a banned one is fine.
+	writeFile(t, root, "modern/modern.go", "package modern\n\nimport \"io/ioutilx\"\n")
+	writeFile(t, root, "vendor/dep/dep.go", "package dep\n\nimport \"io/ioutil\"\n")
+
+	found, err := FindBannedImports(root, []string{"io/ioutil", "example.com/internal/oldlog"})
+	if err != nil {
+		t.Fatalf("FindBannedImports failed: %v", err)
+	}
+
+	expected := []BannedImport{
+		{File: "legacy/files.go", Line: 5, ImportPath: "io/ioutil"},
+		{File: "legacy/files_test.go", Line: 3, ImportPath: "example.com/internal/oldlog"},
+	}
+	if len(found) != len(expected) {
+		t.Fatalf("Expected %d banned imports, got %d: %+v", len(expected), len(found), found)
+	}
+	for i := range expected {
+		if found[i] != expected[i] {
+			t.Errorf("Finding %d: expected %+v, got %+v", i, expected[i], found[i])
+		}
+	}
+}
+
+func TestFindBannedImportsNoList(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "a.go", "package a\n\nimport \"io/ioutil\"\n")
+
+	found, err := FindBannedImports(root, nil)
+	if err != nil || len(found) != 0 {
+		t.Errorf("Expected no findings without a banned list, got %+v, %v", found, err)
+	}
+}
@@ -0,0 +1,213 @@
+package metrics
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Language is a language zenwatch recognizes, identified by file extension.
+type Language struct {
+	Name       string
+	Extensions []string
+	Source     bool // Analyzed as source code; other languages only count towards file statistics
+}
+
+func (l Language) String() string {
+	return l.Name + " (" + strings.Join(l.Extensions, ", ") + ")"
+}
+
+// Languages lists the registered languages. Only Source languages are looked at by the
+// source analyses.
+var Languages = []Language{
+	{Name: "Go", Extensions: []string{".go"}, Source: true},
+	{Name: "Markdown", Extensions: []string{".md", ".markdown"}},
+	{Name: "YAML", Extensions: []string{".yaml", ".yml"}},
+	{Name: "JSON", Extensions: []string{".json"}},
+	{Name: "JavaScript", Extensions: []string{".js", ".mjs", ".cjs"}},
+	{Name: "TypeScript", Extensions: []string{".ts", ".tsx"}},
+}
+
+// matches reports whether path has one of the language's extensions.
+func (l Language) matches(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	for _, e := range l.Extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// isSourceFile reports whether path has the extension of a registered source language.
+func isSourceFile(path string) bool {
+	for _, lang := range Languages {
+		if lang.Source && lang.matches(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// LanguageFilter restricts an analysis to a set of languages. The zero value matches every file.
+type LanguageFilter struct {
+	languages []Language
+}
+
+// ParseLanguageFilter parses a comma-separated list of case-insensitive language names such
+// as "go,markdown". An empty string returns the zero filter.
+func ParseLanguageFilter(s string) (LanguageFilter, error) {
+	var f LanguageFilter
+	for _, field := range strings.Split(s, ",") {
+		name := strings.TrimSpace(field)
+		if name == "" {
+			continue
+		}
+		lang, ok := lookupLanguage(name)
+		if !ok {
+			supported := make([]string, len(Languages))
+			for i, l := range Languages {
+				supported[i] = strings.ToLower(l.Name)
+			}
+			return LanguageFilter{}, fmt.Errorf("unknown language %q (supported: %s)", name, strings.Join(supported, ", "))
+		}
+		if f.Active() && f.Includes(lang.Name) {
+			continue // Listed twice
+		}
+		f.languages = append(f.languages, lang)
+	}
+	return f, nil
+}
+
+func lookupLanguage(name string) (Language, bool) {
+	for _, lang := range Languages {
+		if strings.EqualFold(lang.Name, name) {
+			return lang, true
+		}
+	}
+	return Language{}, false
+}
+
+// Active reports whether the filter restricts anything.
+func (f LanguageFilter) Active() bool {
+	return len(f.languages) > 0
+}
+
+// Includes reports whether the language called name passes the filter.
+func (f LanguageFilter) Includes(name string) bool {
+	if !f.Active() {
+		return true
+	}
+	for _, lang := range f.languages {
+		if strings.EqualFold(lang.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Match reports whether the file at path belongs to a language that passes the filter.
+func (f LanguageFilter) Match(path string) bool {
+	if !f.Active() {
+		return true
+	}
+	for _, lang := range f.languages {
+		if lang.matches(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// String lists the selected language names, e.g. "Go, Markdown"; empty for the zero filter.
+func (f LanguageFilter) String() string {
+	names := make([]string, len(f.languages))
+	for i, lang := range f.languages {
+		names[i] = lang.Name
+	}
+	return strings.Join(names, ", ")
+}
+
+// SourceInventory summarizes which files of a repository the source analyses can see.
+type SourceInventory struct {
+	SourceFiles         int            `json:"sourceFiles"`         // Files of a registered language that are analyzed
+	ExcludedSourceFiles int            `json:"excludedSourceFiles"` // Files of a registered language in skipped directories (vendor, testdata, ...)
+	Extensions          map[string]int `json:"extensions"`          // Number of files per lower-cased extension, "(none)" for files without one
+}
+
+// HasSource reports whether any source file is analyzed.
+func (s *SourceInventory) HasSource() bool {
+	return s.SourceFiles > 0
+}
+
+// TopExtensions returns up to n extensions with the most files, most common first.
+func (s *SourceInventory) TopExtensions(n int) []FileTypeStat {
+	top := make([]FileTypeStat, 0, len(s.Extensions))
+	for ext, count := range s.Extensions {
+		top = append(top, FileTypeStat{Extension: ext, Count: count})
+	}
+	sort.Slice(top, func(i, j int) bool {
+		if top[i].Count != top[j].Count {
+			return top[i].Count > top[j].Count
+		}
+		return top[i].Extension < top[j].Extension
+	})
+	if len(top) > n {
+		top = top[:n]
+	}
+	return top
+}
+
+// TakeSourceInventory walks every file under repoPath, except the .git directory, and
+// counts the source files the analyses would include and the ones they skip.
+func TakeSourceInventory(repoPath string) (*SourceInventory, error) {
+	inv := &SourceInventory{Extensions: make(map[string]int)}
+	err := filepath.WalkDir(repoPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		ext := strings.ToLower(filepath.Ext(path))
+		if ext == "" {
+			ext = "(none)"
+		}
+		inv.Extensions[ext]++
+		if !isSourceFile(path) {
+			return nil
+		}
+
+		relPath, err := filepath.Rel(repoPath, path)
+		if err != nil {
+			return err
+		}
+		if inSkippedGoDir(filepath.ToSlash(relPath)) {
+			inv.ExcludedSourceFiles++
+			return nil
+		}
+		inv.SourceFiles++
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inv, nil
+}
+
+// DescribeLanguages lists the registered source languages for messages, e.g. "Go (.go)".
+func DescribeLanguages() string {
+	var names []string
+	for _, lang := range Languages {
+		if lang.Source {
+			names = append(names, lang.String())
+		}
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTakeSourceInventoryDocsOnly(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "README.md", "# Docs\n")
+	writeFile(t, root, "docs/guide.md", "guide\n")
+	writeFile(t, root, "docs/data.json", "{}\n")
+	writeFile(t, root, "LICENSE", "MIT\n")
+	writeFile(t, root, ".git/HEAD", "ref: refs/heads/main\n")
+
+	inv, err := TakeSourceInventory(root)
+	if err != nil {
+		t.Fatalf("TakeSourceInventory failed: %v", err)
+	}
+	if inv.HasSource() || inv.ExcludedSourceFiles != 0 {
+		t.Errorf("Expected no source files, got %+v", inv)
+	}
+
+	top := inv.TopExtensions(2)
+	if len(top) != 2 || top[0] != (FileTypeStat{Extension: ".md", Count: 2}) || top[1] != (FileTypeStat{Extension: "(none)", Count: 1}) {
+		t.Errorf("Unexpected top extensions: %+v", top)
+	}
+}
+
+func TestTakeSourceInventoryAllExcluded(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "vendor/dep/dep.go", "package dep\n")
+	writeFile(t, root, "testdata/fixture.go", "package fixture\n")
+	writeFile(t, root, "README.md", "# Readme\n")
+
+	inv, err := TakeSourceInventory(root)
+	if err != nil {
+		t.Fatalf("TakeSourceInventory failed: %v", err)
+	}
+	if inv.HasSource() {
+		t.Errorf("Expected no analyzed source files, got %d", inv.SourceFiles)
+	}
+	if inv.ExcludedSourceFiles != 2 {
+		t.Errorf("Expected 2 excluded source files, got %d", inv.ExcludedSourceFiles)
+	}
+
+	writeFile(t, root, "main.go", "package main\n")
+	if inv, _ := TakeSourceInventory(root); inv.SourceFiles != 1 {
+		t.Errorf("Expected 1 analyzed source file, got %d", inv.SourceFiles)
+	}
+}
+
+func TestParseLanguageFilter(t *testing.T) {
+	f, err := ParseLanguageFilter("go, Markdown,go")
+	if err != nil {
+		t.Fatalf("ParseLanguageFilter failed: %v", err)
+	}
+	if f.String() != "Go, Markdown" {
+		t.Errorf("Expected filter \"Go, Markdown\", got %q", f.String())
+	}
+	for path, expected := range map[string]bool{
+		"main.go":         true,
+		"docs/README.MD":  true,
+		"web/app.js":      false,
+		"config.yaml":     false,
+		"LICENSE":         false,
+		"docs/notes.mdx":  false,
+		"docs/guide.md":   true,
+		"vendor/x/dep.go": true, // Directory exclusions are applied by the analyses, not the filter
+	} {
+		if got := f.Match(path); got != expected {
+			t.Errorf("Match(%q) = %v, expected %v", path, got, expected)
+		}
+	}
+	if !f.Includes("go") || f.Includes("YAML") {
+		t.Errorf("Unexpected Includes results for %q", f)
+	}
+
+	var zero LanguageFilter
+	if zero.Active() || !zero.Match("web/app.js") || !zero.Includes("Go") {
+		t.Errorf("Expected the zero filter to match everything")
+	}
+	if f, err := ParseLanguageFilter(""); err != nil || f.Active() {
+		t.Errorf("Expected an empty list to return the zero filter, got %q, %v", f, err)
+	}
+
+	_, err = ParseLanguageFilter("go,cobol")
+	if err == nil || !strings.Contains(err.Error(), `"cobol"`) || !strings.Contains(err.Error(), "go, markdown, yaml") {
+		t.Errorf("Expected an error naming the unknown language and the supported ones, got %v", err)
+	}
+}
@@ -1,9 +1,191 @@
 package metrics
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/user/zenwatch/internal/warning"
+)
+
+// OverallStats separates commit-scoped churn (what the analyzed commit changed)
+// from repository-wide metrics (computed over the whole tree at that commit).
 type OverallStats struct {
-	TotalLinesAdded, TotalLinesDeleted, FunctionsOverThreshold int
-	AverageComplexity float64
-	FileStats map[string]*FileTypeStat
-	ComplexityStats []ComplexityStat
+	// Commit-scoped: lines and files changed by the analyzed commit.
+	TotalLinesAdded   int                      `json:"totalLinesAdded"`
+	TotalLinesDeleted int                      `json:"totalLinesDeleted"`
+	FileStats         map[string]*FileTypeStat `json:"fileStats"`
+	DiffCoverage      *DiffCoverage            `json:"diffCoverage,omitempty"` // Optional: test coverage of the lines the commit added
+
+	// Repository-wide: every function and package in the tree, whether or not the commit touched it.
+	FunctionsOverThreshold int                    `json:"functionsOverThreshold"`
+	AverageComplexity      float64                `json:"averageComplexity"`
+	ComplexityStats        []ComplexityStat       `json:"complexityStats"`
+	PackageCoupling        []PackageCouplingStats `json:"packageCoupling"`
+	Packages               []PackageStat          `json:"packages"`           // Package inventory, largest first
+	DirectoryRollup        *DirectoryStat         `json:"directoryRollup"`    // SLOC and complexity are repository-wide, churn is commit-scoped
+	BannedImports          []BannedImport         `json:"bannedImports"`      // Imports of packages on the configured banned list, tests included
+	Build                  *BuildStatus           `json:"build,omitempty"`    // Optional: whether the module compiles
+	VendorDrift            []VendorDrift          `json:"vendorDrift"`        // Mismatches between go.mod and vendor/modules.txt
+	Style                  []StyleStats           `json:"style"`              // Indentation and line length per language
+	MaxLineLength          int                    `json:"maxLineLength"`      // Display width above which Style counts lines as long
+	DirectoryOverrides     []string               `json:"directoryOverrides"` // Directories below the root whose .zenwatch.yaml adjusted the analysis of their subtree
+
+	// Test functions are summarized separately so they don't skew the production numbers.
+	TestFunctionsOverThreshold int              `json:"testFunctionsOverThreshold"`
+	TestAverageComplexity      float64          `json:"testAverageComplexity"`
+	TestComplexityStats        []ComplexityStat `json:"testComplexityStats"`
+	TableDrivenTestFunctions   int              `json:"tableDrivenTestFunctions"` // Test functions whose case loop is excluded from their complexity
+
+	ComplexityOwnership []AuthorComplexity  `json:"complexityOwnership,omitempty"` // Optional: authors owning the functions over threshold
+	AnnotationAuthors   []AuthorAnnotations `json:"annotationAuthors,omitempty"`   // Optional: TODO and FIXME comments per author of their line
+	Freshness           *CodeFreshness      `json:"freshness,omitempty"`           // Optional: share of the lines changed recently
+	ComplexityTrend     []TrendPoint        `json:"complexityTrend,omitempty"`     // Optional: functions over threshold at recent commits, oldest first
+	RefactoringPlan     *RefactoringPlan    `json:"refactoringPlan,omitempty"`     // Optional: functions over threshold ranked for refactoring
+	Excerpts            []CodeExcerpt       `json:"excerpts,omitempty"`            // Optional: source of the most complex functions, most complex first
+	Run                 *RunStats           `json:"run,omitempty"`                 // Optional: what the run fetched, walked and skipped
+	Cadence             *Cadence            `json:"cadence,omitempty"`             // Optional: intervals between the commits of the full history
+	CustomFindings      []CustomFinding     `json:"customFindings,omitempty"`      // Optional: findings of external analyzer plugins, by source, file and line
+
+	// Set when the resource limits cut analyses short; the sections are named by the Section* constants.
+	Degraded   []string `json:"degraded"`   // Sections skipped because the heap exceeded the memory budget
+	Incomplete []string `json:"incomplete"` // Sections stopped by the time budget; they cover part of the repository or are missing
+	Failed     []string `json:"failed"`     // Sections whose analysis returned an error or panicked; they are missing
+
+	Phases []PhaseStatus `json:"phases"` // Outcome of every analysis of the repository-wide metrics, in the order they ran
+	Fast   bool          `json:"fast"`   // Only the commit and the line counts were analyzed; the skipped analyses are in Phases
+
+	Warnings []warning.Warning `json:"warnings"` // Problems that made the metrics less complete
+}
+
+// Sections of the report the resource limits can cut short or a failed analysis can leave out.
+const (
+	SectionComplexity      = "Cyclomatic Complexity"
+	SectionPackageCoupling = "Package Coupling"
+	SectionTrend           = "Complexity Trend"
+	SectionRefactoringPlan = "Suggested Refactoring Plan"
+	SectionOwnership       = "Complexity Ownership"
+	SectionAnnotations     = "Annotations by Author"
+	SectionFreshness       = "Code Freshness"
+	SectionPackages        = "Package Inventory"
+	SectionBannedImports   = "Banned Imports"
+	SectionGoVersion       = "Go Version"
+	SectionVendorDrift     = "Vendored Dependency Drift"
+	SectionBuild           = "Build Check"
+	SectionDirectoryRollup = "Directory Rollup"
+	SectionStyle           = "Code Style"
+)
+
+// Outcomes of an analysis, the values of PhaseStatus.Status.
+const (
+	PhaseOK         = "ok"
+	PhaseDegraded   = "degraded"   // Skipped because the heap exceeded the memory budget
+	PhaseIncomplete = "incomplete" // Stopped by the time budget
+	PhaseFailed     = "failed"     // Returned an error or panicked
+	PhaseSkipped    = "skipped"    // Not run in fast mode
+)
+
+// PhaseStatus is the outcome of the analysis behind one section of the report.
+type PhaseStatus struct {
+	Section string `json:"section"`         // One of the Section* constants
+	Status  string `json:"status"`          // One of the Phase* constants
+	Error   string `json:"error,omitempty"` // Optional: why the analysis failed
+}
+
+// IsIncomplete reports whether section was stopped by the time budget.
+func (s *OverallStats) IsIncomplete(section string) bool {
+	return slices.Contains(s.Incomplete, section)
+}
+
+// IsFailed reports whether the analysis of section failed.
+func (s *OverallStats) IsFailed(section string) bool {
+	return slices.Contains(s.Failed, section)
+}
+
+type FileTypeStat struct {
+	Extension    string `json:"extension"`
+	Count        int    `json:"count"`
+	TotalBytes   int64  `json:"totalBytes"`
+	LinesAdded   int    `json:"linesAdded"`   // Lines the commit added to files of this type
+	LinesDeleted int    `json:"linesDeleted"` // Lines the commit deleted from files of this type
+}
+
+// AverageBytes returns the mean size of the files of this type, or 0 if there are none.
+func (s *FileTypeStat) AverageBytes() int64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.TotalBytes / int64(s.Count)
+}
+
+type ComplexityStat struct {
+	Complexity      int     `json:"complexity"`
+	Package         string  `json:"package"`
+	FunctionName    string  `json:"functionName"`
+	File            string  `json:"file"`
+	Line            int     `json:"line"`
+	EndLine         int     `json:"endLine"`
+	IsTest          bool    `json:"isTest"`               // Declared in a _test.go file
+	TableDrivenTest bool    `json:"tableDrivenTest"`      // See IsTableDrivenTest
+	OwnedBy         string  `json:"ownedBy,omitempty"`    // Optional: author of most of the function's lines, see AssignOwners
+	OwnerEmail      string  `json:"ownerEmail,omitempty"` // Optional: email of OwnedBy
+	OwnerShare      float64 `json:"ownerShare,omitempty"` // Optional: share of the function's lines by OwnedBy, in [0, 1]
+	Fingerprint     string  `json:"fingerprint"`          // Identifies the finding across runs, see functionFingerprint
+}
+
+// ComputeFileTypeStats groups the given paths (relative to root) by lower-cased
+// extension, counting files and summing their on-disk sizes.
+// Paths that no longer exist (e.g. files deleted by a commit) are counted with a size of 0.
+func ComputeFileTypeStats(root string, paths []string) (map[string]*FileTypeStat, error) {
+	stats := make(map[string]*FileTypeStat)
+	for _, path := range paths {
+		ext := strings.ToLower(filepath.Ext(path))
+		stat, ok := stats[ext]
+		if !ok {
+			stat = &FileTypeStat{Extension: ext}
+			stats[ext] = stat
+		}
+		stat.Count++
+
+		info, err := os.Stat(filepath.Join(root, path))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		if info.Mode().IsRegular() {
+			stat.TotalBytes += info.Size()
+		}
+	}
+	return stats, nil
+}
+
+// AddFileTypeLines adds the lines a commit added to and deleted from the file at path to the
+// stat of its type in stats, so churn can be told apart by type. Paths of types without a
+// stat are ignored.
+func AddFileTypeLines(stats map[string]*FileTypeStat, path string, added, deleted int) {
+	if stat, ok := stats[strings.ToLower(filepath.Ext(path))]; ok {
+		stat.LinesAdded += added
+		stat.LinesDeleted += deleted
+	}
+}
+
+// Versions of the metric algorithms. Bump a version whenever a change to the
+// algorithm can produce different results for the same input.
+const (
+	complexityAlgorithmVersion = "2"
+	couplingAlgorithmVersion   = "1"
+	fileTypesAlgorithmVersion  = "2"
+)
+
+// AlgorithmVersions returns the current version of each metric algorithm, keyed by name.
+func AlgorithmVersions() map[string]string {
+	return map[string]string{
+		"complexity": complexityAlgorithmVersion,
+		"coupling":   couplingAlgorithmVersion,
+		"file-types": fileTypesAlgorithmVersion,
+	}
 }
-type FileTypeStat struct { Extension string; Count int }
-type ComplexityStat struct { Complexity int; Package, FunctionName, File string; Line int }
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFile creates a file (and its parent directories) under root with the given content.
+func writeFile(t *testing.T, root, path, content string) {
+	t.Helper()
+	fullPath := filepath.Join(root, path)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		t.Fatalf("Failed to create directory for %s: %v", path, err)
+	}
+	if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestComputeFileTypeStats(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "fixtures/small.json", strings.Repeat("a", 100))
+	writeFile(t, root, "fixtures/large.JSON", strings.Repeat("b", 301))
+	writeFile(t, root, "main.go", "package main\n")
+
+	paths := []string{"fixtures/small.json", "fixtures/large.JSON", "main.go", "deleted.go"}
+	stats, err := ComputeFileTypeStats(root, paths)
+	if err != nil {
+		t.Fatalf("ComputeFileTypeStats failed: %v", err)
+	}
+
+	jsonStat, ok := stats[".json"]
+	if !ok {
+		t.Fatalf("Expected a .json entry, got %v", stats)
+	}
+	if jsonStat.Count != 2 {
+		t.Errorf("Expected 2 .json files, got %d", jsonStat.Count)
+	}
+	if jsonStat.TotalBytes != 401 {
+		t.Errorf("Expected 401 total bytes for .json, got %d", jsonStat.TotalBytes)
+	}
+	if avg := jsonStat.AverageBytes(); avg != 200 {
+		t.Errorf("Expected 200 average bytes for .json, got %d", avg)
+	}
+
+	// The deleted file is still counted, but contributes no bytes.
+	goStat := stats[".go"]
+	if goStat == nil || goStat.Count != 2 || goStat.TotalBytes != int64(len("package main\n")) {
+		t.Errorf("Unexpected .go stats: %+v", goStat)
+	}
+}
+
+func TestFileTypeStatAverageBytesEmpty(t *testing.T) {
+	stat := &FileTypeStat{Extension: ".go"}
+	if avg := stat.AverageBytes(); avg != 0 {
+		t.Errorf("Expected 0 average bytes for empty stat, got %d", avg)
+	}
+}
@@ -0,0 +1,113 @@
+package metrics
+
+import (
+	"sort"
+
+	"github.com/user/zenwatch/internal/warning"
+)
+
+// Author identifies the author of a line by the name and email of its commit.
+type Author struct {
+	Name  string
+	Email string
+}
+
+// less orders authors alphabetically by name, then by email.
+func (a Author) less(b Author) bool {
+	if a.Name != b.Name {
+		return a.Name < b.Name
+	}
+	return a.Email < b.Email
+}
+
+// BlameFunc returns the author of every line of a file, indexed from 0 for line 1.
+// file is slash-separated and relative to the repository root.
+type BlameFunc func(file string) ([]Author, error)
+
+// AuthorComplexity is the complexity owned by one author.
+type AuthorComplexity struct {
+	Author          string `json:"author"`
+	Email           string `json:"email,omitempty"`
+	Functions       int    `json:"functions"`
+	TotalComplexity int    `json:"totalComplexity"`
+}
+
+// AssignOwners sets OwnedBy and OwnerEmail of every stat to the author of most of the function's
+// lines, and OwnerShare to the share of the lines they wrote, blaming each file once. Ties go to
+// the alphabetically first author. Files that cannot be
+// blamed leave their functions without owner and are reported as warnings.
+func AssignOwners(stats []ComplexityStat, blame BlameFunc) []warning.Warning {
+	var warnings []warning.Warning
+	blamed := make(map[string][]Author)
+	failed := make(map[string]bool)
+	for i := range stats {
+		cs := &stats[i]
+		if failed[cs.File] {
+			continue
+		}
+		authors, ok := blamed[cs.File]
+		if !ok {
+			var err error
+			authors, err = blame(cs.File)
+			if err != nil {
+				failed[cs.File] = true
+				warnings = append(warnings, warning.Warning{
+					Code:    warning.BlameUnavailable,
+					Message: "functions have no owner: " + err.Error(),
+					File:    cs.File,
+				})
+				continue
+			}
+			blamed[cs.File] = authors
+		}
+
+		lines := make(map[Author]int)
+		blamedLines := 0
+		for line := cs.Line; line <= cs.EndLine && line <= len(authors); line++ {
+			lines[authors[line-1]]++
+			blamedLines++
+		}
+		var owner Author
+		for author, n := range lines {
+			if best := lines[owner]; n > best || (n == best && author.less(owner)) {
+				owner = author
+			}
+		}
+		cs.OwnedBy, cs.OwnerEmail, cs.OwnerShare = owner.Name, owner.Email, 0
+		if blamedLines > 0 {
+			cs.OwnerShare = float64(lines[owner]) / float64(blamedLines)
+		}
+	}
+	return warnings
+}
+
+// ComputeComplexityOwnership sums the complexity of stats per owner, most complexity first.
+// Stats without owner are left out.
+func ComputeComplexityOwnership(stats []ComplexityStat) []AuthorComplexity {
+	byAuthor := make(map[Author]*AuthorComplexity)
+	for _, cs := range stats {
+		if cs.OwnedBy == "" {
+			continue
+		}
+		owner := Author{Name: cs.OwnedBy, Email: cs.OwnerEmail}
+		ac, ok := byAuthor[owner]
+		if !ok {
+			ac = &AuthorComplexity{Author: owner.Name, Email: owner.Email}
+			byAuthor[owner] = ac
+		}
+		ac.Functions++
+		ac.TotalComplexity += cs.Complexity
+	}
+
+	ownership := make([]AuthorComplexity, 0, len(byAuthor))
+	for _, ac := range byAuthor {
+		ownership = append(ownership, *ac)
+	}
+	sort.Slice(ownership, func(i, j int) bool {
+		if ownership[i].TotalComplexity != ownership[j].TotalComplexity {
+			return ownership[i].TotalComplexity > ownership[j].TotalComplexity
+		}
+		return Author{ownership[i].Author, ownership[i].Email}.less(Author{ownership[j].Author, ownership[j].Email})
+	})
+	return ownership
+}
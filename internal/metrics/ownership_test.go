@@ -0,0 +1,89 @@
+package metrics
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/user/zenwatch/internal/warning"
+)
+
+func TestAssignOwners(t *testing.T) {
+	// svc.go: lines 1-2 by Bob, 3-9 mostly by Alice, 10-14 mostly by Bob.
+	blame := map[string][]string{
+		"svc.go": {
+			"Bob", "Bob",
+			"Alice", "Alice", "Bob", "Alice", "Alice", "Carol", "Alice",
+			"Bob", "Bob", "Alice", "Bob", "Bob",
+		},
+		"tie.go": {"Zed", "Amy"},
+	}
+	blameCalls := 0
+	blameFunc := func(file string) ([]Author, error) {
+		blameCalls++
+		authors, ok := blame[file]
+		if !ok {
+			return nil, errors.New("object not found")
+		}
+		return blameAuthors(authors), nil
+	}
+
+	stats := []ComplexityStat{
+		{Complexity: 20, FunctionName: "Handle", File: "svc.go", Line: 3, EndLine: 9},
+		{Complexity: 16, FunctionName: "Retry", File: "svc.go", Line: 10, EndLine: 14},
+		{Complexity: 17, FunctionName: "Tie", File: "tie.go", Line: 1, EndLine: 2},
+		{Complexity: 30, FunctionName: "Lost", File: "shallow.go", Line: 1, EndLine: 5},
+		{Complexity: 18, FunctionName: "LostToo", File: "shallow.go", Line: 7, EndLine: 9},
+	}
+	warnings := AssignOwners(stats, blameFunc)
+
+	expectedOwners := []string{"Alice", "Bob", "Amy", "", ""}
+	for i, expected := range expectedOwners {
+		if stats[i].OwnedBy != expected {
+			t.Errorf("%s: expected owner %q, got %q", stats[i].FunctionName, expected, stats[i].OwnedBy)
+		}
+		if expectedEmail := blameAuthors([]string{expected})[0].Email; stats[i].OwnerEmail != expectedEmail {
+			t.Errorf("%s: expected owner email %q, got %q", stats[i].FunctionName, expectedEmail, stats[i].OwnerEmail)
+		}
+	}
+	// Alice wrote 5 of the 7 lines of Handle; Tie is a tie of 1 line each.
+	expectedShares := []float64{5.0 / 7, 4.0 / 5, 0.5, 0, 0}
+	for i, expected := range expectedShares {
+		if stats[i].OwnerShare != expected {
+			t.Errorf("%s: expected owner share %v, got %v", stats[i].FunctionName, expected, stats[i].OwnerShare)
+		}
+	}
+	if blameCalls != 3 {
+		t.Errorf("Expected each file to be blamed once, got %d calls", blameCalls)
+	}
+	if len(warnings) != 1 || warnings[0].Code != warning.BlameUnavailable || warnings[0].File != "shallow.go" {
+		t.Errorf("Expected one blame-unavailable warning for shallow.go, got %+v", warnings)
+	}
+
+	ownership := ComputeComplexityOwnership(stats)
+	expected := []AuthorComplexity{
+		{Author: "Alice", Email: "alice@example.com", Functions: 1, TotalComplexity: 20},
+		{Author: "Amy", Email: "amy@example.com", Functions: 1, TotalComplexity: 17},
+		{Author: "Bob", Email: "bob@example.com", Functions: 1, TotalComplexity: 16},
+	}
+	if len(ownership) != len(expected) {
+		t.Fatalf("Expected ownership %+v, got %+v", expected, ownership)
+	}
+	for i := range expected {
+		if ownership[i] != expected[i] {
+			t.Errorf("Ownership %d: expected %+v, got %+v", i, expected[i], ownership[i])
+		}
+	}
+}
+
+// blameAuthors returns the authors of lines blamed on names, each with an email of their own.
+func blameAuthors(names []string) []Author {
+	authors := make([]Author, len(names))
+	for i, name := range names {
+		authors[i] = Author{Name: name}
+		if name != "" {
+			authors[i].Email = strings.ToLower(name) + "@example.com"
+		}
+	}
+	return authors
+}
@@ -0,0 +1,116 @@
+package metrics
+
+import (
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PackageStat sizes one Go package: the files of one directory sharing a package clause.
+// An external test package (foo_test next to foo) is counted as part of the package it tests.
+type PackageStat struct {
+	Dir           string `json:"dir"`       // Slash-separated directory relative to the repository root; "." for the root
+	Name          string `json:"name"`      // Package clause, without the _test suffix of an external test package
+	Command       bool   `json:"command"`   // Package main, built into an executable
+	Files         int    `json:"files"`     // Non-test files
+	SLOC          int    `json:"sloc"`      // Non-blank lines of the non-test files
+	Functions     int    `json:"functions"` // Functions and methods declared in the non-test files
+	TestFiles     int    `json:"testFiles"`
+	TestSLOC      int    `json:"testSLOC"`
+	TestFunctions int    `json:"testFunctions"`
+}
+
+// ComputePackageInventory walks the Go files under repoPath, in the directories the go tool
+// builds, and sizes each package, largest first. Function counts come from complexity,
+// typically the output of CollectComplexity. Files matching exclude are not counted, and
+// files whose package clause cannot be parsed are skipped; CollectComplexity reports them.
+func ComputePackageInventory(repoPath string, complexity []ComplexityStat, exclude ExcludeFilter) ([]PackageStat, error) {
+	packages := make(map[string]*PackageStat) // By directory and package name
+	// lookup returns the package of a file named relPath with the given package clause.
+	lookup := func(relPath, name string) *PackageStat {
+		isTest := strings.HasSuffix(relPath, "_test.go")
+		if isTest {
+			name = strings.TrimSuffix(name, "_test")
+		}
+		dir := path.Dir(relPath)
+		key := dir + "\x00" + name
+		p, ok := packages[key]
+		if !ok {
+			p = &PackageStat{Dir: dir, Name: name, Command: name == "main"}
+			packages[key] = p
+		}
+		return p
+	}
+
+	fset := token.NewFileSet()
+	err := filepath.WalkDir(repoPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != repoPath && skipGoDir(d.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(p, ".go") || !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(repoPath, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if exclude.Excludes(rel) {
+			return nil
+		}
+		file, err := parser.ParseFile(fset, p, nil, parser.PackageClauseOnly)
+		if err != nil {
+			return nil
+		}
+		sloc, _, err := countSLOC(p)
+		if err != nil {
+			return err
+		}
+		pkg := lookup(rel, file.Name.Name)
+		if strings.HasSuffix(rel, "_test.go") {
+			pkg.TestFiles++
+			pkg.TestSLOC += sloc
+		} else {
+			pkg.Files++
+			pkg.SLOC += sloc
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, cs := range complexity {
+		pkg := lookup(cs.File, cs.Package)
+		if cs.IsTest {
+			pkg.TestFunctions++
+		} else {
+			pkg.Functions++
+		}
+	}
+
+	stats := make([]PackageStat, 0, len(packages))
+	for _, p := range packages {
+		stats = append(stats, *p)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].SLOC != stats[j].SLOC {
+			return stats[i].SLOC > stats[j].SLOC
+		}
+		if stats[i].Dir != stats[j].Dir {
+			return stats[i].Dir < stats[j].Dir
+		}
+		return stats[i].Name < stats[j].Name
+	})
+	return stats, nil
+}
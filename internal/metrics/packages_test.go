@@ -0,0 +1,45 @@
+package metrics
+
+import (
+	"context"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestComputePackageInventory(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "main.go", "package main\n\nfunc main() {}\n\nfunc run() {}\n")
+	writeFile(t, root, "store/store.go", "package store\n\n// Store holds data.\ntype Store struct{}\n\nfunc (s *Store) Get() {}\n")
+	writeFile(t, root, "store/cache.go", "package store\n\nfunc newCache() {}\n")
+	writeFile(t, root, "store/store_test.go", "package store\n\nfunc TestGet() {}\n")
+	writeFile(t, root, "store/example_test.go", "package store_test\n\nfunc ExampleStore() {}\n")
+	writeFile(t, root, "cmd/tool/main.go", "package main\n\nfunc main() {}\n")
+	writeFile(t, root, "cmd/tool/main_test.go", "package main\n\nfunc TestMain() {}\n")
+	writeFile(t, root, "gen/gen.go", "package gen\n\nfunc Generated() {}\n")
+	writeFile(t, root, "vendor/dep/dep.go", "package dep\n\nfunc Dep() {}\n")
+	writeFile(t, root, "broken/broken.go", "func Broken( {\n")
+
+	complexity, _, err := CollectComplexity(context.Background(), root, WorkerOptions{})
+	if err != nil {
+		t.Fatalf("CollectComplexity failed: %v", err)
+	}
+	exclude, err := NewExcludeFilter([]string{"gen/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	complexity = slices.DeleteFunc(complexity, func(cs ComplexityStat) bool { return exclude.Excludes(cs.File) })
+
+	packages, err := ComputePackageInventory(root, complexity, exclude)
+	if err != nil {
+		t.Fatalf("ComputePackageInventory failed: %v", err)
+	}
+	want := []PackageStat{
+		{Dir: "store", Name: "store", Files: 2, SLOC: 6, Functions: 2, TestFiles: 2, TestSLOC: 4, TestFunctions: 2},
+		{Dir: ".", Name: "main", Command: true, Files: 1, SLOC: 3, Functions: 2},
+		{Dir: "cmd/tool", Name: "main", Command: true, Files: 1, SLOC: 2, Functions: 1, TestFiles: 1, TestSLOC: 2, TestFunctions: 1},
+	}
+	if !reflect.DeepEqual(packages, want) {
+		t.Errorf("Unexpected inventory:\n got %+v\nwant %+v", packages, want)
+	}
+}
@@ -0,0 +1,133 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// LineRange is an inclusive range of line numbers.
+type LineRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// String returns "12" for a single line and "12-15" otherwise.
+func (r LineRange) String() string {
+	if r.Start == r.End {
+		return strconv.Itoa(r.Start)
+	}
+	return fmt.Sprintf("%d-%d", r.Start, r.End)
+}
+
+// hunkHeader matches "@@ -old[,count] +new[,count] @@"; omitted counts are 1.
+var hunkHeader = regexp.MustCompile(`^@@ -\d+(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// ParsePatchChanges parses a unified diff, such as the output of git diff, and returns for
+// every file it adds or modifies the line ranges of the new version its hunks touch: added
+// lines, and the position of deleted lines. Paths are taken from the "+++" headers without
+// git's "b/" prefix. Deleted files are left out, as there is nothing left to analyze.
+func ParsePatchChanges(r io.Reader) (map[string][]LineRange, error) {
+	changes := make(map[string][]LineRange)
+	var (
+		file             string
+		oldLeft, newLeft int // Lines of the current hunk still to be read
+		newLine          int // Line number in the new file of the next context or added line
+		lineNo           int
+	)
+	touch := func(line int) {
+		if file == "" {
+			return
+		}
+		line = max(line, 1)
+		ranges := changes[file]
+		if n := len(ranges); n > 0 && line <= ranges[n-1].End+1 {
+			ranges[n-1].End = max(ranges[n-1].End, line)
+			return
+		}
+		changes[file] = append(ranges, LineRange{Start: line, End: line})
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		lineNo++
+
+		if oldLeft > 0 || newLeft > 0 {
+			switch {
+			case strings.HasPrefix(line, "+"):
+				touch(newLine)
+				newLine++
+				newLeft--
+			case strings.HasPrefix(line, "-"):
+				touch(newLine)
+				oldLeft--
+			case strings.HasPrefix(line, `\`): // "\ No newline at end of file"
+			default: // Context line; some tools strip the leading space of empty ones
+				newLine++
+				oldLeft--
+				newLeft--
+			}
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(line, "+++ "):
+			file = patchPath(strings.TrimPrefix(line, "+++ "))
+		case strings.HasPrefix(line, "@@"):
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("malformed hunk header on line %d: %q", lineNo, line)
+			}
+			oldLeft, newLeft = hunkCount(m[1]), hunkCount(m[3])
+			newLine, _ = strconv.Atoi(m[2])
+			if newLeft == 0 {
+				// A pure deletion's header names the line before the deleted ones.
+				newLine++
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read patch: %w", err)
+	}
+	return changes, nil
+}
+
+// patchPath extracts the path of a "+++" header, or "" for /dev/null.
+func patchPath(header string) string {
+	if i := strings.IndexByte(header, '\t'); i >= 0 {
+		header = header[:i] // Timestamp of non-git diffs
+	}
+	if header == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(header, "b/")
+}
+
+func hunkCount(s string) int {
+	if s == "" {
+		return 1
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
+
+// TouchedFunctions returns the stats whose lines overlap a changed range of their file,
+// in their original order.
+func TouchedFunctions(stats []ComplexityStat, changes map[string][]LineRange) []ComplexityStat {
+	var touched []ComplexityStat
+	for _, cs := range stats {
+		end := max(cs.EndLine, cs.Line)
+		for _, r := range changes[cs.File] {
+			if r.Start <= end && r.End >= cs.Line {
+				touched = append(touched, cs)
+				break
+			}
+		}
+	}
+	return touched
+}
@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// samplePatch modifies lib/lib.go in two places, adds new.go and deletes old.go.
+const samplePatch = `diff --git a/lib/lib.go b/lib/lib.go
+index 3b18e51..a7c2f3e 100644
+--- a/lib/lib.go
++++ b/lib/lib.go
+@@ -3,3 +3,6 @@ package lib
+ func Touched(x int) int {
+-	return x
++	if x > 0 {
++		return x
++	}
++	return -x
+ }
+@@ -11,3 +14,2 @@ func Untouched() {}
+ func AlsoTouched() {
+-	--x
+ }
+diff --git a/new.go b/new.go
+new file mode 100644
+--- /dev/null
++++ b/new.go
+@@ -0,0 +1,3 @@
++package lib
++
++func New() {}
+diff --git a/old.go b/old.go
+deleted file mode 100644
+--- a/old.go
++++ /dev/null
+@@ -1 +0,0 @@
+-package lib
+`
+
+func TestParsePatchChanges(t *testing.T) {
+	changes, err := ParsePatchChanges(strings.NewReader(samplePatch))
+	if err != nil {
+		t.Fatalf("ParsePatchChanges failed: %v", err)
+	}
+	want := map[string][]LineRange{
+		"lib/lib.go": {{Start: 4, End: 7}, {Start: 15, End: 15}},
+		"new.go":     {{Start: 1, End: 3}},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("Expected %v, got %v", want, changes)
+	}
+
+	if _, err := ParsePatchChanges(strings.NewReader("+++ b/x.go\n@@ bogus @@\n")); err == nil {
+		t.Errorf("Expected an error for a malformed hunk header")
+	}
+}
+
+func TestTouchedFunctions(t *testing.T) {
+	// lib/lib.go after the patch.
+	src := "package lib\n\nfunc Touched(x int) int {\n\tif x > 0 {\n\t\treturn x\n\t}\n\treturn -x\n}\n\n" +
+		"func Untouched() {}\n\nfunc Between() {}\n\nfunc AlsoTouched() {\n}\n"
+	stats, warnings := CollectComplexityFromSources(map[string][]byte{"lib/lib.go": []byte(src)})
+	if len(warnings) != 0 {
+		t.Fatalf("Unexpected warnings: %v", warnings)
+	}
+	changes, err := ParsePatchChanges(strings.NewReader(samplePatch))
+	if err != nil {
+		t.Fatalf("ParsePatchChanges failed: %v", err)
+	}
+
+	var names []string
+	for _, cs := range TouchedFunctions(stats, changes) {
+		names = append(names, cs.FunctionName)
+	}
+	if want := []string{"Touched", "AlsoTouched"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Expected touched functions %v, got %v", want, names)
+	}
+}
@@ -0,0 +1,200 @@
+package metrics
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PlanSize is the number of functions a refactoring plan lists by default.
+const PlanSize = 10
+
+// DefaultPlanWindow is the period of history whose edits count towards a refactoring plan.
+const DefaultPlanWindow = 90 * 24 * time.Hour
+
+// PlanWeights weigh the signals a refactoring plan ranks functions by. Each signal is scaled
+// to [0, 1] before it is weighed, so the weights are relative to each other.
+type PlanWeights struct {
+	Complexity float64 `json:"complexity"` // Complexity, relative to the most complex candidate
+	Edits      float64 `json:"edits"`      // Commits of the window that changed the function's file, relative to the most edited
+	Churn      float64 `json:"churn"`      // Lines those commits added and deleted, relative to the most churned
+	Ownership  float64 `json:"ownership"`  // Share of the function's lines by its owner; set only when owners are assigned
+}
+
+// DefaultPlanWeights returns the weights of a plan without --plan-weights.
+func DefaultPlanWeights() PlanWeights {
+	return PlanWeights{Complexity: 0.4, Edits: 0.25, Churn: 0.15, Ownership: 0.2}
+}
+
+// ParsePlanWeights parses a comma-separated list such as "complexity=2,edits=1" over the
+// default weights: signals not listed keep their default weight.
+func ParsePlanWeights(s string) (PlanWeights, error) {
+	w := DefaultPlanWeights()
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(field, "=")
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if !ok || err != nil || weight < 0 {
+			return PlanWeights{}, fmt.Errorf("invalid plan weight %q: expected signal=weight with a weight of at least 0", field)
+		}
+		switch strings.TrimSpace(name) {
+		case "complexity":
+			w.Complexity = weight
+		case "edits":
+			w.Edits = weight
+		case "churn":
+			w.Churn = weight
+		case "ownership":
+			w.Ownership = weight
+		default:
+			return PlanWeights{}, fmt.Errorf("unknown plan signal %q (supported: complexity, edits, churn, ownership)", name)
+		}
+	}
+	if w.total() == 0 {
+		return PlanWeights{}, fmt.Errorf("plan weights %q are all 0", s)
+	}
+	return w, nil
+}
+
+func (w PlanWeights) total() float64 {
+	return w.Complexity + w.Edits + w.Churn + w.Ownership
+}
+
+// FileActivity is what the commits of a period did to a file.
+type FileActivity struct {
+	Edits int // Commits that changed the file
+	Churn int // Lines they added and deleted
+}
+
+// Effort sizes of a plan item, by the length of the function.
+const (
+	EffortSmall  = "S" // Up to 30 lines
+	EffortMedium = "M" // Up to 80 lines
+	EffortLarge  = "L"
+)
+
+// PlanItem is a function of a refactoring plan.
+type PlanItem struct {
+	FunctionName string  `json:"functionName"`
+	Package      string  `json:"package"`
+	File         string  `json:"file"`
+	Line         int     `json:"line"`
+	Complexity   int     `json:"complexity"`
+	Lines        int     `json:"lines"`      // Length of the function, which its Effort is estimated from
+	Effort       string  `json:"effort"`     // One of the Effort* sizes
+	Edits        int     `json:"edits"`      // Commits of the window that changed the function's file
+	Churn        int     `json:"churn"`      // Lines those commits added and deleted
+	OwnerShare   float64 `json:"ownerShare"` // Share of the function's lines by its owner; 0 if owners are not assigned
+	Score        float64 `json:"score"`      // Weighted mean of the scaled signals, in [0, 1]
+	Rationale    string  `json:"rationale"`  // Why the function ranks where it does, e.g. "complexity 31, edited 14 times in 90 days, single owner"
+}
+
+// RefactoringPlan ranks functions for refactoring by weighing their complexity, the edits and
+// churn of their file over a period of history, and how concentrated their ownership is.
+type RefactoringPlan struct {
+	Weights PlanWeights   `json:"weights"`
+	Window  time.Duration `json:"window"` // Period of history of the edits and churn, ending at the analyzed commit
+	Items   []PlanItem    `json:"items"`  // Highest score first
+}
+
+// ComputeRefactoringPlan ranks functions, the production functions over threshold that no
+// config suppresses, and returns the first limit of them. activity holds the activity of
+// files over window, by slash-separated path. Every signal is scaled by its largest value
+// among the candidates, so the ranking only depends on the candidates; ties go to the least
+// effort, then to the file and line.
+func ComputeRefactoringPlan(functions []ComplexityStat, activity map[string]FileActivity, window time.Duration, weights PlanWeights, limit int) *RefactoringPlan {
+	var maxComplexity, maxEdits, maxChurn int
+	for _, cs := range functions {
+		a := activity[cs.File]
+		maxComplexity = max(maxComplexity, cs.Complexity)
+		maxEdits = max(maxEdits, a.Edits)
+		maxChurn = max(maxChurn, a.Churn)
+	}
+
+	items := make([]PlanItem, 0, len(functions))
+	for _, cs := range functions {
+		a := activity[cs.File]
+		item := PlanItem{
+			FunctionName: cs.FunctionName,
+			Package:      cs.Package,
+			File:         cs.File,
+			Line:         cs.Line,
+			Complexity:   cs.Complexity,
+			Lines:        max(cs.EndLine-cs.Line+1, 1),
+			Edits:        a.Edits,
+			Churn:        a.Churn,
+			OwnerShare:   cs.OwnerShare,
+		}
+		item.Effort = effortSize(item.Lines)
+		if total := weights.total(); total > 0 {
+			item.Score = (weights.Complexity*scaled(cs.Complexity, maxComplexity) +
+				weights.Edits*scaled(a.Edits, maxEdits) +
+				weights.Churn*scaled(a.Churn, maxChurn) +
+				weights.Ownership*cs.OwnerShare) / total
+		}
+		item.Rationale = planRationale(item, window)
+		items = append(items, item)
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		a, b := items[i], items[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Lines != b.Lines {
+			return a.Lines < b.Lines
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	if limit >= 0 && len(items) > limit {
+		items = items[:limit]
+	}
+	return &RefactoringPlan{Weights: weights, Window: window, Items: items}
+}
+
+func scaled(v, maximum int) float64 {
+	if maximum == 0 {
+		return 0
+	}
+	return float64(v) / float64(maximum)
+}
+
+func effortSize(lines int) string {
+	switch {
+	case lines <= 30:
+		return EffortSmall
+	case lines <= 80:
+		return EffortMedium
+	}
+	return EffortLarge
+}
+
+// planRationale names the signals of item that are set.
+func planRationale(item PlanItem, window time.Duration) string {
+	reasons := []string{fmt.Sprintf("complexity %d", item.Complexity)}
+	days := int(window.Hours() / 24)
+	switch item.Edits {
+	case 0:
+	case 1:
+		reasons = append(reasons, fmt.Sprintf("edited once in %d days", days))
+	default:
+		reasons = append(reasons, fmt.Sprintf("edited %d times in %d days", item.Edits, days))
+	}
+	if item.Churn > 0 {
+		reasons = append(reasons, fmt.Sprintf("%d lines churned", item.Churn))
+	}
+	switch {
+	case item.OwnerShare == 1:
+		reasons = append(reasons, "single owner")
+	case item.OwnerShare >= 0.75:
+		reasons = append(reasons, fmt.Sprintf("%.0f%% one owner", 100*item.OwnerShare))
+	}
+	return strings.Join(reasons, ", ")
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParsePlanWeights(t *testing.T) {
+	w, err := ParsePlanWeights("complexity=2, edits=0")
+	if err != nil {
+		t.Fatalf("ParsePlanWeights failed: %v", err)
+	}
+	want := DefaultPlanWeights()
+	want.Complexity, want.Edits = 2, 0
+	if w != want {
+		t.Errorf("Expected %+v, got %+v", want, w)
+	}
+	if w, err := ParsePlanWeights(""); err != nil || w != DefaultPlanWeights() {
+		t.Errorf("Expected the default weights for an empty list, got %+v, %v", w, err)
+	}
+	for _, invalid := range []string{"size=1", "complexity", "complexity=-1", "churn=x", "complexity=0,edits=0,churn=0,ownership=0"} {
+		if _, err := ParsePlanWeights(invalid); err == nil {
+			t.Errorf("Expected ParsePlanWeights(%q) to fail", invalid)
+		}
+	}
+}
+
+func TestComputeRefactoringPlan(t *testing.T) {
+	functions := []ComplexityStat{
+		{FunctionName: "Parse", File: "parse.go", Line: 10, EndLine: 109, Complexity: 40, OwnerShare: 1},
+		{FunctionName: "Route", File: "route.go", Line: 5, EndLine: 24, Complexity: 20, OwnerShare: 0.5},
+		{FunctionName: "Render", File: "render.go", Line: 1, EndLine: 50, Complexity: 20, OwnerShare: 0.8},
+		{FunctionName: "Quiet", File: "quiet.go", Line: 1, EndLine: 20, Complexity: 16},
+		{FunctionName: "Twin", File: "twin.go", Line: 1, EndLine: 40, Complexity: 16},
+	}
+	activity := map[string]FileActivity{
+		"route.go":  {Edits: 14, Churn: 300},
+		"render.go": {Edits: 1, Churn: 20},
+		"parse.go":  {Edits: 7, Churn: 150},
+		"other.go":  {Edits: 50, Churn: 5000}, // Not a candidate, so it does not scale the others
+	}
+	plan := ComputeRefactoringPlan(functions, activity, DefaultPlanWindow, DefaultPlanWeights(), 10)
+
+	// Parse:  (0.4*1 + 0.25*0.5 + 0.15*0.5 + 0.2*1) = 0.8
+	// Route:  (0.4*0.5 + 0.25*1 + 0.15*1 + 0.2*0.5) = 0.7
+	// Render: (0.4*0.5 + 0.25*1/14 + 0.15*20/300 + 0.2*0.8) ≈ 0.388
+	// Quiet and Twin: 0.4*0.4 = 0.16, the shorter first.
+	var order []string
+	for _, item := range plan.Items {
+		order = append(order, item.FunctionName)
+	}
+	if want := []string{"Parse", "Route", "Render", "Quiet", "Twin"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("Expected order %v, got %v", want, order)
+	}
+	parse := plan.Items[0]
+	if parse.Lines != 100 || parse.Effort != EffortLarge || parse.Score < 0.7999 || parse.Score > 0.8001 {
+		t.Errorf("Expected Parse to be large with a score of 0.8, got %+v", parse)
+	}
+	if want := "complexity 40, edited 7 times in 90 days, 150 lines churned, single owner"; parse.Rationale != want {
+		t.Errorf("Expected rationale %q, got %q", want, parse.Rationale)
+	}
+	if got := plan.Items[1]; got.Effort != EffortSmall || got.Rationale != "complexity 20, edited 14 times in 90 days, 300 lines churned" {
+		t.Errorf("Expected Route to be small without an owner reason, got %+v", got)
+	}
+	if got := plan.Items[2].Rationale; !strings.Contains(got, "edited once") || !strings.HasSuffix(got, "80% one owner") {
+		t.Errorf("Expected Render's single edit and owner share in its rationale, got %q", got)
+	}
+	if got := plan.Items[3].Rationale; got != "complexity 16" {
+		t.Errorf("Expected only the complexity of a quiet function, got %q", got)
+	}
+
+	// Weights change the ranking; the limit cuts it.
+	plan = ComputeRefactoringPlan(functions, activity, 30*24*time.Hour, PlanWeights{Edits: 1}, 2)
+	if len(plan.Items) != 2 || plan.Items[0].FunctionName != "Route" || plan.Items[1].FunctionName != "Parse" {
+		t.Errorf("Expected the two most edited functions, got %+v", plan.Items)
+	}
+	if !strings.Contains(plan.Items[0].Rationale, "in 30 days") {
+		t.Errorf("Expected the window in the rationale, got %q", plan.Items[0].Rationale)
+	}
+	if plan := ComputeRefactoringPlan(nil, nil, DefaultPlanWindow, DefaultPlanWeights(), 10); len(plan.Items) != 0 {
+		t.Errorf("Expected an empty plan without functions, got %+v", plan.Items)
+	}
+}
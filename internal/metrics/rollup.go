@@ -0,0 +1,261 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/user/zenwatch/internal/warning"
+)
+
+// Columns a directory rollup can be sorted by.
+const (
+	RollupSortSLOC          = "sloc"
+	RollupSortFiles         = "files"
+	RollupSortAvgComplexity = "avg-complexity"
+	RollupSortMaxComplexity = "max-complexity"
+	RollupSortChurn         = "churn"
+	RollupSortPath          = "path"
+)
+
+// RollupOptions controls how a directory rollup is built.
+type RollupOptions struct {
+	MaxDepth  int    // Deepest directory level shown; deeper directories are aggregated into their ancestor
+	MinSLOC   int    // Directories with fewer source lines are folded into their parent
+	SortBy    string // One of the RollupSort* constants; empty means RollupSortSLOC
+	ListFiles bool   // Record the files of each directory in DirectoryStat.FileStats
+
+	Languages LanguageFilter // Only files of these languages are counted
+	Exclude   ExcludeFilter  // Files matching these patterns are not counted
+}
+
+// DefaultRollupOptions returns the options used when none are configured.
+func DefaultRollupOptions() RollupOptions {
+	return RollupOptions{MaxDepth: 2, SortBy: RollupSortSLOC}
+}
+
+// Validate checks that the options hold supported values.
+func (o RollupOptions) Validate() error {
+	if o.MaxDepth < 0 {
+		return fmt.Errorf("rollup depth must not be negative, got %d", o.MaxDepth)
+	}
+	switch o.SortBy {
+	case "", RollupSortSLOC, RollupSortFiles, RollupSortAvgComplexity, RollupSortMaxComplexity, RollupSortChurn, RollupSortPath:
+		return nil
+	}
+	return fmt.Errorf("unknown rollup sort column %q (supported: %s, %s, %s, %s, %s, %s)", o.SortBy,
+		RollupSortSLOC, RollupSortFiles, RollupSortAvgComplexity, RollupSortMaxComplexity, RollupSortChurn, RollupSortPath)
+}
+
+// DirectoryStat aggregates the files of a directory and all its subdirectories.
+type DirectoryStat struct {
+	Path              string           `json:"path"` // Slash-separated path relative to the repository root; "." for the root
+	Files             int              `json:"files"`
+	SLOC              int              `json:"sloc"`      // Non-blank lines of text files
+	Functions         int              `json:"functions"` // Go functions, used for the complexity aggregates
+	AverageComplexity float64          `json:"averageComplexity"`
+	MaxComplexity     int              `json:"maxComplexity"`
+	Churn             int              `json:"churn"` // Lines added plus deleted by the analyzed commit
+	Children          []*DirectoryStat `json:"children"`
+	// FileStats lists the files counted in this directory but in none of its Children,
+	// including those of deeper or folded directories. Only set with RollupOptions.ListFiles.
+	FileStats []FileSLOC `json:"fileStats"`
+
+	totalComplexity int
+}
+
+// FileSLOC is a file of a directory rollup.
+type FileSLOC struct {
+	Path string `json:"path"` // Slash-separated path relative to the repository root
+	SLOC int    `json:"sloc"`
+}
+
+// ComputeDirectoryRollup walks repoPath and aggregates SLOC, file counts, complexity
+// (from complexity, typically the output of CollectComplexity) and churn (lines
+// changed per file path) per directory. Binary files count as files without source lines,
+// which is reported as a single warning.
+func ComputeDirectoryRollup(repoPath string, complexity []ComplexityStat, churn map[string]int, opts RollupOptions) (*DirectoryStat, []warning.Warning, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, nil, err
+	}
+
+	root := &DirectoryStat{Path: "."}
+	nodes := map[string]*DirectoryStat{".": root}
+	// ancestors returns the rollup nodes a file in dir contributes to, creating them as needed.
+	ancestors := func(dir string) []*DirectoryStat {
+		result := []*DirectoryStat{root}
+		if dir == "." {
+			return result
+		}
+		segments := strings.Split(dir, "/")
+		for depth := 1; depth <= len(segments) && depth <= opts.MaxDepth; depth++ {
+			p := strings.Join(segments[:depth], "/")
+			node, ok := nodes[p]
+			if !ok {
+				node = &DirectoryStat{Path: p}
+				nodes[p] = node
+				parent := result[len(result)-1]
+				parent.Children = append(parent.Children, node)
+			}
+			result = append(result, node)
+		}
+		return result
+	}
+
+	var binaryFiles []string
+	err := filepath.WalkDir(repoPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || !opts.Languages.Match(p) {
+			return nil
+		}
+		rel, err := filepath.Rel(repoPath, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if opts.Exclude.Excludes(rel) {
+			return nil
+		}
+		sloc, binary, err := countSLOC(p)
+		if err != nil {
+			return err
+		}
+		if binary {
+			binaryFiles = append(binaryFiles, rel)
+		}
+		nodes := ancestors(path.Dir(rel))
+		for _, node := range nodes {
+			node.Files++
+			node.SLOC += sloc
+			node.Churn += churn[rel]
+		}
+		if opts.ListFiles {
+			dir := nodes[len(nodes)-1]
+			dir.FileStats = append(dir.FileStats, FileSLOC{Path: rel, SLOC: sloc})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for _, cs := range complexity {
+		for _, node := range ancestors(path.Dir(cs.File)) {
+			node.Functions++
+			node.totalComplexity += cs.Complexity
+			if cs.Complexity > node.MaxComplexity {
+				node.MaxComplexity = cs.Complexity
+			}
+		}
+	}
+
+	finalizeRollup(root, opts)
+
+	var warnings []warning.Warning
+	if len(binaryFiles) > 0 {
+		warnings = append(warnings, warning.Warning{
+			Code:    warning.BinaryFilesSkipped,
+			Message: fmt.Sprintf("%d binary files are counted as files but not as source lines (first: %s)", len(binaryFiles), binaryFiles[0]),
+		})
+	}
+	return root, warnings, nil
+}
+
+// finalizeRollup computes averages, folds small directories and sorts children, recursively.
+func finalizeRollup(node *DirectoryStat, opts RollupOptions) {
+	if node.Functions > 0 {
+		node.AverageComplexity = float64(node.totalComplexity) / float64(node.Functions)
+	}
+
+	// Folding only removes the child: its numbers are already included in the parent,
+	// but its files move up.
+	kept := node.Children[:0]
+	for _, child := range node.Children {
+		if child.SLOC >= opts.MinSLOC {
+			finalizeRollup(child, opts)
+			kept = append(kept, child)
+		} else {
+			node.FileStats = appendSubtreeFiles(node.FileStats, child)
+		}
+	}
+	node.Children = kept
+	sort.Slice(node.FileStats, func(i, j int) bool {
+		a, b := node.FileStats[i], node.FileStats[j]
+		if a.SLOC != b.SLOC && opts.SortBy != RollupSortPath {
+			return a.SLOC > b.SLOC
+		}
+		return a.Path < b.Path
+	})
+
+	sort.SliceStable(node.Children, func(i, j int) bool {
+		a, b := node.Children[i], node.Children[j]
+		var less, greater bool
+		switch opts.SortBy {
+		case RollupSortPath:
+			return a.Path < b.Path
+		case RollupSortFiles:
+			less, greater = a.Files < b.Files, a.Files > b.Files
+		case RollupSortAvgComplexity:
+			less, greater = a.AverageComplexity < b.AverageComplexity, a.AverageComplexity > b.AverageComplexity
+		case RollupSortMaxComplexity:
+			less, greater = a.MaxComplexity < b.MaxComplexity, a.MaxComplexity > b.MaxComplexity
+		case RollupSortChurn:
+			less, greater = a.Churn < b.Churn, a.Churn > b.Churn
+		default:
+			less, greater = a.SLOC < b.SLOC, a.SLOC > b.SLOC
+		}
+		if greater || less {
+			return greater // Largest first
+		}
+		return a.Path < b.Path
+	})
+}
+
+// appendSubtreeFiles appends the files of d and all its subdirectories to files.
+func appendSubtreeFiles(files []FileSLOC, d *DirectoryStat) []FileSLOC {
+	files = append(files, d.FileStats...)
+	for _, child := range d.Children {
+		files = appendSubtreeFiles(files, child)
+	}
+	return files
+}
+
+// countSLOC returns the number of non-blank lines of a text file, or 0 and true for binary files.
+func countSLOC(path string) (int, bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if isBinary(content) {
+		return 0, true, nil
+	}
+	sloc := 0
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		if len(bytes.TrimSpace(line)) > 0 {
+			sloc++
+		}
+	}
+	return sloc, false, nil
+}
+
+// isBinary reports whether content looks binary, using the same heuristic as git:
+// a NUL byte within the first 8000 bytes.
+func isBinary(content []byte) bool {
+	if len(content) > 8000 {
+		content = content[:8000]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
@@ -0,0 +1,186 @@
+package metrics
+
+import (
+	"math"
+	"slices"
+	"testing"
+
+	"github.com/user/zenwatch/internal/warning"
+)
+
+// rollupFixture creates a small tree:
+//
+//	main.go                    (2 lines)
+//	internal/git/git.go        (3 lines)
+//	internal/git/deep/x/y.go   (1 line, beyond depth 2)
+//	internal/report/report.go  (3 lines)
+//	docs/a.md                  (1 line)
+//	assets/logo.png            (binary)
+func rollupFixture(t *testing.T) string {
+	root := t.TempDir()
+	writeFile(t, root, "main.go", "package main\n\nfunc main() {}\n")
+	writeFile(t, root, "internal/git/git.go", "package git\n\nfunc A() {}\nfunc B() {}\n\n")
+	writeFile(t, root, "internal/git/deep/x/y.go", "package x\n")
+	writeFile(t, root, "internal/report/report.go", "package report\n\nfunc R() {}\n// end\n")
+	writeFile(t, root, "docs/a.md", "# Docs\n")
+	writeFile(t, root, "assets/logo.png", "\x89PNG\x00\x00binary\n")
+	writeFile(t, root, ".git/HEAD", "ref: refs/heads/main\n")
+	return root
+}
+
+func findChild(node *DirectoryStat, path string) *DirectoryStat {
+	for _, child := range node.Children {
+		if child.Path == path {
+			return child
+		}
+	}
+	return nil
+}
+
+func TestComputeDirectoryRollup(t *testing.T) {
+	root := rollupFixture(t)
+	complexity := []ComplexityStat{
+		{Complexity: 10, File: "internal/git/git.go"},
+		{Complexity: 2, File: "internal/git/git.go"},
+		{Complexity: 6, File: "internal/git/deep/x/y.go"},
+		{Complexity: 3, File: "internal/report/report.go"},
+		{Complexity: 1, File: "main.go"},
+	}
+	churn := map[string]int{"internal/report/report.go": 7, "main.go": 2}
+
+	rollup, warnings, err := ComputeDirectoryRollup(root, complexity, churn, DefaultRollupOptions())
+	if err != nil {
+		t.Fatalf("ComputeDirectoryRollup failed: %v", err)
+	}
+
+	if rollup.Path != "." || rollup.Files != 6 || rollup.SLOC != 10 || rollup.Churn != 9 || rollup.MaxComplexity != 10 {
+		t.Errorf("Unexpected root aggregates: %+v", rollup)
+	}
+	if math.Abs(rollup.AverageComplexity-4.4) > 1e-9 {
+		t.Errorf("Expected root average complexity 4.4, got %f", rollup.AverageComplexity)
+	}
+
+	// Children are sorted by SLOC, largest first.
+	var order []string
+	for _, child := range rollup.Children {
+		order = append(order, child.Path)
+	}
+	expectedOrder := []string{"internal", "docs", "assets"}
+	if len(order) != len(expectedOrder) {
+		t.Fatalf("Expected root children %v, got %v", expectedOrder, order)
+	}
+	for i := range expectedOrder {
+		if order[i] != expectedOrder[i] {
+			t.Fatalf("Expected root children %v, got %v", expectedOrder, order)
+		}
+	}
+
+	internal := rollup.Children[0]
+	gitDir := findChild(internal, "internal/git")
+	if gitDir == nil {
+		t.Fatalf("Expected internal/git under internal, got %+v", internal.Children)
+	}
+	// Depth is capped at 2: internal/git/deep/x is aggregated into internal/git.
+	if len(gitDir.Children) != 0 {
+		t.Errorf("Expected no directories below depth 2, got %+v", gitDir.Children)
+	}
+	if gitDir.Files != 2 || gitDir.SLOC != 4 || gitDir.Functions != 3 || gitDir.MaxComplexity != 10 {
+		t.Errorf("Unexpected internal/git aggregates: %+v", gitDir)
+	}
+
+	assets := findChild(rollup, "assets")
+	if assets == nil || assets.Files != 1 || assets.SLOC != 0 {
+		t.Errorf("Expected binary file to count as a file without SLOC, got %+v", assets)
+	}
+	if len(warnings) != 1 || warnings[0].Code != warning.BinaryFilesSkipped {
+		t.Errorf("Expected a single binary-files-skipped warning, got %+v", warnings)
+	}
+}
+
+func TestComputeDirectoryRollupFoldingAndSorting(t *testing.T) {
+	root := rollupFixture(t)
+	churn := map[string]int{"docs/a.md": 40, "internal/report/report.go": 5}
+
+	opts := RollupOptions{MaxDepth: 2, MinSLOC: 2, SortBy: RollupSortChurn}
+	rollup, _, err := ComputeDirectoryRollup(root, nil, churn, opts)
+	if err != nil {
+		t.Fatalf("ComputeDirectoryRollup failed: %v", err)
+	}
+
+	// docs (1 line) and assets (0 lines) are folded into the root, but still counted in it.
+	if len(rollup.Children) != 1 || rollup.Children[0].Path != "internal" {
+		t.Fatalf("Expected only internal to remain, got %+v", rollup.Children)
+	}
+	if rollup.Files != 6 || rollup.Churn != 45 {
+		t.Errorf("Expected folded directories to stay in the root totals, got %+v", rollup)
+	}
+
+	// Within internal, report (churn 5) sorts before git (churn 0).
+	internal := rollup.Children[0]
+	if len(internal.Children) != 2 || internal.Children[0].Path != "internal/report" {
+		t.Errorf("Expected internal/report first when sorting by churn, got %+v", internal.Children)
+	}
+}
+
+func TestComputeDirectoryRollupListFiles(t *testing.T) {
+	root := rollupFixture(t)
+
+	rollup, _, err := ComputeDirectoryRollup(root, nil, nil, DefaultRollupOptions())
+	if err != nil {
+		t.Fatalf("ComputeDirectoryRollup failed: %v", err)
+	}
+	if rollup.FileStats != nil {
+		t.Errorf("Expected no files without ListFiles, got %+v", rollup.FileStats)
+	}
+
+	opts := RollupOptions{MaxDepth: 2, MinSLOC: 2, ListFiles: true}
+	rollup, _, err = ComputeDirectoryRollup(root, nil, nil, opts)
+	if err != nil {
+		t.Fatalf("ComputeDirectoryRollup failed: %v", err)
+	}
+
+	// The files of the folded docs and assets directories move up to the root.
+	wantRoot := []FileSLOC{{"main.go", 2}, {"docs/a.md", 1}, {"assets/logo.png", 0}}
+	if !slices.Equal(rollup.FileStats, wantRoot) {
+		t.Errorf("Expected root files %v, got %v", wantRoot, rollup.FileStats)
+	}
+	// Files below the depth limit stay in their deepest shown directory.
+	git := findChild(findChild(rollup, "internal"), "internal/git")
+	wantGit := []FileSLOC{{"internal/git/git.go", 3}, {"internal/git/deep/x/y.go", 1}}
+	if git == nil || !slices.Equal(git.FileStats, wantGit) {
+		t.Errorf("Expected internal/git files %v, got %+v", wantGit, git)
+	}
+}
+
+func TestRollupOptionsValidate(t *testing.T) {
+	if err := (RollupOptions{SortBy: "size"}).Validate(); err == nil {
+		t.Errorf("Expected error for unknown sort column")
+	}
+	if err := (RollupOptions{MaxDepth: -1}).Validate(); err == nil {
+		t.Errorf("Expected error for negative depth")
+	}
+	if err := DefaultRollupOptions().Validate(); err != nil {
+		t.Errorf("Expected default options to be valid, got %v", err)
+	}
+}
+
+func TestComputeDirectoryRollupLanguageFilter(t *testing.T) {
+	root := rollupFixture(t)
+	languages, err := ParseLanguageFilter("markdown")
+	if err != nil {
+		t.Fatalf("ParseLanguageFilter failed: %v", err)
+	}
+	opts := DefaultRollupOptions()
+	opts.Languages = languages
+
+	rollup, warnings, err := ComputeDirectoryRollup(root, nil, nil, opts)
+	if err != nil {
+		t.Fatalf("ComputeDirectoryRollup failed: %v", err)
+	}
+	if rollup.Files != 1 || rollup.SLOC != 1 || len(rollup.Children) != 1 || rollup.Children[0].Path != "docs" {
+		t.Errorf("Expected only docs/a.md to be counted, got %+v", rollup)
+	}
+	if len(warnings) != 0 {
+		t.Errorf("Expected filtered-out binary files not to warn, got %+v", warnings)
+	}
+}
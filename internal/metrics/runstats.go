@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"io/fs"
+	"path/filepath"
+)
+
+// Reasons a walked file is left out of the source analyses, as counted in RunStats.FilesSkipped.
+const (
+	SkipNotSource      = "not source code"
+	SkipSkippedDir     = "in skipped directory"
+	SkipExcluded       = "excluded by pattern"
+	SkipLanguageFilter = "outside language filter"
+)
+
+// RunStats describes what a run did, to help users understand its results.
+type RunStats struct {
+	ObjectsFetched   int            `json:"objectsFetched"`   // Git objects the server sent for the clone; 0 if it reported none
+	BytesTransferred int64          `json:"bytesTransferred"` // Size of the packfiles received for the clone
+	FilesWalked      int            `json:"filesWalked"`      // Regular files in the analyzed tree, outside .git
+	FilesSkipped     map[string]int `json:"filesSkipped"`     // Walked files the source analyses left out, by Skip* reason
+	ParseErrors      int            `json:"parseErrors"`      // Source files that could not be parsed
+}
+
+// FilesAnalyzed returns the number of walked source files the analyses included.
+func (s *RunStats) FilesAnalyzed() int {
+	n := s.FilesWalked - s.ParseErrors
+	for _, count := range s.FilesSkipped {
+		n -= count
+	}
+	return n
+}
+
+// CountWalkedFiles walks repoPath like the source analyses and fills the FilesWalked and
+// FilesSkipped counts of stats. Each skipped file is counted under the first reason that
+// applies, in the order: not source code, outside the language filter, excluded by pattern,
+// in a skipped directory.
+func CountWalkedFiles(repoPath string, opts RollupOptions, stats *RunStats) error {
+	if stats.FilesSkipped == nil {
+		stats.FilesSkipped = make(map[string]int)
+	}
+	return filepath.WalkDir(repoPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		stats.FilesWalked++
+
+		relPath, err := filepath.Rel(repoPath, path)
+		if err != nil {
+			return err
+		}
+		relPath = filepath.ToSlash(relPath)
+		switch {
+		case !isSourceFile(relPath):
+			stats.FilesSkipped[SkipNotSource]++
+		case !opts.Languages.Match(relPath):
+			stats.FilesSkipped[SkipLanguageFilter]++
+		case opts.Exclude.Excludes(relPath):
+			stats.FilesSkipped[SkipExcluded]++
+		case inSkippedGoDir(relPath):
+			stats.FilesSkipped[SkipSkippedDir]++
+		}
+		return nil
+	})
+}
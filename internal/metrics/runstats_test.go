@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCountWalkedFiles(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "main.go", "package main\n\nfunc main() {}\n")
+	writeFile(t, root, "lib/lib.go", "package lib\n")
+	writeFile(t, root, "gen/api.pb.go", "package gen\n")
+	writeFile(t, root, "vendor/dep/dep.go", "package dep\n")
+	writeFile(t, root, "testdata/fixture.go", "package fixture\n")
+	writeFile(t, root, "README.md", "# Readme\n")
+	writeFile(t, root, "docs/guide.md", "# Guide\n")
+	writeFile(t, root, ".git/HEAD", "ref: refs/heads/main\n")
+
+	exclude, err := NewExcludeFilter([]string{"gen/"})
+	if err != nil {
+		t.Fatalf("NewExcludeFilter failed: %v", err)
+	}
+	stats := &RunStats{ParseErrors: 1}
+	if err := CountWalkedFiles(root, RollupOptions{Exclude: exclude}, stats); err != nil {
+		t.Fatalf("CountWalkedFiles failed: %v", err)
+	}
+
+	if stats.FilesWalked != 7 {
+		t.Errorf("Expected 7 files walked outside .git, got %d", stats.FilesWalked)
+	}
+	want := map[string]int{SkipNotSource: 2, SkipExcluded: 1, SkipSkippedDir: 2}
+	if !reflect.DeepEqual(stats.FilesSkipped, want) {
+		t.Errorf("Expected skipped files %v, got %v", want, stats.FilesSkipped)
+	}
+	// main.go and lib/lib.go, one of which failed to parse.
+	if n := stats.FilesAnalyzed(); n != 1 {
+		t.Errorf("Expected 1 file analyzed, got %d", n)
+	}
+
+	languages, err := ParseLanguageFilter("markdown")
+	if err != nil {
+		t.Fatalf("ParseLanguageFilter failed: %v", err)
+	}
+	stats = &RunStats{}
+	if err := CountWalkedFiles(root, RollupOptions{Languages: languages}, stats); err != nil {
+		t.Fatalf("CountWalkedFiles failed: %v", err)
+	}
+	if stats.FilesSkipped[SkipLanguageFilter] != 5 {
+		t.Errorf("Expected the 5 Go files to be skipped by the language filter, got %v", stats.FilesSkipped)
+	}
+}
@@ -0,0 +1,260 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
+// DefaultMaxLineLength is the display width above which lines count as long.
+const DefaultMaxLineLength = 120
+
+// styleTabStop is the column multiple a tab advances to when measuring line width.
+const styleTabStop = 4
+
+// Dominant indentations of a file.
+const (
+	IndentTabs   = "tabs"
+	IndentSpaces = "spaces"
+)
+
+// FileStyle is the indentation and line length of one file.
+type FileStyle struct {
+	Indent       string // IndentTabs, IndentSpaces, or empty if no line is indented or indentation is not checked
+	IndentWidth  int    // Spaces per indentation level of a space-indented file, 0 if unknown
+	MixedIndent  bool   // Some lines are indented with tabs and others with spaces
+	LongLines    int    // Lines wider than the maximum line length
+	MaxLineWidth int    // Display width of the widest line
+}
+
+// StyleStats aggregates the FileStyle of the files of one language.
+type StyleStats struct {
+	Language           string `json:"language"`
+	Files              int    `json:"files"`
+	IndentChecked      bool   `json:"indentChecked"` // False for Go, whose indentation gofmt settles
+	TabFiles           int    `json:"tabFiles"`      // Files mostly indented with tabs
+	SpaceFiles         int    `json:"spaceFiles"`    // Files mostly indented with spaces
+	IndentWidth        int    `json:"indentWidth"`   // Most common IndentWidth of the space-indented files, 0 if unknown
+	MixedIndentFiles   int    `json:"mixedIndentFiles"`
+	LongLines          int    `json:"longLines"`
+	FilesWithLongLines int    `json:"filesWithLongLines"`
+	MaxLineWidth       int    `json:"maxLineWidth"`
+}
+
+// StyleOptions configure the style pass.
+type StyleOptions struct {
+	MaxLineLength int // Display width above which lines are long; 0 means DefaultMaxLineLength
+	Languages     LanguageFilter
+	Exclude       ExcludeFilter
+}
+
+func (o StyleOptions) maxLineLength() int {
+	if o.MaxLineLength <= 0 {
+		return DefaultMaxLineLength
+	}
+	return o.MaxLineLength
+}
+
+// ComputeStyleStats measures the indentation and line length of the files of every
+// registered language under repoPath, except the .git directory, binary files and files
+// outside opts, and aggregates them per language, ordered by language name.
+func ComputeStyleStats(repoPath string, opts StyleOptions) ([]StyleStats, error) {
+	byLanguage := make(map[string]*StyleStats)
+	widths := make(map[string]map[int]int) // Per language, the number of files of each IndentWidth
+	err := filepath.WalkDir(repoPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		lang, ok := languageOf(p)
+		if !ok || !d.Type().IsRegular() || !opts.Languages.Match(p) {
+			return nil
+		}
+		rel, err := filepath.Rel(repoPath, p)
+		if err != nil {
+			return err
+		}
+		if opts.Exclude.Excludes(filepath.ToSlash(rel)) {
+			return nil
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", p, err)
+		}
+		if isBinary(content) {
+			return nil
+		}
+
+		checkIndent := lang.Name != "Go"
+		style := MeasureFileStyle(content, opts.maxLineLength(), checkIndent)
+		s, ok := byLanguage[lang.Name]
+		if !ok {
+			s = &StyleStats{Language: lang.Name, IndentChecked: checkIndent}
+			byLanguage[lang.Name] = s
+			widths[lang.Name] = make(map[int]int)
+		}
+		s.Files++
+		switch style.Indent {
+		case IndentTabs:
+			s.TabFiles++
+		case IndentSpaces:
+			s.SpaceFiles++
+			if style.IndentWidth > 0 {
+				widths[lang.Name][style.IndentWidth]++
+			}
+		}
+		if style.MixedIndent {
+			s.MixedIndentFiles++
+		}
+		s.LongLines += style.LongLines
+		if style.LongLines > 0 {
+			s.FilesWithLongLines++
+		}
+		s.MaxLineWidth = max(s.MaxLineWidth, style.MaxLineWidth)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make([]StyleStats, 0, len(byLanguage))
+	for name, s := range byLanguage {
+		s.IndentWidth = mostCommon(widths[name])
+		stats = append(stats, *s)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Language < stats[j].Language })
+	return stats, nil
+}
+
+// MeasureFileStyle measures the lines of content. Lines wider than maxLineLength display
+// columns are long. Unless checkIndent is false, the leading whitespace of each line is
+// classified as tab or space indentation; a single leading space is taken for the alignment
+// of a comment continuation, not indentation.
+func MeasureFileStyle(content []byte, maxLineLength int, checkIndent bool) FileStyle {
+	var style FileStyle
+	tabLines, spaceLines := 0, 0
+	steps := make(map[int]int) // Increases of the space indentation between consecutive indented lines
+	previous := 0
+	for _, line := range bytes.Split(content, []byte("\n")) {
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		width := DisplayWidth(line)
+		style.MaxLineWidth = max(style.MaxLineWidth, width)
+		if width > maxLineLength {
+			style.LongLines++
+		}
+		if !checkIndent || len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		lead := line[:len(line)-len(bytes.TrimLeft(line, " \t"))]
+		switch {
+		case len(lead) == 0:
+			previous = 0
+		case lead[0] == '\t':
+			tabLines++
+		case bytes.IndexByte(lead, '\t') < 0 && len(lead) == 1:
+			// Comment continuation, such as " * text" below "/**".
+		default:
+			spaceLines++
+			if bytes.IndexByte(lead, '\t') < 0 {
+				if step := len(lead) - previous; step > 0 {
+					steps[step]++
+				}
+				previous = len(lead)
+			}
+		}
+	}
+
+	switch {
+	case tabLines > spaceLines:
+		style.Indent = IndentTabs
+	case spaceLines > 0:
+		style.Indent = IndentSpaces
+		style.IndentWidth = mostCommon(steps)
+	}
+	style.MixedIndent = tabLines > 0 && spaceLines > 0
+	return style
+}
+
+// mostCommon returns the key with the highest count, the smallest on ties, or 0 if counts is empty.
+func mostCommon(counts map[int]int) int {
+	best := 0
+	for key, n := range counts {
+		if n > counts[best] || (n == counts[best] && key < best) {
+			best = key
+		}
+	}
+	return best
+}
+
+// DisplayWidth returns the number of terminal columns line takes up: East Asian wide and
+// fullwidth characters, such as CJK ideographs, take two, combining marks and other
+// zero-width characters none, and a tab advances to the next multiple of 4 columns.
+// Invalid UTF-8 bytes take one column each.
+func DisplayWidth(line []byte) int {
+	width := 0
+	for len(line) > 0 {
+		r, size := utf8.DecodeRune(line)
+		line = line[size:]
+		switch {
+		case r == '\t':
+			width += styleTabStop - width%styleTabStop
+		case r == utf8.RuneError && size == 1:
+			width++
+		case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf):
+		case isWideRune(r):
+			width += 2
+		default:
+			width++
+		}
+	}
+	return width
+}
+
+// wideRanges are the code point ranges of the East Asian Wide and Fullwidth characters
+// common in source files, and of the emoji presented as wide.
+var wideRanges = []struct{ lo, hi rune }{
+	{0x1100, 0x115F},   // Hangul Jamo initial consonants
+	{0x2E80, 0x303E},   // CJK radicals, Kangxi radicals, CJK symbols and punctuation
+	{0x3041, 0x33FF},   // Hiragana, Katakana, Bopomofo, CJK compatibility
+	{0x3400, 0x4DBF},   // CJK unified ideographs extension A
+	{0x4E00, 0x9FFF},   // CJK unified ideographs
+	{0xA000, 0xA4CF},   // Yi
+	{0xAC00, 0xD7A3},   // Hangul syllables
+	{0xF900, 0xFAFF},   // CJK compatibility ideographs
+	{0xFE30, 0xFE4F},   // CJK compatibility forms
+	{0xFF00, 0xFF60},   // Fullwidth forms
+	{0xFFE0, 0xFFE6},   // Fullwidth signs
+	{0x1F300, 0x1F64F}, // Miscellaneous symbols and pictographs, emoticons
+	{0x1F900, 0x1F9FF}, // Supplemental symbols and pictographs
+	{0x20000, 0x3FFFD}, // CJK unified ideographs extensions B and beyond
+}
+
+func isWideRune(r rune) bool {
+	for _, wr := range wideRanges {
+		if r >= wr.lo && r <= wr.hi {
+			return true
+		}
+	}
+	return false
+}
+
+// languageOf returns the registered language of the file at path.
+func languageOf(path string) (Language, bool) {
+	for _, lang := range Languages {
+		if lang.matches(path) {
+			return lang, true
+		}
+	}
+	return Language{}, false
+}
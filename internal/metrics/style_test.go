@@ -0,0 +1,96 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDisplayWidth(t *testing.T) {
+	tests := []struct {
+		line string
+		want int
+	}{
+		{"", 0},
+		{"hello", 5},
+		{"日本語", 6},                     // CJK ideographs are two columns wide
+		{"// 日本語のコメント", 19},            // Mixed ASCII and CJK
+		{"한국어", 6},                     // Hangul syllables
+		{"ｆｕｌｌ", 8},                    // Fullwidth Latin letters
+		{"e\u0301te\u0301", 3},         // Combining accents take no column
+		{"\tx", 5},                     // Tabs advance to the next multiple of 4
+		{"ab\tx", 5},                   // ...from wherever the line is
+		{"café", 4},                    // Precomposed characters take one column
+		{string([]byte{0xff, 'a'}), 2}, // Invalid UTF-8 takes a column per byte
+	}
+	for _, tt := range tests {
+		if got := DisplayWidth([]byte(tt.line)); got != tt.want {
+			t.Errorf("DisplayWidth(%q) = %d, want %d", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestMeasureFileStyle(t *testing.T) {
+	// 60 CJK characters are 60 runes and 180 bytes but 120 display columns: not long.
+	cjk := strings.Repeat("漢", 60)
+	content := "const a = {\n" +
+		"  b: 1,\n" +
+		"  c: {\n" +
+		"    d: '" + cjk + "',\n" + // 4 + 4 + 120 + 2 = 130 columns
+		"  },\n" +
+		"}\n" +
+		"/**\n * doc\n */\n" +
+		"\tlegacy();\n"
+	style := MeasureFileStyle([]byte(content), 120, true)
+	if style.Indent != IndentSpaces || style.IndentWidth != 2 {
+		t.Errorf("Expected 2-space indentation, got %q width %d", style.Indent, style.IndentWidth)
+	}
+	if !style.MixedIndent {
+		t.Errorf("Expected the tab-indented line to mark the file as mixed")
+	}
+	if style.LongLines != 1 || style.MaxLineWidth != 130 {
+		t.Errorf("Expected one long line of 130 columns, got %d long lines, widest %d", style.LongLines, style.MaxLineWidth)
+	}
+
+	exact := MeasureFileStyle([]byte(cjk+"\n"), 120, true)
+	if exact.LongLines != 0 || exact.MaxLineWidth != 120 {
+		t.Errorf("Expected a line of 120 CJK columns not to be long, got %d long lines, widest %d", exact.LongLines, exact.MaxLineWidth)
+	}
+
+	goStyle := MeasureFileStyle([]byte("func f() {\n\tif x {\n        y()\n\t}\n}\n"), 120, false)
+	if goStyle.Indent != "" || goStyle.MixedIndent {
+		t.Errorf("Expected indentation not to be checked, got %+v", goStyle)
+	}
+}
+
+func TestComputeStyleStats(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "main.go", "package main\n\nfunc main() {\n\tprintln(\""+strings.Repeat("x", 130)+"\")\n    }\n")
+	writeFile(t, root, "web/a.js", "if (x) {\n    y();\n}\n")
+	writeFile(t, root, "web/b.js", "if (x) {\n    y();\n\tz();\n}\n")
+	writeFile(t, root, "web/c.ts", "if (x) {\n\ty();\n}\n")
+	writeFile(t, root, "docs/README.md", "# "+strings.Repeat("長", 70)+"\n")
+	writeFile(t, root, "vendor/gen.js", "if (x) {\n\ty();\n}\n")
+
+	exclude, err := NewExcludeFilter([]string{"vendor/"})
+	if err != nil {
+		t.Fatalf("NewExcludeFilter failed: %v", err)
+	}
+	stats, err := ComputeStyleStats(root, StyleOptions{Exclude: exclude})
+	if err != nil {
+		t.Fatalf("ComputeStyleStats failed: %v", err)
+	}
+	expected := []StyleStats{
+		{Language: "Go", Files: 1, LongLines: 1, FilesWithLongLines: 1, MaxLineWidth: 145},
+		{Language: "JavaScript", Files: 2, IndentChecked: true, SpaceFiles: 2, IndentWidth: 4, MixedIndentFiles: 1, MaxLineWidth: 8},
+		{Language: "Markdown", Files: 1, IndentChecked: true, LongLines: 1, FilesWithLongLines: 1, MaxLineWidth: 142},
+		{Language: "TypeScript", Files: 1, IndentChecked: true, TabFiles: 1, MaxLineWidth: 8},
+	}
+	if len(stats) != len(expected) {
+		t.Fatalf("Expected style stats %+v, got %+v", expected, stats)
+	}
+	for i := range expected {
+		if stats[i] != expected[i] {
+			t.Errorf("Style %d: expected %+v, got %+v", i, expected[i], stats[i])
+		}
+	}
+}
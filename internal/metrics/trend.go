@@ -0,0 +1,162 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/zenwatch/internal/filelock"
+)
+
+// TrendPoint is the number of production functions over the complexity threshold at one commit.
+type TrendPoint struct {
+	Commit                 string `json:"commit"`
+	FunctionsOverThreshold int    `json:"functionsOverThreshold"`
+}
+
+// SourceLoader returns the Go sources of a commit, keyed by slash-separated path.
+type SourceLoader func(hash string) (map[string][]byte, error)
+
+// ComputeComplexityTrend counts the production functions over threshold at each of the given
+// commits, in the given order. Counts found in cache are reused, and new counts are added to
+// it; cache may be nil. Files that fail to parse are skipped, as in the HEAD analysis.
+func ComputeComplexityTrend(commits []string, load SourceLoader, threshold int, cache *TrendCache) ([]TrendPoint, error) {
+	points := make([]TrendPoint, 0, len(commits))
+	for _, hash := range commits {
+		count, ok := cache.get(hash, threshold)
+		if !ok {
+			sources, err := load(hash)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load sources of commit %s: %w", hash, err)
+			}
+			stats, _ := CollectComplexityFromSources(sources)
+			production, _ := SplitTestComplexity(FilterOverThreshold(stats, threshold))
+			count = len(production)
+			cache.put(hash, threshold, count)
+		}
+		points = append(points, TrendPoint{Commit: hash, FunctionsOverThreshold: count})
+	}
+	return points, nil
+}
+
+// sparkBlocks are the bar characters of a sparkline, lowest first.
+var sparkBlocks = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline renders values as a line of block characters scaled between their minimum and maximum.
+// Equal values are drawn as the lowest block.
+func Sparkline(values []int) string {
+	if len(values) == 0 {
+		return ""
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo = min(lo, v)
+		hi = max(hi, v)
+	}
+
+	var sb strings.Builder
+	for _, v := range values {
+		level := 0
+		if hi > lo {
+			level = (v - lo) * (len(sparkBlocks) - 1) / (hi - lo)
+		}
+		sb.WriteRune(sparkBlocks[level])
+	}
+	return sb.String()
+}
+
+// TrendCache persists per-commit trend counts between runs, so only new commits need to be
+// analyzed. Entries are keyed by complexity algorithm version, threshold and commit hash,
+// so a changed algorithm or threshold never reuses stale counts.
+type TrendCache struct {
+	path   string
+	Counts map[string]int `json:"counts"`
+}
+
+// LoadTrendCache reads the cache at path. A missing file yields an empty cache.
+func LoadTrendCache(path string) (*TrendCache, error) {
+	cache := &TrendCache{path: path, Counts: map[string]int{}}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trend cache %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, cache); err != nil {
+		return nil, fmt.Errorf("failed to parse trend cache %s: %w", path, err)
+	}
+	if cache.Counts == nil {
+		cache.Counts = map[string]int{}
+	}
+	return cache, nil
+}
+
+// Save writes the cache back to the path it was loaded from. Several processes may share the
+// cache, so Save holds a lock file while it merges the entries another process saved since the
+// load, and replaces the file atomically: a concurrent load never reads half a file.
+func (c *TrendCache) Save() error {
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o755); err != nil {
+		return fmt.Errorf("failed to create trend cache directory: %w", err)
+	}
+	lock, err := filelock.Acquire(c.path+".lock", filelock.Options{})
+	if err != nil {
+		return fmt.Errorf("failed to lock trend cache: %w", err)
+	}
+	defer lock.Release()
+
+	// Counts are deterministic for a key, so entries of either side can be kept.
+	saved, err := LoadTrendCache(c.path)
+	if err != nil {
+		return err
+	}
+	for key, count := range saved.Counts {
+		if _, ok := c.Counts[key]; !ok {
+			c.Counts[key] = count
+		}
+	}
+	data, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("failed to encode trend cache: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to write trend cache %s: %w", c.path, err)
+	}
+	defer os.Remove(tmp.Name()) // Fails once renamed
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0o644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write trend cache %s: %w", c.path, err)
+	}
+	return nil
+}
+
+func trendCacheKey(hash string, threshold int) string {
+	return fmt.Sprintf("complexity-v%s/threshold-%d/%s", complexityAlgorithmVersion, threshold, hash)
+}
+
+func (c *TrendCache) get(hash string, threshold int) (int, bool) {
+	if c == nil {
+		return 0, false
+	}
+	count, ok := c.Counts[trendCacheKey(hash, threshold)]
+	return count, ok
+}
+
+func (c *TrendCache) put(hash string, threshold, count int) {
+	if c != nil {
+		c.Counts[trendCacheKey(hash, threshold)] = count
+	}
+}
@@ -0,0 +1,160 @@
+package metrics
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// branchyFunc returns a function with the given number of if statements (complexity n+1).
+func branchyFunc(name string, n int) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "func %s(x int) int {\n", name)
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&sb, "\tif x == %d {\n\t\treturn %d\n\t}\n", i, i)
+	}
+	sb.WriteString("\treturn -1\n}\n")
+	return sb.String()
+}
+
+func TestComputeComplexityTrend(t *testing.T) {
+	// Commits oldest first: one complex function is added, then a second one, then one is simplified.
+	history := map[string]map[string][]byte{
+		"c1": {"lib/lib.go": []byte("package lib\n\n" + branchyFunc("A", 2))},
+		"c2": {"lib/lib.go": []byte("package lib\n\n" + branchyFunc("A", 5))},
+		"c3": {
+			"lib/lib.go":      []byte("package lib\n\n" + branchyFunc("A", 5) + branchyFunc("B", 6)),
+			"lib/lib_test.go": []byte("package lib\n\n" + branchyFunc("testHelper", 9)),
+			"vendor/x/x.go":   []byte("package x\n\n" + branchyFunc("V", 9)),
+		},
+		"c4": {"lib/lib.go": []byte("package lib\n\n" + branchyFunc("A", 1) + branchyFunc("B", 6))},
+	}
+	var loaded []string
+	load := func(hash string) (map[string][]byte, error) {
+		loaded = append(loaded, hash)
+		return history[hash], nil
+	}
+
+	cache, err := LoadTrendCache(filepath.Join(t.TempDir(), "zenwatch", "trend.json"))
+	if err != nil {
+		t.Fatalf("LoadTrendCache failed: %v", err)
+	}
+	commits := []string{"c1", "c2", "c3", "c4"}
+	points, err := ComputeComplexityTrend(commits, load, 4, cache)
+	if err != nil {
+		t.Fatalf("ComputeComplexityTrend failed: %v", err)
+	}
+
+	var counts []int
+	for i, p := range points {
+		if p.Commit != commits[i] {
+			t.Errorf("Point %d: expected commit %s, got %s", i, commits[i], p.Commit)
+		}
+		counts = append(counts, p.FunctionsOverThreshold)
+	}
+	if fmt.Sprint(counts) != "[0 1 2 1]" {
+		t.Errorf("Expected counts [0 1 2 1] (tests and vendor excluded), got %v", counts)
+	}
+	if line := Sparkline(counts); line != "▁▄█▄" {
+		t.Errorf("Expected sparkline ▁▄█▄, got %s", line)
+	}
+
+	// A reloaded cache answers for known commits, but not for another threshold.
+	if err := cache.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	cache, err = LoadTrendCache(cache.path)
+	if err != nil {
+		t.Fatalf("LoadTrendCache failed: %v", err)
+	}
+	loaded = nil
+	if _, err := ComputeComplexityTrend(commits, load, 4, cache); err != nil {
+		t.Fatalf("ComputeComplexityTrend failed: %v", err)
+	}
+	if len(loaded) != 0 {
+		t.Errorf("Expected all counts from the cache, loaded %v", loaded)
+	}
+	points, err = ComputeComplexityTrend([]string{"c3"}, load, 6, cache)
+	if err != nil {
+		t.Fatalf("ComputeComplexityTrend failed: %v", err)
+	}
+	if len(loaded) != 1 || points[0].FunctionsOverThreshold != 1 {
+		t.Errorf("Expected c3 to be recomputed for threshold 6 with 1 function, loaded %v, got %+v", loaded, points)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	tests := []struct {
+		values []int
+		want   string
+	}{
+		{nil, ""},
+		{[]int{3, 3, 3}, "▁▁▁"},
+		{[]int{0, 7}, "▁█"},
+		{[]int{0, 1, 2, 3, 4, 5, 6, 7}, "▁▂▃▄▅▆▇█"},
+	}
+	for _, tt := range tests {
+		if got := Sparkline(tt.values); got != tt.want {
+			t.Errorf("Sparkline(%v) = %q, want %q", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestTrendCacheConcurrentSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zenwatch", "trend.json")
+	const writers = 16
+	var wg sync.WaitGroup
+	done := make(chan struct{})
+	// A reader never sees half a file.
+	readerErr := make(chan error, 1)
+	go func() {
+		defer close(readerErr)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if _, err := LoadTrendCache(path); err != nil {
+				readerErr <- err
+				return
+			}
+		}
+	}()
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for round := 0; round < 5; round++ {
+				cache, err := LoadTrendCache(path)
+				if err != nil {
+					t.Errorf("LoadTrendCache failed: %v", err)
+					return
+				}
+				cache.put(fmt.Sprintf("w%d-r%d", i, round), 4, i)
+				if err := cache.Save(); err != nil {
+					t.Errorf("Save failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(done)
+	if err := <-readerErr; err != nil {
+		t.Errorf("Expected every load to succeed, got %v", err)
+	}
+
+	cache, err := LoadTrendCache(path)
+	if err != nil {
+		t.Fatalf("LoadTrendCache failed: %v", err)
+	}
+	if len(cache.Counts) != writers*5 {
+		t.Errorf("Expected the %d entries of every writer to be kept, got %d", writers*5, len(cache.Counts))
+	}
+	if matches, _ := filepath.Glob(filepath.Join(filepath.Dir(path), "trend.json.*")); len(matches) != 0 {
+		t.Errorf("Expected no lock or temporary file to be left, got %v", matches)
+	}
+}
@@ -0,0 +1,136 @@
+package metrics
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Kinds of vendored dependency drift.
+const (
+	VendorMissing             = "missing"              // Required by go.mod but not vendored
+	VendorVersionMismatch     = "version-mismatch"     // Vendored at a different version than required
+	VendorReplacementMismatch = "replacement-mismatch" // Vendored with a different replacement than go.mod declares
+	VendorNotExplicit         = "not-explicit"         // Required by go.mod but not marked explicit in modules.txt
+	VendorUnrequired          = "unrequired"           // Marked explicit in modules.txt but not required by go.mod
+)
+
+// VendorDrift is a mismatch between go.mod and vendor/modules.txt.
+type VendorDrift struct {
+	Module   string `json:"module"`
+	Kind     string `json:"kind"`     // One of the Vendor* constants
+	Required string `json:"required"` // Version, and replacement if any, according to go.mod
+	Vendored string `json:"vendored"` // Version, and replacement if any, according to vendor/modules.txt
+}
+
+// vendoredModule is a "# module version [=> replacement]" entry of vendor/modules.txt.
+type vendoredModule struct {
+	version     string
+	replacement string
+	explicit    bool
+}
+
+// CheckVendorDrift compares the requirements and replacements of repoPath/go.mod with
+// repoPath/vendor/modules.txt, sorted by module and kind. It returns no findings if the
+// repository does not vendor its dependencies.
+func CheckVendorDrift(repoPath string) ([]VendorDrift, error) {
+	vendored, hasExplicitMarkers, err := readModulesTxt(filepath.Join(repoPath, "vendor", "modules.txt"))
+	if err != nil || vendored == nil {
+		return nil, err
+	}
+	mod, err := readGoMod(repoPath)
+	if err != nil || mod == nil {
+		return nil, err
+	}
+
+	var drift []VendorDrift
+	for path, version := range mod.requires {
+		required := describeModuleVersion(version, mod.replacement(path, version))
+		v, ok := vendored[path]
+		if !ok {
+			drift = append(drift, VendorDrift{Module: path, Kind: VendorMissing, Required: required})
+			continue
+		}
+		actual := describeModuleVersion(v.version, v.replacement)
+		switch {
+		case v.version != version:
+			drift = append(drift, VendorDrift{Module: path, Kind: VendorVersionMismatch, Required: required, Vendored: actual})
+		case v.replacement != mod.replacement(path, version):
+			drift = append(drift, VendorDrift{Module: path, Kind: VendorReplacementMismatch, Required: required, Vendored: actual})
+		}
+		// Vendor directories created before Go 1.14 have no explicit markers at all.
+		if hasExplicitMarkers && !v.explicit {
+			drift = append(drift, VendorDrift{Module: path, Kind: VendorNotExplicit, Required: required, Vendored: actual})
+		}
+	}
+	for path, v := range vendored {
+		if _, ok := mod.requires[path]; !ok && v.explicit {
+			drift = append(drift, VendorDrift{Module: path, Kind: VendorUnrequired, Vendored: describeModuleVersion(v.version, v.replacement)})
+		}
+	}
+
+	sort.Slice(drift, func(i, j int) bool {
+		if drift[i].Module != drift[j].Module {
+			return drift[i].Module < drift[j].Module
+		}
+		return drift[i].Kind < drift[j].Kind
+	})
+	return drift, nil
+}
+
+// readModulesTxt parses the module lines of a vendor/modules.txt:
+//
+//	# example.com/a v1.2.0
+//	## explicit; go 1.20
+//	example.com/a/pkg
+//	# example.com/b v1.0.0 => example.com/fork/b v1.0.1
+//	# example.com/c => ../c
+//
+// Lines without a version only record replace directives of modules outside the build
+// list and are ignored. It returns nil and no error if the file does not exist.
+func readModulesTxt(path string) (map[string]*vendoredModule, bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, false, nil
+		}
+		return nil, false, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	modules := make(map[string]*vendoredModule)
+	hasExplicitMarkers := false
+	var current *vendoredModule
+	for _, line := range strings.Split(string(content), "\n") {
+		switch {
+		case strings.HasPrefix(line, "## "):
+			for _, marker := range strings.Split(strings.TrimPrefix(line, "## "), ";") {
+				if strings.TrimSpace(marker) == "explicit" {
+					hasExplicitMarkers = true
+					if current != nil {
+						current.explicit = true
+					}
+				}
+			}
+		case strings.HasPrefix(line, "# "):
+			current = nil
+			module, replacement, _ := strings.Cut(strings.TrimPrefix(line, "# "), " => ")
+			fields := strings.Fields(module)
+			if len(fields) != 2 {
+				continue
+			}
+			current = &vendoredModule{version: fields[1], replacement: strings.TrimSpace(replacement)}
+			modules[fields[0]] = current
+		}
+	}
+	return modules, hasExplicitMarkers, nil
+}
+
+// describeModuleVersion formats a version and its optional replacement, e.g. "v1.0.0 => ../c".
+func describeModuleVersion(version, replacement string) string {
+	if replacement == "" {
+		return version
+	}
+	return version + " => " + replacement
+}
@@ -0,0 +1,76 @@
+package metrics
+
+import "testing"
+
+func TestCheckVendorDrift(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "go.mod", `module example.com/app
+
+go 1.21
+
+require (
+	example.com/ok v1.0.0
+	example.com/bumped v1.3.0 // indirect
+	example.com/forked v2.0.0
+	example.com/missing v0.1.0
+	example.com/unmarked v1.1.0
+)
+
+require example.com/local v0.0.0
+
+replace example.com/forked v2.0.0 => example.com/fork/forked v2.0.1
+
+replace example.com/local => ../local
+`)
+	writeFile(t, root, "vendor/modules.txt", `# example.com/ok v1.0.0
+## explicit; go 1.20
+example.com/ok
+# example.com/bumped v1.2.0
+## explicit
+example.com/bumped/pkg
+# example.com/forked v2.0.0 => example.com/fork/forked v2.0.0
+## explicit
+example.com/forked
+# example.com/unmarked v1.1.0
+example.com/unmarked
+# example.com/local v0.0.0 => ../local
+## explicit
+example.com/local
+# example.com/stale v0.9.0
+## explicit
+example.com/stale
+# example.com/outside => ../outside
+`)
+
+	drift, err := CheckVendorDrift(root)
+	if err != nil {
+		t.Fatalf("CheckVendorDrift failed: %v", err)
+	}
+
+	expected := []VendorDrift{
+		{Module: "example.com/bumped", Kind: VendorVersionMismatch, Required: "v1.3.0", Vendored: "v1.2.0"},
+		{Module: "example.com/forked", Kind: VendorReplacementMismatch,
+			Required: "v2.0.0 => example.com/fork/forked v2.0.1", Vendored: "v2.0.0 => example.com/fork/forked v2.0.0"},
+		{Module: "example.com/missing", Kind: VendorMissing, Required: "v0.1.0"},
+		{Module: "example.com/stale", Kind: VendorUnrequired, Vendored: "v0.9.0"},
+		{Module: "example.com/unmarked", Kind: VendorNotExplicit, Required: "v1.1.0", Vendored: "v1.1.0"},
+	}
+	if len(drift) != len(expected) {
+		t.Fatalf("Expected %d findings, got %d: %+v", len(expected), len(drift), drift)
+	}
+	for i := range expected {
+		if drift[i] != expected[i] {
+			t.Errorf("Finding %d: expected %+v, got %+v", i, expected[i], drift[i])
+		}
+	}
+}
+
+func TestCheckVendorDriftWithoutVendorDirectory(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "go.mod", "module example.com/app\n\nrequire example.com/dep v1.0.0\n")
+
+	drift, err := CheckVendorDrift(root)
+	if err != nil || drift != nil {
+		t.Errorf("Expected the check to be skipped without a vendor directory, got %+v, %v", drift, err)
+	}
+}
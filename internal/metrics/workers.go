@@ -0,0 +1,102 @@
+package metrics
+
+import (
+	"context"
+	"runtime"
+	"sync"
+)
+
+// WorkerOptions bound the resources used to parse the files of a repository.
+type WorkerOptions struct {
+	Concurrency int    // Files parsed at once; 0 parses one file per CPU at once
+	MaxMemory   uint64 // Soft limit of the heap in bytes; 0 disables the memory guard
+	MaxFileSize int64  // Files larger than this many bytes are skipped with a warning; 0 disables
+}
+
+// OverMemory reports whether the heap is at or over the MaxMemory limit.
+func (o WorkerOptions) OverMemory() bool {
+	return o.MaxMemory > 0 && readHeapAlloc() >= o.MaxMemory
+}
+
+// workerPool hands out slots to parse files in. The memory guard is best effort: the heap is
+// only sampled when a file is about to be parsed, so a single large file can still exceed
+// the limit, and while the heap is over it files are parsed one at a time rather than not at all.
+type workerPool struct {
+	mu        sync.Mutex
+	cond      *sync.Cond
+	inFlight  int
+	limit     int
+	maxMemory uint64
+	heapAlloc func() uint64 // Samples the heap; replaced in tests
+}
+
+func newWorkerPool(opts WorkerOptions) *workerPool {
+	limit := opts.Concurrency
+	if limit == 0 {
+		limit = runtime.GOMAXPROCS(0)
+	}
+	p := &workerPool{limit: limit, maxMemory: opts.MaxMemory, heapAlloc: readHeapAlloc}
+	p.cond = sync.NewCond(&p.mu)
+	return p
+}
+
+// readHeapAlloc returns the bytes of allocated heap objects.
+func readHeapAlloc() uint64 {
+	var m runtime.MemStats
+	runtime.ReadMemStats(&m)
+	return m.HeapAlloc
+}
+
+// acquire blocks until a slot is free. While the heap is over the memory limit, it waits for
+// every other slot to be released, and then collects garbage before going on.
+func (p *workerPool) acquire() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for {
+		if p.inFlight < p.limit && !p.overMemory() {
+			break
+		}
+		if p.inFlight == 0 {
+			runtime.GC()
+			break
+		}
+		p.cond.Wait()
+	}
+	p.inFlight++
+}
+
+// release frees a slot taken by acquire.
+func (p *workerPool) release() {
+	p.mu.Lock()
+	p.inFlight--
+	p.mu.Unlock()
+	p.cond.Broadcast()
+}
+
+func (p *workerPool) overMemory() bool {
+	return p.maxMemory > 0 && p.heapAlloc() >= p.maxMemory
+}
+
+// run calls work for each index below n, in as many goroutines as the pool has slots,
+// and returns once all calls have returned. Once ctx is done, no more calls are started
+// and the number of calls made is returned.
+func (p *workerPool) run(ctx context.Context, n int, work func(i int)) int {
+	var wg sync.WaitGroup
+	started := 0
+	for i := range n {
+		p.acquire()
+		if ctx.Err() != nil {
+			p.release()
+			break
+		}
+		started++
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer p.release()
+			work(i)
+		}()
+	}
+	wg.Wait()
+	return started
+}
@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// maxInFlight runs n jobs on pool and returns the most that ran at once.
+func maxInFlight(pool *workerPool, n int) int {
+	var running, peak atomic.Int32
+	pool.run(context.Background(), n, func(int) {
+		cur := running.Add(1)
+		for {
+			prev := peak.Load()
+			if cur <= prev || peak.CompareAndSwap(prev, cur) {
+				break
+			}
+		}
+		time.Sleep(2 * time.Millisecond)
+		running.Add(-1)
+	})
+	return int(peak.Load())
+}
+
+func TestWorkerPoolRespectsConcurrency(t *testing.T) {
+	for _, concurrency := range []int{1, 3, 8} {
+		if peak := maxInFlight(newWorkerPool(WorkerOptions{Concurrency: concurrency}), 40); peak > concurrency {
+			t.Errorf("Expected at most %d jobs at once, got %d", concurrency, peak)
+		}
+	}
+}
+
+func TestWorkerPoolRunsEveryJob(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[int]bool)
+	newWorkerPool(WorkerOptions{Concurrency: 4}).run(context.Background(), 25, func(i int) {
+		mu.Lock()
+		seen[i] = true
+		mu.Unlock()
+	})
+	if len(seen) != 25 {
+		t.Errorf("Expected 25 jobs to run, got %d", len(seen))
+	}
+}
+
+func TestWorkerPoolSerializesOverMemoryLimit(t *testing.T) {
+	pool := newWorkerPool(WorkerOptions{Concurrency: 4, MaxMemory: 100})
+	pool.heapAlloc = func() uint64 { return 200 }
+	if peak := maxInFlight(pool, 10); peak != 1 {
+		t.Errorf("Expected one job at a time over the memory limit, got %d", peak)
+	}
+
+	pool.heapAlloc = func() uint64 { return 50 }
+	if peak := maxInFlight(pool, 10); peak > 4 {
+		t.Errorf("Expected at most 4 jobs at once under the memory limit, got %d", peak)
+	}
+}
+
+func TestWorkerPoolStopsWhenContextIsDone(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	var calls atomic.Int32
+	started := newWorkerPool(WorkerOptions{Concurrency: 1}).run(ctx, 10, func(i int) {
+		calls.Add(1)
+		if i == 2 {
+			cancel()
+		}
+	})
+	if started != 3 || calls.Load() != 3 {
+		t.Errorf("Expected 3 calls before the context was canceled, got %d started and %d made", started, calls.Load())
+	}
+}
@@ -0,0 +1,54 @@
+package plugin
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+)
+
+// Conform checks that p follows the protocol on the tree at root: it runs p twice and
+// returns an error listing every violation found, or nil. Besides the failures Run reports,
+// it checks that every finding is valid, names a file that exists, has a fingerprint no other
+// finding has, and that both runs report the same findings.
+func Conform(ctx context.Context, p Plugin, root string, opts Options) error {
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	req := Request{Root: root, Repository: root}
+	first, err := run(ctx, p, req, opts)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	fingerprints := make(map[string]bool)
+	for i, f := range first.Findings {
+		if err := f.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("finding %d: %w", i+1, err))
+			continue
+		}
+		if fingerprints[f.Fingerprint] {
+			errs = append(errs, fmt.Errorf("finding %d: fingerprint %q is not unique", i+1, f.Fingerprint))
+		}
+		fingerprints[f.Fingerprint] = true
+		if f.File != "" {
+			if _, err := os.Stat(filepath.Join(root, filepath.FromSlash(f.File))); err != nil {
+				errs = append(errs, fmt.Errorf("finding %d: file %q is not in the tree", i+1, f.File))
+			}
+		}
+	}
+
+	second, err := run(ctx, p, req, opts)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("second run: %w", err))
+	} else if !slices.Equal(first.Findings, second.Findings) {
+		errs = append(errs, errors.New("the second run reported different findings; findings must be deterministic"))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("plugin %s does not conform: %w", p.Name, errors.Join(errs...))
+	}
+	return nil
+}
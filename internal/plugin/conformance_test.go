@@ -0,0 +1,60 @@
+package plugin
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The sample plugins of testdata/plugins must conform on the fixture tree of
+// testdata/conformance.
+func TestSamplePluginsConform(t *testing.T) {
+	writeScript(t, t.TempDir(), "probe", "exit 0\n") // Skips without a shell
+	plugins, err := Discover(filepath.Join("testdata", "plugins"))
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	if len(plugins) == 0 {
+		t.Fatalf("Expected sample plugins in testdata/plugins")
+	}
+	root := filepath.Join("testdata", "conformance")
+	for _, p := range plugins {
+		if err := Conform(context.Background(), p, root, Options{}); err != nil {
+			t.Errorf("%v", err)
+		}
+	}
+
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		t.Fatalf("Failed to resolve the root: %v", err)
+	}
+	findings, _, err := Run(context.Background(), New(filepath.Join("testdata", "plugins", "no-panic.sh")), Request{Root: abs}, Options{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	// The two panics of lib.go; that of the test is not reported.
+	if len(findings) != 2 || findings[0].File != "lib/lib.go" || findings[0].Line != 6 || findings[1].Line != 14 {
+		t.Errorf("Expected the panics of lib/lib.go on lines 6 and 14, got %+v", findings)
+	}
+}
+
+func TestConformReportsViolations(t *testing.T) {
+	dir, root := t.TempDir(), t.TempDir()
+	p := New(writeScript(t, dir, "sloppy", `
+printf '{"findings": [
+  {"fingerprint": "same", "severity": "info", "message": "a", "file": "missing.go", "line": 1},
+  {"fingerprint": "same", "severity": "info", "message": "b"},
+  {"fingerprint": "run-%s", "severity": "info", "message": "c"}
+]}' "$(od -An -N4 -tu4 /dev/urandom | tr -d ' ')"
+`))
+	err := Conform(context.Background(), p, root, Options{})
+	if err == nil {
+		t.Fatalf("Expected the sloppy plugin not to conform")
+	}
+	for _, want := range []string{`file "missing.go" is not in the tree`, `fingerprint "same" is not unique`, "must be deterministic"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected %q among the violations, got %v", want, err)
+		}
+	}
+}
@@ -0,0 +1,311 @@
+// Package plugin runs external analyzer plugins: executables, written in any language, that
+// contribute checks zenwatch does not implement.
+//
+// A plugin is run with the root of the analyzed tree as its only argument and in that
+// directory. It reads a JSON Request from standard input and writes a JSON Response to
+// standard output, then exits 0. Anything it writes to standard error is only shown when it
+// fails. A plugin that exits non-zero, runs past its timeout, writes more than its output
+// limit or writes an invalid response contributes no findings; findings that are invalid
+// on their own are dropped. Either way the analysis goes on with a warning.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/user/zenwatch/internal/metrics"
+	"github.com/user/zenwatch/internal/warning"
+)
+
+// Protocol is the version of the protocol, sent in every Request. It changes when a change
+// would break existing plugins.
+const Protocol = 1
+
+// Defaults of Options.
+const (
+	DefaultTimeout   = time.Minute
+	DefaultMaxOutput = 4 << 20
+)
+
+// maxStderr is the number of bytes of standard error quoted when a plugin fails.
+const maxStderr = 512
+
+// Plugin is an analyzer executable.
+type Plugin struct {
+	Name string // The file name without extension; the Source of its findings
+	Path string
+}
+
+// Request describes the analyzed target to a plugin, on its standard input.
+type Request struct {
+	Protocol   int    `json:"protocol"`
+	Root       string `json:"root"`                 // Absolute path of the analyzed tree, also the first argument and working directory
+	Repository string `json:"repository,omitempty"` // URL or path the repository was analyzed from
+	Commit     string `json:"commit,omitempty"`     // Hash of the analyzed commit
+}
+
+// Response is what a plugin writes to its standard output.
+type Response struct {
+	Findings []Finding `json:"findings"`
+}
+
+// Finding is a finding as a plugin reports it.
+type Finding struct {
+	Fingerprint string `json:"fingerprint"` // Required: stable across runs for the same problem
+	Severity    string `json:"severity"`    // Required: error, warning or info
+	Message     string `json:"message"`     // Required
+	File        string `json:"file,omitempty"`
+	Line        int    `json:"line,omitempty"`
+}
+
+// Options limit the run of a plugin.
+type Options struct {
+	Timeout   time.Duration // 0 means DefaultTimeout
+	MaxOutput int64         // Bytes of standard output; 0 means DefaultMaxOutput
+}
+
+// Discover returns the plugins of dir: its executable regular files, except hidden ones,
+// sorted by name. Subdirectories are not searched, so a plugin can keep its support files
+// in one.
+func Discover(dir string) ([]Plugin, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugins directory: %w", err)
+	}
+	var plugins []Plugin
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") || !entry.Type().IsRegular() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat plugin: %w", err)
+		}
+		if info.Mode().Perm()&0o111 == 0 {
+			continue
+		}
+		plugins = append(plugins, New(filepath.Join(dir, entry.Name())))
+	}
+	return plugins, nil
+}
+
+// New returns the plugin of the executable at p.
+func New(p string) Plugin {
+	base := filepath.Base(p)
+	return Plugin{Name: strings.TrimSuffix(base, filepath.Ext(base)), Path: p}
+}
+
+// Collect returns the plugins of dir, if set, followed by the executables of paths. Two
+// plugins with the same name are an error, since their findings could not be told apart.
+func Collect(dir string, paths []string) ([]Plugin, error) {
+	var plugins []Plugin
+	if dir != "" {
+		var err error
+		if plugins, err = Discover(dir); err != nil {
+			return nil, err
+		}
+	}
+	for _, p := range paths {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to stat plugin: %w", err)
+		}
+		if !info.Mode().IsRegular() || info.Mode().Perm()&0o111 == 0 {
+			return nil, fmt.Errorf("plugin %s is not an executable file", p)
+		}
+		plugins = append(plugins, New(p))
+	}
+	seen := make(map[string]string)
+	for _, p := range plugins {
+		if other, ok := seen[p.Name]; ok {
+			return nil, fmt.Errorf("plugins %s and %s are both named %q", other, p.Path, p.Name)
+		}
+		seen[p.Name] = p.Path
+	}
+	return plugins, nil
+}
+
+// Run runs p with req and returns its findings, with a warning for every finding dropped as
+// invalid. A plugin that fails as a whole is returned as an error.
+func Run(ctx context.Context, p Plugin, req Request, opts Options) ([]metrics.CustomFinding, []warning.Warning, error) {
+	resp, err := run(ctx, p, req, opts)
+	if err != nil {
+		return nil, nil, fmt.Errorf("plugin %s: %w", p.Name, err)
+	}
+	var findings []metrics.CustomFinding
+	var warnings []warning.Warning
+	for i, f := range resp.Findings {
+		if err := f.validate(); err != nil {
+			warnings = append(warnings, warning.Warning{Code: warning.PluginFailed, Message: fmt.Sprintf("plugin %s: finding %d is dropped: %v", p.Name, i+1, err)})
+			continue
+		}
+		findings = append(findings, metrics.CustomFinding{
+			Source:      p.Name,
+			Fingerprint: f.Fingerprint,
+			Severity:    f.Severity,
+			Message:     f.Message,
+			File:        f.File,
+			Line:        f.Line,
+		})
+	}
+	return findings, warnings, nil
+}
+
+// RunAll runs every plugin in turn and returns their findings sorted by source, file and
+// line. Plugins that fail are reported as warnings.
+func RunAll(ctx context.Context, plugins []Plugin, req Request, opts Options) ([]metrics.CustomFinding, []warning.Warning) {
+	var findings []metrics.CustomFinding
+	var warnings []warning.Warning
+	for _, p := range plugins {
+		pluginFindings, pluginWarnings, err := Run(ctx, p, req, opts)
+		if err != nil {
+			warnings = append(warnings, warning.Warning{Code: warning.PluginFailed, Message: err.Error()})
+			continue
+		}
+		findings = append(findings, pluginFindings...)
+		warnings = append(warnings, pluginWarnings...)
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		a, b := findings[i], findings[j]
+		if a.Source != b.Source {
+			return a.Source < b.Source
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return findings, warnings
+}
+
+// run runs p and decodes its response.
+func run(ctx context.Context, p Plugin, req Request, opts Options) (*Response, error) {
+	timeout, maxOutput := opts.Timeout, opts.MaxOutput
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	if maxOutput <= 0 {
+		maxOutput = DefaultMaxOutput
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var err error
+	req.Protocol = Protocol
+	if req.Root, err = filepath.Abs(req.Root); err != nil {
+		return nil, err
+	}
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	// Relative paths would be resolved from the root the plugin runs in.
+	executable, err := filepath.Abs(p.Path)
+	if err != nil {
+		return nil, err
+	}
+	stdout := &cappedBuffer{max: maxOutput, overflow: cancel}
+	stderr := &cappedBuffer{max: maxStderr}
+	cmd := exec.CommandContext(ctx, executable, req.Root)
+	cmd.Dir = req.Root
+	cmd.Env = pluginEnv()
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// Children the plugin leaves behind could hold its output open past the timeout.
+	cmd.WaitDelay = time.Second
+	err = cmd.Run()
+	switch {
+	case stdout.overflowed:
+		return nil, fmt.Errorf("stopped after writing more than %d bytes", maxOutput)
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return nil, fmt.Errorf("timed out after %s", timeout)
+	case err != nil:
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) {
+			return nil, fmt.Errorf("failed to run: %w", err)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("exited with status %d: %s", exitErr.ExitCode(), msg)
+		}
+		return nil, fmt.Errorf("exited with status %d", exitErr.ExitCode())
+	}
+
+	var resp Response
+	dec := json.NewDecoder(bytes.NewReader(stdout.Bytes()))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&resp); err != nil {
+		return nil, fmt.Errorf("invalid response: %w", err)
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, errors.New("invalid response: unexpected data after the JSON object")
+	}
+	return &resp, nil
+}
+
+// pluginEnv returns the environment of a plugin: only the variables needed to run programs,
+// so the tokens zenwatch was given are not passed on.
+func pluginEnv() []string {
+	var env []string
+	for _, name := range []string{"PATH", "HOME", "TMPDIR", "LANG", "LC_ALL", "SYSTEMROOT"} {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return append(env, fmt.Sprintf("ZENWATCH_PLUGIN_PROTOCOL=%d", Protocol))
+}
+
+// validate returns an error if f misses a required field or points outside the tree.
+func (f Finding) validate() error {
+	switch {
+	case strings.TrimSpace(f.Fingerprint) == "":
+		return errors.New("no fingerprint")
+	case strings.TrimSpace(f.Message) == "":
+		return errors.New("no message")
+	case f.Severity != metrics.SeverityError && f.Severity != metrics.SeverityWarning && f.Severity != metrics.SeverityInfo:
+		return fmt.Errorf("severity %q is not error, warning or info", f.Severity)
+	case f.Line < 0:
+		return fmt.Errorf("negative line %d", f.Line)
+	case f.Line > 0 && f.File == "":
+		return errors.New("a line without a file")
+	case f.File != "" && (path.IsAbs(f.File) || path.Clean(f.File) != f.File || f.File == ".." || strings.HasPrefix(f.File, "../") || strings.Contains(f.File, `\`)):
+		return fmt.Errorf("file %q is not a clean slash-separated path relative to the root", f.File)
+	}
+	return nil
+}
+
+// cappedBuffer keeps up to max bytes. Past that, it calls overflow, if set, and fails the write.
+// The buffer is not embedded, so its ReadFrom cannot bypass the limit.
+type cappedBuffer struct {
+	buf        bytes.Buffer
+	max        int64
+	overflow   func()
+	overflowed bool
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - int64(b.buf.Len()); int64(len(p)) > room {
+		b.buf.Write(p[:max(room, 0)])
+		if b.overflow != nil {
+			b.overflowed = true
+			b.overflow()
+			return 0, errors.New("output limit exceeded")
+		}
+		return len(p), nil // Standard error is only quoted, so the rest is dropped
+	}
+	return b.buf.Write(p)
+}
+
+func (b *cappedBuffer) Bytes() []byte  { return b.buf.Bytes() }
+func (b *cappedBuffer) String() string { return b.buf.String() }
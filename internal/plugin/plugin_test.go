@@ -0,0 +1,138 @@
+package plugin
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/zenwatch/internal/metrics"
+	"github.com/user/zenwatch/internal/warning"
+)
+
+// writeScript writes a shell script plugin named name to dir.
+func writeScript(t *testing.T, dir, name, body string) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("Skipping: shell script plugins need a POSIX shell")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("Skipping: sh not on PATH")
+	}
+	p := filepath.Join(dir, name)
+	if err := os.WriteFile(p, []byte("#!/bin/sh\n"+body), 0o755); err != nil {
+		t.Fatalf("Failed to write plugin: %v", err)
+	}
+	return p
+}
+
+func TestDiscover(t *testing.T) {
+	dir := t.TempDir()
+	writeScript(t, dir, "b-check.sh", "exit 0\n")
+	writeScript(t, dir, "a-check", "exit 0\n")
+	writeScript(t, dir, ".hidden", "exit 0\n")
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Plugins\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write README: %v", err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "lib"), 0o755); err != nil {
+		t.Fatalf("Failed to create lib: %v", err)
+	}
+	writeScript(t, filepath.Join(dir, "lib"), "helper.sh", "exit 0\n")
+
+	plugins, err := Discover(dir)
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	var names []string
+	for _, p := range plugins {
+		names = append(names, p.Name)
+	}
+	if want := []string{"a-check", "b-check"}; !slices.Equal(names, want) {
+		t.Errorf("Expected plugins %v, got %v", want, names)
+	}
+
+	named := writeScript(t, t.TempDir(), "c-check.py", "exit 0\n")
+	if plugins, err := Collect(dir, []string{named}); err != nil || len(plugins) != 3 || plugins[2].Name != "c-check" {
+		t.Errorf("Expected the named plugin after the discovered ones, got %+v, %v", plugins, err)
+	}
+	duplicate := writeScript(t, t.TempDir(), "a-check.sh", "exit 0\n")
+	if _, err := Collect(dir, []string{duplicate}); err == nil || !strings.Contains(err.Error(), `both named "a-check"`) {
+		t.Errorf("Expected two plugins named a-check to be refused, got %v", err)
+	}
+	if _, err := Collect("", []string{filepath.Join(dir, "README.md")}); err == nil {
+		t.Errorf("Expected a file that is not executable to be refused")
+	}
+}
+
+func TestRun(t *testing.T) {
+	dir, root := t.TempDir(), t.TempDir()
+	t.Setenv("GITHUB_TOKEN", "secret")
+	// Echoes what it was given in the message of its first finding.
+	p := New(writeScript(t, dir, "echo.sh", `
+request=$(cat)
+case "$request" in *'"protocol":1'*) ;; *) exit 3 ;; esac
+printf '{"findings": [
+  {"fingerprint": "a", "severity": "error", "message": "%s %s token=%s", "file": "lib/lib.go", "line": 3},
+  {"fingerprint": "b", "severity": "fatal", "message": "unknown severity"},
+  {"fingerprint": "c", "severity": "info", "message": "outside", "file": "../etc/passwd"},
+  {"fingerprint": "d", "severity": "info", "message": "repository-wide"}
+]}' "$(basename "$1")" "$(basename "$PWD")" "$GITHUB_TOKEN"
+`))
+	findings, warnings, err := Run(context.Background(), p, Request{Root: root, Commit: "abc"}, Options{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	want := []metrics.CustomFinding{
+		{Source: "echo", Fingerprint: "a", Severity: metrics.SeverityError, Message: filepath.Base(root) + " " + filepath.Base(root) + " token=", File: "lib/lib.go", Line: 3},
+		{Source: "echo", Fingerprint: "d", Severity: metrics.SeverityInfo, Message: "repository-wide"},
+	}
+	if !slices.Equal(findings, want) {
+		t.Errorf("Expected %+v, got %+v", want, findings)
+	}
+	if len(warnings) != 2 || warnings[0].Code != warning.PluginFailed || !strings.Contains(warnings[0].Message, "finding 2") || !strings.Contains(warnings[1].Message, "../etc/passwd") {
+		t.Errorf("Expected the invalid findings 2 and 3 to be dropped with warnings, got %v", warnings)
+	}
+}
+
+func TestRunFailures(t *testing.T) {
+	dir, root := t.TempDir(), t.TempDir()
+	tests := []struct {
+		name, body string
+		opts       Options
+		want       string
+	}{
+		{"exit", "echo 'cannot parse config' >&2\nexit 2\n", Options{}, "exited with status 2: cannot parse config"},
+		{"timeout", "sleep 10\n", Options{Timeout: 100 * time.Millisecond}, "timed out after 100ms"},
+		{"flood", "while :; do echo 'xxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxxx'; done\n", Options{MaxOutput: 1024}, "more than 1024 bytes"},
+		{"garbage", "echo 'not json'\n", Options{}, "invalid response"},
+		{"unknown-field", `echo '{"findings": [], "exit": 1}'` + "\n", Options{}, "invalid response"},
+		{"trailing", `echo '{"findings": []} {}'` + "\n", Options{}, "unexpected data"},
+	}
+	var plugins []Plugin
+	for _, tt := range tests {
+		p := New(writeScript(t, dir, tt.name, tt.body))
+		plugins = append(plugins, p)
+		start := time.Now()
+		_, _, err := Run(context.Background(), p, Request{Root: root}, tt.opts)
+		if err == nil || !strings.Contains(err.Error(), "plugin "+tt.name+": ") || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: expected an error containing %q, got %v", tt.name, tt.want, err)
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("%s: expected the plugin to be stopped, it ran %s", tt.name, elapsed)
+		}
+	}
+
+	ok := New(writeScript(t, dir, "ok", `echo '{"findings": [{"fingerprint": "x", "severity": "info", "message": "fine"}]}'`+"\n"))
+	findings, warnings := RunAll(context.Background(), append(plugins[:1], ok), Request{Root: root}, Options{})
+	if len(findings) != 1 || findings[0].Source != "ok" {
+		t.Errorf("Expected the findings of the working plugin, got %+v", findings)
+	}
+	if len(warnings) != 1 || warnings[0].Code != warning.PluginFailed {
+		t.Errorf("Expected a warning for the failed plugin, got %v", warnings)
+	}
+}
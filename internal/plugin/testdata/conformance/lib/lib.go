@@ -0,0 +1,16 @@
+package lib
+
+// Must returns v, panicking if err is set.
+func Must(v int, err error) int {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// Check panics unless ok.
+func Check(ok bool) {
+	if !ok {
+		panic("check failed")
+	}
+}
@@ -0,0 +1,13 @@
+package lib
+
+import "testing"
+
+// The panic of a test is not reported.
+func TestCheck(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			panic("Check did not panic")
+		}
+	}()
+	Check(false)
+}
@@ -0,0 +1,73 @@
+// Package pushgateway pushes metrics to a Prometheus Pushgateway, for runs such as cron jobs
+// that end before Prometheus could scrape them.
+package pushgateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/user/zenwatch/internal/git"
+)
+
+// ContentType is the Prometheus text exposition format the pushed metrics are written in.
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Client pushes metrics to a Pushgateway.
+type Client struct {
+	URL  string // Base URL of the Pushgateway, e.g. http://pushgateway:9091
+	HTTP *http.Client
+}
+
+// NewClient returns a client of the Pushgateway at url.
+func NewClient(url string) *Client {
+	return &Client{URL: url, HTTP: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Push replaces the metrics of the group identified by job and instance with metrics, in the
+// text exposition format. Label values are base64-encoded in the URL, so they may contain
+// slashes, such as a repository URL.
+func (c *Client) Push(ctx context.Context, job, instance string, metrics []byte) error {
+	endpoint := fmt.Sprintf("%s/metrics/job@base64/%s/instance@base64/%s", strings.TrimRight(c.URL, "/"), encodeLabel(job), encodeLabel(instance))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint, bytes.NewReader(metrics))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", withoutURL(err))
+	}
+	req.Header.Set("Content-Type", ContentType)
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push metrics to %s: %w", git.RedactURL(c.URL), withoutURL(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("failed to push metrics to %s: %s %s", git.RedactURL(c.URL), resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// withoutURL returns the cause of a *url.Error, which quotes the request URL and so the
+// credentials it may hold.
+func withoutURL(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// encodeLabel encodes a grouping label value for the URL path. The Pushgateway
+// represents the empty value by a single "=".
+func encodeLabel(value string) string {
+	if value == "" {
+		return "="
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(value))
+}
@@ -0,0 +1,68 @@
+package pushgateway
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPush(t *testing.T) {
+	var method, path, contentType, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path, contentType = r.Method, r.URL.Path, r.Header.Get("Content-Type")
+		content, _ := io.ReadAll(r.Body)
+		body = string(content)
+	}))
+	defer server.Close()
+	client := &Client{URL: server.URL + "/", HTTP: server.Client()}
+
+	metrics := "# TYPE zenwatch_warnings gauge\nzenwatch_warnings 2\n"
+	if err := client.Push(context.Background(), "zenwatch", "https://github.com/user/repo", []byte(metrics)); err != nil {
+		t.Fatalf("Push failed: %v", err)
+	}
+	if method != http.MethodPut {
+		t.Errorf("Expected the group to be replaced with PUT, got %s", method)
+	}
+	if want := "/metrics/job@base64/emVud2F0Y2g/instance@base64/aHR0cHM6Ly9naXRodWIuY29tL3VzZXIvcmVwbw"; path != want {
+		t.Errorf("Unexpected path %s, want %s", path, want)
+	}
+	if contentType != ContentType || body != metrics {
+		t.Errorf("Unexpected push %q of %q", contentType, body)
+	}
+}
+
+func TestPushFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "pushed metrics are invalid", http.StatusBadRequest)
+	}))
+	defer server.Close()
+	client := &Client{URL: server.URL, HTTP: server.Client()}
+
+	err := client.Push(context.Background(), "zenwatch", "", nil)
+	if err == nil || !strings.Contains(err.Error(), "400") || !strings.Contains(err.Error(), "pushed metrics are invalid") {
+		t.Errorf("Expected the rejection to be reported, got %v", err)
+	}
+}
+
+func TestPushErrorsHideCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+	addr := strings.TrimPrefix(server.URL, "http://")
+	client := &Client{URL: "http://ci:s3cr3tpass@" + addr, HTTP: server.Client()}
+
+	err := client.Push(context.Background(), "zenwatch", "", nil)
+	if err == nil || !strings.Contains(err.Error(), "http://"+addr) || strings.Contains(err.Error(), "s3cr3tpass") {
+		t.Errorf("Expected the rejection without the credentials, got %v", err)
+	}
+
+	// Errors of the transport name the request URL too.
+	server.Close()
+	err = client.Push(context.Background(), "zenwatch", "", nil)
+	if err == nil || strings.Contains(err.Error(), "s3cr3tpass") || strings.Contains(err.Error(), "ci@") {
+		t.Errorf("Expected the connection error without the credentials, got %v", err)
+	}
+}
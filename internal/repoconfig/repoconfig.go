@@ -0,0 +1,252 @@
+// Package repoconfig reads the .zenwatch.yaml files committed in an analyzed repository. Each
+// adjusts the analysis of the directory it is in and everything below it, so a monorepo can be
+// strict in one subtree and lenient in another.
+//
+// The files come with the repository, not with the invocation, so they may only tune how its
+// code is measured: keys that would change where results are written or sent are not applied.
+package repoconfig
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/user/zenwatch/internal/metrics"
+	"github.com/user/zenwatch/internal/warning"
+)
+
+// FileName is the name of the config files, read from every directory of the analyzed tree.
+const FileName = ".zenwatch.yaml"
+
+// Keys a config file may set.
+const (
+	KeyComplexityThreshold = "complexity-threshold"
+	KeyExclude             = "exclude"
+	KeySuppress            = "suppress"
+)
+
+// allowedKeys are the only keys applied from a config file. Anything else, such as an output
+// path or a webhook URL, is ignored with a warning.
+var allowedKeys = []string{KeyComplexityThreshold, KeyExclude, KeySuppress}
+
+// Config is the part of one config file that can be applied.
+type Config struct {
+	Dir                 string   // Slash-separated directory of the file relative to the repository root, "." for the root
+	ComplexityThreshold int      // Complexity above which functions are reported; 0 if the file does not set it
+	Exclude             []string // Exclude patterns, relative to Dir
+	Suppress            []string // path.Match patterns of function names never reported over their threshold
+}
+
+// empty reports whether the config changes nothing.
+func (c *Config) empty() bool {
+	return c.ComplexityThreshold == 0 && len(c.Exclude) == 0 && len(c.Suppress) == 0
+}
+
+// contains reports whether file (slash-separated, relative to the repository root) is in
+// the subtree of the config.
+func (c *Config) contains(file string) bool {
+	return c.Dir == "." || strings.HasPrefix(file, c.Dir+"/")
+}
+
+// Tree holds the config files of a repository. The nil Tree has none.
+type Tree struct {
+	configs  []*Config         // Ordered by directory, so every config follows those of its ancestors
+	warnings []warning.Warning // Files and keys that were not applied
+}
+
+// Discover reads the config files under repoPath, except in the .git directory. Files and
+// keys that cannot be applied are left out and reported by Tree.Warnings, so a broken
+// config never fails the analysis. Configs without an applicable key are dropped.
+func Discover(repoPath string) (*Tree, error) {
+	t := &Tree{}
+	err := filepath.WalkDir(repoPath, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.Name() != FileName || !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(repoPath, p)
+		if err != nil {
+			return err
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", p, err)
+		}
+		c, warnings := Parse(content, path.Dir(filepath.ToSlash(rel)))
+		t.warnings = append(t.warnings, warnings...)
+		if c != nil && !c.empty() {
+			t.configs = append(t.configs, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to discover %s files: %w", FileName, err)
+	}
+	sort.SliceStable(t.configs, func(i, j int) bool { return depth(t.configs[i].Dir) < depth(t.configs[j].Dir) })
+	return t, nil
+}
+
+// depth returns the number of directories between the repository root and dir.
+func depth(dir string) int {
+	if dir == "." {
+		return 0
+	}
+	return strings.Count(dir, "/") + 1
+}
+
+// Parse parses the content of the config file in dir. Keys that are not allowed or have an
+// invalid value are left out of the config and returned as warnings; if the file is not
+// valid, the config is nil.
+func Parse(content []byte, dir string) (*Config, []warning.Warning) {
+	file := path.Join(dir, FileName)
+	entries, err := parseYAML(content)
+	if err != nil {
+		return nil, []warning.Warning{{Code: warning.ConfigIgnored, Message: fmt.Sprintf("%s is not applied: %v", FileName, err), File: file}}
+	}
+
+	c := &Config{Dir: dir}
+	var warnings []warning.Warning
+	ignore := func(e entry, format string, args ...any) {
+		warnings = append(warnings, warning.Warning{Code: warning.ConfigIgnored, Message: fmt.Sprintf("%s is not applied: %s", e.key, fmt.Sprintf(format, args...)), File: file, Line: e.line})
+	}
+	for _, e := range entries {
+		switch e.key {
+		case KeyComplexityThreshold:
+			n, err := strconv.Atoi(e.scalar())
+			if e.list || err != nil || n < 1 {
+				ignore(e, "expected a positive number")
+				continue
+			}
+			c.ComplexityThreshold = n
+		case KeyExclude:
+			if _, err := metrics.NewExcludeFilter(e.values); err != nil {
+				ignore(e, "%v", err)
+				continue
+			}
+			c.Exclude = e.values
+		case KeySuppress:
+			if i := slices.IndexFunc(e.values, func(p string) bool { _, err := path.Match(p, ""); return err != nil }); i >= 0 {
+				ignore(e, "invalid function name pattern %q", e.values[i])
+				continue
+			}
+			c.Suppress = e.values
+		default:
+			ignore(e, "only %s can be set in the repository", strings.Join(allowedKeys, ", "))
+		}
+	}
+	return c, warnings
+}
+
+// ancestors returns the configs whose subtree contains file, the root's first.
+func (t *Tree) ancestors(file string) []*Config {
+	if t == nil {
+		return nil
+	}
+	var configs []*Config
+	for _, c := range t.configs {
+		if c.contains(file) {
+			configs = append(configs, c)
+		}
+	}
+	return configs
+}
+
+// Threshold returns the complexity threshold of file: that of its nearest config setting one,
+// or fallback, the threshold of the invocation.
+func (t *Tree) Threshold(file string, fallback int) int {
+	threshold := fallback
+	for _, c := range t.ancestors(file) {
+		if c.ComplexityThreshold > 0 {
+			threshold = c.ComplexityThreshold
+		}
+	}
+	return threshold
+}
+
+// Suppressed reports whether a config of the function's file suppresses it. A pattern
+// matches a method by its qualified name, e.g. "(*T).Foo", or by its bare name, "Foo".
+func (t *Tree) Suppressed(cs metrics.ComplexityStat) bool {
+	bare := cs.FunctionName
+	if i := strings.LastIndexByte(bare, '.'); i >= 0 {
+		bare = bare[i+1:]
+	}
+	for _, c := range t.ancestors(cs.File) {
+		for _, p := range c.Suppress {
+			if ok, _ := path.Match(p, cs.FunctionName); ok {
+				return true
+			}
+			if ok, _ := path.Match(p, bare); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// OverThreshold returns the functions of stats over the threshold of their file, see
+// Threshold, that no config suppresses.
+func (t *Tree) OverThreshold(stats []metrics.ComplexityStat, fallback int) []metrics.ComplexityStat {
+	var over []metrics.ComplexityStat
+	for _, cs := range stats {
+		if cs.Complexity > t.Threshold(cs.File, fallback) && !t.Suppressed(cs) {
+			over = append(over, cs)
+		}
+	}
+	return over
+}
+
+// Exclude returns base, the exclude filter of the invocation, extended with the exclude
+// patterns of every config. Excludes only add up: a config cannot include a file its
+// ancestors or the invocation exclude.
+func (t *Tree) Exclude(base metrics.ExcludeFilter) (metrics.ExcludeFilter, error) {
+	if t == nil {
+		return base, nil
+	}
+	for _, c := range t.configs {
+		if len(c.Exclude) == 0 {
+			continue
+		}
+		var err error
+		if base, err = base.Under(c.Dir, c.Exclude); err != nil {
+			return metrics.ExcludeFilter{}, err
+		}
+	}
+	return base, nil
+}
+
+// Warnings returns the problems that kept config files or keys from being applied.
+func (t *Tree) Warnings() []warning.Warning {
+	if t == nil {
+		return nil
+	}
+	return t.warnings
+}
+
+// Overrides returns the directories below the root whose config file was applied.
+func (t *Tree) Overrides() []string {
+	if t == nil {
+		return nil
+	}
+	var dirs []string
+	for _, c := range t.configs {
+		if c.Dir != "." {
+			dirs = append(dirs, c.Dir)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
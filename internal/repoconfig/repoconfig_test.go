@@ -0,0 +1,184 @@
+package repoconfig
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/user/zenwatch/internal/metrics"
+	"github.com/user/zenwatch/internal/warning"
+)
+
+func writeFile(t *testing.T, root, rel, content string) {
+	t.Helper()
+	p := filepath.Join(root, rel)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		t.Fatalf("Failed to create directory: %v", err)
+	}
+	if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", rel, err)
+	}
+}
+
+func TestParseYAML(t *testing.T) {
+	content := "# Strict for the core\n" +
+		"---\n" +
+		"complexity-threshold: 10 # lower than the default\n" +
+		"exclude:\n" +
+		"  - gen/\n" +
+		"  - \"*.pb.go\"\n" +
+		"\n" +
+		"suppress: ['legacy#Parse', Handle]\n"
+	entries, err := parseYAML([]byte(content))
+	if err != nil {
+		t.Fatalf("parseYAML failed: %v", err)
+	}
+	expected := []entry{
+		{key: "complexity-threshold", values: []string{"10"}, line: 3},
+		{key: "exclude", values: []string{"gen/", "*.pb.go"}, list: true, line: 4},
+		{key: "suppress", values: []string{"legacy#Parse", "Handle"}, list: true, line: 8},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, entries)
+	}
+	for i, e := range expected {
+		got := entries[i]
+		if got.key != e.key || !slices.Equal(got.values, e.values) || got.list != e.list || got.line != e.line {
+			t.Errorf("Entry %d: expected %+v, got %+v", i, e, got)
+		}
+	}
+
+	for _, bad := range []string{
+		"exclude:\n  nested: true\n",
+		"  - orphan\n",
+		"threshold 10\n",
+		"exclude: [gen/\n",
+		"exclude: a\nexclude: b\n",
+	} {
+		if _, err := parseYAML([]byte(bad)); err == nil {
+			t.Errorf("Expected an error for %q", bad)
+		}
+	}
+}
+
+func TestParseAllowsOnlySafeKeys(t *testing.T) {
+	// A nested config trying to send the results elsewhere and overwrite a file.
+	content := "complexity-threshold: 30\n" +
+		"webhook: https://attacker.example/collect\n" +
+		"out: /etc/passwd\n" +
+		"pushgateway-url: http://attacker.example:9091\n" +
+		"exclude: [\"[unclosed\"]\n"
+	c, warnings := Parse([]byte(content), "experiments")
+	if c == nil || c.ComplexityThreshold != 30 || c.Exclude != nil || c.Suppress != nil {
+		t.Fatalf("Expected only the threshold to be applied, got %+v", c)
+	}
+	var ignored []string
+	for _, w := range warnings {
+		if w.Code != warning.ConfigIgnored || w.File != "experiments/.zenwatch.yaml" {
+			t.Errorf("Expected a %s warning on experiments/.zenwatch.yaml, got %+v", warning.ConfigIgnored, w)
+		}
+		ignored = append(ignored, strings.SplitN(w.Message, " ", 2)[0])
+	}
+	if want := []string{"webhook", "out", "pushgateway-url", "exclude"}; !slices.Equal(ignored, want) {
+		t.Errorf("Expected %v to be ignored, got %v", want, ignored)
+	}
+	if warnings[0].Line != 2 {
+		t.Errorf("Expected the webhook warning on line 2, got %d", warnings[0].Line)
+	}
+
+	for _, bad := range []string{"complexity-threshold: 0\n", "complexity-threshold: many\n", "complexity-threshold: [1]\n", "suppress: [\"[x\"]\n"} {
+		c, warnings := Parse([]byte(bad), ".")
+		if c == nil || !c.empty() || len(warnings) != 1 {
+			t.Errorf("Expected %q to be ignored with a warning, got %+v and %v", bad, c, warnings)
+		}
+	}
+	if c, warnings := Parse([]byte("exclude:\n  nested: true\n"), "."); c != nil || len(warnings) != 1 || warnings[0].File != FileName {
+		t.Errorf("Expected a malformed file to be ignored with a warning, got %+v and %v", c, warnings)
+	}
+}
+
+func TestDiscoverPrecedence(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, FileName, "complexity-threshold: 12\nexclude: [\"*.pb.go\"]\nsuppress: [Legacy*]\n")
+	writeFile(t, root, "core/"+FileName, "complexity-threshold: 5\n")
+	writeFile(t, root, "core/api/"+FileName, "exclude: [/gen]\n")
+	writeFile(t, root, "experiments/"+FileName, "complexity-threshold: 40\nsuppress: [Prototype, \"(\\\\*Lab).Run\"]\nwebhook: https://attacker.example\n")
+	writeFile(t, root, "docs/"+FileName, "# Nothing to apply\n")
+	writeFile(t, root, ".git/"+FileName, "complexity-threshold: 1\n")
+
+	tree, err := Discover(root)
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	if want := []string{"core", "core/api", "experiments"}; !slices.Equal(tree.Overrides(), want) {
+		t.Errorf("Expected overrides %v, got %v", want, tree.Overrides())
+	}
+	if len(tree.Warnings()) != 1 || tree.Warnings()[0].File != "experiments/"+FileName {
+		t.Errorf("Expected a warning for the webhook key only, got %v", tree.Warnings())
+	}
+
+	thresholds := []struct {
+		file string
+		want int
+	}{
+		{"main.go", 12},              // The root config over the invocation
+		{"core/core.go", 5},          // The nearest config over the root
+		{"core/api/api.go", 5},       // Inherited: core/api does not set a threshold
+		{"experiments/x/lab.go", 40}, // Inherited from experiments
+		{"corex/a.go", 12},           // Not below core
+		{"docs/tool.go", 12},         // docs has an empty config
+	}
+	for _, tt := range thresholds {
+		if got := tree.Threshold(tt.file, 15); got != tt.want {
+			t.Errorf("Threshold(%q) = %d, want %d", tt.file, got, tt.want)
+		}
+	}
+	var none *Tree
+	if got := none.Threshold("main.go", 15); got != 15 {
+		t.Errorf("Expected the invocation threshold without configs, got %d", got)
+	}
+
+	invocation, err := metrics.NewExcludeFilter([]string{"vendor/"})
+	if err != nil {
+		t.Fatalf("NewExcludeFilter failed: %v", err)
+	}
+	exclude, err := tree.Exclude(invocation)
+	if err != nil {
+		t.Fatalf("Exclude failed: %v", err)
+	}
+	excludes := []struct {
+		path string
+		want bool
+	}{
+		{"vendor/lib/lib.go", true},      // The invocation
+		{"core/api/svc.pb.go", true},     // The root, everywhere
+		{"core/api/gen/models.go", true}, // core/api, anchored at core/api
+		{"core/gen/models.go", false},    // ...and nowhere else
+		{"core/core.go", false},
+	}
+	for _, tt := range excludes {
+		if got := exclude.Excludes(tt.path); got != tt.want {
+			t.Errorf("Excludes(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+
+	stats := []metrics.ComplexityStat{
+		{FunctionName: "Run", File: "main.go", Complexity: 13},
+		{FunctionName: "LegacyRun", File: "main.go", Complexity: 50},             // Suppressed by the root
+		{FunctionName: "(*Server).LegacyServe", File: "main.go", Complexity: 50}, // ...by its bare name
+		{FunctionName: "(*Lab).Run", File: "experiments/lab.go", Complexity: 60}, // ...by its qualified name
+		{FunctionName: "Serve", File: "core/api/api.go", Complexity: 6},          // Over the threshold of core
+		{FunctionName: "Prototype", File: "experiments/lab.go", Complexity: 90},  // Suppressed by experiments
+		{FunctionName: "Prototype", File: "core/core.go", Complexity: 9},         // ...but not in core
+		{FunctionName: "Explore", File: "experiments/lab.go", Complexity: 35},
+	}
+	var over []string
+	for _, cs := range tree.OverThreshold(stats, 15) {
+		over = append(over, cs.File+":"+cs.FunctionName)
+	}
+	if want := []string{"main.go:Run", "core/api/api.go:Serve", "core/core.go:Prototype"}; !slices.Equal(over, want) {
+		t.Errorf("Expected %v over threshold, got %v", want, over)
+	}
+}
@@ -0,0 +1,133 @@
+package repoconfig
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// entry is a top-level key of a config file with its value.
+type entry struct {
+	key    string
+	values []string // The scalar value, or the items of a list
+	list   bool
+	line   int
+}
+
+// scalar returns the value of a scalar entry.
+func (e entry) scalar() string {
+	if len(e.values) == 0 {
+		return ""
+	}
+	return e.values[0]
+}
+
+// parseYAML parses the subset of YAML config files use: top-level "key: value" scalars and
+// lists of scalars, written either as "key: [a, b]" or as "key:" followed by indented
+// "- item" lines. Comments start with # at the beginning of a line or after a space.
+// Nested mappings, anchors and multi-line strings are not supported.
+func parseYAML(content []byte) ([]entry, error) {
+	var entries []entry
+	seen := make(map[string]bool)
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for n := 1; scanner.Scan(); n++ {
+		raw := stripComment(scanner.Text())
+		line := strings.TrimSpace(raw)
+		if line == "" || line == "---" {
+			continue
+		}
+		if raw[0] == ' ' || raw[0] == '\t' {
+			item, ok := strings.CutPrefix(line, "- ")
+			if !ok && line != "-" {
+				return nil, fmt.Errorf("line %d: nested mappings are not supported", n)
+			}
+			last := len(entries) - 1
+			if last < 0 || !entries[last].list {
+				return nil, fmt.Errorf("line %d: list item outside a list", n)
+			}
+			value, err := unquote(strings.TrimSpace(item))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			entries[last].values = append(entries[last].values, value)
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", n)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if seen[key] {
+			return nil, fmt.Errorf("line %d: duplicate key %q", n, key)
+		}
+		seen[key] = true
+		e := entry{key: key, line: n}
+		switch {
+		case value == "":
+			e.list = true // Items follow on the next lines
+		case strings.HasPrefix(value, "["):
+			inner, ok := strings.CutSuffix(value[1:], "]")
+			if !ok {
+				return nil, fmt.Errorf("line %d: unterminated list", n)
+			}
+			e.list = true
+			for _, item := range strings.Split(inner, ",") {
+				if item = strings.TrimSpace(item); item == "" {
+					continue
+				}
+				v, err := unquote(item)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", n, err)
+				}
+				e.values = append(e.values, v)
+			}
+		default:
+			v, err := unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			e.values = []string{v}
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
+
+// stripComment removes a trailing comment from line, leaving # inside quotes alone.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unquote returns the value of a plain, single-quoted or double-quoted scalar.
+func unquote(s string) (string, error) {
+	switch {
+	case len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"':
+		v, err := strconv.Unquote(s)
+		if err != nil {
+			return "", fmt.Errorf("invalid quoted string %s", s)
+		}
+		return v, nil
+	case len(s) >= 2 && s[0] == '\'' && s[len(s)-1] == '\'':
+		return strings.ReplaceAll(s[1:len(s)-1], "''", "'"), nil
+	}
+	return s, nil
+}
@@ -0,0 +1,178 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultBadgeBaseURL is the shields.io-compatible service used when no base URL is configured.
+const DefaultBadgeBaseURL = "https://img.shields.io"
+
+// BadgeOptions configures badge URL generation.
+type BadgeOptions struct {
+	BaseURL  string // Base URL of a shields.io-compatible service; empty means DefaultBadgeBaseURL
+	Baseline string // Prior report to show the complexity delta since, see LoadBaseline; empty shows the absolute badge
+}
+
+// Validate checks that BaseURL, if set, is an absolute http(s) URL.
+func (o BadgeOptions) Validate() error {
+	if o.BaseURL == "" {
+		return nil
+	}
+	u, err := url.Parse(o.BaseURL)
+	if err != nil {
+		return fmt.Errorf("invalid badge base URL %q: %w", o.BaseURL, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid badge base URL %q: must be an absolute http or https URL", o.BaseURL)
+	}
+	return nil
+}
+
+// Badge is the content of the ZenWatch status badge, independent of how it is rendered.
+type Badge struct {
+	Label   string `json:"label"`
+	Message string `json:"message"`
+	Color   string `json:"color"` // shields.io color name
+}
+
+// BuildBadge returns the badge for a commit's changed lines and average complexity.
+// Example: Total Changes: 150, Avg Complexity: 8.5
+func BuildBadge(totalChangedLines int, avgComplexity float64) Badge {
+	return Badge{
+		Label: "ZenWatch",
+		// Ensure avgComplexity is formatted nicely, e.g., "8.5" not "8.500000"
+		Message: fmt.Sprintf("changes %d | avg complx %.1f", totalChangedLines, avgComplexity),
+		Color:   "blue",
+	}
+}
+
+// BuildDeltaBadge returns the badge for the change of the average complexity since a baseline,
+// e.g. "complexity ▼0.8". The delta is rounded to the tenth shown: a decrease is green whatever
+// the complexity, an increase red, and a delta that rounds to zero is shown unchanged in blue.
+func BuildDeltaBadge(delta float64) Badge {
+	rounded := math.Round(delta*10) / 10
+	badge := Badge{Label: "ZenWatch", Message: "complexity ±0.0", Color: "blue"}
+	switch {
+	case rounded < 0:
+		badge.Message, badge.Color = fmt.Sprintf("complexity ▼%.1f", -rounded), "brightgreen"
+	case rounded > 0:
+		badge.Message, badge.Color = fmt.Sprintf("complexity ▲%.1f", rounded), "red"
+	}
+	return badge
+}
+
+// GenerateBadgeURL creates a URL for a shields.io badge showing BuildBadge's result.
+func GenerateBadgeURL(totalChangedLines int, avgComplexity float64, opts BadgeOptions) string {
+	return BadgeURL(BuildBadge(totalChangedLines, avgComplexity), opts)
+}
+
+// BadgeURL returns the URL of badge on the shields.io-compatible service of opts.
+func BadgeURL(badge Badge, opts BadgeOptions) string {
+	baseURL := opts.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultBadgeBaseURL
+	}
+	// Trim trailing slashes so base URLs with and without them produce the same badge URL.
+	return fmt.Sprintf("%s/badge/%s-%s-%s", strings.TrimRight(baseURL, "/"), badge.Label, url.PathEscape(badge.Message), badge.Color)
+}
+
+// ErrNoBaselineComplexity is returned by LoadBaseline for a file that is not a zenwatch report.
+var ErrNoBaselineComplexity = errors.New("no average complexity in the baseline report")
+
+// baselineComplexity matches the average complexity in the Summary of a Markdown report.
+var baselineComplexity = regexp.MustCompile(`\*\*Average Complexity \(of functions over threshold\):\*\* ([0-9]+\.[0-9]+)`)
+
+// LoadBaseline returns the average complexity of the prior report at path, a Markdown or a json
+// report. A missing file fails with an error wrapping fs.ErrNotExist, a Markdown file without
+// the Summary or a json report without stats with ErrNoBaselineComplexity.
+func LoadBaseline(path string) (float64, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read baseline: %w", err)
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(content), []byte("{")) {
+		var doc jsonReport
+		if err := json.Unmarshal(content, &doc); err != nil {
+			return 0, fmt.Errorf("failed to decode baseline %s: %w", path, err)
+		}
+		if doc.Stats == nil {
+			return 0, fmt.Errorf("baseline %s: %w", path, ErrNoBaselineComplexity)
+		}
+		return doc.Stats.AverageComplexity, nil
+	}
+	match := baselineComplexity.FindSubmatch(content)
+	if match == nil {
+		return 0, fmt.Errorf("baseline %s: %w", path, ErrNoBaselineComplexity)
+	}
+	return strconv.ParseFloat(string(match[1]), 64)
+}
+
+// badgeColors holds the hex value of every color name a badge is built with.
+var badgeColors = map[string]string{
+	"blue":        "#007ec6",
+	"brightgreen": "#4c1",
+	"red":         "#e05d44",
+}
+
+// badgeCharWidth approximates the width in pixels of a character of the 11px Verdana of badges.
+const badgeCharWidth = 7
+
+// WriteBadgeSVG writes badge to w as an SVG image in the flat style of shields.io, for READMEs
+// that cannot load images from a badge service.
+func WriteBadgeSVG(badge Badge, w io.Writer) error {
+	color, ok := badgeColors[badge.Color]
+	if !ok {
+		return fmt.Errorf("unknown badge color %q", badge.Color)
+	}
+	labelWidth := badgeCharWidth*utf8.RuneCountInString(badge.Label) + 10
+	messageWidth := badgeCharWidth*utf8.RuneCountInString(badge.Message) + 10
+	width := labelWidth + messageWidth
+	label, message := html.EscapeString(badge.Label), html.EscapeString(badge.Message)
+
+	_, err := fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="%[1]d" height="20" role="img" aria-label="%[4]s: %[5]s">
+<title>%[4]s: %[5]s</title>
+<linearGradient id="s" x2="0" y2="100%%"><stop offset="0" stop-color="#bbb" stop-opacity=".1"/><stop offset="1" stop-opacity=".1"/></linearGradient>
+<clipPath id="r"><rect width="%[1]d" height="20" rx="3" fill="#fff"/></clipPath>
+<g clip-path="url(#r)"><rect width="%[2]d" height="20" fill="#555"/><rect x="%[2]d" width="%[3]d" height="20" fill="%[6]s"/><rect width="%[1]d" height="20" fill="url(#s)"/></g>
+<g fill="#fff" text-anchor="middle" font-family="Verdana,Geneva,DejaVu Sans,sans-serif" font-size="11"><text x="%[7]d" y="14">%[4]s</text><text x="%[8]d" y="14">%[5]s</text></g>
+</svg>
+`, width, labelWidth, messageWidth, label, message, color, labelWidth/2, labelWidth+messageWidth/2)
+	if err != nil {
+		return fmt.Errorf("failed to write badge: %w: %w", ErrOutputWrite, err)
+	}
+	return nil
+}
+
+// GenerateBadgeSVG writes badge as an SVG image to outputPath, see WriteBadgeSVG.
+func GenerateBadgeSVG(badge Badge, outputPath string) error {
+	outputDir := filepath.Dir(outputPath)
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory %s: %w: %w", outputDir, ErrOutputWrite, err)
+	}
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create badge file %s: %w: %w", outputPath, ErrOutputWrite, err)
+	}
+	defer file.Close()
+
+	if err := WriteBadgeSVG(badge, file); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write badge file %s: %w: %w", outputPath, ErrOutputWrite, err)
+	}
+	return nil
+}
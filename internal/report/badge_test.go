@@ -0,0 +1,144 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateBadgeURLBaseURL(t *testing.T) {
+	const path = "/badge/ZenWatch-changes%20150%20%7C%20avg%20complx%208.5-blue"
+	tests := []struct {
+		baseURL  string
+		expected string
+	}{
+		{baseURL: "", expected: "https://img.shields.io" + path},
+		{baseURL: "https://badges.internal.example.com", expected: "https://badges.internal.example.com" + path},
+		{baseURL: "https://badges.internal.example.com/", expected: "https://badges.internal.example.com" + path},
+		{baseURL: "http://tools.example.com/shields", expected: "http://tools.example.com/shields" + path},
+		{baseURL: "http://tools.example.com/shields//", expected: "http://tools.example.com/shields" + path},
+	}
+	for _, tc := range tests {
+		opts := BadgeOptions{BaseURL: tc.baseURL}
+		if err := opts.Validate(); err != nil {
+			t.Fatalf("Validate(%q) failed: %v", tc.baseURL, err)
+		}
+		if got := GenerateBadgeURL(150, 8.5, opts); got != tc.expected {
+			t.Errorf("GenerateBadgeURL with base %q = %q, expected %q", tc.baseURL, got, tc.expected)
+		}
+	}
+}
+
+func TestBadgeOptionsValidateRejectsInvalidBaseURL(t *testing.T) {
+	for _, baseURL := range []string{"img.shields.io", "/badge", "ftp://badges.example.com", "https://", "://bad"} {
+		if err := (BadgeOptions{BaseURL: baseURL}).Validate(); err == nil {
+			t.Errorf("Expected Validate to reject base URL %q", baseURL)
+		}
+	}
+}
+
+func TestBuildBadgeMatchesURL(t *testing.T) {
+	badge := BuildBadge(150, 8.5)
+	if badge.Label != "ZenWatch" || badge.Message != "changes 150 | avg complx 8.5" || badge.Color != "blue" {
+		t.Errorf("Unexpected badge %+v", badge)
+	}
+
+	u, err := url.Parse(GenerateBadgeURL(150, 8.5, BadgeOptions{}))
+	if err != nil {
+		t.Fatalf("Failed to parse badge URL: %v", err)
+	}
+	parts := strings.Split(strings.TrimPrefix(u.Path, "/badge/"), "-")
+	if len(parts) != 3 {
+		t.Fatalf("Expected label-message-color path, got %q", u.Path)
+	}
+	if got := (Badge{Label: parts[0], Message: parts[1], Color: parts[2]}); got != badge {
+		t.Errorf("Badge URL encodes %+v, expected %+v", got, badge)
+	}
+}
+
+func TestBuildDeltaBadge(t *testing.T) {
+	tests := []struct {
+		delta   float64
+		message string
+		color   string
+	}{
+		{delta: -0.8, message: "complexity ▼0.8", color: "brightgreen"},
+		{delta: 1.3, message: "complexity ▲1.3", color: "red"},
+		{delta: 0, message: "complexity ±0.0", color: "blue"},
+		// Deltas that round to zero are unchanged, rather than a red ▲0.0 or a green ▼0.0.
+		{delta: 0.04, message: "complexity ±0.0", color: "blue"},
+		{delta: -0.04, message: "complexity ±0.0", color: "blue"},
+		{delta: 0.06, message: "complexity ▲0.1", color: "red"},
+		{delta: -0.06, message: "complexity ▼0.1", color: "brightgreen"},
+		// An improvement is green however complex the code still is.
+		{delta: -12.4, message: "complexity ▼12.4", color: "brightgreen"},
+	}
+	for _, tc := range tests {
+		badge := BuildDeltaBadge(tc.delta)
+		if badge.Label != "ZenWatch" || badge.Message != tc.message || badge.Color != tc.color {
+			t.Errorf("BuildDeltaBadge(%v) = %+v, expected message %q in %s", tc.delta, badge, tc.message, tc.color)
+		}
+	}
+
+	const expected = "https://img.shields.io/badge/ZenWatch-complexity%20%E2%96%BC0.8-brightgreen"
+	if got := BadgeURL(BuildDeltaBadge(-0.8), BadgeOptions{}); got != expected {
+		t.Errorf("BadgeURL = %q, expected %q", got, expected)
+	}
+}
+
+func TestWriteBadgeSVG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteBadgeSVG(BuildDeltaBadge(1.3), &buf); err != nil {
+		t.Fatalf("WriteBadgeSVG failed: %v", err)
+	}
+	svg := buf.String()
+	for _, part := range []string{"<svg ", ">ZenWatch</text>", ">complexity ▲1.3</text>", `fill="#e05d44"`} {
+		if !strings.Contains(svg, part) {
+			t.Errorf("Expected the SVG to contain %q, got:\n%s", part, svg)
+		}
+	}
+
+	if err := WriteBadgeSVG(Badge{Label: "ZenWatch", Message: "x", Color: "mauve"}, &buf); err == nil {
+		t.Errorf("Expected an error for an unknown color")
+	}
+}
+
+func TestLoadBaseline(t *testing.T) {
+	data := newTestReportData()
+	data.Stats.AverageComplexity = 7.5
+	dir := t.TempDir()
+
+	markdownPath := filepath.Join(dir, "report.md")
+	if err := GenerateMarkdownReport(data, markdownPath); err != nil {
+		t.Fatal(err)
+	}
+	jsonPath := filepath.Join(dir, "report.json")
+	if err := GenerateJSONReport(data, jsonPath); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{markdownPath, jsonPath} {
+		avg, err := LoadBaseline(path)
+		if err != nil {
+			t.Fatalf("LoadBaseline(%s) failed: %v", filepath.Base(path), err)
+		}
+		if avg != 7.5 {
+			t.Errorf("Expected the average complexity of %s, got %v", filepath.Base(path), avg)
+		}
+	}
+
+	notesPath := filepath.Join(dir, "notes.md")
+	if err := os.WriteFile(notesPath, []byte("# Notes\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadBaseline(notesPath); !errors.Is(err, ErrNoBaselineComplexity) {
+		t.Errorf("Expected ErrNoBaselineComplexity for a file that is not a report, got %v", err)
+	}
+	if _, err := LoadBaseline(filepath.Join(dir, "missing.md")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected a missing baseline to wrap fs.ErrNotExist, got %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/user/zenwatch/internal/metrics"
+)
+
+// Delimiters of the HTML comment embedding the stats in a Markdown report. Markdown
+// renderers hide the comment, so one file serves both readers and tools.
+const (
+	embeddedDataPrefix = "<!-- zenwatch-data: "
+	embeddedDataSuffix = " -->"
+)
+
+// ErrNoEmbeddedData is returned by ExtractEmbeddedData for a report written without --embed-data.
+var ErrNoEmbeddedData = errors.New("report has no zenwatch-data comment")
+
+// writeEmbeddedData writes stats as JSON in a zenwatch-data comment. encoding/json escapes
+// < and >, so the JSON cannot end the comment early.
+func writeEmbeddedData(w io.Writer, stats *metrics.OverallStats) error {
+	content, err := json.Marshal(stats)
+	if err != nil {
+		return fmt.Errorf("failed to encode embedded data: %w", err)
+	}
+	if _, err := fmt.Fprintf(w, "\n%s%s%s\n", embeddedDataPrefix, content, embeddedDataSuffix); err != nil {
+		return fmt.Errorf("%w: %w", ErrOutputWrite, err)
+	}
+	return nil
+}
+
+// ExtractEmbeddedData returns the stats embedded in a Markdown report written with EmbedData.
+func ExtractEmbeddedData(markdown []byte) (*metrics.OverallStats, error) {
+	start := bytes.LastIndex(markdown, []byte(embeddedDataPrefix))
+	if start < 0 {
+		return nil, ErrNoEmbeddedData
+	}
+	content := markdown[start+len(embeddedDataPrefix):]
+	end := bytes.Index(content, []byte(embeddedDataSuffix))
+	if end < 0 {
+		return nil, fmt.Errorf("zenwatch-data comment is not closed")
+	}
+	var stats metrics.OverallStats
+	if err := json.Unmarshal(content[:end], &stats); err != nil {
+		return nil, fmt.Errorf("failed to decode zenwatch-data comment: %w", err)
+	}
+	return &stats, nil
+}
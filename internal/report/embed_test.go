@@ -0,0 +1,45 @@
+package report
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/zenwatch/internal/metrics"
+	"github.com/user/zenwatch/internal/warning"
+)
+
+func TestEmbeddedData(t *testing.T) {
+	data := newTestReportData()
+	data.Stats.TotalLinesAdded = 42
+	data.Stats.FileStats[".go"] = &metrics.FileTypeStat{Extension: ".go", Count: 2, TotalBytes: 512, LinesAdded: 42}
+	data.Stats.FunctionsOverThreshold = 1
+	data.Stats.AverageComplexity = 21.5
+	data.Stats.ComplexityStats = []metrics.ComplexityStat{{Complexity: 21, FunctionName: "parse", File: "internal/p/p.go", Line: 7, EndLine: 60, Package: "p"}}
+	data.Stats.Freshness = &metrics.CodeFreshness{Window: 90 * 24 * time.Hour, AsOf: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), RecentLines: 3, TotalLines: 10}
+	// A message that would close the comment if it were not escaped.
+	data.Stats.Warnings = []warning.Warning{{Code: warning.UnparseableFile, Message: "expected '-->' here", File: "gen.go"}}
+
+	content := renderReport(t, data)
+	if strings.Contains(content, "zenwatch-data") {
+		t.Fatalf("Expected no embedded data without EmbedData")
+	}
+	if _, err := ExtractEmbeddedData([]byte(content)); !errors.Is(err, ErrNoEmbeddedData) {
+		t.Errorf("Expected ErrNoEmbeddedData, got %v", err)
+	}
+
+	data.Options.EmbedData = true
+	content = renderReport(t, data)
+	if !strings.HasSuffix(content, " -->\n") || strings.Count(content, "<!-- zenwatch-data: ") != 1 {
+		t.Fatalf("Expected the report to end with one zenwatch-data comment, got:\n%s", content)
+	}
+	stats, err := ExtractEmbeddedData([]byte(content))
+	if err != nil {
+		t.Fatalf("ExtractEmbeddedData failed: %v", err)
+	}
+	if !reflect.DeepEqual(stats, data.Stats) {
+		t.Errorf("Expected the embedded stats to parse back to\n%+v\ngot\n%+v", data.Stats, stats)
+	}
+}
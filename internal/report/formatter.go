@@ -0,0 +1,112 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// Formatter renders report data in an output format.
+type Formatter interface {
+	Format(data ReportData, w io.Writer) error
+}
+
+// FormatterFunc adapts a function to the Formatter interface.
+type FormatterFunc func(data ReportData, w io.Writer) error
+
+// Format calls f(data, w).
+func (f FormatterFunc) Format(data ReportData, w io.Writer) error {
+	return f(data, w)
+}
+
+// Classes of errors callers branch on, such as the CLI mapping them to exit statuses.
+// They are wrapped together with the underlying error, so errors.Is finds both.
+var (
+	ErrTemplateParse = errors.New("invalid report template")
+	ErrOutputWrite   = errors.New("cannot write report output")
+)
+
+// Names of the built-in formatters.
+const (
+	FormatMarkdown    = "markdown"
+	FormatJSON        = "json"
+	FormatHeatmapJSON = "heatmap-json"
+	FormatTreemapJSON = "treemap-json"
+)
+
+var (
+	formattersMu sync.RWMutex
+	formatters   = map[string]Formatter{
+		FormatMarkdown:    FormatterFunc(WriteMarkdownReport),
+		FormatJSON:        FormatterFunc(WriteJSONReport),
+		FormatHeatmapJSON: FormatterFunc(WriteHeatmapJSON),
+		FormatTreemapJSON: FormatterFunc(WriteTreemapJSON),
+	}
+)
+
+// RegisterFormatter makes f available under the format name, next to the built-in formatters.
+// Like database/sql.Register, it panics if name is empty, f is nil or name is already
+// registered; it is meant to be called from init functions.
+func RegisterFormatter(name string, f Formatter) {
+	formattersMu.Lock()
+	defer formattersMu.Unlock()
+	if name == "" || f == nil {
+		panic("report: RegisterFormatter needs a name and a formatter")
+	}
+	if _, dup := formatters[name]; dup {
+		panic("report: RegisterFormatter called twice for format " + name)
+	}
+	formatters[name] = f
+}
+
+// LookupFormatter returns the formatter registered under the format name.
+func LookupFormatter(name string) (Formatter, bool) {
+	formattersMu.RLock()
+	defer formattersMu.RUnlock()
+	f, ok := formatters[name]
+	return f, ok
+}
+
+// FormatterNames returns the names of the registered formatters, sorted.
+func FormatterNames() []string {
+	formattersMu.RLock()
+	defer formattersMu.RUnlock()
+	names := make([]string, 0, len(formatters))
+	for name := range formatters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// GenerateReport renders data with the formatter registered under format and writes it to
+// outputPath, creating its directory if needed.
+func GenerateReport(data ReportData, format, outputPath string) error {
+	f, ok := LookupFormatter(format)
+	if !ok {
+		return fmt.Errorf("unknown output format %q", format)
+	}
+
+	outputDir := filepath.Dir(outputPath)
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory %s: %w: %w", outputDir, ErrOutputWrite, err)
+	}
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create report file %s: %w: %w", outputPath, ErrOutputWrite, err)
+	}
+	defer file.Close()
+
+	if err := f.Format(data, file); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write report file %s: %w: %w", outputPath, ErrOutputWrite, err)
+	}
+	fmt.Printf("Report (%s) generated at %s\n", format, outputPath)
+	return nil
+}
@@ -0,0 +1,74 @@
+package report
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestRegisterFormatter(t *testing.T) {
+	RegisterFormatter("test-summary", FormatterFunc(func(data ReportData, w io.Writer) error {
+		_, err := fmt.Fprintf(w, "%s: %d over threshold\n", data.RepoURL, data.Stats.FunctionsOverThreshold)
+		return err
+	}))
+
+	names := FormatterNames()
+	for _, name := range []string{FormatMarkdown, FormatHeatmapJSON, "test-summary"} {
+		if !slices.Contains(names, name) {
+			t.Errorf("Expected %s among the formatters, got %v", name, names)
+		}
+	}
+
+	data := newTestReportData()
+	data.Stats.FunctionsOverThreshold = 3
+	outputPath := filepath.Join(t.TempDir(), "out", "summary.txt")
+	if err := GenerateReport(data, "test-summary", outputPath); err != nil {
+		t.Fatalf("GenerateReport failed: %v", err)
+	}
+	content, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read output: %v", err)
+	}
+	if want := "https://github.com/user/testrepo: 3 over threshold\n"; string(content) != want {
+		t.Errorf("Expected %q, got %q", want, content)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("Expected registering a format twice to panic")
+		}
+	}()
+	RegisterFormatter(FormatMarkdown, FormatterFunc(WriteMarkdownReport))
+}
+
+func TestGenerateReportUnknownFormat(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "report.txt")
+	if err := GenerateReport(newTestReportData(), "no-such-format", outputPath); err == nil {
+		t.Errorf("Expected an error for an unknown format")
+	}
+	if _, err := os.Stat(outputPath); err == nil {
+		t.Errorf("Expected no output file for an unknown format")
+	}
+}
+
+func TestGenerateReportOutputWriteError(t *testing.T) {
+	dir := t.TempDir()
+	blocker := filepath.Join(dir, "file")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := GenerateReport(newTestReportData(), FormatMarkdown, filepath.Join(blocker, "report.md"))
+	if !errors.Is(err, ErrOutputWrite) {
+		t.Errorf("Expected ErrOutputWrite when the output directory cannot be created, got %v", err)
+	}
+}
+
+func TestParseTemplateError(t *testing.T) {
+	if _, err := parseTemplate("broken", "{{if .Stats}}", nil); !errors.Is(err, ErrTemplateParse) {
+		t.Errorf("Expected ErrTemplateParse for an unterminated action, got %v", err)
+	}
+}
@@ -0,0 +1,160 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/user/zenwatch/internal/metrics"
+)
+
+// HeatmapSchemaVersion is the version of the heatmap JSON format.
+const HeatmapSchemaVersion = 1
+
+// DefaultHeatmapMaxNodes caps the number of directory nodes in a heatmap.
+const DefaultHeatmapMaxNodes = 500
+
+// Heatmap is the heatmap-json output: the directory rollup as a nested tree, suitable for
+// drawing a treemap sized by SLOC and colored by complexity.
+type Heatmap struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	RepoURL       string       `json:"repoURL"`
+	CommitHash    string       `json:"commitHash"`
+	MaxNodes      int          `json:"maxNodes"`
+	Root          *HeatmapNode `json:"root"`
+}
+
+// HeatmapNode is a directory and the totals of its whole subtree, pruned or not.
+type HeatmapNode struct {
+	Path          string         `json:"path"` // Slash-separated, "." for the root
+	Files         int            `json:"files"`
+	SLOC          int            `json:"sloc"`
+	AvgComplexity float64        `json:"avgComplexity"`
+	MaxComplexity int            `json:"maxComplexity"`
+	Churn         int            `json:"churn"`
+	Pruned        int            `json:"pruned,omitempty"` // Number of subdirectories aggregated into this node
+	Children      []*HeatmapNode `json:"children,omitempty"`
+}
+
+// BuildHeatmap converts a directory rollup into a heatmap tree of at most maxNodes nodes.
+// The rollup is already capped at its MaxDepth. Nodes are kept breadth-first in rollup order,
+// so shallow directories win over deep ones. When only some children of a directory fit, the
+// remaining ones are aggregated into a single "<dir>/(other)" node; when none fit, the
+// directory becomes a leaf. Either way the totals of the pruned subtrees stay in their ancestors.
+func BuildHeatmap(root *metrics.DirectoryStat, maxNodes int) *HeatmapNode {
+	if root == nil {
+		return nil
+	}
+	rootNode := newHeatmapNode(root)
+	budget := maxNodes - 1
+
+	type pending struct {
+		node *HeatmapNode
+		dir  *metrics.DirectoryStat
+	}
+	queue := []pending{{rootNode, root}}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		children := p.dir.Children
+		if len(children) == 0 {
+			continue
+		}
+
+		keep := len(children)
+		if keep > budget {
+			keep = max(budget-1, 0)
+		}
+		for _, child := range children[:keep] {
+			node := newHeatmapNode(child)
+			p.node.Children = append(p.node.Children, node)
+			queue = append(queue, pending{node, child})
+		}
+		budget -= keep
+
+		rest := children[keep:]
+		if len(rest) == 0 {
+			continue
+		}
+		if budget > 0 && keep > 0 {
+			p.node.Children = append(p.node.Children, aggregateHeatmapNodes(p.dir.Path, rest))
+			budget--
+		} else {
+			p.node.Pruned = countDirectories(children)
+			p.node.Children = nil
+		}
+	}
+	return rootNode
+}
+
+func newHeatmapNode(d *metrics.DirectoryStat) *HeatmapNode {
+	return &HeatmapNode{
+		Path:          d.Path,
+		Files:         d.Files,
+		SLOC:          d.SLOC,
+		AvgComplexity: d.AverageComplexity,
+		MaxComplexity: d.MaxComplexity,
+		Churn:         d.Churn,
+	}
+}
+
+// aggregateHeatmapNodes merges sibling directories into one node below parent.
+// The average complexity is weighted by the number of functions of each directory.
+func aggregateHeatmapNodes(parent string, dirs []*metrics.DirectoryStat) *HeatmapNode {
+	node := &HeatmapNode{Path: parent + "/(other)", Pruned: countDirectories(dirs)}
+	if parent == "." {
+		node.Path = "(other)"
+	}
+	functions := 0
+	totalComplexity := 0.0
+	for _, d := range dirs {
+		node.Files += d.Files
+		node.SLOC += d.SLOC
+		node.Churn += d.Churn
+		node.MaxComplexity = max(node.MaxComplexity, d.MaxComplexity)
+		functions += d.Functions
+		totalComplexity += d.AverageComplexity * float64(d.Functions)
+	}
+	if functions > 0 {
+		node.AvgComplexity = totalComplexity / float64(functions)
+	}
+	return node
+}
+
+// countDirectories returns the number of directories in dirs and all their descendants.
+func countDirectories(dirs []*metrics.DirectoryStat) int {
+	n := len(dirs)
+	for _, d := range dirs {
+		n += countDirectories(d.Children)
+	}
+	return n
+}
+
+// GenerateHeatmapJSON writes the heatmap of data's directory rollup, capped at maxNodes
+// nodes, as indented JSON to outputPath.
+func GenerateHeatmapJSON(data ReportData, maxNodes int, outputPath string) error {
+	data.Options.HeatmapMaxNodes = maxNodes
+	return GenerateReport(data, FormatHeatmapJSON, outputPath)
+}
+
+// WriteHeatmapJSON writes the heatmap of data's directory rollup as indented JSON to w,
+// capped at data.Options.HeatmapMaxNodes nodes. It is the heatmap-json Formatter.
+func WriteHeatmapJSON(data ReportData, w io.Writer) error {
+	maxNodes := data.Options.heatmapMaxNodes()
+	heatmap := Heatmap{SchemaVersion: HeatmapSchemaVersion, RepoURL: data.RepoURL, MaxNodes: maxNodes}
+	if data.Commit != nil {
+		heatmap.CommitHash = data.Commit.Hash
+	}
+	if data.Stats != nil {
+		heatmap.Root = BuildHeatmap(data.Stats.DirectoryRollup, maxNodes)
+	}
+
+	content, err := json.MarshalIndent(heatmap, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode heatmap: %w", err)
+	}
+	if _, err := w.Write(append(content, '\n')); err != nil {
+		return fmt.Errorf("failed to write heatmap: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,95 @@
+package report
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/user/zenwatch/internal/metrics"
+)
+
+var update = flag.Bool("update", false, "Rewrite the golden files in testdata")
+
+// heatmapRollup returns a rollup of 8 directories:
+//
+//	. ─┬─ internal ─┬─ git ── deep
+//	   │            ├─ report
+//	   │            └─ metrics
+//	   ├─ cmd
+//	   └─ docs
+func heatmapRollup() *metrics.DirectoryStat {
+	return &metrics.DirectoryStat{
+		Path: ".", Files: 12, SLOC: 900, Functions: 20, AverageComplexity: 5, MaxComplexity: 30, Churn: 40,
+		Children: []*metrics.DirectoryStat{
+			{Path: "internal", Files: 8, SLOC: 700, Functions: 16, AverageComplexity: 5.5, MaxComplexity: 30, Churn: 30,
+				Children: []*metrics.DirectoryStat{
+					{Path: "internal/git", Files: 3, SLOC: 300, Functions: 8, AverageComplexity: 7, MaxComplexity: 30, Churn: 20,
+						Children: []*metrics.DirectoryStat{{Path: "internal/git/deep", Files: 1, SLOC: 50, Functions: 2, AverageComplexity: 3, MaxComplexity: 4}}},
+					{Path: "internal/report", Files: 3, SLOC: 250, Functions: 6, AverageComplexity: 4, MaxComplexity: 9, Churn: 10},
+					{Path: "internal/metrics", Files: 2, SLOC: 150, Functions: 2, AverageComplexity: 4, MaxComplexity: 5},
+				}},
+			{Path: "cmd", Files: 2, SLOC: 150, Functions: 4, AverageComplexity: 3, MaxComplexity: 6, Churn: 10},
+			{Path: "docs", Files: 2, SLOC: 50},
+		},
+	}
+}
+
+func TestGenerateHeatmapJSONGolden(t *testing.T) {
+	data := newTestReportData()
+	data.Stats.DirectoryRollup = heatmapRollup()
+
+	// 6 nodes: the root, its 3 children, then internal/git and an aggregate of the other two
+	// internal directories; internal/git/deep no longer fits.
+	outputPath := filepath.Join(t.TempDir(), "heatmap.json")
+	if err := GenerateHeatmapJSON(data, 6, outputPath); err != nil {
+		t.Fatalf("GenerateHeatmapJSON failed: %v", err)
+	}
+	got, err := os.ReadFile(outputPath)
+	if err != nil {
+		t.Fatalf("Failed to read heatmap: %v", err)
+	}
+
+	golden := filepath.Join("testdata", "heatmap.golden.json")
+	if *update {
+		if err := os.WriteFile(golden, got, 0644); err != nil {
+			t.Fatalf("Failed to update golden file: %v", err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("Failed to read golden file (run with -update to create it): %v", err)
+	}
+	if string(got) != string(want) {
+		t.Errorf("Heatmap differs from %s (run with -update if intended):\n%s", golden, got)
+	}
+}
+
+func TestBuildHeatmapCaps(t *testing.T) {
+	rollup := heatmapRollup()
+	if n := countHeatmapNodes(BuildHeatmap(rollup, DefaultHeatmapMaxNodes)); n != 8 {
+		t.Errorf("Expected all 8 directories without pruning, got %d nodes", n)
+	}
+
+	for maxNodes := 1; maxNodes <= 8; maxNodes++ {
+		root := BuildHeatmap(rollup, maxNodes)
+		if n := countHeatmapNodes(root); n > maxNodes {
+			t.Errorf("maxNodes %d: got %d nodes", maxNodes, n)
+		}
+		if root.SLOC != 900 || root.Files != 12 {
+			t.Errorf("maxNodes %d: expected the root to keep the repository totals, got %+v", maxNodes, root)
+		}
+	}
+
+	if root := BuildHeatmap(rollup, 1); root.Pruned != 7 || root.Children != nil {
+		t.Errorf("Expected a single root node aggregating 7 directories, got %+v", root)
+	}
+}
+
+func countHeatmapNodes(node *HeatmapNode) int {
+	n := 1
+	for _, child := range node.Children {
+		n += countHeatmapNodes(child)
+	}
+	return n
+}
@@ -0,0 +1,219 @@
+package report
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/zenwatch/internal/metrics"
+)
+
+// IssueDraft is a refactoring ticket drafted from a function over the complexity threshold.
+type IssueDraft struct {
+	Fingerprint string // Identifies the finding across runs, see metrics.ComplexityStat.Fingerprint
+	Title       string
+	Labels      []string
+	Body        string // Markdown
+}
+
+// BuildIssueDrafts drafts an issue for each of the first limit functions over threshold in
+// data, most complex first. A limit of 0 drafts all of them. Excerpts in data.Stats.Excerpts
+// are included in the drafts of their functions.
+func BuildIssueDrafts(data ReportData, limit int) []IssueDraft {
+	findings := data.Stats.ComplexityStats
+	if limit > 0 && len(findings) > limit {
+		findings = findings[:limit]
+	}
+	drafts := make([]IssueDraft, 0, len(findings))
+	for _, cs := range findings {
+		name := qualifiedFunctionName(cs)
+		labels := []string{"refactoring", "complexity"}
+		if cs.Complexity > criticalComplexity(data.ComplexityThreshold) {
+			labels = append(labels, "severity:high")
+		} else {
+			labels = append(labels, "severity:medium")
+		}
+		drafts = append(drafts, IssueDraft{
+			Fingerprint: findingFingerprint(cs),
+			Title:       fmt.Sprintf("Refactor %s (complexity %d)", name, cs.Complexity),
+			Labels:      labels,
+			Body:        issueBody(data, cs, name),
+		})
+	}
+	return drafts
+}
+
+// qualifiedFunctionName returns the function name qualified by its package directory,
+// e.g. "pkg/foo.ParseThing", or by its package name for files at the repository root.
+func qualifiedFunctionName(cs metrics.ComplexityStat) string {
+	if dir := path.Dir(cs.File); dir != "." && dir != "" {
+		return dir + "." + cs.FunctionName
+	}
+	if cs.Package != "" {
+		return cs.Package + "." + cs.FunctionName
+	}
+	return cs.FunctionName
+}
+
+// findingFingerprint returns the fingerprint of a complexity finding. Stats built without
+// one, e.g. by hand, are identified by their file and function instead.
+func findingFingerprint(cs metrics.ComplexityStat) string {
+	if cs.Fingerprint != "" {
+		return cs.Fingerprint
+	}
+	sum := sha256.Sum256([]byte("complexity\x00" + cs.File + "\x00" + cs.FunctionName))
+	return hex.EncodeToString(sum[:8])
+}
+
+func issueBody(data ReportData, cs metrics.ComplexityStat, name string) string {
+	var b strings.Builder
+	location := fmt.Sprintf("%s:%d", cs.File, cs.Line)
+	if link := sourceLink(data, cs); link != "" {
+		location = fmt.Sprintf("[%s](%s)", location, link)
+	}
+	fmt.Fprintf(&b, "**Location:** %s\n\n", location)
+	fmt.Fprintf(&b, "`%s` has a cyclomatic complexity of %d, over the threshold of %d. "+
+		"Consider splitting it into smaller functions.\n\n", name, cs.Complexity, data.ComplexityThreshold)
+
+	b.WriteString("| Metric | Value |\n|--------|-------|\n")
+	fmt.Fprintf(&b, "| Cyclomatic Complexity | %d |\n", cs.Complexity)
+	fmt.Fprintf(&b, "| Threshold | %d |\n", data.ComplexityThreshold)
+	if cs.EndLine >= cs.Line && cs.Line > 0 {
+		fmt.Fprintf(&b, "| Lines | %d-%d (%d) |\n", cs.Line, cs.EndLine, cs.EndLine-cs.Line+1)
+	}
+	if cs.OwnedBy != "" {
+		fmt.Fprintf(&b, "| Owner | %s |\n", cs.OwnedBy)
+	}
+
+	for _, e := range data.Stats.Excerpts {
+		if e.File == cs.File && e.Line == cs.Line {
+			fmt.Fprintf(&b, "\n%s\n", codeBlock(e))
+			if e.Truncated {
+				b.WriteString("\n*Excerpt truncated.*\n")
+			}
+			break
+		}
+	}
+	if data.Commit != nil {
+		fmt.Fprintf(&b, "\n---\n*Drafted by zenwatch from commit %s.*\n", shortHash(data.Commit.Hash))
+	}
+	return b.String()
+}
+
+// sourceLink links to the function's first line on the web view of an http(s) repository
+// at the analyzed commit, in the blob/<commit>/<path>#L<line> form of GitHub and GitLab.
+// It returns "" for other repositories.
+func sourceLink(data ReportData, cs metrics.ComplexityStat) string {
+	if data.Commit == nil || data.Commit.Hash == "" ||
+		!(strings.HasPrefix(data.RepoURL, "https://") || strings.HasPrefix(data.RepoURL, "http://")) {
+		return ""
+	}
+	base := strings.TrimSuffix(strings.TrimSuffix(data.RepoURL, "/"), ".git")
+	return fmt.Sprintf("%s/blob/%s/%s#L%d", base, data.Commit.Hash, cs.File, cs.Line)
+}
+
+// Markdown renders the draft as a Markdown file with YAML front matter holding the title,
+// labels and fingerprint.
+func (d IssueDraft) Markdown() string {
+	return fmt.Sprintf("---\ntitle: %q\nlabels: [%s]\nfingerprint: %s\n---\n\n%s",
+		d.Title, strings.Join(d.Labels, ", "), d.Fingerprint, d.Body)
+}
+
+// fileName returns the name of the draft's file: a slug of its title and the start of its
+// fingerprint, which keeps names of same-named functions apart.
+func (d IssueDraft) fileName() string {
+	slug := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			return r
+		case r >= 'A' && r <= 'Z':
+			return r + 'a' - 'A'
+		default:
+			return '-'
+		}
+	}, strings.TrimPrefix(strings.SplitN(d.Title, " (", 2)[0], "Refactor "))
+	for strings.Contains(slug, "--") {
+		slug = strings.ReplaceAll(slug, "--", "-")
+	}
+	return strings.Trim(slug, "-") + "-" + d.Fingerprint[:8] + ".md"
+}
+
+// WriteIssueDrafts writes each draft as a Markdown file in dir, creating it if needed. Drafts
+// whose fingerprint is already in the front matter of a Markdown file in dir are skipped, so
+// repeated runs don't recreate tickets that were already drafted. It returns the paths written
+// and the number of drafts skipped.
+func WriteIssueDrafts(dir string, drafts []IssueDraft) ([]string, int, error) {
+	existing, err := existingFingerprints(dir)
+	if err != nil {
+		return nil, 0, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, 0, fmt.Errorf("failed to create issues directory: %w: %w", ErrOutputWrite, err)
+	}
+	var written []string
+	skipped := 0
+	for _, d := range drafts {
+		if existing[d.Fingerprint] {
+			skipped++
+			continue
+		}
+		p := filepath.Join(dir, d.fileName())
+		if err := os.WriteFile(p, []byte(d.Markdown()), 0644); err != nil {
+			return written, skipped, fmt.Errorf("failed to write issue draft %s: %w: %w", p, ErrOutputWrite, err)
+		}
+		existing[d.Fingerprint] = true
+		written = append(written, p)
+	}
+	return written, skipped, nil
+}
+
+// existingFingerprints returns the fingerprints in the front matter of the Markdown files in
+// dir. A missing dir has none.
+func existingFingerprints(dir string) (map[string]bool, error) {
+	fingerprints := make(map[string]bool)
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return fingerprints, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read issues directory: %w", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".md" {
+			continue
+		}
+		if fp := frontMatterFingerprint(filepath.Join(dir, entry.Name())); fp != "" {
+			fingerprints[fp] = true
+		}
+	}
+	return fingerprints, nil
+}
+
+// frontMatterFingerprint returns the fingerprint field of the file's front matter, or "" if
+// it has none or cannot be read.
+func frontMatterFingerprint(p string) string {
+	f, err := os.Open(p)
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "---" {
+		return ""
+	}
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "---" {
+			break
+		}
+		if value, ok := strings.CutPrefix(line, "fingerprint:"); ok {
+			return strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+	}
+	return ""
+}
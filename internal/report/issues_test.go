@@ -0,0 +1,95 @@
+package report
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/user/zenwatch/internal/metrics"
+)
+
+func issuesData() ReportData {
+	data := newTestReportData()
+	data.Stats.ComplexityStats = []metrics.ComplexityStat{
+		{Complexity: 34, Package: "foo", FunctionName: "ParseThing", File: "pkg/foo/parse.go", Line: 42, EndLine: 97},
+		{Complexity: 24, Package: "main", FunctionName: "run", File: "main.go", Line: 10, EndLine: 40},
+		{Complexity: 18, Package: "foo", FunctionName: "Other", File: "pkg/foo/other.go", Line: 5, EndLine: 30},
+	}
+	data.Stats.Excerpts = []metrics.CodeExcerpt{
+		{FunctionName: "ParseThing", File: "pkg/foo/parse.go", Line: 42, Language: "go", Code: "func ParseThing(s string) (Thing, error) {"},
+	}
+	return data
+}
+
+func TestBuildIssueDrafts(t *testing.T) {
+	drafts := BuildIssueDrafts(issuesData(), 2)
+	if len(drafts) != 2 {
+		t.Fatalf("Expected --issues-max to cap the drafts at 2, got %d", len(drafts))
+	}
+	d := drafts[0]
+	if d.Title != "Refactor pkg/foo.ParseThing (complexity 34)" {
+		t.Errorf("Unexpected title %q", d.Title)
+	}
+	if drafts[1].Title != "Refactor main.run (complexity 24)" {
+		t.Errorf("Expected root functions to be qualified by package name, got %q", drafts[1].Title)
+	}
+	for _, expected := range []string{
+		"**Location:** [pkg/foo/parse.go:42](https://github.com/user/testrepo/blob/a1b2c3d4e5f6/pkg/foo/parse.go#L42)",
+		"| Cyclomatic Complexity | 34 |",
+		"| Lines | 42-97 (56) |",
+		"```go\nfunc ParseThing(s string) (Thing, error) {\n```",
+	} {
+		if !strings.Contains(d.Body, expected) {
+			t.Errorf("Expected %q in body, got:\n%s", expected, d.Body)
+		}
+	}
+	if strings.Join(d.Labels, ",") != "refactoring,complexity,severity:high" {
+		t.Errorf("Unexpected labels %v", d.Labels)
+	}
+
+	// The fingerprint survives the function moving within its file.
+	moved := issuesData()
+	moved.Stats.ComplexityStats[0].Line, moved.Stats.ComplexityStats[0].EndLine = 92, 147
+	if fp := BuildIssueDrafts(moved, 1)[0].Fingerprint; fp != d.Fingerprint {
+		t.Errorf("Expected a stable fingerprint, got %s and %s", d.Fingerprint, fp)
+	}
+	// The fingerprint of the analysis is used when present.
+	moved.Stats.ComplexityStats[0].Fingerprint = "0123456789abcdef"
+	if fp := BuildIssueDrafts(moved, 1)[0].Fingerprint; fp != "0123456789abcdef" {
+		t.Errorf("Expected the stat's fingerprint, got %s", fp)
+	}
+}
+
+func TestWriteIssueDraftsDeduplicates(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "issues")
+	written, skipped, err := WriteIssueDrafts(dir, BuildIssueDrafts(issuesData(), 2))
+	if err != nil {
+		t.Fatalf("WriteIssueDrafts failed: %v", err)
+	}
+	if len(written) != 2 || skipped != 0 {
+		t.Fatalf("Expected 2 drafts written, got %v (skipped %d)", written, skipped)
+	}
+	content, err := os.ReadFile(written[0])
+	if err != nil {
+		t.Fatalf("Failed to read draft: %v", err)
+	}
+	if !strings.HasPrefix(string(content), "---\ntitle: \"Refactor pkg/foo.ParseThing (complexity 34)\"\nlabels: [refactoring, complexity, severity:high]\nfingerprint: ") {
+		t.Errorf("Unexpected front matter:\n%s", content)
+	}
+	if !strings.HasPrefix(filepath.Base(written[0]), "pkg-foo-parsething-") {
+		t.Errorf("Unexpected file name %s", written[0])
+	}
+
+	// A renamed draft is still recognized by its fingerprint, and only new findings are written.
+	if err := os.Rename(written[0], filepath.Join(dir, "ticket-123.md")); err != nil {
+		t.Fatal(err)
+	}
+	written, skipped, err = WriteIssueDrafts(dir, BuildIssueDrafts(issuesData(), 0))
+	if err != nil {
+		t.Fatalf("WriteIssueDrafts failed: %v", err)
+	}
+	if len(written) != 1 || skipped != 2 || !strings.HasPrefix(filepath.Base(written[0]), "pkg-foo-other-") {
+		t.Errorf("Expected only the new finding to be written, got %v (skipped %d)", written, skipped)
+	}
+}
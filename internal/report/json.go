@@ -0,0 +1,38 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// JSONSchemaVersion is the version of the json report format. Fields are named by their json
+// tags, which only change with it.
+const JSONSchemaVersion = 1
+
+// jsonReport is the document of the json format: the report data and the version of its schema.
+type jsonReport struct {
+	SchemaVersion int `json:"schemaVersion"`
+	ReportData
+}
+
+// GenerateJSONReport writes the analysis data as an indented JSON report to outputPath.
+func GenerateJSONReport(data ReportData, outputPath string) error {
+	return GenerateReport(data, FormatJSON, outputPath)
+}
+
+// WriteJSONReport writes data as indented JSON to w, with the same commit history order and
+// secret redaction as the Markdown report. It is the json Formatter.
+func WriteJSONReport(data ReportData, w io.Writer) error {
+	data.CommitHistory = sortCommitHistory(data.CommitHistory)
+	data.AnalysisConfig = RedactConfigSecrets(data.AnalysisConfig)
+
+	content, err := json.MarshalIndent(jsonReport{SchemaVersion: JSONSchemaVersion, ReportData: data}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode JSON report: %w", err)
+	}
+	if _, err := w.Write(append(content, '\n')); err != nil {
+		return fmt.Errorf("failed to write JSON report: %w: %w", ErrOutputWrite, err)
+	}
+	return nil
+}
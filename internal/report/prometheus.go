@@ -0,0 +1,48 @@
+package report
+
+import (
+	"fmt"
+	"io"
+)
+
+// gauge is a metric of the Prometheus exposition of a report.
+type gauge struct {
+	name  string
+	help  string
+	value float64
+}
+
+// WritePrometheusMetrics writes the headline metrics of the report as gauges in the
+// Prometheus text exposition format. The gauges carry no labels; the repository is
+// identified by the labels of the scrape target or Pushgateway group.
+func WritePrometheusMetrics(data ReportData, w io.Writer) error {
+	var gauges []gauge
+	if stats := data.Stats; stats != nil {
+		gauges = append(gauges,
+			gauge{"zenwatch_lines_added", "Lines added by the analyzed commit.", float64(stats.TotalLinesAdded)},
+			gauge{"zenwatch_lines_deleted", "Lines deleted by the analyzed commit.", float64(stats.TotalLinesDeleted)},
+			gauge{"zenwatch_functions_over_threshold", "Functions whose cyclomatic complexity is over the threshold.", float64(stats.FunctionsOverThreshold)},
+			gauge{"zenwatch_average_complexity", "Average cyclomatic complexity of the functions over the threshold.", stats.AverageComplexity},
+			gauge{"zenwatch_complexity_threshold", "Cyclomatic complexity above which functions are reported.", float64(data.ComplexityThreshold)},
+			gauge{"zenwatch_banned_imports", "Imports of banned packages.", float64(len(stats.BannedImports))},
+		)
+		if stats.Build != nil {
+			compiles := 0.0
+			if stats.Build.Compiles {
+				compiles = 1
+			}
+			gauges = append(gauges,
+				gauge{"zenwatch_build_compiles", "Whether the module compiles (1) or not (0).", compiles},
+				gauge{"zenwatch_vet_findings", "Findings of go vet.", float64(stats.Build.VetFindings)},
+			)
+		}
+	}
+	gauges = append(gauges, gauge{"zenwatch_warnings", "Problems that made the analysis less complete.", float64(len(data.Warnings))})
+
+	for _, g := range gauges {
+		if _, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s gauge\n%s %g\n", g.name, g.help, g.name, g.name, g.value); err != nil {
+			return fmt.Errorf("failed to write metrics: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,426 @@
+package report
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/user/zenwatch/internal/git"
+	"github.com/user/zenwatch/internal/metrics"
+	"github.com/user/zenwatch/internal/warning"
+)
+
+// RedactOptions selects which parts of a report are redacted before rendering.
+type RedactOptions struct {
+	Authors  bool // Replace author names and emails with stable pseudonyms
+	Paths    bool // Hash path segments beyond the top-level directory
+	Messages bool // Strip commit message bodies, keeping only the subject line
+	Secrets  bool // Mask string literals on code excerpt lines that mention a secret
+
+	// Key derives the pseudonyms and path hashes; a random key per report if empty. A fixed
+	// key makes them reproducible, and so correlatable across reports.
+	Key []byte
+}
+
+// Enabled reports whether any redaction is requested.
+func (o RedactOptions) Enabled() bool {
+	return o.Authors || o.Paths || o.Messages || o.Secrets
+}
+
+// ParseRedactOptions parses a comma-separated list such as "authors,paths,messages".
+// An empty string disables redaction.
+func ParseRedactOptions(s string) (RedactOptions, error) {
+	var opts RedactOptions
+	for _, field := range strings.Split(s, ",") {
+		switch strings.TrimSpace(field) {
+		case "":
+		case "authors":
+			opts.Authors = true
+		case "paths":
+			opts.Paths = true
+		case "messages":
+			opts.Messages = true
+		case "secrets":
+			opts.Secrets = true
+		default:
+			return RedactOptions{}, fmt.Errorf("unknown redact field %q (supported: authors, paths, messages, secrets)", field)
+		}
+	}
+	return opts, nil
+}
+
+// Redact returns a copy of data with the selected fields redacted; data itself is not modified.
+// Author pseudonyms (Author-1, Author-2, ...) and hashed paths are consistent within the
+// returned report, but are derived from a random per-call key, unless opts.Key is set, so
+// they cannot be reversed or correlated across reports.
+func Redact(data ReportData, opts RedactOptions) (ReportData, error) {
+	if !opts.Enabled() {
+		return data, nil
+	}
+
+	key := opts.Key
+	if len(key) == 0 {
+		key = make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			return ReportData{}, fmt.Errorf("failed to generate redaction key: %w", err)
+		}
+	}
+	r := &redactor{opts: opts, key: key, authors: make(map[string]string)}
+
+	if data.Commit != nil {
+		commit := r.commit(*data.Commit)
+		data.Commit = &commit
+	}
+
+	if data.CommitHistory != nil {
+		history := make([]CommitRowData, len(data.CommitHistory))
+		for i, row := range data.CommitHistory {
+			row.CommitInfo = r.commit(row.CommitInfo)
+			history[i] = row
+		}
+		data.CommitHistory = history
+	}
+
+	if data.TagRange != nil {
+		tagRange := *data.TagRange
+		tagRange.Commits = make([]git.CommitInfo, len(data.TagRange.Commits))
+		for i, c := range data.TagRange.Commits {
+			tagRange.Commits[i] = r.commit(c)
+		}
+		data.TagRange = &tagRange
+	}
+
+	if data.ShotgunCommits != nil {
+		shotgun := make([]git.CommitInfo, len(data.ShotgunCommits))
+		for i, c := range data.ShotgunCommits {
+			shotgun[i] = r.commit(c)
+		}
+		data.ShotgunCommits = shotgun
+	}
+	if data.Submodules != nil {
+		submodules := make([]git.Submodule, len(data.Submodules))
+		for i, sm := range data.Submodules {
+			if opts.Paths {
+				sm.Path = r.path(sm.Path)
+			}
+			if sm.LatestCommit != nil {
+				commit := r.commit(*sm.LatestCommit)
+				sm.LatestCommit = &commit
+			}
+			submodules[i] = sm
+		}
+		data.Submodules = submodules
+	}
+
+	if data.Stats != nil && opts.Authors && data.Stats.AnnotationAuthors != nil {
+		stats := *data.Stats
+		stats.AnnotationAuthors = make([]metrics.AuthorAnnotations, len(data.Stats.AnnotationAuthors))
+		for i, aa := range data.Stats.AnnotationAuthors {
+			aa.Author, aa.Email = r.author(aa.Author, aa.Email), ""
+			stats.AnnotationAuthors[i] = aa
+		}
+		data.Stats = &stats
+	}
+
+	if data.Stats != nil && opts.Authors && data.Stats.ComplexityOwnership != nil {
+		stats := *data.Stats
+		stats.ComplexityStats = make([]metrics.ComplexityStat, len(data.Stats.ComplexityStats))
+		for i, cs := range data.Stats.ComplexityStats {
+			if cs.OwnedBy != "" {
+				cs.OwnedBy, cs.OwnerEmail = r.author(cs.OwnedBy, cs.OwnerEmail), ""
+			}
+			stats.ComplexityStats[i] = cs
+		}
+		stats.ComplexityOwnership = make([]metrics.AuthorComplexity, len(data.Stats.ComplexityOwnership))
+		for i, ac := range data.Stats.ComplexityOwnership {
+			ac.Author, ac.Email = r.author(ac.Author, ac.Email), ""
+			stats.ComplexityOwnership[i] = ac
+		}
+		data.Stats = &stats
+	}
+
+	if data.Stats != nil && opts.Paths {
+		stats := *data.Stats
+		stats.ComplexityStats = make([]metrics.ComplexityStat, len(data.Stats.ComplexityStats))
+		for i, cs := range data.Stats.ComplexityStats {
+			cs.File = r.path(cs.File)
+			cs.Package = r.path(cs.Package)
+			stats.ComplexityStats[i] = cs
+		}
+		// Import paths name the module and its directories.
+		stats.PackageCoupling = make([]metrics.PackageCouplingStats, len(data.Stats.PackageCoupling))
+		for i, pc := range data.Stats.PackageCoupling {
+			pc.Package = r.path(pc.Package)
+			stats.PackageCoupling[i] = pc
+		}
+		stats.TestComplexityStats = make([]metrics.ComplexityStat, len(data.Stats.TestComplexityStats))
+		for i, cs := range data.Stats.TestComplexityStats {
+			cs.File = r.path(cs.File)
+			cs.Package = r.path(cs.Package)
+			stats.TestComplexityStats[i] = cs
+		}
+		stats.DirectoryOverrides = make([]string, len(data.Stats.DirectoryOverrides))
+		for i, dir := range data.Stats.DirectoryOverrides {
+			if strings.Contains(dir, "/") { // As in the rollup, top-level directories are kept
+				dir = r.path(dir)
+			}
+			stats.DirectoryOverrides[i] = dir
+		}
+		stats.BannedImports = make([]metrics.BannedImport, len(data.Stats.BannedImports))
+		for i, bi := range data.Stats.BannedImports {
+			bi.File = r.path(bi.File)
+			stats.BannedImports[i] = bi
+		}
+		stats.DirectoryRollup = r.rollup(stats.DirectoryRollup)
+		if dc := stats.DiffCoverage; dc != nil {
+			redacted := *dc
+			redacted.Profile = r.text(dc.Profile, "")
+			redacted.Files = make([]metrics.FileDiffCoverage, len(dc.Files))
+			for i, f := range dc.Files {
+				f.File = r.path(f.File)
+				redacted.Files[i] = f
+			}
+			stats.DiffCoverage = &redacted
+		}
+		if stats.CustomFindings != nil {
+			stats.CustomFindings = make([]metrics.CustomFinding, len(data.Stats.CustomFindings))
+			for i, f := range data.Stats.CustomFindings {
+				f.Message = r.text(f.Message, f.File)
+				f.Fingerprint = r.text(f.Fingerprint, f.File)
+				if f.File != "" {
+					f.File = r.path(f.File)
+				}
+				stats.CustomFindings[i] = f
+			}
+		}
+		if plan := stats.RefactoringPlan; plan != nil {
+			redacted := *plan
+			redacted.Items = make([]metrics.PlanItem, len(plan.Items))
+			for i, item := range plan.Items {
+				item.File = r.path(item.File)
+				item.Package = r.path(item.Package)
+				redacted.Items[i] = item
+			}
+			stats.RefactoringPlan = &redacted
+		}
+		stats.Packages = make([]metrics.PackageStat, len(data.Stats.Packages))
+		for i, p := range data.Stats.Packages {
+			if strings.Contains(p.Dir, "/") { // As in the rollup, top-level directories are kept
+				p.Dir = r.path(p.Dir)
+			}
+			p.Name = r.path(p.Name)
+			stats.Packages[i] = p
+		}
+		if stats.Excerpts != nil {
+			stats.Excerpts = make([]metrics.CodeExcerpt, len(data.Stats.Excerpts))
+			for i, e := range data.Stats.Excerpts {
+				e.File = r.path(e.File)
+				stats.Excerpts[i] = e
+			}
+		}
+		if stats.Build != nil {
+			// Compiler errors quote paths and source, so only the status is kept.
+			build := *stats.Build
+			build.Errors = nil
+			// Binaries are named after their package directory.
+			build.Binaries = make([]metrics.BinarySize, len(stats.Build.Binaries))
+			for i, b := range stats.Build.Binaries {
+				b.Name = r.hash(b.Name)
+				build.Binaries[i] = b
+			}
+			stats.Build = &build
+		}
+		stats.Phases = make([]metrics.PhaseStatus, len(data.Stats.Phases))
+		for i, p := range data.Stats.Phases {
+			p.Error = r.text(p.Error, "")
+			stats.Phases[i] = p
+		}
+		stats.Warnings = r.warnings(data.Stats.Warnings)
+		data.Stats = &stats
+	}
+
+	if data.Stats != nil && opts.Secrets && data.Stats.Excerpts != nil {
+		stats := *data.Stats
+		stats.Excerpts = make([]metrics.CodeExcerpt, len(data.Stats.Excerpts))
+		for i, e := range data.Stats.Excerpts {
+			e.Code = redactSecretLiterals(e.Code)
+			stats.Excerpts[i] = e
+		}
+		data.Stats = &stats
+	}
+
+	if data.ChangedFunctions != nil && opts.Paths {
+		changed := make([]git.ChangedFunction, len(data.ChangedFunctions))
+		for i, cf := range data.ChangedFunctions {
+			cf.File = r.path(cf.File)
+			changed[i] = cf
+		}
+		data.ChangedFunctions = changed
+	}
+
+	if opts.Paths {
+		data.Warnings = r.warnings(data.Warnings)
+	}
+
+	return data, nil
+}
+
+// secretCodeMarkers identify code lines likely to hold a secret, such as a hardcoded API key.
+var secretCodeMarkers = append([]string{"apikey", "api_key", "private_key", "privatekey"}, secretConfigMarkers...)
+
+// stringLiteralPattern matches Go interpreted and raw string literals on a single line.
+var stringLiteralPattern = regexp.MustCompile("\"(?:[^\"\\\\]|\\\\.)*\"|`[^`]*`")
+
+// redactSecretLiterals replaces the string literals on every line of code that mentions a secret.
+func redactSecretLiterals(code string) string {
+	lines := strings.Split(code, "\n")
+	for i, line := range lines {
+		lower := strings.ToLower(line)
+		for _, marker := range secretCodeMarkers {
+			if strings.Contains(lower, marker) {
+				lines[i] = stringLiteralPattern.ReplaceAllString(line, `"`+redactedValue+`"`)
+				break
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// redactor holds the state shared by all redactions of a single report.
+type redactor struct {
+	opts    RedactOptions
+	key     []byte
+	authors map[string]string // identity -> pseudonym
+}
+
+func (r *redactor) commit(c git.CommitInfo) git.CommitInfo {
+	if r.opts.Authors {
+		c.Author = r.author(c.Author, c.Email)
+		c.Message = r.messageIdentities(c.Message)
+		c.FullMessage = r.messageIdentities(c.FullMessage)
+		c.Email = "redacted"
+	}
+	if r.opts.Messages {
+		c.Message = strings.SplitN(c.Message, "\n", 2)[0]
+		c.FullMessage = c.Message
+	}
+	return c
+}
+
+// author returns the pseudonym for an identity, assigning the next free one on first use.
+func (r *redactor) author(name, email string) string {
+	identity := strings.ToLower(strings.TrimSpace(email))
+	if identity == "" {
+		identity = strings.TrimSpace(name)
+	}
+	if pseudonym, ok := r.authors[identity]; ok {
+		return pseudonym
+	}
+	pseudonym := fmt.Sprintf("Author-%d", len(r.authors)+1)
+	r.authors[identity] = pseudonym
+	return pseudonym
+}
+
+// identityTrailerPattern matches commit message trailers naming a person, such as
+// "Signed-off-by: Ada Lovelace <ada@example.com>" or "Co-authored-by: ...".
+var identityTrailerPattern = regexp.MustCompile(`(?m)^([A-Za-z-]+-by:[ \t]*)([^<\n]*?)[ \t]*<([^>\n]*)>[ \t]*$`)
+
+// messageIdentities replaces the people named in the trailers of a commit message with their
+// pseudonyms, so co-authors and reviewers are anonymized like commit authors.
+func (r *redactor) messageIdentities(message string) string {
+	return identityTrailerPattern.ReplaceAllStringFunc(message, func(trailer string) string {
+		m := identityTrailerPattern.FindStringSubmatch(trailer)
+		return m[1] + r.author(m[2], m[3])
+	})
+}
+
+// path keeps the top-level directory and replaces every deeper segment with a keyed hash.
+// The file extension is kept so file types remain recognizable.
+func (r *redactor) path(p string) string {
+	segments := strings.Split(path.Clean(p), "/")
+	for i := range segments {
+		if i == 0 && len(segments) > 1 {
+			continue
+		}
+		ext := ""
+		if i == len(segments)-1 {
+			ext = path.Ext(segments[i])
+		}
+		segments[i] = r.hash(segments[i]) + ext
+	}
+	return strings.Join(segments, "/")
+}
+
+// pathTokenPattern matches URLs, which are kept, and the slash-separated paths quoted in
+// messages, such as "first: internal/billing/blob.bin" or a parser's "/tmp/clone/a/b.go:3:1".
+var pathTokenPattern = regexp.MustCompile(`[A-Za-z][A-Za-z0-9+.-]*://\S*|[\w.-]*(?:/[\w.-]+)+`)
+
+// text redacts the paths quoted in a message: every occurrence of file, the path the message
+// is about, if set, or of its base name, and every slash-separated token naming a path.
+func (r *redactor) text(s, file string) string {
+	if file != "" {
+		redacted := r.path(file)
+		parts := strings.Split(s, file)
+		for i, part := range parts {
+			parts[i] = strings.ReplaceAll(r.text(part, ""), path.Base(file), path.Base(redacted))
+		}
+		return strings.Join(parts, redacted)
+	}
+	return pathTokenPattern.ReplaceAllStringFunc(s, func(token string) string {
+		trimmed := strings.TrimRight(token, ".") // A full stop ending the sentence
+		if strings.Contains(token, "://") || !strings.ContainsFunc(trimmed, unicode.IsLetter) {
+			return token
+		}
+		return r.path(trimmed) + token[len(trimmed):]
+	})
+}
+
+// warnings returns a copy of warnings with their files and the paths in their messages redacted.
+func (r *redactor) warnings(warnings []warning.Warning) []warning.Warning {
+	if warnings == nil {
+		return nil
+	}
+	redacted := make([]warning.Warning, len(warnings))
+	for i, w := range warnings {
+		w.Message = r.text(w.Message, w.File)
+		if w.File != "" {
+			w.File = r.path(w.File)
+		}
+		redacted[i] = w
+	}
+	return redacted
+}
+
+// rollup returns a copy of the directory tree with every directory and file path redacted.
+func (r *redactor) rollup(d *metrics.DirectoryStat) *metrics.DirectoryStat {
+	if d == nil {
+		return nil
+	}
+	redacted := *d
+	if strings.Contains(d.Path, "/") { // The root and top-level directories are kept, as for files
+		redacted.Path = r.path(d.Path)
+	}
+	redacted.Children = make([]*metrics.DirectoryStat, len(d.Children))
+	for i, child := range d.Children {
+		redacted.Children[i] = r.rollup(child)
+	}
+	if d.FileStats != nil {
+		redacted.FileStats = make([]metrics.FileSLOC, len(d.FileStats))
+		for i, file := range d.FileStats {
+			redacted.FileStats[i] = metrics.FileSLOC{Path: r.path(file.Path), SLOC: file.SLOC}
+		}
+	}
+	return &redacted
+}
+
+func (r *redactor) hash(s string) string {
+	mac := hmac.New(sha256.New, r.key)
+	mac.Write([]byte(s))
+	return hex.EncodeToString(mac.Sum(nil))[:8]
+}
@@ -0,0 +1,281 @@
+package report
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/user/zenwatch/internal/git"
+	"github.com/user/zenwatch/internal/metrics"
+	"github.com/user/zenwatch/internal/warning"
+)
+
+var emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]+`)
+
+func TestParseRedactOptions(t *testing.T) {
+	opts, err := ParseRedactOptions("authors, paths,messages")
+	if err != nil {
+		t.Fatalf("ParseRedactOptions failed: %v", err)
+	}
+	if !opts.Authors || !opts.Paths || !opts.Messages {
+		t.Errorf("Expected all redactions enabled, got %+v", opts)
+	}
+
+	if opts, err := ParseRedactOptions(""); err != nil || opts.Enabled() {
+		t.Errorf("Expected empty string to disable redaction, got %+v, %v", opts, err)
+	}
+
+	if _, err := ParseRedactOptions("authors,emails"); err == nil {
+		t.Errorf("Expected error for unknown redact field")
+	}
+}
+
+func TestRedactRemovesEmailsFromReport(t *testing.T) {
+	data := newTestReportData()
+	data.BadgeURL = GenerateBadgeURL(10, 20, BadgeOptions{})
+	data.ShowCommitHistory = true
+	data.CommitHistory = []CommitRowData{
+		{CommitInfo: git.CommitInfo{Hash: "aaa111", Author: "Jules Verne", Email: "jules@example.com"}},
+		{CommitInfo: git.CommitInfo{Hash: "bbb222", Author: "Ada Lovelace", Email: "ada@example.com"}},
+		{CommitInfo: git.CommitInfo{Hash: "ccc333", Author: "J. Verne", Email: "JULES@example.com"}},
+	}
+
+	redacted, err := Redact(data, RedactOptions{Authors: true, Paths: true, Messages: true})
+	if err != nil {
+		t.Fatalf("Redact failed: %v", err)
+	}
+
+	content := renderReport(t, redacted)
+	for _, artifact := range []string{content, redacted.BadgeURL} {
+		if emails := emailPattern.FindAllString(artifact, -1); len(emails) > 0 {
+			t.Errorf("Expected no email addresses in redacted output, found %v", emails)
+		}
+		for _, name := range []string{"Jules", "Verne", "Ada"} {
+			if strings.Contains(artifact, name) {
+				t.Errorf("Expected %q to be redacted from output", name)
+			}
+		}
+	}
+
+	// The same identity maps to the same pseudonym, in order of first appearance.
+	expectedAuthors := []string{"Author-1", "Author-2", "Author-1"}
+	for i, row := range redacted.CommitHistory {
+		if row.Author != expectedAuthors[i] {
+			t.Errorf("Row %d: expected author %s, got %s", i, expectedAuthors[i], row.Author)
+		}
+	}
+	if redacted.Commit.Author != "Author-1" {
+		t.Errorf("Expected latest commit author Author-1, got %s", redacted.Commit.Author)
+	}
+
+	// The input must not be modified.
+	if data.Commit.Email != "jules@example.com" {
+		t.Errorf("Redact modified its input")
+	}
+}
+
+func TestRedactPathsInEveryFormat(t *testing.T) {
+	const (
+		dir      = "secretteam/internalproj"
+		file     = dir + "/ledger.go"
+		testFile = dir + "/ledger_test.go"
+		blob     = dir + "/blob.bin"
+	)
+	data := newTestReportData()
+	data.IncludeTests = true
+	data.Stats.FunctionsOverThreshold = 1
+	data.Stats.ComplexityStats = []metrics.ComplexityStat{{Complexity: 20, Package: dir, FunctionName: "charge", File: file, Line: 42}}
+	data.Stats.TestFunctionsOverThreshold = 1
+	data.Stats.TestComplexityStats = []metrics.ComplexityStat{{Complexity: 20, Package: dir, FunctionName: "TestCharge", File: testFile, Line: 7, IsTest: true}}
+	data.Stats.PackageCoupling = []metrics.PackageCouplingStats{{Package: "github.com/acme/" + dir, EfferentCoupling: 1}}
+	data.Stats.Packages = []metrics.PackageStat{{Dir: dir, Name: "internalproj", Files: 1}}
+	data.Stats.BannedImports = []metrics.BannedImport{{File: file, Line: 3, ImportPath: "unsafe"}}
+	data.Stats.DirectoryOverrides = []string{dir}
+	data.Stats.DirectoryRollup = &metrics.DirectoryStat{Path: ".", Children: []*metrics.DirectoryStat{
+		{Path: "secretteam", Children: []*metrics.DirectoryStat{{Path: dir, SLOC: 10, FileStats: []metrics.FileSLOC{{Path: file, SLOC: 10}}}}},
+	}}
+	data.Stats.DiffCoverage = &metrics.DiffCoverage{Profile: dir + "/cover.out", Files: []metrics.FileDiffCoverage{{File: file, Coverable: 1}}, Coverable: 1}
+	data.Stats.CustomFindings = []metrics.CustomFinding{{Source: "lint", Severity: metrics.SeverityWarning, Message: "ledger.go shadows " + blob, File: file, Line: 5}}
+	data.Stats.RefactoringPlan = &metrics.RefactoringPlan{Items: []metrics.PlanItem{{FunctionName: "charge", Package: dir, File: file, Complexity: 20}}}
+	data.Stats.Excerpts = []metrics.CodeExcerpt{{FunctionName: "charge", File: file, Line: 42, Code: "func charge() {}"}}
+	data.Stats.Failed = []string{metrics.SectionBuild}
+	data.Stats.Phases = []metrics.PhaseStatus{{Section: metrics.SectionBuild, Status: metrics.PhaseFailed, Error: "open /tmp/clone/" + file + ": permission denied"}}
+	data.Stats.Warnings = []warning.Warning{
+		{Code: warning.UnparseableFile, Message: "file skipped: " + file + ":3:1: expected 'package'", File: file},
+		{Code: warning.PhaseFailed, Message: "Build Check failed, so it is left out of the report: open /tmp/clone/" + file + ": permission denied"},
+	}
+	data.Warnings = []warning.Warning{{Code: warning.BinaryFilesSkipped, Message: "1 binary file is not counted, first: " + blob + "."}}
+	data.ChangedFunctions = []git.ChangedFunction{{Name: "charge", File: file, Change: git.FunctionModified, LinesTouched: 2}}
+	data.Submodules = []git.Submodule{{Path: dir + "/vendored", Commit: "abc123", Depth: 1}}
+
+	redacted, err := Redact(data, RedactOptions{Paths: true})
+	if err != nil {
+		t.Fatalf("Redact failed: %v", err)
+	}
+	for _, name := range FormatterNames() {
+		f, _ := LookupFormatter(name)
+		var buf bytes.Buffer
+		if err := f.Format(redacted, &buf); err != nil {
+			t.Fatalf("Format %s failed: %v", name, err)
+		}
+		// The top-level directory is kept by design; nothing below it may appear.
+		for _, raw := range []string{"internalproj", "ledger", "blob", "cover.out", "vendored"} {
+			if strings.Contains(buf.String(), raw) {
+				t.Errorf("Expected %q to be redacted from the %s format, got:\n%s", raw, name, buf.String())
+			}
+		}
+	}
+
+	// Paths hash the same wherever they appear, so warnings still point at the redacted file.
+	want := redacted.Stats.ComplexityStats[0].File
+	if !regexp.MustCompile(`^secretteam/[0-9a-f]{8}/[0-9a-f]{8}\.go$`).MatchString(want) {
+		t.Errorf("Expected hashed path below top-level directory, got %s", want)
+	}
+	if w := redacted.Stats.Warnings[0]; w.File != want || !strings.Contains(w.Message, want+":3:1") {
+		t.Errorf("Expected the warning to name %s, got %+v", want, w)
+	}
+	if msg := redacted.Warnings[0].Message; !strings.HasSuffix(msg, ".bin.") {
+		t.Errorf("Expected the sentence to keep its full stop after the hashed path, got %q", msg)
+	}
+
+	if data.Stats.TestComplexityStats[0].File != testFile || data.Warnings[0].Message != "1 binary file is not counted, first: "+blob+"." {
+		t.Errorf("Redact modified its input")
+	}
+}
+
+func TestRedactComplexityOwnership(t *testing.T) {
+	data := newTestReportData()
+	// The owner is the author of the latest commit under another spelling of their name.
+	data.Stats.ComplexityStats = []metrics.ComplexityStat{{Complexity: 20, FunctionName: "charge", OwnedBy: "J. Verne", OwnerEmail: "Jules@example.com"}}
+	data.Stats.ComplexityOwnership = []metrics.AuthorComplexity{{Author: "J. Verne", Email: "Jules@example.com", Functions: 1, TotalComplexity: 20}}
+	data.Stats.AnnotationAuthors = []metrics.AuthorAnnotations{{Author: "J. Verne", Email: "Jules@example.com", TODO: 2}}
+
+	redacted, err := Redact(data, RedactOptions{Authors: true})
+	if err != nil {
+		t.Fatalf("Redact failed: %v", err)
+	}
+	if content := renderReport(t, redacted); strings.Contains(content, "Verne") {
+		t.Errorf("Expected owners to be redacted, got:\n%s", content)
+	}
+	var buf bytes.Buffer
+	if err := WriteJSONReport(redacted, &buf); err != nil {
+		t.Fatalf("WriteJSONReport failed: %v", err)
+	}
+	if emails := emailPattern.FindAllString(buf.String(), -1); len(emails) > 0 {
+		t.Errorf("Expected no email addresses in the redacted JSON report, found %v", emails)
+	}
+	owner := redacted.Stats.ComplexityOwnership[0].Author
+	if owner != redacted.Commit.Author || redacted.Stats.ComplexityStats[0].OwnedBy != owner {
+		t.Errorf("Expected the commit author's pseudonym %q for the owner everywhere, got %q and %q",
+			redacted.Commit.Author, owner, redacted.Stats.ComplexityStats[0].OwnedBy)
+	}
+	if redacted.Stats.AnnotationAuthors[0].Author != owner {
+		t.Errorf("Expected annotation authors to share the owner's pseudonym %q, got %q", owner, redacted.Stats.AnnotationAuthors[0].Author)
+	}
+	if data.Stats.ComplexityOwnership[0].Author != "J. Verne" {
+		t.Errorf("Redact modified its input")
+	}
+}
+
+func TestRedactSecretsInExcerpts(t *testing.T) {
+	data := newTestReportData()
+	data.Stats.Excerpts = []metrics.CodeExcerpt{{
+		FunctionName: "connect", File: "internal/db/connect.go", Line: 3, Language: "go",
+		Code: "func connect() {\n\tpassword := \"hunter2\"\n\tapiKey := `sk-live-123`\n\tdsn := \"postgres://db\"\n}",
+	}}
+
+	redacted, err := Redact(data, RedactOptions{Secrets: true, Paths: true})
+	if err != nil {
+		t.Fatalf("Redact failed: %v", err)
+	}
+	excerpt := redacted.Stats.Excerpts[0]
+	for _, secret := range []string{"hunter2", "sk-live-123"} {
+		if strings.Contains(excerpt.Code, secret) {
+			t.Errorf("Expected %q to be redacted from the excerpt, got:\n%s", secret, excerpt.Code)
+		}
+	}
+	if !strings.Contains(excerpt.Code, "password := \"[REDACTED]\"") || !strings.Contains(excerpt.Code, "dsn := \"postgres://db\"") {
+		t.Errorf("Expected only literals on secret lines to be masked, got:\n%s", excerpt.Code)
+	}
+	if strings.Contains(excerpt.File, "connect") {
+		t.Errorf("Expected the excerpt path to be redacted, got %s", excerpt.File)
+	}
+	if !strings.Contains(data.Stats.Excerpts[0].Code, "hunter2") {
+		t.Errorf("Redact modified its input")
+	}
+}
+
+func TestRedactDirectoryRollup(t *testing.T) {
+	data := newTestReportData()
+	data.Stats.DirectoryRollup = &metrics.DirectoryStat{Path: ".", Children: []*metrics.DirectoryStat{
+		{Path: "internal", Children: []*metrics.DirectoryStat{{Path: "internal/acme_billing", SLOC: 10}}},
+	}}
+
+	redacted, err := Redact(data, RedactOptions{Paths: true})
+	if err != nil {
+		t.Fatalf("Redact failed: %v", err)
+	}
+	root := redacted.Stats.DirectoryRollup
+	if root.Path != "." || root.Children[0].Path != "internal" {
+		t.Errorf("Expected the root and top-level directories to be kept, got %q and %q", root.Path, root.Children[0].Path)
+	}
+	if leaf := root.Children[0].Children[0]; strings.Contains(leaf.Path, "acme") || leaf.SLOC != 10 {
+		t.Errorf("Expected a hashed directory with its metrics, got %+v", leaf)
+	}
+	if data.Stats.DirectoryRollup.Children[0].Children[0].Path != "internal/acme_billing" {
+		t.Errorf("Redact modified its input")
+	}
+}
+
+func TestRedactKey(t *testing.T) {
+	data := newTestReportData()
+	data.Stats.DirectoryRollup = &metrics.DirectoryStat{Path: ".", Children: []*metrics.DirectoryStat{
+		{Path: "internal", Children: []*metrics.DirectoryStat{{Path: "internal/acme_billing"}}},
+	}}
+	hashed := func(opts RedactOptions) string {
+		t.Helper()
+		redacted, err := Redact(data, opts)
+		if err != nil {
+			t.Fatalf("Redact failed: %v", err)
+		}
+		return redacted.Stats.DirectoryRollup.Children[0].Children[0].Path
+	}
+
+	keyed := RedactOptions{Paths: true, Key: []byte("fixed")}
+	if a, b := hashed(keyed), hashed(keyed); a != b {
+		t.Errorf("Expected a fixed key to hash paths the same, got %q and %q", a, b)
+	}
+	if a, b := hashed(RedactOptions{Paths: true}), hashed(RedactOptions{Paths: true}); a == b {
+		t.Errorf("Expected random keys to hash paths differently, got %q twice", a)
+	}
+}
+
+func TestRedactAuthorsInMessageTrailers(t *testing.T) {
+	data := newTestReportData()
+	data.Commit = &git.CommitInfo{
+		Hash:        "aaa111",
+		Author:      "Jules Verne",
+		Email:       "jules@example.com",
+		Message:     "Add the submarine",
+		FullMessage: "Add the submarine\n\nCo-authored-by: Ada Lovelace <ada@example.com>\nSigned-off-by: Jules Verne <jules@example.com>",
+	}
+	data.ShotgunCommits = []git.CommitInfo{{Hash: "bbb222", Author: "Ada Lovelace", Email: "ADA@example.com"}}
+
+	redacted, err := Redact(data, RedactOptions{Authors: true})
+	if err != nil {
+		t.Fatalf("Redact failed: %v", err)
+	}
+	want := "Add the submarine\n\nCo-authored-by: Author-2\nSigned-off-by: Author-1"
+	if redacted.Commit.Author != "Author-1" || redacted.Commit.FullMessage != want {
+		t.Errorf("Expected trailers to use the pseudonyms, got %s and %q", redacted.Commit.Author, redacted.Commit.FullMessage)
+	}
+	if redacted.ShotgunCommits[0].Author != "Author-2" {
+		t.Errorf("Expected the co-author to keep their pseudonym across the report, got %s", redacted.ShotgunCommits[0].Author)
+	}
+	for _, name := range []string{"Jules", "Verne", "Ada", "Lovelace", "@example.com"} {
+		if strings.Contains(redacted.Commit.FullMessage+renderReport(t, redacted), name) {
+			t.Errorf("Expected %q not to leak", name)
+		}
+	}
+}
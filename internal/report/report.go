@@ -3,12 +3,17 @@ package report
 import (
 	"fmt"
 	"html/template" // Using html/template for Markdown to be safe, though text/template is often fine for MD
-	"os"
-	"path/filepath"
+	"io"
+	"math"
+	"path"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/user/zenwatch/internal/commitlint"
 	"github.com/user/zenwatch/internal/git"
 	"github.com/user/zenwatch/internal/metrics"
+	"github.com/user/zenwatch/internal/warning"
 )
 
 const markdownTemplate = `
@@ -16,31 +21,178 @@ const markdownTemplate = `
 
 **Repository:** {{.RepoURL}}
 **Analyzed At:** {{.ReportDate}}
+{{- if .LanguageFilter}}
+**Languages:** {{.LanguageFilter}} (other files are not analyzed)
+{{- end}}
 
 {{if .BadgeURL}}
 ![ZenWatch Stats]({{.BadgeURL}})
 {{end}}
+{{- with .Stats}}{{if .Failed}}
+> ❌ **Partial analysis:** some analyses failed, so their sections are missing from this report. The other sections are unaffected.
+{{range .Phases}}{{if eq .Status "failed" -}}
+> - **{{.Section}}:** {{.Error}}
+{{end}}{{end}}{{end}}{{if or .Degraded .Incomplete}}
+> ⚠️ **Limited analysis:** resource limits cut parts of this report short.
+{{range .Degraded -}}
+> - **{{.}}:** skipped, the heap exceeded the --max-memory budget.
+{{end}}{{range .Incomplete -}}
+> - **{{.}}:** stopped at the --phase-timeout budget, so it is partial or missing.
+{{end}}{{end}}{{end}}
 
+{{if .Uncommitted -}}
+## Uncommitted Changes Analyzed
+*Uncommitted changes against {{shortHash .Commit.Hash}}: the staged and unstaged changes of the worktree, analyzed as the files are on disk. The commit below is the one they are compared with.*
+
+- **Base Commit:** {{.Commit.Hash}}
+{{- else -}}
 ## Latest Commit Analyzed
 - **Hash:** {{.Commit.Hash}}
+{{- end}}
+{{- with .Branch}}
+- **Branch:** {{.}}
+{{- end}}
 - **Author:** {{.Commit.Author}} <{{.Commit.Email}}>
 - **Date:** {{.Commit.Date}}
 - **Message:** {{.Commit.Message}}
+- **Files Changed:** {{.Commit.FilesChanged}} (+{{.Commit.LinesAdded}} / -{{.Commit.LinesDeleted}})
+
+{{if .Uncommitted -}}
+## Code Statistics (uncommitted changes)
+*Scope: changes of the worktree not committed yet.*
+{{- else -}}
+## Code Statistics (latest commit)
+*Scope: changes introduced by the analyzed commit.*
+{{- end}}
 
-## Code Statistics
 - **Total Lines Added:** {{.Stats.TotalLinesAdded}}
 - **Total Lines Deleted:** {{.Stats.TotalLinesDeleted}}
-  *Note: Line counts are overall for the commit. Per-file line counts were not available with current git analysis settings.*
 
-### File Type Distribution
-| Extension | Count |
-|-----------|-------|
+### File Type Distribution (files changed by the commit)
+| Extension | Count | Total Bytes | Avg Bytes | Lines Added | Lines Deleted |
+|-----------|-------|-------------|-----------|-------------|---------------|
 {{range $ext, $stat := .Stats.FileStats -}}
-| {{$ext}} | {{$stat.Count}} |
+| {{$ext}} | {{$stat.Count}} | {{$stat.TotalBytes}} | {{$stat.AverageBytes}} | +{{$stat.LinesAdded}} | -{{$stat.LinesDeleted}} |
+{{end}}
+{{- if .ChangedFunctions}}
+### Changed Functions
+*Go functions the commit added, removed or modified; moved functions kept their source.*
+
+| Function | File | Change | Lines Touched |
+|----------|------|--------|---------------|
+{{range .ChangedFunctions -}}
+| {{if .Name}}{{.Name}}{{else}}(whole file){{end}} | {{.File}} | {{.Change}} | {{.LinesTouched}} |
+{{end}}
+{{- end}}
+{{- with .Stats.DiffCoverage}}
+### Diff Coverage
+*Lines the commit added to non-test Go files, checked against the coverage profile {{.Profile}}. Only lines in a statement block of the profile count; files missing from the profile count as uncovered.*
+
+**{{printf "%.1f" .Percent}}%** of the added lines are covered by tests: {{.Covered}} of {{.Coverable}}.
+{{if .Files}}
+| File | Covered | Coverable | Diff Coverage | Uncovered Lines |
+|------|---------|-----------|---------------|-----------------|
+{{range .Files -}}
+| {{.File}}{{if not .InProfile}} (not in profile){{end}} | {{.Covered}} | {{.Coverable}} | {{printf "%.1f" .Percent}}% | {{lineRanges .Uncovered}} |
+{{end}}
+{{- end}}
+{{- end}}
+{{with .TagRange}}
+## Changes Since {{.Tag}}
+*Scope: commits after tag {{.Tag}} ({{.TagCommit}}) up to the analyzed commit, merge commits excluded.*
+
+- **Commits:** {{len .Commits}}
+- **Files Changed:** {{.FilesChanged}}
+- **Lines Added:** {{.LinesAdded}}
+- **Lines Deleted:** {{.LinesDeleted}}
+{{if .Commits}}
+| Hash | Author | Date | Files Changed | Lines Added | Lines Deleted | Message |
+|------|--------|------|---------------|-------------|---------------|---------|
+{{range .Commits -}}
+| {{.Hash}} | {{.Author}} | {{.Date}} | {{.FilesChanged}} | {{.LinesAdded}} | {{.LinesDeleted}} | {{.Message}} |
+{{end}}
+{{- end}}
 {{end}}
+{{- with .Stats.Cadence}}
+## Commit Cadence
+*Scope: all {{.Commits}} commits reachable from the analyzed commit, merge commits included, by author date.*
 
+- **First Commit:** {{.First.Format "2006-01-02"}}
+- **Latest Commit:** {{.Last.Format "2006-01-02"}}
+{{if gt .Commits 1 -}}
+- **Mean Interval:** {{duration .MeanInterval}}
+- **Median Interval:** {{duration .MedianInterval}}
+- **Longest Gap:** {{duration .LongestGap}}
+{{else}}
+The repository has a single commit, so there are no intervals to measure.
+{{end}}
+{{- end}}
+{{- if gt .LintedCommits 0}}
+## Commit Message Lint
+*Scope: {{.LintedCommits}} commit message(s) checked against the rules of {{lintRulesFile}} or its defaults.*
+{{if .CommitLintFindings}}
+| Commit | Rule | Severity | Finding |
+|--------|------|----------|---------|
+{{range .CommitLintFindings -}}
+| {{.Commit}} | {{.Rule}} | {{.Severity}} | {{.Message}} |
+{{end}}
+{{- else}}
+All commit messages follow the rules.
+{{end}}
+{{- end}}
+{{- with .Submodules}}
+## Submodules
+{{if eq $.SubmoduleMode "none" -}}
+*Scope: submodules pinned by the analyzed commit. They were not fetched (--submodules none), so their files are left out of every metric.*
+{{- else -}}
+*Scope: submodules pinned by the analyzed commit and, up to the depth limit, the submodules nested in them. Fetched submodules are checked out at their pinned commit and their files count towards the repository-wide metrics under their path; the statistics of the latest commit only cover the repository itself.*
+{{- end}}
+
+| Path | Pinned Commit | URL | Fetched |
+|------|---------------|-----|---------|
+{{range . -}}
+| {{.Path}} | {{.Commit}} | {{.URL}} | {{if .Fetched}}yes{{else if eq $.SubmoduleMode "none"}}no{{else}}no, see Warnings{{end}} |
+{{end}}
+{{- if eq $.SubmoduleMode "full"}}
+### Submodule Commits
+*The pinned commit of each fetched submodule and the files it changed in the submodule.*
+
+| Path | Author | Date | Files Changed | Lines Added | Lines Deleted | Message |
+|------|--------|------|---------------|-------------|---------------|---------|
+{{range . -}}{{$path := .Path}}{{with .LatestCommit -}}
+| {{$path}} | {{.Author}} | {{.Date}} | {{.FilesChanged}} | {{.LinesAdded}} | {{.LinesDeleted}} | {{.Message}} |
+{{end}}{{end}}
+{{- end}}
+{{- end}}
+
+{{with .EmptyAnalysis -}}
+## No Source Code Found
+{{if .ExcludedSourceFiles -}}
+All {{.ExcludedSourceFiles}} source files are in skipped directories (vendor, testdata, hidden and underscore-prefixed directories), so no source code was analyzed.
+{{- else -}}
+The repository contains no source files, so no source code was analyzed.
+{{- end}}
+
+- **Languages Looked For:** {{languages}}
+- **Most Common File Types:** {{range $i, $ext := .TopExtensions 5}}{{if $i}}, {{end}}{{$ext.Extension}} ({{$ext.Count}}){{end}}
+{{else -}}
+{{if .Stats.Fast -}}
+## Cyclomatic Complexity Analysis
+*⏩ Skipped in fast mode: no source file was parsed, so complexity, Package Coupling, Banned Imports and Code Style are not reported. Run without --fast for the full analysis.*
+{{else -}}
 ## Cyclomatic Complexity Analysis (Threshold > {{.ComplexityThreshold}})
-- **Average Complexity (of functions over threshold):** {{printf "%.2f" .Stats.AverageComplexity}}
+*Scope: whole repository at the analyzed commit, including files the commit did not touch.*
+{{- with .Stats.DirectoryOverrides}}
+*{{len .}} directory-level override(s) from .zenwatch.yaml files were active, so thresholds, excludes and suppressions differ in: {{join . ", "}}.*
+{{- end}}
+{{- if .Stats.IsIncomplete "Cyclomatic Complexity"}}
+*⚠️ Incomplete: only the files parsed within --phase-timeout are covered.*
+{{- end}}
+{{- if .Stats.IsFailed "Cyclomatic Complexity"}}
+*❌ Unavailable: the analysis failed, so no function is counted below.*
+{{- end}}
+
+- **Average Complexity (of functions over threshold):** {{printf "%.2f" .Stats.AverageComplexity}} {{severity .Stats.AverageComplexity .ComplexityThreshold (criticalComplexity .ComplexityThreshold)}}
 - **Functions Over Threshold:** {{.Stats.FunctionsOverThreshold}}
 
 {{if gt .Stats.FunctionsOverThreshold 0 -}}
@@ -50,59 +202,450 @@ const markdownTemplate = `
 {{range .Stats.ComplexityStats -}}
 | {{.Complexity}} | {{.FunctionName}}                     | {{.File}}:{{.Line}} | {{.Package}}    |
 {{end}}
+{{range .Stats.Excerpts}}
+#### {{.FunctionName}} ({{.File}}:{{.Line}})
+{{codeBlock .}}
+{{if .Truncated}}*Excerpt truncated.*
+{{end}}{{end}}
 {{else -}}
 No functions found with cyclomatic complexity greater than {{.ComplexityThreshold}}.
 {{end}}
+{{if .IncludeTests}}
+### Test Code Complexity
+- **Average Complexity (of test functions over threshold):** {{printf "%.2f" .Stats.TestAverageComplexity}}
+- **Test Functions Over Threshold:** {{.Stats.TestFunctionsOverThreshold}}
+- **Table-Driven Test Functions:** {{.Stats.TableDrivenTestFunctions}} (the loop over test cases is not counted towards their complexity)
+
+{{if gt .Stats.TestFunctionsOverThreshold 0 -}}
+| Complexity | Function                               | File:Line        | Package        |
+|------------|----------------------------------------|------------------|----------------|
+{{range .Stats.TestComplexityStats -}}
+| {{.Complexity}} | {{.FunctionName}}                     | {{.File}}:{{.Line}} | {{.Package}}    |
+{{end}}
+{{else -}}
+No test functions found with cyclomatic complexity greater than {{.ComplexityThreshold}}.
+{{end}}
+{{end}}{{end}}{{if .Stats.ComplexityOwnership}}
+### Complexity Ownership
+*Functions over threshold, attributed to the author of most of their lines.*
+{{- if $.Stats.IsIncomplete "Complexity Ownership"}}
+*⚠️ Incomplete: files not blamed within --phase-timeout are left out.*
+{{- end}}
+
+| Author | Functions | Total Complexity |
+|--------|-----------|------------------|
+{{range .Stats.ComplexityOwnership -}}
+| {{.Author}} | {{.Functions}} | {{.TotalComplexity}} |
+{{end}}
+{{end}}{{if .Stats.AnnotationAuthors}}
+### Annotations by Author
+*TODO and FIXME comments, attributed to the author who last changed their line.*
+{{- if $.Stats.IsIncomplete "Annotations by Author"}}
+*⚠️ Incomplete: files not blamed within --phase-timeout are left out.*
+{{- end}}
+
+| Author | TODO | FIXME | Total |
+|--------|------|-------|-------|
+{{range .Stats.AnnotationAuthors -}}
+| {{.Author}} | {{.TODO}} | {{.FIXME}} | {{.Total}} |
+{{end}}
+{{end}}{{with .Stats.Freshness}}
+### Code Freshness
+*Go source lines last changed within {{duration .Window}} before the analyzed commit, by blame. A high share may signal code that has not settled yet.*
+{{- if $.Stats.IsIncomplete "Code Freshness"}}
+*⚠️ Incomplete: files not blamed within --phase-timeout are left out.*
+{{- end}}
+
+**{{printf "%.1f" .Percent}}%** recently changed: {{.RecentLines}} of {{.TotalLines}} lines.
+{{end}}{{with .Stats.ComplexityTrend}}
+### Complexity Trend
+*Functions over threshold at the last {{len .}} commits, oldest first.*
+
+{{sparkline .}} {{range $i, $p := .}}{{if $i}} → {{end}}{{$p.FunctionsOverThreshold}}{{end}}
+{{end}}{{with .Stats.RefactoringPlan}}
+### Suggested Refactoring Plan
+*Functions over threshold ranked by complexity (weight {{.Weights.Complexity}}), the edits (weight {{.Weights.Edits}}) and churn (weight {{.Weights.Churn}}) of their file in the {{days .Window}} days before the analyzed commit, and the share of their lines by one owner (weight {{.Weights.Ownership}}). Suppressed functions and tests are left out. Effort is estimated from the length of the function: S up to 30 lines, M up to 80, L beyond.*
+{{- if $.Stats.IsIncomplete "Suggested Refactoring Plan"}}
+*⚠️ Incomplete: the history was not read within --phase-timeout.*
+{{- end}}
+
+{{if .Items -}}
+| # | Function | File:Line | Effort | Score | Rationale |
+|---|----------|-----------|--------|-------|-----------|
+{{range $i, $item := .Items -}}
+| {{inc $i}} | {{.FunctionName}} | {{.File}}:{{.Line}} | {{.Effort}} ({{.Lines}} lines) | {{printf "%.2f" .Score}} | {{.Rationale}} |
+{{end}}
+{{- else -}}
+No function is over the complexity threshold.
+{{end}}
+{{end}}{{if .Stats.Packages}}
+## Package Inventory
+*Scope: whole repository at the analyzed commit. External test packages are counted with the package they test.*
+
+| Package | Directory | Files | SLOC | Functions | Test Files | Test SLOC | Test Functions |
+|---------|-----------|-------|------|-----------|------------|-----------|----------------|
+{{range .Stats.Packages -}}
+| {{.Name}}{{if .Command}} (command){{end}} | {{.Dir}} | {{.Files}} | {{.SLOC}} | {{.Functions}} | {{.TestFiles}} | {{.TestSLOC}} | {{.TestFunctions}} |
+{{end}}
+{{end}}{{if .Stats.PackageCoupling}}
+## Package Coupling
+*Scope: whole repository at the analyzed commit.*
+
+| Package | Ca | Ce | Instability | Interfaces / Concrete Types | Abstractness | Distance | Zone |
+|---------|----|----|-------------|-----------------------------|--------------|----------|------|
+{{range .Stats.PackageCoupling -}}
+| {{.Package}} | {{.AfferentCoupling}} | {{.EfferentCoupling}} | {{printf "%.2f" .Instability}} | {{.Interfaces}} / {{.ConcreteTypes}} | {{printf "%.2f" .AbstractnessScore}} | {{printf "%.2f" .DistanceFromMainSequence}} | {{.Zone}} |
+{{end}}
+{{end}}{{if .Stats.BannedImports}}
+## Banned Imports
+*Scope: whole repository at the analyzed commit, including tests.*
+
+| Import | Location |
+|--------|----------|
+{{range .Stats.BannedImports -}}
+| {{.ImportPath}} | {{.File}}:{{.Line}} |
+{{end}}
+{{end}}{{if .Stats.VendorDrift}}
+## Vendored Dependency Drift
+*Scope: go.mod compared with vendor/modules.txt at the analyzed commit.*
+
+| Module | Problem | go.mod | vendor/modules.txt |
+|--------|---------|--------|--------------------|
+{{range .Stats.VendorDrift -}}
+| {{.Module}} | {{.Kind}} | {{.Required}} | {{.Vendored}} |
+{{end}}
+{{end}}{{with .Stats.Build}}
+## Build Check
+*Scope: go build ./... and go vet ./... at the analyzed commit.*
+
+- **Compiles:** {{if .Compiles}}yes{{else if .TimedOut}}no, timed out{{else}}no{{end}}
+- **Build Time:** {{duration .Duration}}
+{{if .Compiles -}}
+- **Vet Findings:** {{.VetFindings}}
+{{end}}
+{{- if .Binaries}}
+| Binary | Size (bytes) |
+|--------|--------------|
+{{range .Binaries -}}
+| {{.Name}} | {{.Bytes}} |
+{{end}}
+{{- end}}
+{{- if .Errors}}
+First errors:
+{{range .Errors -}}
+- {{.}}
+{{end}}
+{{- end}}
+{{end}}{{with .Stats.DirectoryRollup}}
+## Directory Rollup
+*Scope: files, SLOC and complexity cover the whole repository; churn covers the analyzed commit. Small directories are folded into their parent.*
+{{- if $.Stats.Fast}}
+*The complexity columns are empty: fast mode skips the complexity analysis.*
+{{- end}}
+
+| Directory | Files | SLOC | Avg Complexity | Max Complexity | Churn |
+|-----------|-------|------|----------------|----------------|-------|
+{{range directoryRows . -}}
+| {{.Label}} | {{.Files}} | {{.SLOC}} | {{printf "%.2f" .AverageComplexity}} | {{.MaxComplexity}} | {{.Churn}} |
+{{end}}
+{{end}}{{if .Stats.Style}}
+## Code Style
+*Scope: whole repository at the analyzed commit. Files are counted by their dominant indentation; Go indentation is left to gofmt. Widths are in display columns, with wide CJK characters counting twice.*
+
+| Language | Files | Indentation | Mixed Indentation | Lines > {{.Stats.MaxLineLength}} Columns | Longest Line |
+|----------|-------|-------------|-------------------|-------------------|--------------|
+{{range .Stats.Style -}}
+| {{.Language}} | {{.Files}} | {{if .IndentChecked}}{{.TabFiles}} tabs, {{.SpaceFiles}} spaces{{with .IndentWidth}} ({{.}} wide){{end}}{{else}}gofmt{{end}} | {{if .IndentChecked}}{{.MixedIndentFiles}}{{else}}-{{end}} | {{.LongLines}} in {{.FilesWithLongLines}} files | {{.MaxLineWidth}} |
+{{end}}
+{{end}}{{if .Stats.CustomFindings}}
+## Custom Findings
+*Reported by external analyzer plugins, named in the Source column. zenwatch does not check these findings beyond their format.*
+
+| Source | Severity | Location | Message |
+|--------|----------|----------|---------|
+{{range .Stats.CustomFindings -}}
+| {{.Source}} | {{.Severity}} | {{or .Location "-"}} | {{.Message}} |
+{{end}}
+{{end}}{{end}}
+{{if .Warnings}}
+## Warnings
+*These problems did not stop the analysis but make parts of this report less complete.*
+
+| Code | Location | Message |
+|------|----------|---------|
+{{range .Warnings -}}
+| {{.Code}} | {{.Location}} | {{.Message}} |
+{{end}}
+{{end}}
+{{with .Stats.Run}}
+## Run Statistics
+*What this run did: the clone transfer and the files walked in the analyzed tree.*
+
+- **Objects Fetched:** {{.ObjectsFetched}}
+- **Bytes Transferred:** {{.BytesTransferred}}
+- **Files Walked:** {{.FilesWalked}}
+- **Files Analyzed:** {{.FilesAnalyzed}}
+{{range $reason, $count := .FilesSkipped -}}
+- **Files Skipped ({{$reason}}):** {{$count}}
+{{end -}}
+- **Parse Errors:** {{.ParseErrors}}
+{{end}}
+{{if .AnalysisConfig}}
+<details>
+<summary>Analysis Configuration</summary>
+
+| Option | Value |
+|--------|-------|
+{{range $name, $value := .AnalysisConfig -}}
+| {{$name}} | {{$value}} |
+{{end}}
+</details>
+{{end}}
+{{if .ShowCommitHistory}}
+## Commit History
+| Hash | Author | Date | Files Changed | Lines Added | Lines Deleted | Risk Score |
+|------|--------|------|---------------|-------------|---------------|------------|
+{{range .CommitHistory -}}
+| {{.Hash}} | {{.Author}} | {{.Date}} | {{.FilesChanged}} | {{.LinesAdded}} | {{.LinesDeleted}} | {{printf "%.2f" .RiskScore}} |
+{{end}}
+{{end}}
+{{if gt .MaxFilesPerCommit 0}}
+## Shotgun Commits
+*Scope: full history of HEAD, merge commits excluded. Commits changing more than {{.MaxFilesPerCommit}} files often spread one change across the codebase ("shotgun surgery").*
+{{if .ShotgunCommits}}
+| Hash | Author | Date | Files Changed | Message |
+|------|--------|------|---------------|---------|
+{{range .ShotgunCommits -}}
+| {{.Hash}} | {{.Author}} | {{.Date}} | {{.FilesChanged}} | {{.Message}} |
+{{end}}
+{{- else}}
+No commit changed more than {{.MaxFilesPerCommit}} files.
+{{end}}
+{{end}}
 `
 
 // ReportData holds all necessary data for rendering the Markdown report.
 type ReportData struct {
-	RepoURL             string
-	ReportDate          string
-	BadgeURL            string // Optional: URL for the status badge
-	Commit              *git.CommitInfo
-	Stats               *metrics.OverallStats
-	ComplexityThreshold int
+	RepoURL             string                   `json:"repoURL"`
+	ReportDate          string                   `json:"reportDate"`
+	Badge               Badge                    `json:"badge"`              // Status badge, rendered by BadgeURL and WriteBadgeSVG
+	BadgeURL            string                   `json:"badgeURL,omitempty"` // Optional: URL for the status badge
+	Commit              *git.CommitInfo          `json:"commit"`
+	Branch              string                   `json:"branch,omitempty"` // Optional: branch of the analyzed commit
+	Uncommitted         bool                     `json:"uncommitted"`      // The changes are the uncommitted ones of a worktree against Commit, see git.AnalyzeWorktree
+	Stats               *metrics.OverallStats    `json:"stats"`
+	ComplexityThreshold int                      `json:"complexityThreshold"`
+	CommitHistory       []CommitRowData          `json:"commitHistory,omitempty"` // Optional: one entry per analyzed commit
+	ShowCommitHistory   bool                     `json:"showCommitHistory"`       // Render the Commit History section
+	Options             ReportOptions            `json:"-"`
+	IncludeTests        bool                     `json:"includeTests"`             // Render the Test Code Complexity section
+	AnalysisConfig      map[string]string        `json:"analysisConfig,omitempty"` // Optional: the options the analysis ran with, by name
+	EmptyAnalysis       *metrics.SourceInventory `json:"emptyAnalysis,omitempty"`  // Set when no source code was found; replaces the source analysis sections
+	Warnings            []warning.Warning        `json:"warnings"`                 // Problems of the commit and metric analyses
+	LanguageFilter      string                   `json:"languageFilter,omitempty"` // Optional: the languages the analysis was restricted to
+	MaxFilesPerCommit   int                      `json:"maxFilesPerCommit"`        // Render the Shotgun Commits section when above 0
+	ShotgunCommits      []git.CommitInfo         `json:"shotgunCommits"`           // Commits of the history changing more than MaxFilesPerCommit files
+	TagRange            *git.TagRange            `json:"tagRange,omitempty"`       // Optional: the changes since the latest tag
+	ChangedFunctions    []git.ChangedFunction    `json:"changedFunctions"`         // Go functions changed by the analyzed commit
+	LintedCommits       int                      `json:"lintedCommits"`            // Render the Commit Message Lint section when above 0
+	CommitLintFindings  []commitlint.Finding     `json:"commitLintFindings"`       // Rules broken by the messages of the linted commits
+	Submodules          []git.Submodule          `json:"submodules"`               // Submodules of the analyzed commit, after the ones they are nested in
+	SubmoduleMode       string                   `json:"submoduleMode"`            // How the submodules were analyzed, one of the git.Submodules* modes
 }
 
-// GenerateMarkdownReport creates a Markdown report from the analysis data.
-func GenerateMarkdownReport(data ReportData, outputPath string) error {
-	tmpl, err := template.New("markdownReport").Parse(markdownTemplate)
-	if err != nil {
-		return fmt.Errorf("failed to parse markdown template: %w", err)
+// criticalComplexity returns the average complexity considered critical: twice the
+// reporting threshold.
+func criticalComplexity(threshold int) int {
+	return 2 * threshold
+}
+
+// redactedValue replaces the value of secret configuration options in reports.
+const redactedValue = "[REDACTED]"
+
+// secretConfigMarkers identify configuration options whose values must never be embedded in a report.
+var secretConfigMarkers = []string{"token", "password", "secret", "credential"}
+
+// RedactConfigSecrets returns a copy of config with the values of secret options replaced
+// and the credentials removed from URL values, as git.RedactURL does.
+func RedactConfigSecrets(config map[string]string) map[string]string {
+	if config == nil {
+		return nil
+	}
+	redacted := make(map[string]string, len(config))
+	for name, value := range config {
+		redacted[name] = git.RedactURL(value)
+		lower := strings.ToLower(name)
+		for _, marker := range secretConfigMarkers {
+			if strings.Contains(lower, marker) && value != "" {
+				redacted[name] = redactedValue
+				break
+			}
+		}
 	}
+	return redacted
+}
 
-	// Ensure output directory exists
-	outputDir := filepath.Dir(outputPath)
-	if err := os.MkdirAll(outputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory %s: %w", outputDir, err)
+// formatDuration renders d in its two largest units, e.g. "3d 4h", "2h 30m" or "45m".
+// Durations under a minute are rendered as "<1m".
+func formatDuration(d time.Duration) string {
+	if d < time.Minute {
+		return "<1m"
+	}
+	days, hours, minutes := int(d/(24*time.Hour)), int(d/time.Hour)%24, int(d/time.Minute)%60
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd %dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
 	}
+}
 
-	file, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to create report file %s: %w", outputPath, err)
+// codeBlock renders an excerpt as a fenced code block. The code is emitted verbatim, since
+// escaped entities would show up literally inside the block, and the fence is made longer
+// than any backtick run in the code so the code cannot close it.
+func codeBlock(e metrics.CodeExcerpt) template.HTML {
+	fence := "```"
+	for strings.Contains(e.Code, fence) {
+		fence += "`"
+	}
+	return template.HTML(fence + e.Language + "\n" + e.Code + "\n" + fence)
+}
+
+// CommitRowData is a single row of the Commit History table.
+type CommitRowData struct {
+	git.CommitInfo
+	RiskScore float64 `json:"riskScore,omitempty"` // Optional: 0 when no risk score was computed, see CommitRiskScore
+}
+
+// CommitRiskScore rates how risky a commit is to review from its size and spread:
+// log2(1 + lines added and deleted) × log2(1 + files changed). A one-line fix scores 1,
+// a 1,000-line change across 30 files about 50; a commit changing nothing scores 0.
+func CommitRiskScore(c git.CommitInfo) float64 {
+	return math.Log2(1+float64(c.LinesAdded+c.LinesDeleted)) * math.Log2(1+float64(c.FilesChanged))
+}
+
+// CommitRows returns the Commit History rows of commits, with their risk scores.
+func CommitRows(commits []git.CommitInfo) []CommitRowData {
+	rows := make([]CommitRowData, len(commits))
+	for i, c := range commits {
+		rows[i] = CommitRowData{CommitInfo: c, RiskScore: CommitRiskScore(c)}
 	}
-	defer file.Close()
+	return rows
+}
+
+// commitDateLayout matches the format produced by time.Time.String(),
+// which is how git.CommitInfo.Date is populated.
+const commitDateLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
 
-	err = tmpl.Execute(file, data)
+// sortCommitHistory returns a copy of rows ordered by commit date, newest first.
+// Rows whose date cannot be parsed keep their relative order at the end.
+func sortCommitHistory(rows []CommitRowData) []CommitRowData {
+	sorted := make([]CommitRowData, len(rows))
+	copy(sorted, rows)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		ti, errI := time.Parse(commitDateLayout, sorted[i].Date)
+		tj, errJ := time.Parse(commitDateLayout, sorted[j].Date)
+		if errI != nil || errJ != nil {
+			return errI == nil && errJ != nil
+		}
+		return ti.After(tj)
+	})
+	return sorted
+}
+
+// GenerateMarkdownReport creates a Markdown report from the analysis data.
+func GenerateMarkdownReport(data ReportData, outputPath string) error {
+	return GenerateReport(data, FormatMarkdown, outputPath)
+}
+
+// WriteMarkdownReport renders data as a Markdown report to w. It is the markdown Formatter.
+func WriteMarkdownReport(data ReportData, w io.Writer) error {
+	if err := data.Options.Validate(); err != nil {
+		return err
+	}
+	funcs := template.FuncMap{
+		"severity": func(value float64, warn, crit int) string {
+			return data.Options.severityEmoji(value, float64(warn), float64(crit))
+		},
+		"criticalComplexity": criticalComplexity,
+		"directoryRows":      directoryRows,
+		"languages":          metrics.DescribeLanguages,
+		"lintRulesFile":      func() string { return commitlint.FileName },
+		"duration":           formatDuration,
+		"codeBlock":          codeBlock,
+		"join":               strings.Join,
+		"shortHash":          shortHash,
+		"inc":                func(i int) int { return i + 1 },
+		"days":               func(d time.Duration) int { return int(d.Hours() / 24) },
+		"lineRanges":         formatLineRanges,
+		"sparkline": func(points []metrics.TrendPoint) string {
+			values := make([]int, len(points))
+			for i, p := range points {
+				values[i] = p.FunctionsOverThreshold
+			}
+			return metrics.Sparkline(values)
+		},
+	}
+	tmpl, err := parseTemplate("markdownReport", markdownTemplate, funcs)
 	if err != nil {
+		return err
+	}
+
+	data.CommitHistory = sortCommitHistory(data.CommitHistory)
+	data.AnalysisConfig = RedactConfigSecrets(data.AnalysisConfig)
+	if err := tmpl.Execute(w, data); err != nil {
 		return fmt.Errorf("failed to execute template: %w", err)
 	}
-	fmt.Printf("Markdown report generated at %s\n", outputPath)
+	if data.Options.EmbedData && data.Stats != nil {
+		return writeEmbeddedData(w, data.Stats)
+	}
 	return nil
 }
 
-// GenerateBadgeURL creates a URL for a shields.io badge.
-// Example: Total Changes: 150, Avg Complexity: 8.5
-func GenerateBadgeURL(totalChangedLines int, avgComplexity float64) string {
-	label := "ZenWatch"
-	// Ensure avgComplexity is formatted nicely for the URL, e.g., "8.5" not "8.500000"
-	message := fmt.Sprintf("changes %d | avg complx %.1f", totalChangedLines, avgComplexity)
-	color := "blue"
+// parseTemplate parses the report template text with the helpers in funcs.
+func parseTemplate(name, text string, funcs template.FuncMap) (*template.Template, error) {
+	tmpl, err := template.New(name).Funcs(funcs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s template: %w: %w", name, ErrTemplateParse, err)
+	}
+	return tmpl, nil
+}
 
-	// URL encode message
-	safeMessage := strings.ReplaceAll(message, " ", "%20")
-	safeMessage = strings.ReplaceAll(safeMessage, "|", "%7C")
+// formatLineRanges returns ranges as "3, 7-9", or "-" if there are none.
+func formatLineRanges(ranges []metrics.LineRange) string {
+	if len(ranges) == 0 {
+		return "-"
+	}
+	parts := make([]string, len(ranges))
+	for i, r := range ranges {
+		parts[i] = r.String()
+	}
+	return strings.Join(parts, ", ")
+}
 
-	return fmt.Sprintf("https://img.shields.io/badge/%s-%s-%s", label, safeMessage, color)
+// directoryRow is a DirectoryStat with its tree-drawing label for the Directory Rollup table.
+type directoryRow struct {
+	Label string
+	*metrics.DirectoryStat
+}
+
+// directoryRows flattens a directory rollup depth-first into labeled table rows.
+// Non-breaking spaces keep the tree indentation intact when the Markdown is rendered.
+func directoryRows(root *metrics.DirectoryStat) []directoryRow {
+	rows := []directoryRow{{Label: "./", DirectoryStat: root}}
+	var walk func(node *metrics.DirectoryStat, indent string)
+	walk = func(node *metrics.DirectoryStat, indent string) {
+		for i, child := range node.Children {
+			branch, next := "├── ", "│\u00a0\u00a0\u00a0"
+			if i == len(node.Children)-1 {
+				branch, next = "└── ", "\u00a0\u00a0\u00a0\u00a0"
+			}
+			rows = append(rows, directoryRow{Label: indent + branch + path.Base(child.Path) + "/", DirectoryStat: child})
+			walk(child, indent+next)
+		}
+	}
+	walk(root, "")
+	return rows
 }
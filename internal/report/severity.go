@@ -0,0 +1,70 @@
+package report
+
+import "fmt"
+
+// Emoji styles accepted by ReportOptions.EmojiStyle.
+const (
+	EmojiStyleColorDot     = "color-dot"
+	EmojiStyleTrafficLight = "traffic-light"
+	EmojiStyleNone         = "none"
+)
+
+// emojiStyles maps each style to its low, medium and high severity indicators.
+var emojiStyles = map[string][3]string{
+	EmojiStyleColorDot:     {"🟢", "🟡", "🔴"},
+	EmojiStyleTrafficLight: {"✅", "⚠️", "⛔"},
+	EmojiStyleNone:         {"", "", ""},
+}
+
+// ReportOptions controls presentation details of the generated report.
+type ReportOptions struct {
+	EmojiStyle      string // One of the EmojiStyle* constants; empty means EmojiStyleColorDot
+	HeatmapMaxNodes int    // Node cap of the heatmap-json output; 0 means DefaultHeatmapMaxNodes
+	EmbedData       bool   // End the Markdown report with the stats as a zenwatch-data comment
+}
+
+// Validate checks that the options hold supported values.
+func (o ReportOptions) Validate() error {
+	if _, ok := emojiStyles[o.emojiStyle()]; !ok {
+		return fmt.Errorf("unknown emoji style %q (supported: %s, %s, %s)",
+			o.EmojiStyle, EmojiStyleColorDot, EmojiStyleTrafficLight, EmojiStyleNone)
+	}
+	return nil
+}
+
+func (o ReportOptions) emojiStyle() string {
+	if o.EmojiStyle == "" {
+		return EmojiStyleColorDot
+	}
+	return o.EmojiStyle
+}
+
+func (o ReportOptions) heatmapMaxNodes() int {
+	if o.HeatmapMaxNodes <= 0 {
+		return DefaultHeatmapMaxNodes
+	}
+	return o.HeatmapMaxNodes
+}
+
+// severityEmoji returns the indicator for value in the configured style.
+func (o ReportOptions) severityEmoji(value, warnThreshold, critThreshold float64) string {
+	return emojiStyles[o.emojiStyle()][severityLevel(value, warnThreshold, critThreshold)]
+}
+
+// SeverityEmoji returns "🟢" below warnThreshold, "🟡" from warnThreshold up to critThreshold
+// and "🔴" above critThreshold.
+func SeverityEmoji(value, warnThreshold, critThreshold float64) string {
+	return ReportOptions{}.severityEmoji(value, warnThreshold, critThreshold)
+}
+
+// severityLevel returns 0 (low), 1 (medium) or 2 (high).
+func severityLevel(value, warnThreshold, critThreshold float64) int {
+	switch {
+	case value > critThreshold:
+		return 2
+	case value >= warnThreshold:
+		return 1
+	default:
+		return 0
+	}
+}
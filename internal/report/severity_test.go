@@ -0,0 +1,67 @@
+package report
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSeverityEmoji(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{value: 5, expected: "🟢"},
+		{value: 10, expected: "🟡"},
+		{value: 15, expected: "🟡"},
+		{value: 20, expected: "🔴"},
+	}
+	for _, tc := range tests {
+		if got := SeverityEmoji(tc.value, 10, 15); got != tc.expected {
+			t.Errorf("SeverityEmoji(%v, 10, 15) = %q, expected %q", tc.value, got, tc.expected)
+		}
+	}
+}
+
+func TestReportOptionsSeverityEmojiStyles(t *testing.T) {
+	tests := []struct {
+		style    string
+		expected [3]string
+	}{
+		{style: "", expected: [3]string{"🟢", "🟡", "🔴"}},
+		{style: EmojiStyleColorDot, expected: [3]string{"🟢", "🟡", "🔴"}},
+		{style: EmojiStyleTrafficLight, expected: [3]string{"✅", "⚠️", "⛔"}},
+		{style: EmojiStyleNone, expected: [3]string{"", "", ""}},
+	}
+	for _, tc := range tests {
+		opts := ReportOptions{EmojiStyle: tc.style}
+		if err := opts.Validate(); err != nil {
+			t.Fatalf("Validate(%q) failed: %v", tc.style, err)
+		}
+		for i, value := range []float64{1, 12, 30} {
+			if got := opts.severityEmoji(value, 10, 15); got != tc.expected[i] {
+				t.Errorf("Style %q, value %v: got %q, expected %q", tc.style, value, got, tc.expected[i])
+			}
+		}
+	}
+}
+
+func TestReportOptionsValidateUnknownStyle(t *testing.T) {
+	if err := (ReportOptions{EmojiStyle: "rainbow"}).Validate(); err == nil {
+		t.Errorf("Expected error for unknown emoji style")
+	}
+}
+
+func TestGenerateMarkdownReportSeverityEmoji(t *testing.T) {
+	data := newTestReportData()
+	data.Stats.AverageComplexity = 40
+	data.Stats.FunctionsOverThreshold = 1
+
+	if content := renderReport(t, data); !strings.Contains(content, "40.00 🔴") {
+		t.Errorf("Expected red indicator next to average complexity, got:\n%s", content)
+	}
+
+	data.Options.EmojiStyle = EmojiStyleNone
+	if content := renderReport(t, data); strings.Contains(content, "🔴") {
+		t.Errorf("Expected no indicator with emoji style none")
+	}
+}
@@ -0,0 +1,129 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/user/zenwatch/internal/ansi"
+	"github.com/user/zenwatch/internal/metrics"
+)
+
+// SummaryFindings is the number of functions listed in the terminal summary.
+const SummaryFindings = 5
+
+// WriteTerminalSummary prints a short, optionally colored summary of data for interactive
+// runs: a boxed header naming the repository and commit, the key metrics, the most complex
+// functions over threshold unless fast mode skipped them, and the verdict of the gates, where
+// a nil verdict means they passed.
+// reportPath names the file holding the full report.
+func WriteTerminalSummary(w io.Writer, data ReportData, reportPath string, verdict error, style ansi.Styler) error {
+	header := []string{style.Bold("zenwatch") + " " + data.RepoURL}
+	if c := data.Commit; c != nil {
+		on := ""
+		if data.Branch != "" {
+			on = " on " + data.Branch
+		}
+		if data.Uncommitted {
+			header = append(header, fmt.Sprintf("uncommitted changes against %s%s", shortHash(c.Hash), on))
+		} else {
+			header = append(header, fmt.Sprintf("commit %s%s by %s, %s", shortHash(c.Hash), on, c.Author, c.Date))
+		}
+	}
+	var b strings.Builder
+	writeBox(&b, header)
+
+	stats := data.Stats
+	crit := criticalComplexity(data.ComplexityThreshold)
+	overColor := style.Green
+	if stats.FunctionsOverThreshold > 0 {
+		overColor = style.Red
+	}
+	warningColor := style.Green
+	if len(data.Warnings) > 0 {
+		warningColor = style.Yellow
+	}
+	avg := fmt.Sprintf("%.2f", stats.AverageComplexity)
+	switch severityLevel(stats.AverageComplexity, float64(data.ComplexityThreshold), float64(crit)) {
+	case 2:
+		avg = style.Red(avg)
+	case 1:
+		avg = style.Yellow(avg)
+	default:
+		avg = style.Green(avg)
+	}
+
+	fmt.Fprintf(&b, "\n%s\n", style.Bold("Key metrics"))
+	if stats.Fast {
+		fmt.Fprintf(&b, "  Complexity                       %s\n", style.Dim("skipped in fast mode"))
+	} else {
+		fmt.Fprintf(&b, "  Functions over threshold (> %d)  %s\n", data.ComplexityThreshold, overColor(fmt.Sprint(stats.FunctionsOverThreshold)))
+		fmt.Fprintf(&b, "  Average complexity               %s\n", avg)
+	}
+	fmt.Fprintf(&b, "  Lines changed by the commit      +%d -%d\n", stats.TotalLinesAdded, stats.TotalLinesDeleted)
+	if dc := stats.DiffCoverage; dc != nil {
+		fmt.Fprintf(&b, "  Diff coverage                    %.1f%% (%d of %d lines)\n", dc.Percent(), dc.Covered, dc.Coverable)
+	}
+	if n := len(stats.CustomFindings); n > 0 {
+		fmt.Fprintf(&b, "  Custom findings                  %d\n", n)
+	}
+	fmt.Fprintf(&b, "  Warnings                         %s\n", warningColor(fmt.Sprint(len(data.Warnings))))
+	if !stats.Fast {
+		writeTopFindings(&b, stats.ComplexityStats, style)
+	}
+
+	writeVerdict(&b, verdict, reportPath, style)
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// writeTopFindings lists the first SummaryFindings functions over threshold.
+func writeTopFindings(b *strings.Builder, stats []metrics.ComplexityStat, style ansi.Styler) {
+	fmt.Fprintf(b, "\n%s\n", style.Bold("Top findings"))
+	findings := stats
+	if len(findings) > SummaryFindings {
+		findings = findings[:SummaryFindings]
+	}
+	if len(findings) == 0 {
+		fmt.Fprintf(b, "  %s\n", style.Dim("No functions over the complexity threshold."))
+	}
+	for i, cs := range findings {
+		fmt.Fprintf(b, "  %d. %s  %s  %s\n", i+1, style.Red(fmt.Sprintf("%3d", cs.Complexity)), cs.FunctionName,
+			style.Dim(fmt.Sprintf("%s:%d", cs.File, cs.Line)))
+	}
+	if more := len(stats) - len(findings); more > 0 {
+		fmt.Fprintf(b, "  %s\n", style.Dim(fmt.Sprintf("... and %d more", more)))
+	}
+}
+
+// writeVerdict writes the verdict of the gates and the path of the full report.
+func writeVerdict(b *strings.Builder, verdict error, reportPath string, style ansi.Styler) {
+	if verdict == nil {
+		fmt.Fprintf(b, "\n%s %s\n", style.Bold("Verdict:"), style.Green("PASS"))
+	} else {
+		fmt.Fprintf(b, "\n%s %s %s\n", style.Bold("Verdict:"), style.Red("FAIL"), verdict)
+	}
+	fmt.Fprintf(b, "Full report: %s\n", reportPath)
+}
+
+func shortHash(hash string) string {
+	if len(hash) > 7 {
+		return hash[:7]
+	}
+	return hash
+}
+
+// writeBox frames lines in a box sized to the widest line, ignoring styling.
+func writeBox(b *strings.Builder, lines []string) {
+	width := 0
+	for _, line := range lines {
+		width = max(width, utf8.RuneCountInString(ansi.Strip(line)))
+	}
+	fmt.Fprintf(b, "┌%s┐\n", strings.Repeat("─", width+2))
+	for _, line := range lines {
+		pad := width - utf8.RuneCountInString(ansi.Strip(line))
+		fmt.Fprintf(b, "│ %s%s │\n", line, strings.Repeat(" ", pad))
+	}
+	fmt.Fprintf(b, "└%s┘\n", strings.Repeat("─", width+2))
+}
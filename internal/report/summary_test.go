@@ -0,0 +1,87 @@
+package report
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/user/zenwatch/internal/ansi"
+	"github.com/user/zenwatch/internal/metrics"
+	"github.com/user/zenwatch/internal/warning"
+)
+
+func summaryData() ReportData {
+	data := newTestReportData()
+	data.Stats.TotalLinesAdded, data.Stats.TotalLinesDeleted = 120, 30
+	for i := 0; i < 7; i++ {
+		data.Stats.ComplexityStats = append(data.Stats.ComplexityStats, metrics.ComplexityStat{
+			Complexity: 40 - 3*i, FunctionName: fmt.Sprintf("Handle%d", i), File: "internal/api/handlers.go", Line: 10 + 20*i,
+		})
+	}
+	data.Stats.FunctionsOverThreshold = len(data.Stats.ComplexityStats)
+	data.Stats.AverageComplexity = 31
+	data.Warnings = []warning.Warning{{Code: warning.UnparseableFile, File: "broken.go", Message: "syntax error"}}
+	return data
+}
+
+func TestWriteTerminalSummaryGolden(t *testing.T) {
+	var buf bytes.Buffer
+	verdict := errors.New("over budget: functions-over-threshold is 7, budget 5")
+	if err := WriteTerminalSummary(&buf, summaryData(), "report.md", verdict, ansi.Styler{}); err != nil {
+		t.Fatalf("WriteTerminalSummary failed: %v", err)
+	}
+
+	golden := filepath.Join("testdata", "summary.golden.txt")
+	if *update {
+		if err := os.WriteFile(golden, buf.Bytes(), 0644); err != nil {
+			t.Fatalf("Failed to update golden file: %v", err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("Failed to read golden file (run with -update to create it): %v", err)
+	}
+	if got := buf.String(); got != string(want) {
+		t.Errorf("Summary differs from %s (run with -update if intended):\n%s", golden, got)
+	}
+}
+
+func TestWriteTerminalSummaryColor(t *testing.T) {
+	var plain, colored bytes.Buffer
+	data := summaryData()
+	if err := WriteTerminalSummary(&plain, data, "report.md", nil, ansi.Styler{}); err != nil {
+		t.Fatalf("WriteTerminalSummary failed: %v", err)
+	}
+	if err := WriteTerminalSummary(&colored, data, "report.md", nil, ansi.Styler{Color: true}); err != nil {
+		t.Fatalf("WriteTerminalSummary failed: %v", err)
+	}
+	if strings.Contains(plain.String(), "\x1b[") {
+		t.Errorf("Expected no escape sequences without color, got:\n%q", plain.String())
+	}
+	if !strings.Contains(colored.String(), "\x1b[") {
+		t.Errorf("Expected escape sequences with color")
+	}
+	// Styling must not change the text or the box alignment.
+	if got := ansi.Strip(colored.String()); got != plain.String() {
+		t.Errorf("Expected the stripped colored summary to equal the plain one, got:\n%s\nwant:\n%s", got, plain.String())
+	}
+	if !strings.Contains(plain.String(), "Verdict: PASS\n") {
+		t.Errorf("Expected a passing verdict, got:\n%s", plain.String())
+	}
+}
+
+func TestWriteTerminalSummaryFast(t *testing.T) {
+	data := newTestReportData()
+	data.Stats.Fast = true
+	var buf bytes.Buffer
+	if err := WriteTerminalSummary(&buf, data, "report.md", nil, ansi.Styler{}); err != nil {
+		t.Fatalf("WriteTerminalSummary failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Complexity                       skipped in fast mode\n") || strings.Contains(buf.String(), "Top findings") {
+		t.Errorf("Expected the complexity metrics to be marked skipped, got:\n%s", buf.String())
+	}
+}
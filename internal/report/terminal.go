@@ -0,0 +1,24 @@
+package report
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/user/zenwatch/internal/metrics"
+)
+
+// WriteComplexityTable prints stats as an aligned terminal table in their given order,
+// flagging the functions over threshold.
+func WriteComplexityTable(w io.Writer, stats []metrics.ComplexityStat, threshold int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "STATUS\tCOMPLEXITY\tFUNCTION\tLOCATION\n")
+	for _, cs := range stats {
+		status := "ok"
+		if cs.Complexity > threshold {
+			status = "OVER"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s:%d\n", status, cs.Complexity, cs.FunctionName, cs.File, cs.Line)
+	}
+	return tw.Flush()
+}
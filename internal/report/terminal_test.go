@@ -0,0 +1,30 @@
+package report
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/user/zenwatch/internal/metrics"
+)
+
+func TestWriteComplexityTable(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteComplexityTable(&buf, []metrics.ComplexityStat{
+		{Complexity: 21, FunctionName: "AnalyzeLatestCommit", File: "internal/git/git.go", Line: 76},
+		{Complexity: 3, FunctionName: "Cleanup", File: "internal/git/git.go", Line: 198},
+	}, 15)
+	if err != nil {
+		t.Fatalf("WriteComplexityTable failed: %v", err)
+	}
+
+	expected := strings.Join([]string{
+		"STATUS  COMPLEXITY  FUNCTION             LOCATION",
+		"OVER    21          AnalyzeLatestCommit  internal/git/git.go:76",
+		"ok      3           Cleanup              internal/git/git.go:198",
+		"",
+	}, "\n")
+	if buf.String() != expected {
+		t.Errorf("Unexpected table:\n%q\nwant:\n%q", buf.String(), expected)
+	}
+}
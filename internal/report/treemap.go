@@ -0,0 +1,78 @@
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/user/zenwatch/internal/metrics"
+)
+
+// TreemapSchemaVersion is the version of the treemap JSON format.
+const TreemapSchemaVersion = 1
+
+// Kinds of treemap nodes.
+const (
+	TreemapDirectory = "directory"
+	TreemapFile      = "file"
+)
+
+// Treemap is the treemap-json output: the directory rollup with its files as leaves,
+// sized by source lines.
+type Treemap struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	RepoURL       string       `json:"repoURL"`
+	CommitHash    string       `json:"commitHash"`
+	Root          *TreemapNode `json:"root"`
+}
+
+// TreemapNode is a directory or a file. The SLOC of a directory is the sum of its children.
+type TreemapNode struct {
+	Name     string         `json:"name"`
+	Path     string         `json:"path"` // Slash-separated, "." for the root
+	Kind     string         `json:"kind"` // TreemapDirectory or TreemapFile
+	SLOC     int            `json:"sloc"`
+	Children []*TreemapNode `json:"children,omitempty"`
+}
+
+// BuildTreemap converts a directory rollup built with RollupOptions.ListFiles into a treemap.
+// Subdirectories come first, then the files, each in rollup order. Files of directories
+// deeper than the rollup's MaxDepth or folded into their parent are leaves of the deepest
+// directory shown, named by their path relative to it.
+func BuildTreemap(root *metrics.DirectoryStat) *TreemapNode {
+	if root == nil {
+		return nil
+	}
+	node := &TreemapNode{Name: path.Base(root.Path), Path: root.Path, Kind: TreemapDirectory, SLOC: root.SLOC}
+	for _, child := range root.Children {
+		node.Children = append(node.Children, BuildTreemap(child))
+	}
+	for _, file := range root.FileStats {
+		name := strings.TrimPrefix(file.Path, root.Path+"/")
+		node.Children = append(node.Children, &TreemapNode{Name: name, Path: file.Path, Kind: TreemapFile, SLOC: file.SLOC})
+	}
+	return node
+}
+
+// WriteTreemapJSON writes the treemap of data's directory rollup as indented JSON to w.
+// It is the treemap-json Formatter.
+func WriteTreemapJSON(data ReportData, w io.Writer) error {
+	treemap := Treemap{SchemaVersion: TreemapSchemaVersion, RepoURL: data.RepoURL}
+	if data.Commit != nil {
+		treemap.CommitHash = data.Commit.Hash
+	}
+	if data.Stats != nil {
+		treemap.Root = BuildTreemap(data.Stats.DirectoryRollup)
+	}
+
+	content, err := json.MarshalIndent(treemap, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode treemap: %w", err)
+	}
+	if _, err := w.Write(append(content, '\n')); err != nil {
+		return fmt.Errorf("failed to write treemap: %w", err)
+	}
+	return nil
+}
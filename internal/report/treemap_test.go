@@ -0,0 +1,89 @@
+package report
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/user/zenwatch/internal/metrics"
+)
+
+func TestWriteTreemapJSON(t *testing.T) {
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"main.go":                "package main\n\nfunc main() {}\n",
+		"internal/git/git.go":    "package git\n\nfunc A() {}\nfunc B() {}\n",
+		"internal/git/deep/x.go": "package deep\n",
+		"internal/report/r.go":   "package report\n\n\nfunc R() {}\n",
+		"internal/report/doc.go": "// Package report.\npackage report\n",
+		"docs/guide/index.md":    "# Guide\n\nText\n",
+	} {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	opts := metrics.RollupOptions{MaxDepth: 2, SortBy: metrics.RollupSortPath, ListFiles: true}
+	rollup, _, err := metrics.ComputeDirectoryRollup(root, nil, nil, opts)
+	if err != nil {
+		t.Fatalf("ComputeDirectoryRollup failed: %v", err)
+	}
+	data := newTestReportData()
+	data.Stats.DirectoryRollup = rollup
+
+	var buf bytes.Buffer
+	if err := WriteTreemapJSON(data, &buf); err != nil {
+		t.Fatalf("WriteTreemapJSON failed: %v", err)
+	}
+	var treemap Treemap
+	if err := json.Unmarshal(buf.Bytes(), &treemap); err != nil {
+		t.Fatalf("Failed to decode treemap: %v\n%s", err, buf.String())
+	}
+	if treemap.SchemaVersion != TreemapSchemaVersion || treemap.RepoURL != data.RepoURL || treemap.CommitHash != data.Commit.Hash {
+		t.Errorf("Unexpected treemap header: %+v", treemap)
+	}
+
+	// Every file is a leaf of its directory, with internal/git/deep beyond the depth limit
+	// listed in internal/git.
+	var leaves []string
+	var walk func(node *TreemapNode, parent string)
+	walk = func(node *TreemapNode, parent string) {
+		if node.Kind == TreemapFile {
+			leaves = append(leaves, parent+" > "+node.Name)
+			return
+		}
+		sum := 0
+		for _, child := range node.Children {
+			sum += child.SLOC
+			walk(child, node.Path)
+		}
+		if sum != node.SLOC {
+			t.Errorf("Expected %s to have the %d lines of its children, got %d", node.Path, sum, node.SLOC)
+		}
+	}
+	walk(treemap.Root, "")
+	want := []string{
+		"docs/guide > index.md",
+		"internal/git > deep/x.go",
+		"internal/git > git.go",
+		"internal/report > doc.go",
+		"internal/report > r.go",
+		". > main.go",
+	}
+	if len(leaves) != len(want) {
+		t.Fatalf("Expected leaves %v, got %v", want, leaves)
+	}
+	for i := range want {
+		if leaves[i] != want[i] {
+			t.Fatalf("Expected leaves %v, got %v", want, leaves)
+		}
+	}
+	if treemap.Root.Path != "." || treemap.Root.SLOC != 12 {
+		t.Errorf("Expected the root to hold all 12 lines, got %+v", treemap.Root)
+	}
+}
@@ -0,0 +1,152 @@
+package vcs
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/user/zenwatch/internal/git"
+)
+
+// clonePrefix is shared with git clones, so zenwatch gc also removes leftover Mercurial clones.
+const clonePrefix = "zenwatch-clone-"
+
+// Mercurial is the VCS of Mercurial repositories. It runs the hg binary, which must be on PATH.
+// Mercurial has no shallow clones, so the full history is always cloned.
+type Mercurial struct{}
+
+func (Mercurial) Name() string { return NameMercurial }
+
+func (Mercurial) Clone(url string, depth int) (string, git.CloneStats, error) {
+	url = strings.TrimPrefix(url, mercurialURLPrefix)
+	tempDir, err := os.MkdirTemp("", clonePrefix+"*")
+	if err != nil {
+		return "", git.CloneStats{}, fmt.Errorf("failed to create temp dir: %w", err)
+	}
+	if _, err := runHg("", "clone", "--quiet", url, tempDir); err != nil {
+		os.RemoveAll(tempDir)
+		return "", git.CloneStats{}, fmt.Errorf("failed to clone repository %s: %w", url, err)
+	}
+	return tempDir, git.CloneStats{}, nil
+}
+
+// hgCommitTemplate prints the fields of a commit on separate lines; the description comes
+// last as it may span several.
+const hgCommitTemplate = "{node}\\n{author|person}\\n{author|email}\\n{date|hgdate}\\n{desc}"
+
+func (Mercurial) LatestCommit(repoPath string) (*git.RepositoryInfo, error) {
+	out, err := runHg(repoPath, "log", "--rev", ".", "--template", hgCommitTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest commit: %w", err)
+	}
+	fields := strings.SplitN(string(out), "\n", 5)
+	if len(fields) < 5 {
+		return nil, fmt.Errorf("failed to parse latest commit: unexpected hg log output %q", out)
+	}
+	date, err := parseHgDate(fields[3])
+	if err != nil {
+		return nil, err
+	}
+	commit := git.CommitInfo{
+		Hash:        fields[0],
+		Author:      fields[1],
+		Email:       fields[2],
+		Date:        date.String(),
+		When:        date,
+		Message:     strings.Split(fields[4], "\n")[0],
+		FullMessage: strings.TrimRight(fields[4], "\n"),
+	}
+
+	// --git diffs name both sides of every file, including added and deleted ones.
+	diff, err := runHg(repoPath, "diff", "--git", "--change", ".")
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff latest commit: %w", err)
+	}
+	changed, err := parseDiffLineCounts(bytes.NewReader(diff))
+	if err != nil {
+		return nil, err
+	}
+	commit.FilesChanged = len(changed)
+	for _, cf := range changed {
+		commit.LinesAdded += cf.LinesAdded
+		commit.LinesDeleted += cf.LinesDeleted
+	}
+	return &git.RepositoryInfo{
+		TempPath:          repoPath,
+		LatestCommit:      commit,
+		ChangedFiles:      changed,
+		TotalLinesAdded:   commit.LinesAdded,
+		TotalLinesDeleted: commit.LinesDeleted,
+	}, nil
+}
+
+func (Mercurial) Cleanup(repoPath string) { os.RemoveAll(repoPath) }
+
+// parseHgDate parses hg's "hgdate" format: Unix seconds and the offset in seconds west of UTC.
+func parseHgDate(s string) (time.Time, error) {
+	var seconds, offset int64
+	if _, err := fmt.Sscanf(s, "%d %d", &seconds, &offset); err != nil {
+		return time.Time{}, fmt.Errorf("failed to parse commit date %q: %w", s, err)
+	}
+	return time.Unix(seconds, 0).In(time.FixedZone("", int(-offset))), nil
+}
+
+// parseDiffLineCounts counts the added and deleted lines of every file of a git-style diff,
+// in the order the files appear.
+func parseDiffLineCounts(r io.Reader) ([]git.ChangedFileStats, error) {
+	var changed []git.ChangedFileStats
+	var current *git.ChangedFileStats
+	inHunk := false
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			// "diff --git a/<path> b/<path>": the new path names renamed files.
+			path := line[strings.LastIndex(line, " b/")+3:]
+			changed = append(changed, git.ChangedFileStats{Path: path, FileType: strings.ToLower(filepath.Ext(path))})
+			current = &changed[len(changed)-1]
+			inHunk = false
+		case current == nil:
+		case strings.HasPrefix(line, "@@"):
+			inHunk = true
+		case !inHunk:
+			// File headers such as "--- a/<path>" and "+++ b/<path>".
+		case strings.HasPrefix(line, "+"):
+			current.LinesAdded++
+		case strings.HasPrefix(line, "-"):
+			current.LinesDeleted++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read diff: %w", err)
+	}
+	return changed, nil
+}
+
+// runHg runs hg with args in dir, or the current directory if dir is empty, and returns its
+// standard output.
+func runHg(dir string, args ...string) ([]byte, error) {
+	if _, err := exec.LookPath("hg"); err != nil {
+		return nil, errors.New("Mercurial repositories need the hg binary on PATH")
+	}
+	cmd := exec.Command("hg", append([]string{"--noninteractive"}, args...)...)
+	cmd.Dir = dir
+	// HGPLAIN disables user configuration that changes hg's output.
+	cmd.Env = append(os.Environ(), "HGPLAIN=1")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("hg %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
@@ -0,0 +1,84 @@
+// Package vcs abstracts the version control systems zenwatch can analyze. Every
+// implementation produces a git.RepositoryInfo, so the metrics and report layers do not
+// depend on the system a repository uses.
+package vcs
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/user/zenwatch/internal/git"
+)
+
+// Names of the supported version control systems, as accepted by --vcs.
+const (
+	NameGit       = "git"
+	NameMercurial = "hg"
+)
+
+// mercurialURLPrefix marks Mercurial URLs, as in git-remote-hg: "hg::https://host/repo".
+const mercurialURLPrefix = "hg::"
+
+// VCS clones a repository and describes its latest commit.
+type VCS interface {
+	// Name returns the name of the system, one of the Name* constants.
+	Name() string
+	// Clone copies the repository at url into a new temporary directory and returns its
+	// path. depth limits the history to the last commits where supported; 0 clones it all.
+	Clone(url string, depth int) (string, git.CloneStats, error)
+	// LatestCommit describes the checked-out commit of the clone at repoPath and the files
+	// it changed.
+	LatestCommit(repoPath string) (*git.RepositoryInfo, error)
+	// Cleanup removes a clone made by Clone.
+	Cleanup(repoPath string)
+}
+
+// Git is the VCS of git repositories.
+type Git struct {
+	SkipChangedFunctions bool   // Leave RepositoryInfo.ChangedFunctions empty instead of parsing the changed Go files
+	Branch               string // Branch to clone, see git.CloneOptions; empty clones the default branch
+	Commit               string // Commit to check out and describe instead of the latest, see git.ResolveCommit
+}
+
+func (Git) Name() string { return NameGit }
+
+func (g Git) Clone(url string, depth int) (string, git.CloneStats, error) {
+	return git.CloneRepositoryWithOptions(url, git.CloneOptions{Depth: depth, Branch: g.Branch, Commit: g.Commit})
+}
+
+func (g Git) LatestCommit(repoPath string) (*git.RepositoryInfo, error) {
+	switch {
+	case g.Commit != "" && g.SkipChangedFunctions:
+		return git.AnalyzeCommitFiles(repoPath, g.Commit)
+	case g.Commit != "":
+		return git.AnalyzeCommit(repoPath, g.Commit)
+	case g.SkipChangedFunctions:
+		return git.AnalyzeLatestCommitFiles(repoPath)
+	}
+	return git.AnalyzeLatestCommit(repoPath)
+}
+
+func (Git) Cleanup(repoPath string) { git.Cleanup(repoPath) }
+
+// ForURL returns the VCS named name, or when name is empty the one url belongs to: Mercurial
+// for "hg::" URLs and local repositories with a .hg directory, git otherwise.
+func ForURL(url, name string) (VCS, error) {
+	switch name {
+	case NameGit:
+		return Git{}, nil
+	case NameMercurial:
+		return Mercurial{}, nil
+	case "":
+	default:
+		return nil, fmt.Errorf("unknown version control system %q (supported: %s, %s)", name, NameGit, NameMercurial)
+	}
+	if strings.HasPrefix(url, mercurialURLPrefix) {
+		return Mercurial{}, nil
+	}
+	if info, err := os.Stat(filepath.Join(url, ".hg")); err == nil && info.IsDir() {
+		return Mercurial{}, nil
+	}
+	return Git{}, nil
+}
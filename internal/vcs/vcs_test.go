@@ -0,0 +1,148 @@
+package vcs
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestForURL(t *testing.T) {
+	hgRepo := t.TempDir()
+	if err := os.Mkdir(filepath.Join(hgRepo, ".hg"), 0o755); err != nil {
+		t.Fatalf("Failed to create .hg: %v", err)
+	}
+	tests := []struct {
+		url, name string
+		want      string
+	}{
+		{"https://github.com/user/repo.git", "", NameGit},
+		{t.TempDir(), "", NameGit},
+		{"hg::https://hg.example.com/repo", "", NameMercurial},
+		{hgRepo, "", NameMercurial},
+		{"https://hg.example.com/repo", "hg", NameMercurial},
+		{hgRepo, "git", NameGit},
+	}
+	for _, tt := range tests {
+		v, err := ForURL(tt.url, tt.name)
+		if err != nil {
+			t.Errorf("ForURL(%q, %q) failed: %v", tt.url, tt.name, err)
+			continue
+		}
+		if v.Name() != tt.want {
+			t.Errorf("ForURL(%q, %q) = %s, want %s", tt.url, tt.name, v.Name(), tt.want)
+		}
+	}
+	if _, err := ForURL("https://github.com/user/repo.git", "svn"); err == nil {
+		t.Errorf("Expected an unknown version control system to be rejected")
+	}
+}
+
+func TestParseDiffLineCounts(t *testing.T) {
+	diff := `diff --git a/main.go b/main.go
+--- a/main.go
++++ b/main.go
+@@ -1,3 +1,4 @@
+ package main
+-// old
++// new
++// added
+--- deleted line starting with --
+diff --git a/new.md b/new.md
+new file mode 100644
+--- /dev/null
++++ b/new.md
+@@ -0,0 +1,1 @@
++# Title
+diff --git a/old.go b/renamed.go
+rename from old.go
+rename to renamed.go
+`
+	changed, err := parseDiffLineCounts(strings.NewReader(diff))
+	if err != nil {
+		t.Fatalf("parseDiffLineCounts failed: %v", err)
+	}
+	if len(changed) != 3 {
+		t.Fatalf("Expected 3 changed files, got %+v", changed)
+	}
+	if cf := changed[0]; cf.Path != "main.go" || cf.FileType != ".go" || cf.LinesAdded != 2 || cf.LinesDeleted != 2 {
+		t.Errorf("Unexpected counts for main.go: %+v", cf)
+	}
+	if cf := changed[1]; cf.Path != "new.md" || cf.LinesAdded != 1 || cf.LinesDeleted != 0 {
+		t.Errorf("Unexpected counts for new.md: %+v", cf)
+	}
+	if cf := changed[2]; cf.Path != "renamed.go" || cf.LinesAdded != 0 || cf.LinesDeleted != 0 {
+		t.Errorf("Unexpected counts for renamed.go: %+v", cf)
+	}
+}
+
+func TestParseHgDate(t *testing.T) {
+	// hg offsets are in seconds west of UTC, so -7200 is UTC+2.
+	date, err := parseHgDate("1700000000 -7200")
+	if err != nil {
+		t.Fatalf("parseHgDate failed: %v", err)
+	}
+	if !date.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("Expected the Unix time to be kept, got %v", date)
+	}
+	if _, offset := date.Zone(); offset != 7200 {
+		t.Errorf("Expected a UTC+2 offset, got %d", offset)
+	}
+	if _, err := parseHgDate("yesterday"); err == nil {
+		t.Errorf("Expected an invalid date to be rejected")
+	}
+}
+
+func TestMercurialLatestCommit(t *testing.T) {
+	if _, err := exec.LookPath("hg"); err != nil {
+		t.Skip("Skipping TestMercurialLatestCommit: hg not on PATH")
+	}
+
+	src := t.TempDir()
+	runHgTest(t, src, "init")
+	writeTestFile(t, filepath.Join(src, "main.go"), "package main\n")
+	runHgTest(t, src, "add", "main.go")
+	runHgTest(t, src, "commit", "-m", "initial", "-u", "Jane Doe <jane@example.com>")
+	writeTestFile(t, filepath.Join(src, "main.go"), "package main\n\nfunc main() {}\n")
+	writeTestFile(t, filepath.Join(src, "README.md"), "# Demo\n")
+	runHgTest(t, src, "add", "README.md")
+	runHgTest(t, src, "commit", "-m", "add main\n\nwith a body", "-u", "Jane Doe <jane@example.com>")
+
+	hg := Mercurial{}
+	repoPath, _, err := hg.Clone("hg::"+src, 1)
+	if err != nil {
+		t.Fatalf("Clone failed: %v", err)
+	}
+	defer hg.Cleanup(repoPath)
+
+	info, err := hg.LatestCommit(repoPath)
+	if err != nil {
+		t.Fatalf("LatestCommit failed: %v", err)
+	}
+	commit := info.LatestCommit
+	if len(commit.Hash) != 40 || commit.Author != "Jane Doe" || commit.Email != "jane@example.com" || commit.Message != "add main" {
+		t.Errorf("Unexpected commit: %+v", commit)
+	}
+	if commit.FilesChanged != 2 || commit.LinesAdded != 3 || commit.LinesDeleted != 0 {
+		t.Errorf("Expected 2 files and 3 added lines, got %+v", commit)
+	}
+	if info.TotalLinesAdded != commit.LinesAdded || len(info.ChangedFiles) != 2 {
+		t.Errorf("Expected the totals to match the commit, got %+v", info)
+	}
+}
+
+func runHgTest(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	if _, err := runHg(dir, args...); err != nil {
+		t.Fatalf("%v", err)
+	}
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
@@ -0,0 +1,49 @@
+// Package warning describes problems that did not stop an analysis but make its results
+// less complete, so they can be reported instead of silently ignored.
+package warning
+
+import "fmt"
+
+// Code identifies the kind of a warning.
+type Code string
+
+const (
+	UnparseableFile        Code = "unparseable-file"         // A Go file could not be parsed and was skipped
+	CommitStatsUnavailable Code = "commit-stats-unavailable" // Line counts of the analyzed commit could not be computed
+	ShallowCloneFallback   Code = "shallow-clone-fallback"   // The parent commit is missing, so the commit was diffed against an empty tree
+	BinaryFilesSkipped     Code = "binary-files-skipped"     // Binary files are not counted as source lines
+	BlameUnavailable       Code = "blame-unavailable"        // A file could not be blamed, so its functions have no owner
+	NewerGoVersion         Code = "newer-go-version"         // go.mod declares a Go version newer than zenwatch's parser
+	HistoryTruncated       Code = "history-truncated"        // Fewer commits than requested are in the clone's history
+	NoTag                  Code = "no-tag"                   // No tag is reachable from HEAD to compare to
+	BuildSkipped           Code = "build-skipped"            // The build check could not run, e.g. without the Go toolchain
+	RepoArchived           Code = "repo-archived"            // The forge reports the repository as archived or disabled
+	RepoMoved              Code = "repo-moved"               // The forge reports the repository under a new name
+	SubmoduleUnavailable   Code = "submodule-unavailable"    // A submodule could not be fetched or analyzed, so only its path and pinned commit are reported
+	FileTooLarge           Code = "file-too-large"           // A file over the size limit was skipped
+	DegradedMode           Code = "degraded-mode"            // The heap exceeded the memory budget, so optional analyses were skipped
+	PhaseTimeout           Code = "phase-timeout"            // An analysis ran out of its time budget, so its results are partial or missing
+	PhaseFailed            Code = "phase-failed"             // An analysis returned an error or panicked, so its section is missing
+	ConfigIgnored          Code = "config-ignored"           // A .zenwatch.yaml file or key was malformed or not allowed in the repository, so it was not applied
+	PluginFailed           Code = "plugin-failed"            // An analyzer plugin failed or reported an invalid finding, so its findings are missing
+)
+
+// Warning is a single analysis problem, optionally tied to a file and line.
+type Warning struct {
+	Code    Code   `json:"code"`
+	Message string `json:"message"`
+	File    string `json:"file,omitempty"` // Optional: slash-separated path relative to the repository root
+	Line    int    `json:"line,omitempty"` // Optional: 0 if the warning is not tied to a line
+}
+
+// Location returns "file:line", "file" or "" depending on which are set.
+func (w Warning) Location() string {
+	switch {
+	case w.File == "":
+		return ""
+	case w.Line == 0:
+		return w.File
+	default:
+		return fmt.Sprintf("%s:%d", w.File, w.Line)
+	}
+}
@@ -0,0 +1,19 @@
+package warning
+
+import "testing"
+
+func TestLocation(t *testing.T) {
+	tests := []struct {
+		warning  Warning
+		expected string
+	}{
+		{Warning{Code: CommitStatsUnavailable}, ""},
+		{Warning{Code: UnparseableFile, File: "cmd/main.go"}, "cmd/main.go"},
+		{Warning{Code: UnparseableFile, File: "cmd/main.go", Line: 12}, "cmd/main.go:12"},
+	}
+	for _, tc := range tests {
+		if got := tc.warning.Location(); got != tc.expected {
+			t.Errorf("Location() of %+v = %q, expected %q", tc.warning, got, tc.expected)
+		}
+	}
+}
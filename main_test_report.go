@@ -20,6 +20,7 @@ func main() {
 		Message: "feat: implement amazing new features",
 	}
 
+	complexityThreshold := 15
 	overallStats := &metrics.OverallStats{
 		TotalLinesAdded:   150,
 		TotalLinesDeleted: 30,
@@ -31,17 +32,15 @@ func main() {
 			{Complexity: 20, Package: "main", FunctionName: "complexFunc", File: "main.go", Line: 42},
 			{Complexity: 16, Package: "helper", FunctionName: "anotherComplex", File: "utils/helper.go", Line: 101},
 		},
-		AverageComplexity: 18.0,
-		FunctionsOverThreshold: 2,
 	}
+	overallStats.AverageComplexity, overallStats.FunctionsOverThreshold = metrics.AggregateComplexity(overallStats.ComplexityStats, complexityThreshold)
 
-	complexityThreshold := 15
 	repoURL := "https://github.com/user/testrepo"
 	reportDate := time.Now().Format("2006-01-02 15:04:05 MST")
 
 	// Test badge URL generation
 	totalChanges := overallStats.TotalLinesAdded + overallStats.TotalLinesDeleted
-	badgeURL := report.GenerateBadgeURL(totalChanges, overallStats.AverageComplexity)
+	badgeURL := report.GenerateBadgeURL(totalChanges, overallStats.AverageComplexity, report.BadgeOptions{})
 	fmt.Println("Generated Badge URL:", badgeURL)
 
 	reportData := report.ReportData{
@@ -0,0 +1,162 @@
+package zenwatch
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// archiveExtensions are the extensions of the archives a Target may name, see isArchive.
+var archiveExtensions = []string{".tar.gz", ".tgz", ".tar", ".zip"}
+
+// ErrNoRepositoryInArchive is returned by Analyze for an archive holding no git or Mercurial
+// repository. The analysis reports a commit and its history, so an export of the files alone,
+// such as git archive writes, cannot be analyzed.
+var ErrNoRepositoryInArchive = errors.New("the archive holds no git or Mercurial repository")
+
+// isArchive reports whether target names a local archive file rather than a repository.
+func isArchive(target string) bool {
+	lower := strings.ToLower(target)
+	for _, ext := range archiveExtensions {
+		if strings.HasSuffix(lower, ext) {
+			info, err := os.Stat(target)
+			return err == nil && info.Mode().IsRegular()
+		}
+	}
+	return false
+}
+
+// extractArchive extracts archive into a new temporary directory, which the caller removes,
+// and returns it with the path of the repository in it: the directory itself or, as for
+// archives of a checkout such as repo/.git, its only subdirectory.
+func extractArchive(archive string) (dir, repoPath string, err error) {
+	dir, err = os.MkdirTemp("", "zenwatch-archive-*")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create a directory for %s: %w", archive, err)
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(dir)
+		}
+	}()
+	if strings.HasSuffix(strings.ToLower(archive), ".zip") {
+		err = extractZip(archive, dir)
+	} else {
+		err = extractTar(archive, dir)
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("failed to extract %s: %w", archive, err)
+	}
+
+	repoPath = dir
+	if entries, err := os.ReadDir(dir); err == nil && len(entries) == 1 && entries[0].IsDir() {
+		repoPath = filepath.Join(dir, entries[0].Name())
+	}
+	for _, marker := range []string{".git", ".hg"} {
+		if _, err := os.Stat(filepath.Join(repoPath, marker)); err == nil {
+			return dir, repoPath, nil
+		}
+	}
+	return "", "", fmt.Errorf("%s: %w", archive, ErrNoRepositoryInArchive)
+}
+
+// extractTar extracts a tar archive, gzip-compressed unless it ends in .tar, into dir.
+func extractTar(archive, dir string) error {
+	f, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if !strings.HasSuffix(strings.ToLower(archive), ".tar") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return err
+		}
+		defer gz.Close()
+		r = gz
+	}
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = extractEntry(dir, hdr.Name, fs.ModeDir, nil)
+		case tar.TypeReg:
+			err = extractEntry(dir, hdr.Name, fs.FileMode(hdr.Mode), tr)
+		}
+		// Links and special files are skipped: the analysis reads the history, not the checkout.
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// extractZip extracts a zip archive into dir.
+func extractZip(archive, dir string) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	for _, file := range zr.File {
+		mode := file.Mode()
+		if !mode.IsDir() && !mode.IsRegular() {
+			continue // As in extractTar
+		}
+		if err := extractZipEntry(dir, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// extractZipEntry extracts one file or directory of a zip archive into dir.
+func extractZipEntry(dir string, file *zip.File) error {
+	if file.Mode().IsDir() {
+		return extractEntry(dir, file.Name, fs.ModeDir, nil)
+	}
+	rc, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return extractEntry(dir, file.Name, file.Mode(), rc)
+}
+
+// extractEntry creates the directory or the file name of an archive below dir, with the
+// content of r for a file. Names leaving dir, such as "../x" or "/x", are rejected.
+func extractEntry(dir, name string, mode fs.FileMode, r io.Reader) error {
+	if !filepath.IsLocal(filepath.FromSlash(name)) {
+		return fmt.Errorf("entry %q is outside the archive's directory", name)
+	}
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if mode.IsDir() {
+		return os.MkdirAll(path, 0o755)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	out, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm()|0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, r); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
@@ -0,0 +1,123 @@
+package zenwatch
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+// writeTarGz archives the files under root into a gzip-compressed tar at path, below prefix.
+func writeTarGz(t *testing.T, path, root, prefix string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("Failed to create %s: %v", path, err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil || rel == "." {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		hdr.Name = prefix + filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil || !info.Mode().IsRegular() {
+			return err
+		}
+		content, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer content.Close()
+		_, err = io.Copy(tw, content)
+		return err
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if err == nil {
+		err = gz.Close()
+	}
+	if err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+func TestAnalyzeArchive(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping TestAnalyzeArchive: git not on PATH")
+	}
+	repo := t.TempDir()
+	writeFile(t, repo, "lib/lib.go", "package lib\n\n"+complexFunc("Complex", ComplexityThreshold+5))
+	runGit(t, repo, "init", "-q")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "-q", "-m", "Add lib")
+	archive := filepath.Join(t.TempDir(), "repo.tar.gz")
+	writeTarGz(t, archive, repo, "repo/")
+
+	result, err := (&Client{}).Analyze(context.Background(), Target{URL: archive}, DefaultOptions())
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if result.Stats.FunctionsOverThreshold != 1 || result.Repository.LatestCommit.Message != "Add lib" {
+		t.Errorf("Expected the commit of the archived repository, got %q with %d function(s) over threshold",
+			result.Repository.LatestCommit.Message, result.Stats.FunctionsOverThreshold)
+	}
+	if result.Repository.URL != archive {
+		t.Errorf("Expected the report to name the archive, got %s", result.Repository.URL)
+	}
+
+	// An export of the files has no history to analyze.
+	export := filepath.Join(t.TempDir(), "export.tar.gz")
+	writeTarGz(t, export, filepath.Join(repo, "lib"), "")
+	if _, err := (&Client{}).Analyze(context.Background(), Target{URL: export}, DefaultOptions()); !errors.Is(err, ErrNoRepositoryInArchive) {
+		t.Errorf("Expected ErrNoRepositoryInArchive, got %v", err)
+	}
+}
+
+func TestExtractArchiveRejectsEscapingEntries(t *testing.T) {
+	archive := filepath.Join(t.TempDir(), "evil.zip")
+	f, err := os.Create(archive)
+	if err != nil {
+		t.Fatalf("Failed to create %s: %v", archive, err)
+	}
+	zw := zip.NewWriter(f)
+	w, err := zw.Create("../outside.txt")
+	if err == nil {
+		_, err = w.Write([]byte("escaped"))
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	f.Close()
+	if err != nil {
+		t.Fatalf("Failed to write %s: %v", archive, err)
+	}
+
+	if _, _, err := extractArchive(archive); err == nil {
+		t.Errorf("Expected an entry outside the archive's directory to be rejected")
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(archive), "outside.txt")); err == nil {
+		t.Errorf("Expected nothing to be written outside the extraction directory")
+	}
+}
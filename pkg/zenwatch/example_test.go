@@ -0,0 +1,52 @@
+package zenwatch_test
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/user/zenwatch/pkg/zenwatch"
+)
+
+// Analyzing a repository and rendering its Markdown report, as zenwatch analyze does.
+func ExampleClient_Analyze() {
+	client := &zenwatch.Client{Log: os.Stderr, GitHubToken: os.Getenv("GITHUB_TOKEN")}
+	opts := zenwatch.DefaultOptions()
+	opts.IncludeTests = true
+	result, err := client.Analyze(context.Background(), zenwatch.Target{URL: "https://github.com/user/repo.git"}, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d function(s) over threshold at %s\n", result.Stats.FunctionsOverThreshold, result.Repository.LatestCommit.Hash)
+	if err := result.RenderMarkdown(os.Stdout); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Analyzing a checked-out tree restricted to Go, without cloning.
+func ExampleAnalyzeTree() {
+	dir, err := os.MkdirTemp("", "zenwatch-example-")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := "package lib\n\nfunc Sign(x int) int {\n\tif x < 0 {\n\t\treturn -1\n\t}\n\treturn 1\n}\n"
+	if err := os.WriteFile(filepath.Join(dir, "lib.go"), []byte(src), 0644); err != nil {
+		log.Fatal(err)
+	}
+
+	opts := zenwatch.DefaultOptions()
+	opts.Rollup.Languages, err = zenwatch.ParseLanguageFilter("go")
+	if err != nil {
+		log.Fatal(err)
+	}
+	stats, err := zenwatch.AnalyzeTree(context.Background(), dir, opts)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d file(s), %d function(s) over a complexity of %d\n",
+		stats.DirectoryRollup.Files, stats.FunctionsOverThreshold, zenwatch.ComplexityThreshold)
+	// Output: 1 file(s), 0 function(s) over a complexity of 15
+}
@@ -0,0 +1,405 @@
+package zenwatch
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"time"
+
+	"github.com/user/zenwatch/internal/git"
+	"github.com/user/zenwatch/internal/metrics"
+	"github.com/user/zenwatch/internal/warning"
+)
+
+// buildCommitStats derives the commit-scoped statistics, the only ones available without source code.
+// Changed files outside the language filter or excluded by pattern are not counted.
+func buildCommitStats(repoPath string, repoInfo *git.RepositoryInfo, languages metrics.LanguageFilter, exclude metrics.ExcludeFilter) (*metrics.OverallStats, error) {
+	var changed []git.ChangedFileStats
+	paths := make([]string, 0, len(repoInfo.ChangedFiles))
+	for _, cf := range repoInfo.ChangedFiles {
+		if languages.Match(cf.Path) && !exclude.Excludes(cf.Path) {
+			changed = append(changed, cf)
+			paths = append(paths, cf.Path)
+		}
+	}
+	fileStats, err := metrics.ComputeFileTypeStats(repoPath, paths)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute file type stats: %w", err)
+	}
+	for _, cf := range changed {
+		metrics.AddFileTypeLines(fileStats, cf.Path, cf.LinesAdded, cf.LinesDeleted)
+	}
+	return &metrics.OverallStats{
+		TotalLinesAdded:   repoInfo.TotalLinesAdded,
+		TotalLinesDeleted: repoInfo.TotalLinesDeleted,
+		FileStats:         fileStats,
+	}, nil
+}
+
+// collectRunStats combines the clone transfer with the files walked in the clone. Parse errors
+// are the distinct files the analyses reported as unparseable.
+func collectRunStats(repoPath string, clone git.CloneStats, warnings []warning.Warning, rollup metrics.RollupOptions) (*metrics.RunStats, error) {
+	run := &metrics.RunStats{ObjectsFetched: clone.Objects, BytesTransferred: clone.Bytes}
+	if err := metrics.CountWalkedFiles(repoPath, rollup, run); err != nil {
+		return nil, fmt.Errorf("failed to count walked files: %w", err)
+	}
+	unparseable := make(map[string]bool)
+	for _, w := range warnings {
+		if w.Code == warning.UnparseableFile {
+			unparseable[w.File] = true
+		}
+	}
+	run.ParseErrors = len(unparseable)
+	return run, nil
+}
+
+// fastSkipped are the sections of the analyses Options.Fast skips, in the order they run.
+// The Directory Rollup still runs, as it only counts lines.
+var fastSkipped = []string{
+	metrics.SectionPackageCoupling,
+	metrics.SectionComplexity,
+	metrics.SectionBannedImports,
+	metrics.SectionGoVersion,
+	metrics.SectionVendorDrift,
+	metrics.SectionPackages,
+	metrics.SectionStyle,
+}
+
+// buildOverallStats derives the report statistics from the analyzed commit.
+// The Go analyses only run if the language filter includes Go, and none but the Directory
+// Rollup in fast mode. An analysis that fails
+// leaves its section out and is recorded in stats.Failed instead of failing the run, so
+// the commit and the other sections are still reported.
+func buildOverallStats(ctx context.Context, repoPath string, repoInfo *git.RepositoryInfo, opts Options) (*metrics.OverallStats, error) {
+	stats, err := buildCommitStats(repoPath, repoInfo, opts.Rollup.Languages, opts.Rollup.Exclude)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		coupling           []metrics.PackageCouplingStats
+		allComplexity      []metrics.ComplexityStat
+		complexityWarnings []warning.Warning
+		goVersionWarnings  []warning.Warning
+		buildWarnings      []warning.Warning
+		bannedImports      []metrics.BannedImport
+	)
+	limits := &analysisLimits{ctx: ctx, workers: opts.Workers, phaseTimeout: opts.PhaseTimeout, stats: stats}
+	if opts.Fast {
+		stats.Fast = true
+		for _, section := range fastSkipped {
+			stats.Phases = append(stats.Phases, metrics.PhaseStatus{Section: section, Status: metrics.PhaseSkipped})
+		}
+	}
+	if opts.Rollup.Languages.Includes("Go") && !opts.Fast {
+		if !limits.degrade(metrics.SectionPackageCoupling) {
+			limits.run(metrics.SectionPackageCoupling, func(ctx context.Context) (err error) {
+				coupling, err = metrics.ComputePackageCoupling(ctx, repoPath)
+				return err
+			})
+		}
+		limits.run(metrics.SectionComplexity, func(ctx context.Context) (err error) {
+			allComplexity, complexityWarnings, err = metrics.CollectComplexity(ctx, repoPath, opts.Workers)
+			return err
+		})
+		limits.run(metrics.SectionBannedImports, func(context.Context) (err error) {
+			bannedImports, err = metrics.FindBannedImports(repoPath, opts.BannedImports)
+			return err
+		})
+		limits.run(metrics.SectionGoVersion, func(context.Context) (err error) {
+			goVersionWarnings, err = metrics.CheckGoVersion(repoPath, runtime.Version())
+			return err
+		})
+		limits.run(metrics.SectionVendorDrift, func(context.Context) (err error) {
+			stats.VendorDrift, err = metrics.CheckVendorDrift(repoPath)
+			return err
+		})
+		if opts.CheckBuild {
+			limits.run(metrics.SectionBuild, func(ctx context.Context) (err error) {
+				stats.Build, buildWarnings, err = metrics.CheckBuild(ctx, repoPath, opts.Build)
+				return err
+			})
+		}
+	}
+	if exclude := opts.Rollup.Exclude; exclude.Active() {
+		allComplexity = slices.DeleteFunc(allComplexity, func(cs metrics.ComplexityStat) bool { return exclude.Excludes(cs.File) })
+		complexityWarnings = slices.DeleteFunc(complexityWarnings, func(w warning.Warning) bool { return w.File != "" && exclude.Excludes(w.File) })
+		bannedImports = slices.DeleteFunc(bannedImports, func(bi metrics.BannedImport) bool { return exclude.Excludes(bi.File) })
+	}
+	if opts.Rollup.Languages.Includes("Go") && !opts.Fast {
+		limits.run(metrics.SectionPackages, func(context.Context) (err error) {
+			stats.Packages, err = metrics.ComputePackageInventory(repoPath, allComplexity, opts.Rollup.Exclude)
+			return err
+		})
+	}
+	production, tests := metrics.SplitTestComplexity(opts.repoConfig.OverThreshold(allComplexity, ComplexityThreshold))
+	if n := opts.Excerpts; n != 0 {
+		if n < 0 {
+			n = len(production)
+		}
+		stats.Excerpts = extractExcerpts(repoPath, repoInfo.LatestCommit.Hash, production, n)
+	}
+	var trendWarnings []warning.Warning
+	if opts.Trend > 0 && opts.Rollup.Languages.Includes("Go") && !limits.degrade(metrics.SectionTrend) {
+		limits.run(metrics.SectionTrend, func(ctx context.Context) (err error) {
+			stats.ComplexityTrend, trendWarnings, err = computeComplexityTrend(ctx, repoPath, opts.Trend)
+			return err
+		})
+	}
+	var ownershipWarnings []warning.Warning
+	if opts.Ownership && !limits.degrade(metrics.SectionOwnership) {
+		limits.run(metrics.SectionOwnership, func(ctx context.Context) error {
+			unblamed := make(map[string]bool) // Files left unblamed once the time budget ran out
+			ownershipWarnings = metrics.AssignOwners(production, func(file string) ([]metrics.Author, error) {
+				if err := ctx.Err(); err != nil {
+					unblamed[file] = true
+					return nil, err
+				}
+				return git.BlameAuthors(repoPath, file)
+			})
+			if ctx.Err() != nil {
+				ownershipWarnings = slices.DeleteFunc(ownershipWarnings, func(w warning.Warning) bool { return unblamed[w.File] })
+			}
+			stats.ComplexityOwnership = metrics.ComputeComplexityOwnership(production)
+			return ctx.Err()
+		})
+	}
+	var planWarnings []warning.Warning
+	if opts.RefactoringPlan && !limits.degrade(metrics.SectionRefactoringPlan) {
+		limits.run(metrics.SectionRefactoringPlan, func(context.Context) error {
+			since := repoInfo.LatestCommit.When.Add(-metrics.DefaultPlanWindow)
+			changes, warnings, err := git.FileChangesSince(repoPath, since)
+			if err != nil {
+				return err
+			}
+			planWarnings = warnings
+			activity := make(map[string]metrics.FileActivity, len(changes))
+			for file, c := range changes {
+				activity[file] = metrics.FileActivity{Edits: c.Commits, Churn: c.LinesAdded + c.LinesDeleted}
+			}
+			weights := opts.PlanWeights
+			if weights == (metrics.PlanWeights{}) {
+				weights = metrics.DefaultPlanWeights()
+			}
+			// Ownership ran first, so the owner shares of production are set if it was asked for.
+			stats.RefactoringPlan = metrics.ComputeRefactoringPlan(production, activity, metrics.DefaultPlanWindow, weights, metrics.PlanSize)
+			return nil
+		})
+	}
+	var annotationWarnings []warning.Warning
+	if opts.AnnotationAuthors && opts.Rollup.Languages.Includes("Go") && !limits.degrade(metrics.SectionAnnotations) {
+		limits.run(metrics.SectionAnnotations, func(ctx context.Context) error {
+			annotations, err := metrics.ScanAnnotations(repoPath, opts.Rollup.Exclude)
+			if err != nil {
+				return fmt.Errorf("failed to scan annotations: %w", err)
+			}
+			unblamed := make(map[string]bool) // Files left unblamed once the time budget ran out
+			stats.AnnotationAuthors, annotationWarnings = metrics.AttributeAnnotations(annotations, func(file string) ([]metrics.Author, error) {
+				if err := ctx.Err(); err != nil {
+					unblamed[file] = true
+					return nil, err
+				}
+				return git.BlameAuthors(repoPath, file)
+			})
+			if ctx.Err() != nil {
+				annotationWarnings = slices.DeleteFunc(annotationWarnings, func(w warning.Warning) bool { return unblamed[w.File] })
+			}
+			return ctx.Err()
+		})
+	}
+	var freshnessWarnings []warning.Warning
+	if opts.RecentWindow > 0 && opts.Rollup.Languages.Includes("Go") && !limits.degrade(metrics.SectionFreshness) {
+		limits.run(metrics.SectionFreshness, func(ctx context.Context) (err error) {
+			stats.Freshness, freshnessWarnings, err = metrics.ComputeCodeFreshness(ctx, repoPath, opts.Rollup.Exclude, func(file string) ([]time.Time, error) {
+				lines, err := git.Blame(repoPath, file)
+				if err != nil {
+					return nil, err
+				}
+				dates := make([]time.Time, len(lines))
+				for i, line := range lines {
+					dates[i] = line.Date
+				}
+				return dates, nil
+			}, repoInfo.LatestCommit.When, opts.RecentWindow)
+			return err
+		})
+	}
+
+	churn := make(map[string]int)
+	for _, cf := range repoInfo.ChangedFiles {
+		churn[cf.Path] = cf.LinesAdded + cf.LinesDeleted
+	}
+	var rollupWarnings []warning.Warning
+	limits.run(metrics.SectionDirectoryRollup, func(context.Context) (err error) {
+		stats.DirectoryRollup, rollupWarnings, err = metrics.ComputeDirectoryRollup(repoPath, allComplexity, churn, opts.Rollup)
+		return err
+	})
+	stats.MaxLineLength = cmp.Or(opts.MaxLineLength, metrics.DefaultMaxLineLength)
+	if !opts.Fast {
+		limits.run(metrics.SectionStyle, func(context.Context) (err error) {
+			stats.Style, err = metrics.ComputeStyleStats(repoPath, metrics.StyleOptions{MaxLineLength: stats.MaxLineLength, Languages: opts.Rollup.Languages, Exclude: opts.Rollup.Exclude})
+			return err
+		})
+	}
+	if err := ctx.Err(); err != nil {
+		// The caller gave up on the analysis; the phases it cut short did not fail.
+		return nil, err
+	}
+
+	// production and tests are already over the threshold of their file's directory, which
+	// can be below the root's, so none is filtered out again.
+	stats.AverageComplexity, stats.FunctionsOverThreshold = metrics.AggregateComplexity(production, 0)
+	stats.ComplexityStats = production
+	stats.PackageCoupling = coupling
+	stats.BannedImports = bannedImports
+	stats.DirectoryOverrides = opts.repoConfig.Overrides()
+	stats.Warnings = slices.Concat(opts.repoConfig.Warnings(), goVersionWarnings, buildWarnings, complexityWarnings, rollupWarnings, ownershipWarnings, annotationWarnings, freshnessWarnings, trendWarnings, planWarnings, limits.warnings)
+	if opts.IncludeTests {
+		stats.TestAverageComplexity, stats.TestFunctionsOverThreshold = metrics.AggregateComplexity(tests, 0)
+		stats.TestComplexityStats = tests
+		for _, cs := range allComplexity {
+			if cs.TableDrivenTest {
+				stats.TableDrivenTestFunctions++
+			}
+		}
+	}
+	return stats, nil
+}
+
+// beforePhase is called before every analysis run by analysisLimits.run with its section.
+// Tests replace it to make an analysis fail.
+var beforePhase = func(section string) {}
+
+// analysisLimits applies the memory and time budgets to the analyses of buildOverallStats
+// and isolates their failures, recording the outcome of every analysis in stats.
+type analysisLimits struct {
+	ctx          context.Context // Parent of the phase contexts
+	workers      metrics.WorkerOptions
+	phaseTimeout time.Duration
+	stats        *metrics.OverallStats
+	warnings     []warning.Warning
+}
+
+// degrade reports whether the heap is over the memory budget even after collecting garbage,
+// in which case the optional analysis of section is to be skipped.
+func (l *analysisLimits) degrade(section string) bool {
+	if !l.workers.OverMemory() {
+		return false
+	}
+	runtime.GC()
+	if !l.workers.OverMemory() {
+		return false
+	}
+	l.stats.Degraded = append(l.stats.Degraded, section)
+	l.stats.Phases = append(l.stats.Phases, metrics.PhaseStatus{Section: section, Status: metrics.PhaseDegraded})
+	l.warnings = append(l.warnings, warning.Warning{
+		Code:    warning.DegradedMode,
+		Message: section + " skipped: the heap exceeded --max-memory",
+	})
+	return true
+}
+
+// run runs the analysis of section with the context of its phase. If the phase timeout
+// expires, section is recorded as incomplete; if analyze returns any other error or panics,
+// section is recorded as failed. Either way the analyses after it still run.
+func (l *analysisLimits) run(section string, analyze func(ctx context.Context) error) {
+	ctx, cancel := l.phase()
+	defer cancel()
+	err := func() (err error) {
+		defer func() {
+			if r := recover(); r != nil {
+				err = fmt.Errorf("panic: %v", r)
+			}
+		}()
+		beforePhase(section)
+		return analyze(ctx)
+	}()
+	switch {
+	case err == nil:
+		l.stats.Phases = append(l.stats.Phases, metrics.PhaseStatus{Section: section, Status: metrics.PhaseOK})
+	case l.timedOut(section, err):
+	default:
+		l.stats.Failed = append(l.stats.Failed, section)
+		l.stats.Phases = append(l.stats.Phases, metrics.PhaseStatus{Section: section, Status: metrics.PhaseFailed, Error: err.Error()})
+		l.warnings = append(l.warnings, warning.Warning{
+			Code:    warning.PhaseFailed,
+			Message: fmt.Sprintf("%s failed, so it is left out of the report: %v", section, err),
+		})
+	}
+}
+
+// phase returns the context of an analysis, which expires after the phase timeout if there is one.
+func (l *analysisLimits) phase() (context.Context, context.CancelFunc) {
+	if l.phaseTimeout == 0 {
+		return context.WithCancel(l.ctx)
+	}
+	return context.WithTimeout(l.ctx, l.phaseTimeout)
+}
+
+// timedOut reports whether err is the expiry of a phase context, in which case section is
+// recorded as incomplete.
+func (l *analysisLimits) timedOut(section string, err error) bool {
+	if !errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	l.stats.Incomplete = append(l.stats.Incomplete, section)
+	l.stats.Phases = append(l.stats.Phases, metrics.PhaseStatus{Section: section, Status: metrics.PhaseIncomplete})
+	l.warnings = append(l.warnings, warning.Warning{
+		Code:    warning.PhaseTimeout,
+		Message: fmt.Sprintf("%s stopped after --phase-timeout of %v", section, l.phaseTimeout),
+	})
+	return true
+}
+
+// extractExcerpts returns excerpts of the first n functions of stats, read
+// from the tree of the analyzed commit rather than the worktree. Functions in files outside
+// the commit, such as untracked files, get no excerpt.
+func extractExcerpts(repoPath, hash string, stats []metrics.ComplexityStat, n int) []metrics.CodeExcerpt {
+	var excerpts []metrics.CodeExcerpt
+	for _, cs := range stats[:min(len(stats), n)] {
+		src, err := git.ReadFileAt(repoPath, hash, cs.File)
+		if err != nil {
+			continue
+		}
+		excerpts = append(excerpts, metrics.ExtractExcerpt(cs, src))
+	}
+	return excerpts
+}
+
+// computeComplexityTrend counts the functions over threshold at each of the last n first-parent
+// commits of the repository. Counts are cached in the user cache directory across runs; if it
+// cannot be determined, every commit is analyzed. Once ctx is done, no more commits are read.
+func computeComplexityTrend(ctx context.Context, repoPath string, n int) ([]metrics.TrendPoint, []warning.Warning, error) {
+	commits, warnings, err := git.FirstParentHistory(repoPath, n)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var cache *metrics.TrendCache
+	if cacheDir, err := os.UserCacheDir(); err == nil {
+		cache, err = metrics.LoadTrendCache(filepath.Join(cacheDir, "zenwatch", "trend.json"))
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	points, err := metrics.ComputeComplexityTrend(commits, func(hash string) (map[string][]byte, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		return git.GoSourcesAt(repoPath, hash)
+	}, ComplexityThreshold, cache)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cache != nil {
+		if err := cache.Save(); err != nil {
+			return nil, nil, err
+		}
+	}
+	return points, warnings, nil
+}
@@ -0,0 +1,240 @@
+package zenwatch
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/zenwatch/internal/git"
+	"github.com/user/zenwatch/internal/metrics"
+	"github.com/user/zenwatch/internal/warning"
+)
+
+// writeFile creates a file (and its parent directories) under root with the given content.
+func writeFile(t *testing.T, root, path, content string) {
+	t.Helper()
+	fullPath := filepath.Join(root, path)
+	if err := os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+		t.Fatalf("Failed to create directory for %s: %v", path, err)
+	}
+	if err := os.WriteFile(fullPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write %s: %v", path, err)
+	}
+}
+
+// complexFunc returns the source of a function with the given cyclomatic complexity.
+func complexFunc(name string, complexity int) string {
+	return "func " + name + "(x int) int {\n" +
+		strings.Repeat("\tif x > 0 {\n\t\tx--\n\t}\n", complexity-1) +
+		"\treturn x\n}\n"
+}
+
+func TestBuildOverallStatsComplexityIsRepositoryWide(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "legacy/old.go", "package legacy\n\n"+complexFunc("Untouched", ComplexityThreshold+5))
+	writeFile(t, root, "feature/new.go", "package feature\n\nfunc Touched() {}\n")
+
+	// The latest commit only changed feature/new.go.
+	repoInfo := &git.RepositoryInfo{
+		ChangedFiles: []git.ChangedFileStats{{Path: "feature/new.go", FileType: ".go"}},
+	}
+
+	stats, err := buildOverallStats(context.Background(), root, repoInfo, DefaultOptions())
+	if err != nil {
+		t.Fatalf("buildOverallStats failed: %v", err)
+	}
+
+	if stats.FunctionsOverThreshold != 1 || len(stats.ComplexityStats) != 1 {
+		t.Fatalf("Expected 1 function over threshold, got %d: %+v", stats.FunctionsOverThreshold, stats.ComplexityStats)
+	}
+	if cs := stats.ComplexityStats[0]; cs.FunctionName != "Untouched" || cs.File != "legacy/old.go" {
+		t.Errorf("Expected the untouched legacy function to be reported, got %+v", cs)
+	}
+
+	// Churn stays commit-scoped.
+	if goStat := stats.FileStats[".go"]; goStat == nil || goStat.Count != 1 {
+		t.Errorf("Expected file stats to only count the changed file, got %+v", stats.FileStats)
+	}
+}
+
+func TestBuildOverallStatsWarnings(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "ok.go", "package ok\n\nfunc OK() {}\n")
+	writeFile(t, root, "broken.go", "package ok\n\nfunc Broken( {\n")
+	writeFile(t, root, "logo.png", "\x89PNG\x00binary")
+
+	stats, err := buildOverallStats(context.Background(), root, &git.RepositoryInfo{}, DefaultOptions())
+	if err != nil {
+		t.Fatalf("buildOverallStats failed: %v", err)
+	}
+
+	var codes []warning.Code
+	for _, w := range stats.Warnings {
+		codes = append(codes, w.Code)
+	}
+	if len(codes) != 2 || codes[0] != warning.UnparseableFile || codes[1] != warning.BinaryFilesSkipped {
+		t.Errorf("Expected unparseable-file and binary-files-skipped warnings, got %+v", stats.Warnings)
+	}
+}
+
+func TestBuildOverallStatsLanguageFilter(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "legacy/old.go", "package legacy\n\n"+complexFunc("Complex", ComplexityThreshold+5))
+	writeFile(t, root, "web/bundle.js", "var a = 1;\nvar b = 2;\n")
+	writeFile(t, root, "README.md", "# Readme\n")
+	repoInfo := &git.RepositoryInfo{
+		ChangedFiles: []git.ChangedFileStats{{Path: "web/bundle.js"}, {Path: "README.md"}, {Path: "legacy/old.go"}},
+	}
+
+	for _, tc := range []struct {
+		lang        string
+		fileTypes   []string
+		goMetrics   bool
+		rollupFiles int
+	}{
+		{lang: "", fileTypes: []string{".go", ".js", ".md"}, goMetrics: true, rollupFiles: 3},
+		{lang: "go", fileTypes: []string{".go"}, goMetrics: true, rollupFiles: 1},
+		{lang: "markdown,javascript", fileTypes: []string{".js", ".md"}, goMetrics: false, rollupFiles: 2},
+	} {
+		languages, err := metrics.ParseLanguageFilter(tc.lang)
+		if err != nil {
+			t.Fatalf("ParseLanguageFilter(%q) failed: %v", tc.lang, err)
+		}
+		rollup := metrics.DefaultRollupOptions()
+		rollup.Languages = languages
+		stats, err := buildOverallStats(context.Background(), root, repoInfo, Options{Rollup: rollup})
+		if err != nil {
+			t.Fatalf("buildOverallStats with --lang %q failed: %v", tc.lang, err)
+		}
+
+		if len(stats.FileStats) != len(tc.fileTypes) {
+			t.Errorf("--lang %q: expected file types %v, got %v", tc.lang, tc.fileTypes, stats.FileStats)
+		}
+		for _, ext := range tc.fileTypes {
+			if stats.FileStats[ext] == nil {
+				t.Errorf("--lang %q: expected file type %s to be counted", tc.lang, ext)
+			}
+		}
+		if got := stats.FunctionsOverThreshold > 0; got != tc.goMetrics {
+			t.Errorf("--lang %q: expected Go metrics %v, got %d functions over threshold", tc.lang, tc.goMetrics, stats.FunctionsOverThreshold)
+		}
+		if stats.DirectoryRollup.Files != tc.rollupFiles {
+			t.Errorf("--lang %q: expected %d files in the rollup, got %d", tc.lang, tc.rollupFiles, stats.DirectoryRollup.Files)
+		}
+	}
+}
+
+func TestBuildCommitStatsLinesByFileType(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "lib/lib.go", "package lib\n")
+	writeFile(t, root, "lib/util.go", "package lib\n")
+	writeFile(t, root, "testdata/fixture.json", "{}\n")
+	writeFile(t, root, "gen/api.pb.go", "package gen\n")
+	repoInfo := &git.RepositoryInfo{
+		ChangedFiles: []git.ChangedFileStats{
+			{Path: "lib/lib.go", LinesAdded: 100, LinesDeleted: 20},
+			{Path: "lib/util.go", LinesAdded: 20, LinesDeleted: 10},
+			{Path: "testdata/fixture.json", LinesAdded: 400},
+			{Path: "gen/api.pb.go", LinesAdded: 900, LinesDeleted: 900},
+		},
+	}
+	exclude, err := metrics.NewExcludeFilter([]string{"*.pb.go"})
+	if err != nil {
+		t.Fatalf("NewExcludeFilter failed: %v", err)
+	}
+
+	stats, err := buildCommitStats(root, repoInfo, metrics.LanguageFilter{}, exclude)
+	if err != nil {
+		t.Fatalf("buildCommitStats failed: %v", err)
+	}
+	for ext, want := range map[string][2]int{".go": {120, 30}, ".json": {400, 0}} {
+		stat := stats.FileStats[ext]
+		if stat == nil || stat.LinesAdded != want[0] || stat.LinesDeleted != want[1] {
+			t.Errorf("Expected %s: +%d/-%d, got %+v", ext, want[0], want[1], stat)
+		}
+	}
+}
+
+func TestBuildOverallStatsExclude(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "lib/lib.go", "package lib\n\n"+complexFunc("Handwritten", ComplexityThreshold+5))
+	writeFile(t, root, "gen/api.pb.go", "package gen\n\n"+complexFunc("Generated", ComplexityThreshold+5))
+	exclude, err := NewExcludeFilter([]string{"*.pb.go"})
+	if err != nil {
+		t.Fatalf("NewExcludeFilter failed: %v", err)
+	}
+	opts := DefaultOptions()
+	opts.Rollup.Exclude = exclude
+
+	repoInfo := &git.RepositoryInfo{ChangedFiles: []git.ChangedFileStats{{Path: "lib/lib.go"}, {Path: "gen/api.pb.go"}}}
+	stats, err := buildOverallStats(context.Background(), root, repoInfo, opts)
+	if err != nil {
+		t.Fatalf("buildOverallStats failed: %v", err)
+	}
+	if len(stats.ComplexityStats) != 1 || stats.ComplexityStats[0].FunctionName != "Handwritten" {
+		t.Errorf("Expected only the function of lib/lib.go to be reported, got %+v", stats.ComplexityStats)
+	}
+	if stats.DirectoryRollup.Files != 1 || stats.FileStats[".go"].Count != 1 {
+		t.Errorf("Expected excluded files to be left out of the rollup and file types, got %d files and %+v",
+			stats.DirectoryRollup.Files, stats.FileStats[".go"])
+	}
+}
+
+func TestBuildOverallStatsResourceLimits(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "go.mod", "module example.com/lib\n\ngo 1.22\n")
+	writeFile(t, root, "lib/lib.go", "package lib\n\n"+complexFunc("Complex", ComplexityThreshold+5))
+	repoInfo := &git.RepositoryInfo{ChangedFiles: []git.ChangedFileStats{{Path: "lib/lib.go"}}}
+	opts := DefaultOptions()
+
+	stats, err := buildOverallStats(context.Background(), root, repoInfo, opts)
+	if err != nil {
+		t.Fatalf("buildOverallStats failed: %v", err)
+	}
+	if len(stats.Degraded) > 0 || len(stats.Incomplete) > 0 || len(stats.PackageCoupling) == 0 {
+		t.Fatalf("Expected the default limits not to affect a small repository, got %+v and %+v", stats.Degraded, stats.Incomplete)
+	}
+
+	// Any heap is over a budget of one byte.
+	degraded := opts
+	degraded.Workers.MaxMemory = 1
+	stats, err = buildOverallStats(context.Background(), root, repoInfo, degraded)
+	if err != nil {
+		t.Fatalf("buildOverallStats failed: %v", err)
+	}
+	if len(stats.Degraded) != 1 || stats.Degraded[0] != metrics.SectionPackageCoupling || stats.PackageCoupling != nil {
+		t.Errorf("Expected Package Coupling to be skipped in degraded mode, got %v with %+v", stats.Degraded, stats.PackageCoupling)
+	}
+	if stats.FunctionsOverThreshold != 1 {
+		t.Errorf("Expected the complexity analysis to run in degraded mode, got %d functions", stats.FunctionsOverThreshold)
+	}
+	if !hasWarning(stats.Warnings, warning.DegradedMode) {
+		t.Errorf("Expected a degraded-mode warning, got %+v", stats.Warnings)
+	}
+
+	timedOut := opts
+	timedOut.PhaseTimeout = time.Nanosecond
+	stats, err = buildOverallStats(context.Background(), root, repoInfo, timedOut)
+	if err != nil {
+		t.Fatalf("Expected a phase timeout not to fail the analysis, got %v", err)
+	}
+	if !stats.IsIncomplete(metrics.SectionComplexity) || !stats.IsIncomplete(metrics.SectionPackageCoupling) {
+		t.Errorf("Expected the complexity and coupling analyses to be incomplete, got %v", stats.Incomplete)
+	}
+	if !hasWarning(stats.Warnings, warning.PhaseTimeout) {
+		t.Errorf("Expected a phase-timeout warning, got %+v", stats.Warnings)
+	}
+}
+
+// hasWarning reports whether warnings holds a warning with the given code.
+func hasWarning(warnings []warning.Warning, code warning.Code) bool {
+	for _, w := range warnings {
+		if w.Code == code {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,752 @@
+// Package zenwatch runs the ZenWatch analysis of a repository from Go code. The zenwatch
+// command is a thin consumer of this package, so a program embedding it gets the same
+// statistics, warnings and reports as the command line.
+//
+// The package surface (the exported identifiers of this package and the types they alias)
+// is versioned by APIVersion following semantic versioning: removing or changing the meaning
+// of an identifier bumps the major version, additions bump the minor version.
+package zenwatch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/user/zenwatch/internal/budget"
+	"github.com/user/zenwatch/internal/commitlint"
+	"github.com/user/zenwatch/internal/git"
+	"github.com/user/zenwatch/internal/github"
+	"github.com/user/zenwatch/internal/metrics"
+	"github.com/user/zenwatch/internal/plugin"
+	"github.com/user/zenwatch/internal/repoconfig"
+	"github.com/user/zenwatch/internal/report"
+	"github.com/user/zenwatch/internal/vcs"
+	"github.com/user/zenwatch/internal/warning"
+)
+
+// APIVersion is the semantic version of the package surface.
+const APIVersion = "1.0.0"
+
+// ComplexityThreshold is the cyclomatic complexity above which functions are reported.
+const ComplexityThreshold = 15
+
+// Defaults of the resource limits, generous enough not to affect ordinary repositories.
+const (
+	DefaultMaxFileSize  = 16 << 20 // Larger Go files are usually generated
+	DefaultPhaseTimeout = 30 * time.Minute
+)
+
+// Types of the analysis results and options, shared with the packages computing them.
+type (
+	RepositoryInfo  = git.RepositoryInfo
+	CommitInfo      = git.CommitInfo
+	Stats           = metrics.OverallStats
+	SourceInventory = metrics.SourceInventory
+	Warning         = warning.Warning
+	ReportData      = report.ReportData
+	Budget          = budget.Budget
+	RollupOptions   = metrics.RollupOptions
+	LanguageFilter  = metrics.LanguageFilter
+	ExcludeFilter   = metrics.ExcludeFilter
+	WorkerOptions   = metrics.WorkerOptions
+	BuildOptions    = metrics.BuildOptions
+	ReportOptions   = report.ReportOptions
+	BadgeOptions    = report.BadgeOptions
+	RedactOptions   = report.RedactOptions
+	Plugin          = plugin.Plugin
+	PluginOptions   = plugin.Options
+	PlanWeights     = metrics.PlanWeights
+)
+
+// How the submodules of a git repository are analyzed, the values of Options.Submodules.
+const (
+	SubmodulesNone    = git.SubmodulesNone
+	SubmodulesShallow = git.SubmodulesShallow
+	SubmodulesFull    = git.SubmodulesFull
+)
+
+// ParseLanguageFilter parses a comma-separated list of language names such as "go,yaml".
+// An empty list matches every language.
+func ParseLanguageFilter(s string) (LanguageFilter, error) {
+	return metrics.ParseLanguageFilter(s)
+}
+
+// NewExcludeFilter compiles .gitignore-style patterns of files to leave out of the analysis.
+func NewExcludeFilter(patterns []string) (ExcludeFilter, error) {
+	return metrics.NewExcludeFilter(patterns)
+}
+
+// ErrArchived is returned by Analyze with Options.SkipArchived when GitHub reports the
+// repository as archived or disabled.
+var ErrArchived = errors.New("GitHub reports the repository as archived or disabled")
+
+// ErrPartialAnalysis is wrapped by Result.Err when analyses failed and their sections are
+// missing from the report.
+var ErrPartialAnalysis = errors.New("partial analysis")
+
+// NoSourceError is returned by Analyze when the repository has no source code to analyze
+// and Options.AllowEmpty is not set.
+type NoSourceError struct {
+	Inventory *SourceInventory
+}
+
+func (e *NoSourceError) Error() string {
+	var reason string
+	if e.Inventory.ExcludedSourceFiles > 0 {
+		reason = fmt.Sprintf("all %d source files are in skipped directories (vendor, testdata, hidden and underscore-prefixed directories)",
+			e.Inventory.ExcludedSourceFiles)
+	} else {
+		var found []string
+		for _, ext := range e.Inventory.TopExtensions(5) {
+			found = append(found, fmt.Sprintf("%s (%d)", ext.Extension, ext.Count))
+		}
+		if len(found) == 0 {
+			found = []string{"nothing"}
+		}
+		reason = fmt.Sprintf("no %s files found; the repository mostly contains %s",
+			metrics.DescribeLanguages(), strings.Join(found, ", "))
+	}
+	return "no source code to analyze: " + reason
+}
+
+// Target is the repository to analyze.
+type Target struct {
+	URL string // Remote URL or local path of the repository, or a local .tar.gz, .tgz, .tar or .zip archive of one; "hg::" URLs name Mercurial repositories
+	VCS string // "git" or "hg"; empty picks the system URL belongs to
+}
+
+// Options configure an analysis. DefaultOptions holds the defaults of the zenwatch analyze
+// flags, which Options mirror field by field.
+type Options struct {
+	Branch            string        // Branch to analyze the latest commit of, e.g. develop; empty analyzes the default branch
+	Commit            string        // Commit to analyze instead of the latest, full or abbreviated to 7 characters; clones the full history
+	Depth             int           // Commits of history to clone, raised as the other options need; 0 clones the full history
+	Rollup            RollupOptions // Languages and files analyzed, and the shape of the Directory Rollup
+	Fast              bool          // Only analyze the commit and count lines, parsing no source file; see fastOnly
+	IncludeTests      bool          // Also report the complexity of test functions, summarized separately
+	MaxLineLength     int           // Display width above which lines count as long; 0 means 120
+	BannedImports     []string      // Import paths to flag wherever they are imported
+	AllowEmpty        bool          // Analyze the commit alone instead of failing when no source code is found
+	CheckBuild        bool          // Build and vet the module (needs the Go toolchain)
+	Build             BuildOptions  // How CheckBuild builds
+	Ownership         bool          // Attribute functions over threshold to authors via blame
+	AnnotationAuthors bool          // Count TODO and FIXME comments per author via blame
+	RecentWindow      time.Duration // Report the share of lines changed this long before the commit; 0 disables
+	Trend             int           // Number of commits to chart functions over threshold for; 0 disables
+	RefactoringPlan   bool          // Rank the functions over threshold for refactoring; clones the full history
+	PlanWeights       PlanWeights   // Weights of the signals of the RefactoringPlan; the zero value means DefaultPlanWeights
+	MaxFilesPerCommit int           // Flag history commits changing more files; 0 disables
+	CompareToTag      bool          // Report the changes since the latest tag reachable from HEAD
+	LintCommits       bool          // Check the analyzed commit messages against the commit lint rules
+	Cadence           bool          // Report the intervals between the commits of the full history
+	SkipArchived      bool          // Fail with ErrArchived for GitHub repositories reported as archived or disabled
+	Excerpts          int           // Number of the most complex functions to excerpt the source of; negative excerpts all
+	RunStats          bool          // Add a Run Statistics section to the report
+	CoverProfile      string        // Path of a go test -coverprofile file to compute the diff coverage of the commit with; empty disables
+	Plugins           []Plugin      // External analyzers whose findings are reported as Custom Findings, see plugin.Collect
+	PluginLimits      PluginOptions // Timeout and output limit of each plugin
+	Untracked         bool          // Also analyze the untracked files of a local repository
+	Worktree          bool          // Analyze the uncommitted changes of a local repository against its HEAD instead of its latest commit
+	Submodules        string        // How to analyze the submodules, one of the Submodules* modes; empty means SubmodulesNone
+	SubmoduleDepth    int           // Deepest nesting of the submodules to fetch
+	Workers           WorkerOptions // Limits of the concurrent parsing of Go files
+	PhaseTimeout      time.Duration // Time budget of each analysis; 0 disables
+	PinnedCommit      string        // Fail unless the analyzed commit is this commit
+	HistoryTable      bool          // Render the Commit History table, with the commits of history as many as Depth clones
+	Report            ReportOptions
+	Badge             BadgeOptions
+	Redact            RedactOptions     // Parts of the report data to redact
+	Config            map[string]string // Optional: the options by name, listed in the report
+
+	repoConfig *repoconfig.Tree // The .zenwatch.yaml files of the analyzed tree, see applyRepoConfig
+}
+
+// DefaultOptions returns the options of a zenwatch analyze run without flags.
+func DefaultOptions() Options {
+	return Options{
+		Depth:          1,
+		Rollup:         metrics.DefaultRollupOptions(),
+		MaxLineLength:  metrics.DefaultMaxLineLength,
+		Build:          metrics.BuildOptions{Timeout: metrics.DefaultBuildTimeout},
+		Excerpts:       metrics.ExcerptCount,
+		Submodules:     git.SubmodulesNone,
+		SubmoduleDepth: git.DefaultSubmoduleDepth,
+		Workers:        metrics.WorkerOptions{MaxFileSize: DefaultMaxFileSize},
+		PhaseTimeout:   DefaultPhaseTimeout,
+	}
+}
+
+// gitOnly returns the names of the options set that read the git history or working tree directly.
+func (o Options) gitOnly() []string {
+	var names []string
+	for _, opt := range []struct {
+		name string
+		set  bool
+	}{
+		{"Trend", o.Trend > 0},
+		{"Ownership", o.Ownership},
+		{"AnnotationAuthors", o.AnnotationAuthors},
+		{"RecentWindow", o.RecentWindow > 0},
+		{"CompareToTag", o.CompareToTag},
+		{"MaxFilesPerCommit", o.MaxFilesPerCommit > 0},
+		{"Untracked", o.Untracked},
+		{"Worktree", o.Worktree},
+		{"Cadence", o.Cadence},
+		{"Submodules", o.Submodules != "" && o.Submodules != git.SubmodulesNone},
+		{"CoverProfile", o.CoverProfile != ""},
+		{"Branch", o.Branch != ""},
+		{"RefactoringPlan", o.RefactoringPlan},
+		{"Commit", o.Commit != ""},
+	} {
+		if opt.set {
+			names = append(names, opt.name)
+		}
+	}
+	return names
+}
+
+// fastOnly returns the names of the options set that need an analysis Fast skips.
+func (o Options) fastOnly() []string {
+	var names []string
+	for _, opt := range []struct {
+		name string
+		set  bool
+	}{
+		{"IncludeTests", o.IncludeTests},
+		{"BannedImports", len(o.BannedImports) > 0},
+		{"CheckBuild", o.CheckBuild},
+		{"Ownership", o.Ownership},
+		{"AnnotationAuthors", o.AnnotationAuthors},
+		{"RecentWindow", o.RecentWindow > 0},
+		{"Trend", o.Trend > 0},
+		{"RefactoringPlan", o.RefactoringPlan},
+	} {
+		if opt.set {
+			names = append(names, opt.name)
+		}
+	}
+	return names
+}
+
+// OptionError is the error of Validate for an option the analysis cannot honor, alone or
+// combined with another one. Options are named by their field name.
+type OptionError struct {
+	Option string // The option rejected, e.g. "Ownership"
+	With   string // The option it cannot be combined with, if that is why, e.g. "Fast"
+	Reason string // Why it is rejected, e.g. "is only supported for git repositories"; follows With if set
+}
+
+func (e *OptionError) Error() string {
+	return "option " + e.Describe(func(option string) string { return option })
+}
+
+// Describe returns the message of e with the options named by name, e.g. after the flags
+// they are set with.
+func (e *OptionError) Describe(name func(option string) string) string {
+	if e.With == "" {
+		return name(e.Option) + " " + e.Reason
+	}
+	message := name(e.Option) + " cannot be combined with " + name(e.With)
+	if e.Reason != "" {
+		message += ", " + e.Reason
+	}
+	return message
+}
+
+// Validate checks that the options hold supported values for an analysis of target. Options
+// rejected for their value, their combination or target fail with an *OptionError.
+func (o Options) Validate(target Target) error {
+	repoVCS, err := vcs.ForURL(target.URL, target.VCS)
+	if err != nil {
+		return err
+	}
+	if err := o.validate(repoVCS.Name()); err != nil {
+		return err
+	}
+	for _, opt := range []struct {
+		name string
+		set  bool
+	}{{"Untracked", o.Untracked}, {"Worktree", o.Worktree}} {
+		if !opt.set {
+			continue
+		}
+		if info, err := os.Stat(target.URL); err != nil || !info.IsDir() {
+			return &OptionError{Option: opt.name, Reason: "needs a local repository path, got " + target.URL}
+		}
+	}
+	return nil
+}
+
+// validate checks that the options hold supported values for a repository of the named VCS.
+func (o Options) validate(vcsName string) error {
+	if err := o.Rollup.Validate(); err != nil {
+		return err
+	}
+	if err := o.Report.Validate(); err != nil {
+		return err
+	}
+	if err := o.Badge.Validate(); err != nil {
+		return err
+	}
+	if o.Commit != "" && o.Branch != "" {
+		return &OptionError{Option: "Commit", With: "Branch"}
+	}
+	if o.Worktree && o.Commit != "" {
+		return &OptionError{Option: "Worktree", With: "Commit", Reason: "as it analyzes the checked-out commit"}
+	}
+	if o.Worktree && o.Branch != "" {
+		return &OptionError{Option: "Worktree", With: "Branch", Reason: "as it analyzes the checked-out commit"}
+	}
+	if o.Depth < 0 {
+		return &OptionError{Option: "Depth", Reason: fmt.Sprintf("must not be negative, got %d", o.Depth)}
+	}
+	switch o.Submodules {
+	case "", git.SubmodulesNone, git.SubmodulesShallow, git.SubmodulesFull:
+	default:
+		return &OptionError{Option: "Submodules", Reason: fmt.Sprintf("has unknown mode %q (supported: %s, %s, %s)",
+			o.Submodules, git.SubmodulesNone, git.SubmodulesShallow, git.SubmodulesFull)}
+	}
+	if names := o.gitOnly(); vcsName != vcs.NameGit && len(names) > 0 {
+		return &OptionError{Option: names[0], Reason: "is only supported for git repositories"}
+	}
+	if names := o.fastOnly(); o.Fast && len(names) > 0 {
+		return &OptionError{Option: names[0], With: "Fast", Reason: "which skips the analysis it needs"}
+	}
+	return nil
+}
+
+// Client runs analyses. The zero value is ready to use.
+type Client struct {
+	Now         func() time.Time // Clock of the report date and of the build check duration; time.Now if nil
+	Log         io.Writer        // Progress messages, such as the submodules fetched; discarded if nil
+	ErrorLog    io.Writer        // Failures that do not fail the analysis, such as an unreachable GitHub API; discarded if nil
+	GitHubToken string           // Token to query the status of GitHub repositories with; the status is not queried if empty
+}
+
+func (c *Client) now() time.Time {
+	if c.Now == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
+
+func (c *Client) logf(format string, args ...any) {
+	if c.Log != nil {
+		fmt.Fprintf(c.Log, format, args...)
+	}
+}
+
+func (c *Client) warnf(format string, args ...any) {
+	if c.ErrorLog != nil {
+		fmt.Fprintf(c.ErrorLog, "Warning: "+format+"\n", args...)
+	}
+}
+
+// badge returns the status badge of stats: the complexity delta since the baseline of opts if
+// there is one, the absolute badge otherwise.
+func (c *Client) badge(stats *metrics.OverallStats, opts report.BadgeOptions) (report.Badge, error) {
+	absolute := report.BuildBadge(stats.TotalLinesAdded+stats.TotalLinesDeleted, stats.AverageComplexity)
+	if opts.Baseline == "" {
+		return absolute, nil
+	}
+	baseline, err := report.LoadBaseline(opts.Baseline)
+	if errors.Is(err, fs.ErrNotExist) || errors.Is(err, report.ErrNoBaselineComplexity) {
+		c.logf("No complexity baseline, showing the absolute badge: %v\n", err)
+		return absolute, nil
+	} else if err != nil {
+		return report.Badge{}, err
+	}
+	return report.BuildDeltaBadge(stats.AverageComplexity - baseline), nil
+}
+
+// Result is the outcome of an analysis.
+type Result struct {
+	Repository *RepositoryInfo // The analyzed commit, unredacted
+	Stats      *Stats          // Statistics of the analyzed commit, unredacted
+	Warnings   []Warning       // Problems of the repository, the commit and the metric analyses, redacted
+	Budget     Budget          // Budget file of the analyzed tree, nil if there is none
+	Data       ReportData      // What the renderers render, redacted as Options.Redact asks
+}
+
+// Err returns an error wrapping ErrPartialAnalysis and naming the sections whose analysis
+// failed, or nil if none did. The report of a partial analysis still holds every other section.
+func (r *Result) Err() error {
+	if len(r.Stats.Failed) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s failed, see the warnings of the report", ErrPartialAnalysis, strings.Join(r.Stats.Failed, ", "))
+}
+
+// RenderMarkdown writes the Markdown report.
+func (r *Result) RenderMarkdown(w io.Writer) error {
+	return report.WriteMarkdownReport(r.Data, w)
+}
+
+// RenderJSON writes the report data as indented JSON in the json report format, versioned by
+// its schemaVersion field.
+func (r *Result) RenderJSON(w io.Writer) error {
+	return report.WriteJSONReport(r.Data, w)
+}
+
+// Render writes the report in a format of the zenwatch --format flag handled by a report
+// formatter, such as "markdown" or "heatmap-json".
+func (r *Result) Render(format string, w io.Writer) error {
+	f, ok := report.LookupFormatter(format)
+	if !ok {
+		return fmt.Errorf("unknown output format %q", format)
+	}
+	return f.Format(r.Data, w)
+}
+
+// analyzeWorktree describes the uncommitted changes of the local repository at path.
+func analyzeWorktree(path string, opts Options) (*RepositoryInfo, error) {
+	if opts.Fast {
+		return git.AnalyzeWorktreeFiles(path, opts.Untracked)
+	}
+	return git.AnalyzeWorktree(path, opts.Untracked)
+}
+
+// Analyze clones target, analyzes its checked-out commit and removes the clone. An archive
+// is extracted first and the repository in it analyzed; the report names the archive.
+func (c *Client) Analyze(ctx context.Context, target Target, opts Options) (*Result, error) {
+	// The URL may hold a token, so only its redacted form is reported.
+	repoURL := git.RedactURL(target.URL)
+	if isArchive(target.URL) {
+		dir, repoPath, err := extractArchive(target.URL)
+		if err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(dir)
+		target.URL = repoPath
+	}
+	if err := opts.Validate(target); err != nil {
+		return nil, err
+	}
+	repoVCS, err := vcs.ForURL(target.URL, target.VCS)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Build.Now == nil {
+		opts.Build.Now = c.Now
+	}
+	var worktreeInfo *RepositoryInfo
+	if g, ok := repoVCS.(vcs.Git); ok {
+		g.SkipChangedFunctions = opts.Fast // Listing them parses the Go files the commit changed
+		g.Branch = opts.Branch
+		g.Commit = opts.Commit
+		if opts.Worktree {
+			if worktreeInfo, err = analyzeWorktree(target.URL, opts); err != nil {
+				return nil, err
+			}
+			// The clone starts at the HEAD of the worktree and is then brought up to its files.
+			if g.Branch = worktreeInfo.Branch; g.Branch == "" {
+				g.Commit = worktreeInfo.LatestCommit.Hash
+			}
+		}
+		repoVCS = g
+	}
+	var repoWarnings []warning.Warning
+	if status := c.repoStatus(ctx, target.URL); status != nil {
+		if opts.SkipArchived && (status.Archived || status.Disabled) {
+			return nil, fmt.Errorf("skipping %s: %w", repoURL, ErrArchived)
+		}
+		repoWarnings = status.Warnings()
+	}
+	depth := opts.Depth
+	if depth > 0 && opts.Trend > depth {
+		depth = opts.Trend
+	}
+	if opts.CoverProfile != "" && depth == 1 {
+		depth = 2 // The parent, without which every line of the tree would count as added
+	}
+	if opts.HistoryTable && depth > 0 && depth <= opts.Depth {
+		depth = opts.Depth + 1 // The parent of the oldest commit of the table, for its counts
+	}
+	if opts.MaxFilesPerCommit > 0 || opts.CompareToTag || opts.Cadence || opts.RecentWindow > 0 || opts.Commit != "" || opts.RefactoringPlan {
+		// Shotgun commits, tags, the cadence, the commit and the edits of the plan are looked for, and lines blamed, in the full history
+		depth = 0
+	}
+	repoPath, cloneStats, err := repoVCS.Clone(target.URL, depth)
+	if err != nil {
+		return nil, err
+	}
+	defer repoVCS.Cleanup(repoPath)
+
+	repoInfo := worktreeInfo
+	if repoInfo == nil {
+		if repoInfo, err = repoVCS.LatestCommit(repoPath); err != nil {
+			return nil, err
+		}
+	} else {
+		repoInfo.TempPath = repoPath
+	}
+	repoInfo.URL = repoURL
+	if opts.PinnedCommit != "" && repoInfo.LatestCommit.Hash != opts.PinnedCommit {
+		return nil, fmt.Errorf("the analyzed commit is %s, not the pinned commit %s",
+			repoInfo.LatestCommit.Hash, opts.PinnedCommit)
+	}
+
+	var (
+		submodules        []git.Submodule
+		submoduleWarnings []warning.Warning
+	)
+	if repoVCS.Name() == vcs.NameGit {
+		submodules, err = git.ListSubmodules(repoPath, repoInfo.LatestCommit.Hash)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Submodules == git.SubmodulesShallow || opts.Submodules == git.SubmodulesFull {
+			// The submodules are fetched into the clone, so the metrics walk their files under their path.
+			submodules, submoduleWarnings = git.FetchSubmodules(repoPath, target.URL, submodules, git.SubmoduleOptions{Mode: opts.Submodules, MaxDepth: opts.SubmoduleDepth})
+			c.logf("Fetched %d of %d submodule(s)\n", countFetched(submodules), len(submodules))
+		}
+	}
+
+	if opts.Worktree {
+		changed, err := git.CopyWorktreeChanges(target.URL, repoPath, opts.Untracked)
+		if err != nil {
+			return nil, err
+		}
+		c.logf("Including %d uncommitted file change(s)\n", len(changed))
+	} else if opts.Untracked {
+		// Untracked files are in no commit, so they only count towards the repository-wide metrics.
+		copied, err := git.CopyUntrackedFiles(target.URL, repoPath)
+		if err != nil {
+			return nil, err
+		}
+		c.logf("Including %d untracked file(s)\n", len(copied))
+	}
+
+	if opts, err = applyRepoConfig(repoPath, opts); err != nil {
+		return nil, err
+	}
+	inventory, err := metrics.TakeSourceInventory(repoPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list source files: %w", err)
+	}
+	var stats *metrics.OverallStats
+	if inventory.HasSource() {
+		stats, err = buildOverallStats(ctx, repoPath, repoInfo, opts)
+	} else if opts.AllowEmpty {
+		stats, err = buildCommitStats(repoPath, repoInfo, opts.Rollup.Languages, opts.Rollup.Exclude)
+	} else {
+		return nil, &NoSourceError{Inventory: inventory}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if opts.CoverProfile != "" {
+		if stats.DiffCoverage, err = diffCoverage(opts.CoverProfile, repoInfo, opts.Rollup.Exclude); err != nil {
+			return nil, err
+		}
+	}
+	if len(opts.Plugins) > 0 {
+		req := plugin.Request{Root: repoPath, Repository: repoURL, Commit: repoInfo.LatestCommit.Hash}
+		var pluginWarnings []warning.Warning
+		stats.CustomFindings, pluginWarnings = plugin.RunAll(ctx, opts.Plugins, req, opts.PluginLimits)
+		stats.Warnings = append(stats.Warnings, pluginWarnings...)
+	}
+	if opts.RunStats {
+		stats.Run, err = collectRunStats(repoPath, cloneStats, stats.Warnings, opts.Rollup)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var commits []git.CommitInfo
+	if opts.MaxFilesPerCommit > 0 || (opts.HistoryTable && repoVCS.Name() == vcs.NameGit && !opts.Worktree) {
+		limit := opts.Depth // Only the table reads the history, as far as the clone goes
+		if opts.MaxFilesPerCommit > 0 {
+			limit = 0
+		}
+		var historyWarnings []warning.Warning
+		commits, historyWarnings, err = git.CommitHistory(repoPath, limit)
+		if err != nil {
+			return nil, err
+		}
+		stats.Warnings = append(stats.Warnings, historyWarnings...)
+	}
+	var shotgun []git.CommitInfo
+	if opts.MaxFilesPerCommit > 0 {
+		shotgun = git.ShotgunCommits(commits, opts.MaxFilesPerCommit)
+	}
+	if opts.Cadence {
+		times, historyWarnings, err := git.CommitTimes(repoPath)
+		if err != nil {
+			return nil, err
+		}
+		stats.Cadence = metrics.ComputeCadence(times)
+		stats.Warnings = append(stats.Warnings, historyWarnings...)
+	}
+	var tagRange *git.TagRange
+	if opts.CompareToTag {
+		var rangeWarnings []warning.Warning
+		tagRange, rangeWarnings, err = git.LatestTagRange(repoPath)
+		if errors.Is(err, git.ErrNoTag) {
+			c.warnf("--compare-to-tag: %v, reporting the latest commit only", err)
+			rangeWarnings = []warning.Warning{{Code: warning.NoTag, Message: "--compare-to-tag found no tag reachable from HEAD, so only the latest commit is reported"}}
+		} else if err != nil {
+			return nil, err
+		}
+		stats.Warnings = append(stats.Warnings, rangeWarnings...)
+	}
+	var lintFindings []commitlint.Finding
+	var linted []git.CommitInfo
+	if opts.LintCommits {
+		// The rules are read from the analyzed tree, like the budget.
+		rules, err := commitlint.Load(filepath.Join(repoPath, commitlint.FileName))
+		if err != nil {
+			return nil, err
+		}
+		linted = []git.CommitInfo{repoInfo.LatestCommit}
+		if tagRange != nil && len(tagRange.Commits) > 0 {
+			linted = tagRange.Commits
+		}
+		lintFindings = rules.Lint(linted)
+	}
+	// The budget is read from the analyzed tree, so every branch carries its own ratchet.
+	b, err := budget.Load(filepath.Join(repoPath, budget.FileName))
+	if err != nil {
+		return nil, err
+	}
+	changedFunctions := slices.DeleteFunc(slices.Clone(repoInfo.ChangedFunctions), func(cf git.ChangedFunction) bool {
+		return !opts.Rollup.Languages.Match(cf.File) || opts.Rollup.Exclude.Excludes(cf.File)
+	})
+	badge, err := c.badge(stats, opts.Badge)
+	if err != nil {
+		return nil, err
+	}
+	commit := repoInfo.LatestCommit
+	history := report.CommitRows(commitHistory(commit, commits, tagRange, opts))
+
+	data := report.ReportData{
+		RepoURL:             repoURL,
+		ReportDate:          c.now().Format("2006-01-02 15:04:05 MST"),
+		Badge:               badge,
+		BadgeURL:            report.BadgeURL(badge, opts.Badge),
+		Commit:              &commit,
+		Branch:              repoInfo.Branch,
+		Uncommitted:         repoInfo.Uncommitted,
+		Stats:               stats,
+		ComplexityThreshold: opts.repoConfig.Threshold(".", ComplexityThreshold), // The root .zenwatch.yaml may change it
+		CommitHistory:       history,
+		ShowCommitHistory:   opts.HistoryTable || len(history) > 1,
+		Options:             opts.Report,
+		IncludeTests:        opts.IncludeTests,
+		AnalysisConfig:      opts.Config,
+		LanguageFilter:      opts.Rollup.Languages.String(),
+		MaxFilesPerCommit:   opts.MaxFilesPerCommit,
+		ShotgunCommits:      shotgun,
+		TagRange:            tagRange,
+		ChangedFunctions:    changedFunctions,
+		LintedCommits:       len(linted),
+		CommitLintFindings:  lintFindings,
+		Submodules:          submodules,
+		SubmoduleMode:       opts.Submodules,
+		Warnings:            slices.Concat(repoWarnings, repoInfo.Warnings, submoduleWarnings, stats.Warnings),
+	}
+	if !inventory.HasSource() {
+		data.EmptyAnalysis = inventory
+	}
+	data, err = report.Redact(data, opts.Redact)
+	if err != nil {
+		return nil, err
+	}
+	return &Result{Repository: repoInfo, Stats: stats, Warnings: data.Warnings, Budget: b, Data: data}, nil
+}
+
+// AnalyzeTree analyzes the files under dir as they are, without version control, so the
+// statistics scoped to a commit are empty.
+func AnalyzeTree(ctx context.Context, dir string, opts Options) (*Stats, error) {
+	if err := opts.validate(""); err != nil {
+		return nil, err
+	}
+	opts, err := applyRepoConfig(dir, opts)
+	if err != nil {
+		return nil, err
+	}
+	return buildOverallStats(ctx, dir, &git.RepositoryInfo{}, opts)
+}
+
+// applyRepoConfig reads the .zenwatch.yaml files under repoPath into opts, adding their
+// exclude patterns to those of the invocation.
+func applyRepoConfig(repoPath string, opts Options) (Options, error) {
+	tree, err := repoconfig.Discover(repoPath)
+	if err != nil {
+		return opts, err
+	}
+	if opts.Rollup.Exclude, err = tree.Exclude(opts.Rollup.Exclude); err != nil {
+		return opts, err
+	}
+	opts.repoConfig = tree
+	return opts, nil
+}
+
+// diffCoverage checks the lines the commit of repoInfo added against the coverage profile
+// at profilePath.
+func diffCoverage(profilePath string, repoInfo *git.RepositoryInfo, exclude metrics.ExcludeFilter) (*metrics.DiffCoverage, error) {
+	f, err := os.Open(profilePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open coverage profile: %w", err)
+	}
+	defer f.Close()
+	profile, err := metrics.ParseCoverProfile(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", profilePath, err)
+	}
+	dc := metrics.ComputeDiffCoverage(profile, repoInfo.AddedLines, exclude)
+	dc.Profile = filepath.Base(profilePath)
+	return &dc, nil
+}
+
+// repoStatus returns the GitHub status of repoURL, or nil if it is not a GitHub URL, the
+// client has no token or the API cannot be queried. The status is advisory, so API
+// failures are only logged.
+func (c *Client) repoStatus(ctx context.Context, repoURL string) *github.RepoStatus {
+	owner, name, ok := github.ParseRepoURL(repoURL)
+	if !ok || c.GitHubToken == "" {
+		return nil
+	}
+	status, err := github.NewClient(c.GitHubToken).RepoStatus(ctx, owner, name)
+	if err != nil {
+		c.warnf("%v", err)
+		return nil
+	}
+	return status
+}
+
+// commitHistory returns the commits of the Commit History table, newest first: those since
+// the tag with CompareToTag, otherwise with HistoryTable the commits of history, as many as
+// Depth clones. The analyzed commit heads the table either way.
+func commitHistory(commit git.CommitInfo, history []git.CommitInfo, tagRange *git.TagRange, opts Options) []git.CommitInfo {
+	switch {
+	case tagRange != nil && len(tagRange.Commits) > 0:
+		history = tagRange.Commits
+	case opts.HistoryTable && opts.Depth > 0:
+		history = history[:min(len(history), opts.Depth)]
+	case !opts.HistoryTable:
+		history = nil
+	}
+	// The analyzed commit is left out of the history when it is a merge, or the shallow end of the clone.
+	if len(history) == 0 || history[0].Hash != commit.Hash {
+		history = append([]git.CommitInfo{commit}, history...)
+	}
+	return history
+}
+
+// countFetched returns the number of submodules whose files were fetched.
+func countFetched(submodules []git.Submodule) int {
+	n := 0
+	for _, sm := range submodules {
+		if sm.Fetched {
+			n++
+		}
+	}
+	return n
+}
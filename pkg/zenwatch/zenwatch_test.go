@@ -0,0 +1,672 @@
+package zenwatch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/user/zenwatch/internal/budget"
+	"github.com/user/zenwatch/internal/git"
+	"github.com/user/zenwatch/internal/metrics"
+	"github.com/user/zenwatch/internal/plugin"
+	"github.com/user/zenwatch/internal/repoconfig"
+	"github.com/user/zenwatch/internal/report"
+	"github.com/user/zenwatch/internal/warning"
+)
+
+// runGit runs git in dir as a fixed author.
+func runGit(t *testing.T, dir string, args ...string) {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=Test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_AUTHOR_DATE=2024-06-01T12:00:00Z", "GIT_COMMITTER_NAME=Test",
+		"GIT_COMMITTER_EMAIL=test@example.com", "GIT_COMMITTER_DATE=2024-06-01T12:00:00Z")
+	if out, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("git %v failed: %v\n%s", args, err, out)
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping TestAnalyze: git not on PATH")
+	}
+	repo := t.TempDir()
+	writeFile(t, repo, "lib/lib.go", "package lib\n\n"+complexFunc("Complex", ComplexityThreshold+5))
+	writeFile(t, repo, budget.FileName, "{\"functions-over-threshold\": 3}\n")
+	runGit(t, repo, "init", "-q")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "-q", "-m", "Add lib")
+
+	client := &Client{Now: func() time.Time { return time.Date(2024, 6, 2, 9, 30, 0, 0, time.UTC) }}
+	opts := DefaultOptions()
+	opts.Redact.Authors = true
+	result, err := client.Analyze(context.Background(), Target{URL: repo}, opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if result.Stats.FunctionsOverThreshold != 1 || result.Repository.LatestCommit.Author != "Test" {
+		t.Errorf("Expected 1 function over threshold in a commit by Test, got %d by %q",
+			result.Stats.FunctionsOverThreshold, result.Repository.LatestCommit.Author)
+	}
+	if result.Budget[budget.FunctionsOverThreshold] != 3 {
+		t.Errorf("Expected the budget of the analyzed tree, got %v", result.Budget)
+	}
+
+	var markdown bytes.Buffer
+	if err := result.RenderMarkdown(&markdown); err != nil {
+		t.Fatalf("RenderMarkdown failed: %v", err)
+	}
+	if !strings.Contains(markdown.String(), "2024-06-02 09:30:00 UTC") || strings.Contains(markdown.String(), "test@example.com") {
+		t.Errorf("Expected a redacted report dated by the client clock, got:\n%s", markdown.String())
+	}
+
+	var out bytes.Buffer
+	if err := result.RenderJSON(&out); err != nil {
+		t.Fatalf("RenderJSON failed: %v", err)
+	}
+	var decoded struct {
+		SchemaVersion int        `json:"schemaVersion"`
+		Commit        CommitInfo `json:"commit"`
+		Stats         Stats      `json:"stats"`
+	}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("Failed to parse the JSON report: %v\n%s", err, out.String())
+	}
+	if decoded.SchemaVersion != report.JSONSchemaVersion || decoded.Stats.FunctionsOverThreshold != 1 || decoded.Commit.Author == "Test" {
+		t.Errorf("Expected the redacted stats of schema version %d, got %+v", report.JSONSchemaVersion, decoded)
+	}
+	// RenderJSON and the json format write the same document.
+	var formatted bytes.Buffer
+	if err := result.Render(report.FormatJSON, &formatted); err != nil {
+		t.Fatalf("Render failed: %v", err)
+	}
+	if formatted.String() != out.String() {
+		t.Errorf("Expected RenderJSON to write the json format, got:\n%s\nand:\n%s", out.String(), formatted.String())
+	}
+
+	if _, err := client.Analyze(context.Background(), Target{URL: t.TempDir()}, DefaultOptions()); err == nil {
+		t.Errorf("Expected a directory that is not a repository to fail")
+	}
+	empty := t.TempDir()
+	writeFile(t, empty, "README.md", "# Readme\n")
+	runGit(t, empty, "init", "-q")
+	runGit(t, empty, "add", ".")
+	runGit(t, empty, "commit", "-q", "-m", "Add readme")
+	var noSource *NoSourceError
+	if _, err := client.Analyze(context.Background(), Target{URL: empty}, DefaultOptions()); !errors.As(err, &noSource) {
+		t.Errorf("Expected a NoSourceError, got %v", err)
+	}
+}
+
+func TestAnalyzeBranch(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping TestAnalyzeBranch: git not on PATH")
+	}
+	repo := t.TempDir()
+	writeFile(t, repo, "lib/lib.go", "package lib\n")
+	runGit(t, repo, "init", "-q", "-b", "main")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "-q", "-m", "Add lib")
+	runGit(t, repo, "checkout", "-q", "-b", "develop")
+	writeFile(t, repo, "lib/next.go", "package lib\n\n"+complexFunc("Next", ComplexityThreshold+5))
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "-q", "-m", "Add next")
+	runGit(t, repo, "checkout", "-q", "main")
+
+	opts := DefaultOptions()
+	opts.Branch = "develop"
+	result, err := (&Client{}).Analyze(context.Background(), Target{URL: repo}, opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if result.Repository.Branch != "develop" || result.Repository.LatestCommit.Message != "Add next" || result.Stats.FunctionsOverThreshold != 1 {
+		t.Errorf("Expected the tip of develop to be analyzed, got %q on %q with %d functions over threshold",
+			result.Repository.LatestCommit.Message, result.Repository.Branch, result.Stats.FunctionsOverThreshold)
+	}
+	var markdown bytes.Buffer
+	if err := result.RenderMarkdown(&markdown); err != nil {
+		t.Fatalf("RenderMarkdown failed: %v", err)
+	}
+	if !strings.Contains(markdown.String(), "- **Branch:** develop\n") {
+		t.Errorf("Expected the branch in the report, got:\n%s", markdown.String())
+	}
+
+	opts.Branch = "release"
+	if _, err := (&Client{}).Analyze(context.Background(), Target{URL: repo}, opts); !errors.Is(err, git.ErrRefNotFound) || !strings.Contains(err.Error(), `branch "release" does not exist`) {
+		t.Errorf("Expected a missing branch to be reported as such, got %v", err)
+	}
+}
+
+func TestAnalyzeDepth(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping TestAnalyzeDepth: git not on PATH")
+	}
+	repo := t.TempDir()
+	writeFile(t, repo, "lib/lib.go", "package lib\n")
+	runGit(t, repo, "init", "-q")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "-q", "-m", "Add lib")
+	writeFile(t, repo, "lib/next.go", "package lib\n\nvar Next = 1\n")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "-q", "-m", "Add next")
+
+	for _, tt := range []struct {
+		depth        int
+		wantFiles    int
+		wantFallback bool
+	}{
+		{1, 2, true},  // Diffed against an empty tree
+		{2, 1, false}, // Diffed against the parent, the one other commit cloned
+		{0, 1, false}, // Diffed against the parent
+	} {
+		opts := DefaultOptions()
+		opts.Depth = tt.depth
+		result, err := (&Client{}).Analyze(context.Background(), Target{URL: repo}, opts)
+		if err != nil {
+			t.Fatalf("Depth %d: Analyze failed: %v", tt.depth, err)
+		}
+		fallback := hasWarning(result.Repository.Warnings, warning.ShallowCloneFallback)
+		if len(result.Repository.ChangedFiles) != tt.wantFiles || fallback != tt.wantFallback {
+			t.Errorf("Depth %d: expected %d changed files and fallback %v, got %+v and %v",
+				tt.depth, tt.wantFiles, tt.wantFallback, result.Repository.ChangedFiles, fallback)
+		}
+	}
+
+	opts := DefaultOptions()
+	opts.Depth = -1
+	if _, err := (&Client{}).Analyze(context.Background(), Target{URL: repo}, opts); err == nil {
+		t.Errorf("Expected a negative depth to be rejected")
+	}
+}
+
+func TestAnalyzeHistoryTable(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping TestAnalyzeHistoryTable: git not on PATH")
+	}
+	repo := t.TempDir()
+	runGit(t, repo, "init", "-q")
+	for i, name := range []string{"a", "b", "c"} {
+		writeFile(t, repo, "lib/"+name+".go", "package lib\n\n"+strings.Repeat("// Line\n", i+1))
+		runGit(t, repo, "add", ".")
+		runGit(t, repo, "commit", "-q", "-m", "Add "+name)
+	}
+
+	opts := DefaultOptions()
+	opts.HistoryTable = true
+	opts.Depth = 2
+	result, err := (&Client{}).Analyze(context.Background(), Target{URL: repo}, opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	history := result.Data.CommitHistory
+	if len(history) != 2 || history[0].Message != "Add c" || history[1].Message != "Add b" {
+		t.Fatalf("Expected the two commits --depth clones, newest first, got %+v", history)
+	}
+	if history[0].LinesAdded != 5 || history[0].RiskScore != report.CommitRiskScore(history[0].CommitInfo) || history[0].RiskScore == 0 {
+		t.Errorf("Expected the counts and risk score of the latest commit, got %+v", history[0])
+	}
+	// Both commits of the table have their parent in the clone.
+	if hasWarning(result.Warnings, warning.CommitStatsUnavailable) || !result.Data.ShowCommitHistory {
+		t.Errorf("Expected the table to be shown without warnings, got %v", result.Warnings)
+	}
+
+	// The commits since the tag make the table without --history-table.
+	runGit(t, repo, "tag", "v1", "HEAD~2")
+	opts = DefaultOptions()
+	opts.CompareToTag = true
+	result, err = (&Client{}).Analyze(context.Background(), Target{URL: repo}, opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if history := result.Data.CommitHistory; len(history) != 2 || !result.Data.ShowCommitHistory {
+		t.Errorf("Expected the two commits since v1 in the shown table, got %+v", history)
+	}
+}
+
+func TestAnalyzeCommit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping TestAnalyzeCommit: git not on PATH")
+	}
+	repo := t.TempDir()
+	writeFile(t, repo, "lib/lib.go", "package lib\n\n"+complexFunc("Complex", ComplexityThreshold+5))
+	runGit(t, repo, "init", "-q")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "-q", "-m", "Add complex")
+	out, err := exec.Command("git", "-C", repo, "rev-parse", "HEAD").Output()
+	if err != nil {
+		t.Fatalf("git rev-parse failed: %v", err)
+	}
+	first := strings.TrimSpace(string(out))
+	writeFile(t, repo, "lib/lib.go", "package lib\n")
+	runGit(t, repo, "commit", "-q", "-am", "Simplify")
+
+	opts := DefaultOptions()
+	opts.Commit = first[:7]
+	result, err := (&Client{}).Analyze(context.Background(), Target{URL: repo}, opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	// The tree of the commit is analyzed, not the one of HEAD.
+	if result.Repository.LatestCommit.Hash != first || result.Stats.FunctionsOverThreshold != 1 {
+		t.Errorf("Expected commit %s with 1 function over threshold, got %s with %d",
+			first, result.Repository.LatestCommit.Hash, result.Stats.FunctionsOverThreshold)
+	}
+
+	opts.Branch = "main"
+	if _, err := (&Client{}).Analyze(context.Background(), Target{URL: repo}, opts); err == nil {
+		t.Errorf("Expected Commit and Branch to be rejected together")
+	}
+}
+
+func TestAnalyzeWorktree(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping TestAnalyzeWorktree: git not on PATH")
+	}
+	repo := t.TempDir()
+	writeFile(t, repo, "lib/lib.go", "package lib\n")
+	runGit(t, repo, "init", "-q", "-b", "main")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "-q", "-m", "Add lib")
+	writeFile(t, repo, "lib/lib.go", "package lib\n\n"+complexFunc("Complex", ComplexityThreshold+5))
+	writeFile(t, repo, "lib/draft.go", "package lib\n\n"+complexFunc("Draft", ComplexityThreshold+5))
+
+	opts := DefaultOptions()
+	opts.Worktree = true
+	result, err := (&Client{}).Analyze(context.Background(), Target{URL: repo}, opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	// The modified file is analyzed as it is on disk; the untracked one is left out.
+	if !result.Repository.Uncommitted || result.Repository.Branch != "main" || result.Stats.FunctionsOverThreshold != 1 ||
+		len(result.Repository.ChangedFiles) != 1 || result.Repository.ChangedFiles[0].Path != "lib/lib.go" {
+		t.Errorf("Expected the uncommitted change to lib/lib.go on main with 1 function over threshold, got %+v with %d",
+			result.Repository.ChangedFiles, result.Stats.FunctionsOverThreshold)
+	}
+	var markdown bytes.Buffer
+	if err := result.RenderMarkdown(&markdown); err != nil {
+		t.Fatalf("RenderMarkdown failed: %v", err)
+	}
+	if !strings.Contains(markdown.String(), "## Uncommitted Changes Analyzed") {
+		t.Errorf("Expected the report to be labelled as uncommitted changes, got:\n%s", markdown.String())
+	}
+
+	opts.Untracked = true
+	result, err = (&Client{}).Analyze(context.Background(), Target{URL: repo}, opts)
+	if err != nil {
+		t.Fatalf("Analyze with untracked files failed: %v", err)
+	}
+	if result.Stats.FunctionsOverThreshold != 2 || len(result.Repository.ChangedFiles) != 2 {
+		t.Errorf("Expected the untracked file to be analyzed too, got %+v with %d functions over threshold",
+			result.Repository.ChangedFiles, result.Stats.FunctionsOverThreshold)
+	}
+
+	opts.Branch = "main"
+	if _, err := (&Client{}).Analyze(context.Background(), Target{URL: repo}, opts); err == nil {
+		t.Errorf("Expected Worktree and Branch to be rejected together")
+	}
+	opts.Branch = ""
+	if _, err := (&Client{}).Analyze(context.Background(), Target{URL: "https://github.com/user/repo.git"}, opts); err == nil {
+		t.Errorf("Expected Worktree to be rejected for a remote URL")
+	}
+}
+
+func TestAnalyzePartial(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping TestAnalyzePartial: git not on PATH")
+	}
+	repo := t.TempDir()
+	writeFile(t, repo, "lib/lib.go", "package lib\n\n"+complexFunc("Complex", ComplexityThreshold+5))
+	runGit(t, repo, "init", "-q")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "-q", "-m", "Add lib")
+
+	defer func(hook func(string)) { beforePhase = hook }(beforePhase)
+	beforePhase = func(section string) {
+		if section == metrics.SectionComplexity {
+			panic("unexpected node")
+		}
+	}
+	result, err := (&Client{}).Analyze(context.Background(), Target{URL: repo}, DefaultOptions())
+	if err != nil {
+		t.Fatalf("Expected a failed analysis not to fail Analyze, got %v", err)
+	}
+	if err := result.Err(); !errors.Is(err, ErrPartialAnalysis) || !strings.Contains(err.Error(), metrics.SectionComplexity) {
+		t.Errorf("Expected a partial-analysis error naming %s, got %v", metrics.SectionComplexity, err)
+	}
+
+	var markdown bytes.Buffer
+	if err := result.RenderMarkdown(&markdown); err != nil {
+		t.Fatalf("RenderMarkdown failed: %v", err)
+	}
+	for _, want := range []string{
+		"> ❌ **Partial analysis:**",
+		"> - **Cyclomatic Complexity:** panic: unexpected node\n",
+		"- **Hash:** " + result.Repository.LatestCommit.Hash,
+		"- **Message:** Add lib",
+		"## Directory Rollup",
+	} {
+		if !strings.Contains(markdown.String(), want) {
+			t.Errorf("Expected %q in the report, got:\n%s", want, markdown.String())
+		}
+	}
+
+	var out bytes.Buffer
+	if err := result.RenderJSON(&out); err != nil {
+		t.Fatalf("RenderJSON failed: %v", err)
+	}
+	var decoded struct{ Stats Stats }
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatalf("Failed to parse the JSON report: %v", err)
+	}
+	statuses := make(map[string]string)
+	for _, phase := range decoded.Stats.Phases {
+		statuses[phase.Section] = phase.Status
+	}
+	if statuses[metrics.SectionComplexity] != metrics.PhaseFailed || statuses[metrics.SectionDirectoryRollup] != metrics.PhaseOK {
+		t.Errorf("Expected the JSON phases to mark the complexity analysis failed and the rollup ok, got %+v", decoded.Stats.Phases)
+	}
+}
+
+func TestAnalyzeFast(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping TestAnalyzeFast: git not on PATH")
+	}
+	repo := t.TempDir()
+	writeFile(t, repo, "lib/lib.go", "package lib\n\n"+complexFunc("Complex", ComplexityThreshold+5))
+	runGit(t, repo, "init", "-q")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "-q", "-m", "Add lib")
+
+	defer func(hook func(string)) { beforePhase = hook }(beforePhase)
+	var ran []string
+	beforePhase = func(section string) { ran = append(ran, section) }
+	opts := DefaultOptions()
+	opts.Fast = true
+	result, err := (&Client{}).Analyze(context.Background(), Target{URL: repo}, opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if want := []string{metrics.SectionDirectoryRollup}; !slices.Equal(ran, want) {
+		t.Errorf("Expected only %v to run in fast mode, got %v", want, ran)
+	}
+	stats := result.Stats
+	if !stats.Fast || stats.ComplexityStats != nil || stats.DirectoryRollup.SLOC == 0 || stats.TotalLinesAdded == 0 {
+		t.Errorf("Expected line counts without complexity, got %+v", stats)
+	}
+	if result.Repository.ChangedFunctions != nil {
+		t.Errorf("Expected the changed Go files not to be parsed, got %+v", result.Repository.ChangedFunctions)
+	}
+	statuses := make(map[string]string)
+	for _, phase := range stats.Phases {
+		statuses[phase.Section] = phase.Status
+	}
+	if statuses[metrics.SectionComplexity] != metrics.PhaseSkipped || statuses[metrics.SectionStyle] != metrics.PhaseSkipped || statuses[metrics.SectionDirectoryRollup] != metrics.PhaseOK {
+		t.Errorf("Expected the phases to tell the skipped analyses apart, got %+v", stats.Phases)
+	}
+
+	var markdown bytes.Buffer
+	if err := result.RenderMarkdown(&markdown); err != nil {
+		t.Fatalf("RenderMarkdown failed: %v", err)
+	}
+	if !strings.Contains(markdown.String(), "Skipped in fast mode") || strings.Contains(markdown.String(), "Functions Over Threshold") {
+		t.Errorf("Expected the complexity section to be replaced by the fast-mode note, got:\n%s", markdown.String())
+	}
+
+	opts.IncludeTests = true
+	if _, err := (&Client{}).Analyze(context.Background(), Target{URL: repo}, opts); err == nil || !strings.Contains(err.Error(), "IncludeTests") {
+		t.Errorf("Expected IncludeTests to be refused in fast mode, got %v", err)
+	}
+}
+
+func TestAnalyzeDiffCoverage(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping TestAnalyzeDiffCoverage: git not on PATH")
+	}
+	repo := t.TempDir()
+	writeFile(t, repo, "go.mod", "module example.com/repo\n")
+	const old = "package lib\n\n// Old was there before the commit.\nfunc Old() int {\n\treturn 1\n}\n"
+	writeFile(t, repo, "lib/lib.go", old)
+	runGit(t, repo, "init", "-q")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "-q", "-m", "Add lib")
+	// The commit adds a tested function (lines 8-10), an untested one (12-14), a file the
+	// tests never load and a test.
+	writeFile(t, repo, "lib/lib.go", old+"\n"+
+		"func Tested() int {\n\treturn 2\n}\n\n"+
+		"func Untested() int {\n\treturn 3\n}\n")
+	writeFile(t, repo, "lib/extra.go", "package lib\n\nvar Extra = 4\n")
+	writeFile(t, repo, "lib/lib_test.go", "package lib\n")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "-q", "-m", "Add functions")
+
+	profile := filepath.Join(t.TempDir(), "cover.out")
+	writeFile(t, filepath.Dir(profile), "cover.out", "mode: set\n"+
+		"example.com/repo/lib/lib.go:4.16,6.2 1 1\n"+
+		"example.com/repo/lib/lib.go:8.19,10.2 1 1\n"+
+		"example.com/repo/lib/lib.go:12.21,14.2 1 0\n")
+	opts := DefaultOptions()
+	opts.CoverProfile = profile
+	result, err := (&Client{}).Analyze(context.Background(), Target{URL: repo}, opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	dc := result.Stats.DiffCoverage
+	if dc == nil || dc.Profile != "cover.out" {
+		t.Fatalf("Expected the diff coverage of cover.out, got %+v", dc)
+	}
+	// lib.go: 6 added lines in blocks, 3 covered; extra.go: its 2 non-blank lines, uncovered.
+	if dc.Covered != 3 || dc.Coverable != 8 || len(dc.Files) != 2 {
+		t.Fatalf("Expected 3 of 8 lines covered in 2 files, got %+v", dc)
+	}
+	if extra := dc.Files[0]; extra.File != "lib/extra.go" || extra.InProfile || extra.Covered != 0 {
+		t.Errorf("Expected lib/extra.go to count as uncovered, got %+v", extra)
+	}
+	if lib := dc.Files[1]; len(lib.Uncovered) != 1 || lib.Uncovered[0] != (metrics.LineRange{Start: 12, End: 14}) {
+		t.Errorf("Expected Untested to be the uncovered lines of lib/lib.go, got %+v", lib)
+	}
+
+	var markdown bytes.Buffer
+	if err := result.RenderMarkdown(&markdown); err != nil {
+		t.Fatalf("RenderMarkdown failed: %v", err)
+	}
+	for _, want := range []string{"### Diff Coverage", "**37.5%** of the added lines", "| lib/extra.go (not in profile) | 0 | 2 | 0.0% | 1, 3 |", "| 12-14 |"} {
+		if !strings.Contains(markdown.String(), want) {
+			t.Errorf("Expected %q in the report, got:\n%s", want, markdown.String())
+		}
+	}
+}
+
+func TestAnalyzePlugins(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping TestAnalyzePlugins: git not on PATH")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("Skipping TestAnalyzePlugins: sh not on PATH")
+	}
+	repo := t.TempDir()
+	writeFile(t, repo, "lib/lib.go", "package lib\n\nfunc Lib() {}\n")
+	runGit(t, repo, "init", "-q")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "-q", "-m", "Add lib")
+
+	dir := t.TempDir()
+	// Reports the file it finds in the tree, to show it runs there.
+	writeFile(t, dir, "license-header.sh", "#!/bin/sh\ncat >/dev/null\nf=$(ls lib)\n"+
+		`printf '{"findings": [{"fingerprint": "%s", "severity": "error", "message": "no license header", "file": "lib/%s", "line": 1}]}' "$f" "$f"`+"\n")
+	writeFile(t, dir, "broken.sh", "#!/bin/sh\nexit 1\n")
+	for _, name := range []string{"license-header.sh", "broken.sh"} {
+		if err := os.Chmod(filepath.Join(dir, name), 0o755); err != nil {
+			t.Fatalf("Failed to make %s executable: %v", name, err)
+		}
+	}
+	plugins, err := plugin.Discover(dir)
+	if err != nil {
+		t.Fatalf("Discover failed: %v", err)
+	}
+	opts := DefaultOptions()
+	opts.Plugins = plugins
+	result, err := (&Client{}).Analyze(context.Background(), Target{URL: repo}, opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	want := []metrics.CustomFinding{{Source: "license-header", Fingerprint: "lib.go", Severity: metrics.SeverityError, Message: "no license header", File: "lib/lib.go", Line: 1}}
+	if !slices.Equal(result.Stats.CustomFindings, want) {
+		t.Errorf("Expected %+v, got %+v", want, result.Stats.CustomFindings)
+	}
+	if !hasWarning(result.Stats.Warnings, warning.PluginFailed) {
+		t.Errorf("Expected a warning for the broken plugin, got %v", result.Stats.Warnings)
+	}
+
+	var markdown bytes.Buffer
+	if err := result.RenderMarkdown(&markdown); err != nil {
+		t.Fatalf("RenderMarkdown failed: %v", err)
+	}
+	if !strings.Contains(markdown.String(), "| license-header | error | lib/lib.go:1 | no license header |") {
+		t.Errorf("Expected the finding in the Custom Findings section, got:\n%s", markdown.String())
+	}
+}
+
+func TestAnalyzeTreeDirectoryOverrides(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, root, "main.go", "package main\n\n"+complexFunc("Main", 12))
+	writeFile(t, root, "core/core.go", "package core\n\n"+complexFunc("Core", 8))
+	writeFile(t, root, "core/gen/gen.go", "package gen\n\n"+complexFunc("Generated", 40))
+	writeFile(t, root, "experiments/lab.go", "package lab\n\n"+complexFunc("Explore", 25)+"\n"+complexFunc("Prototype", 60))
+	writeFile(t, root, repoconfig.FileName, "complexity-threshold: 10\n")
+	writeFile(t, root, "core/"+repoconfig.FileName, "complexity-threshold: 5\nexclude: [gen/]\n")
+	writeFile(t, root, "experiments/"+repoconfig.FileName, "complexity-threshold: 30\nsuppress: [Prototype]\nwebhook: https://attacker.example/collect\n")
+
+	stats, err := AnalyzeTree(context.Background(), root, DefaultOptions())
+	if err != nil {
+		t.Fatalf("AnalyzeTree failed: %v", err)
+	}
+	var over []string
+	for _, cs := range stats.ComplexityStats {
+		over = append(over, cs.FunctionName)
+	}
+	slices.Sort(over)
+	// Main is over the root threshold, Core over that of core; core/gen is excluded, Explore
+	// is under the threshold of experiments and Prototype suppressed there.
+	if want := []string{"Core", "Main"}; !slices.Equal(over, want) {
+		t.Errorf("Expected %v over threshold, got %v", want, over)
+	}
+	if want := []string{"core", "experiments"}; !slices.Equal(stats.DirectoryOverrides, want) {
+		t.Errorf("Expected overrides %v, got %v", want, stats.DirectoryOverrides)
+	}
+	if !hasWarning(stats.Warnings, warning.ConfigIgnored) {
+		t.Errorf("Expected the webhook key to be ignored with a warning, got %v", stats.Warnings)
+	}
+	if stats.DirectoryRollup.Functions != 4 {
+		t.Errorf("Expected the function of core/gen to be left out of the rollup, got %d functions", stats.DirectoryRollup.Functions)
+	}
+}
+
+func TestAnalyzeRefactoringPlan(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping TestAnalyzeRefactoringPlan: git not on PATH")
+	}
+	repo := t.TempDir()
+	writeFile(t, repo, "calm/calm.go", "package calm\n\n"+complexFunc("Calm", ComplexityThreshold+10))
+	writeFile(t, repo, "busy/busy.go", "package busy\n\n"+complexFunc("Busy", ComplexityThreshold+5))
+	writeFile(t, repo, "busy/legacy.go", "package busy\n\n"+complexFunc("Legacy", ComplexityThreshold+20))
+	writeFile(t, repo, "busy/"+repoconfig.FileName, "suppress: [Legacy]\n")
+	runGit(t, repo, "init", "-q")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "-q", "-m", "Add packages")
+	for i := 0; i < 3; i++ {
+		writeFile(t, repo, "busy/busy.go", "package busy\n\n"+complexFunc("Busy", ComplexityThreshold+5)+strings.Repeat("\n", i+1))
+		runGit(t, repo, "commit", "-q", "-am", "Touch busy")
+	}
+
+	opts := DefaultOptions()
+	opts.RefactoringPlan = true
+	opts.PlanWeights = PlanWeights{Complexity: 1, Edits: 3}
+	result, err := (&Client{}).Analyze(context.Background(), Target{URL: repo}, opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	plan := result.Stats.RefactoringPlan
+	if plan == nil || len(plan.Items) != 2 {
+		t.Fatalf("Expected a plan of Busy and Calm, the suppressed Legacy left out, got %+v", plan)
+	}
+	// Busy, edited 4 times, outranks the more complex Calm once edits weigh more.
+	if busy := plan.Items[0]; busy.FunctionName != "Busy" || busy.Edits != 4 || !strings.Contains(busy.Rationale, "edited 4 times in 90 days") {
+		t.Errorf("Expected Busy first with its 4 edits, got %+v", busy)
+	}
+	var markdown bytes.Buffer
+	if err := result.RenderMarkdown(&markdown); err != nil {
+		t.Fatalf("RenderMarkdown failed: %v", err)
+	}
+	if !strings.Contains(markdown.String(), "### Suggested Refactoring Plan") {
+		t.Errorf("Expected the plan in the report, got:\n%s", markdown.String())
+	}
+}
+
+func TestAnalyzeBadgeBaseline(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("Skipping TestAnalyzeBadgeBaseline: git not on PATH")
+	}
+	repo := t.TempDir()
+	writeFile(t, repo, "lib/lib.go", "package lib\n\n"+complexFunc("Complex", ComplexityThreshold+5))
+	runGit(t, repo, "init", "-q")
+	runGit(t, repo, "add", ".")
+	runGit(t, repo, "commit", "-q", "-m", "Add complex")
+
+	opts := DefaultOptions()
+	result, err := (&Client{}).Analyze(context.Background(), Target{URL: repo}, opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	var baseline bytes.Buffer
+	if err := result.RenderMarkdown(&baseline); err != nil {
+		t.Fatalf("RenderMarkdown failed: %v", err)
+	}
+	opts.Badge.Baseline = filepath.Join(t.TempDir(), "baseline.md")
+	writeFile(t, filepath.Dir(opts.Badge.Baseline), "baseline.md", baseline.String())
+
+	writeFile(t, repo, "lib/lib.go", "package lib\n\nfunc Simple() int {\n\treturn 1\n}\n")
+	runGit(t, repo, "commit", "-q", "-am", "Simplify")
+	result, err = (&Client{}).Analyze(context.Background(), Target{URL: repo}, opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if badge := result.Data.Badge; !strings.HasPrefix(badge.Message, "complexity ▼") || badge.Color != "brightgreen" {
+		t.Errorf("Expected a green badge of the complexity decrease, got %+v", badge)
+	}
+
+	// Without a baseline, the absolute badge is shown and the fallback logged.
+	var log bytes.Buffer
+	opts.Badge.Baseline = filepath.Join(t.TempDir(), "missing.md")
+	result, err = (&Client{Log: &log}).Analyze(context.Background(), Target{URL: repo}, opts)
+	if err != nil {
+		t.Fatalf("Analyze failed: %v", err)
+	}
+	if badge := result.Data.Badge; !strings.HasPrefix(badge.Message, "changes ") || !strings.Contains(log.String(), "No complexity baseline") {
+		t.Errorf("Expected the absolute badge and a logged fallback, got %+v and log %q", badge, log.String())
+	}
+}
+
+func TestAnalyzeRejectsGitOnlyOptions(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Ownership = true
+	_, err := (&Client{}).Analyze(context.Background(), Target{URL: "hg::https://example.com/repo", VCS: "hg"}, opts)
+	var optErr *OptionError
+	if !errors.As(err, &optErr) || optErr.Option != "Ownership" || err.Error() != "option Ownership is only supported for git repositories" {
+		t.Errorf("Expected Ownership to be rejected for Mercurial, got %v", err)
+	}
+
+	opts = DefaultOptions()
+	opts.Untracked = true
+	err = opts.Validate(Target{URL: "https://github.com/user/repo.git"})
+	if !errors.As(err, &optErr) || optErr.Option != "Untracked" || !strings.Contains(err.Error(), "needs a local repository path") {
+		t.Errorf("Expected Untracked to be rejected for a remote URL, got %v", err)
+	}
+}